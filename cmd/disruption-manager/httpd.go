@@ -87,6 +87,10 @@ func (s *httpServer) requestHandler(w http.ResponseWriter, req *http.Request) {
 		writer := bufio.NewWriter(w)
 		defer writer.Flush()
 		reply := dm_proto.DisruptionResponse{Response: state}
+		if state == sub_proto.DisruptionStateDenied &&
+			request.Request != sub_proto.DisruptionRequestCancel {
+			reply.Message = s.disruptionManager.getDenialMessage(request.MDB)
+		}
 		if err := libjson.WriteWithIndent(writer, "    ", reply); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -184,6 +188,10 @@ func (s *httpServer) statusHandler(w http.ResponseWriter, req *http.Request) {
 			groupList.totalWaiting)
 		fmt.Fprintln(writer, `<a href="showState">dashboard</a><br>`)
 	}
+	dm := s.disruptionManager
+	if window := dm.maintenanceWindows.Default(); window != nil {
+		fmt.Fprintf(writer, "Default maintenance window: %s<br>\n", window)
+	}
 	for _, htmlWriter := range s.htmlWriters {
 		htmlWriter.WriteHtml(writer)
 	}
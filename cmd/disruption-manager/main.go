@@ -11,10 +11,13 @@ import (
 	"github.com/Cloud-Foundations/Dominator/lib/flags/loadflags"
 	"github.com/Cloud-Foundations/Dominator/lib/log/serverlogger"
 	"github.com/Cloud-Foundations/Dominator/lib/srpc/setupserver"
+	"github.com/Cloud-Foundations/Dominator/lib/timewindow"
 	"github.com/Cloud-Foundations/tricorder/go/tricorder"
 )
 
 var (
+	maintenanceWindow = flag.String("maintenanceWindow", "",
+		"Default maintenance window when disruption is permitted")
 	maximumPermittedDuration = flag.Duration("maximumPermittedDuration",
 		time.Hour,
 		"Maximum time disruption will be permitted after last request")
@@ -41,8 +44,16 @@ func main() {
 	flag.Parse()
 	tricorder.RegisterFlags()
 	logger := serverlogger.New("")
+	var window *timewindow.Windows
+	if *maintenanceWindow != "" {
+		var err error
+		window, err = timewindow.Parse(*maintenanceWindow)
+		if err != nil {
+			logger.Fatalf("Unable to parse maintenance window: %s\n", err)
+		}
+	}
 	dm, err := newDisruptionManager(filepath.Join(*stateDir, "state.json"),
-		*maximumPermittedDuration, logger)
+		*maximumPermittedDuration, window, logger)
 	if err != nil {
 		logger.Fatalf("Unable to create Disruption Manager: %s\n", err)
 	}
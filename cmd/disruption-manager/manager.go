@@ -17,8 +17,10 @@ import (
 	"github.com/Cloud-Foundations/Dominator/lib/fsutil"
 	"github.com/Cloud-Foundations/Dominator/lib/json"
 	"github.com/Cloud-Foundations/Dominator/lib/log"
+	"github.com/Cloud-Foundations/Dominator/lib/maintenancewindow"
 	"github.com/Cloud-Foundations/Dominator/lib/mdb"
 	"github.com/Cloud-Foundations/Dominator/lib/tags"
+	"github.com/Cloud-Foundations/Dominator/lib/timewindow"
 	sub_proto "github.com/Cloud-Foundations/Dominator/proto/sub"
 )
 
@@ -31,6 +33,7 @@ const (
 
 type disruptionManager struct {
 	logger              log.DebugLogger
+	maintenanceWindows  *maintenancewindow.Cache
 	maxDuration         time.Duration
 	stateFilename       string
 	recalculateNotifier chan<- struct{}
@@ -45,6 +48,7 @@ type groupInfoType struct {
 	permitted    map[string]time.Time     // K: hostname, V: last request time.
 	requested    map[string]time.Time     // K: hostname, V: last request time.
 	waiting      map[string]*waitDataType // K: hostname.
+	windows      map[string]*timewindow.Windows
 }
 
 type groupStatsType struct {
@@ -128,6 +132,7 @@ func sortWaitInfos(list []waitInfoType) {
 
 func newDisruptionManager(stateFilename string,
 	maximumPermittedDuration time.Duration,
+	maintenanceWindow *timewindow.Windows,
 	logger log.DebugLogger) (*disruptionManager, error) {
 	recalculateNotifier := make(chan struct{}, 1)
 	writeNotifier := make(chan struct{}, 1)
@@ -136,6 +141,7 @@ func newDisruptionManager(stateFilename string,
 		exportable:          &groupList,
 		groups:              make(map[string]*groupInfoType),
 		logger:              logger,
+		maintenanceWindows:  maintenancewindow.NewCache(maintenanceWindow),
 		maxDuration:         maximumPermittedDuration,
 		stateFilename:       stateFilename,
 		recalculateNotifier: recalculateNotifier,
@@ -225,6 +231,7 @@ func (dm *disruptionManager) cancel(machine mdb.Machine) (
 				machine.Hostname, groupText)
 		}
 		delete(group.requested, machine.Hostname)
+		delete(group.windows, machine.Hostname)
 	}
 	return sub_proto.DisruptionStateDenied, logMessage, nil
 }
@@ -244,6 +251,15 @@ func (dm *disruptionManager) check(machine mdb.Machine) (
 	if !previouslyRequested {
 		return sub_proto.DisruptionStateDenied, "", nil
 	}
+	if !dm.inMaintenanceWindow(machine, time.Now()) {
+		invalidate = true
+		delete(group.requested, machine.Hostname)
+		delete(group.windows, machine.Hostname)
+		return sub_proto.DisruptionStateDenied,
+			fmt.Sprintf("%s: requested->denied: outside window (%s)",
+				machine.Hostname, groupText),
+			nil
+	}
 	if !group.canPermit(machine.Tags) {
 		return sub_proto.DisruptionStateRequested, "", nil
 	}
@@ -251,6 +267,7 @@ func (dm *disruptionManager) check(machine mdb.Machine) (
 	invalidate = true
 	group.permitted[machine.Hostname] = lastRequestTime
 	delete(group.requested, machine.Hostname)
+	delete(group.windows, machine.Hostname)
 	return sub_proto.DisruptionStatePermitted,
 		fmt.Sprintf("%s: requested->permitted (%s)",
 			machine.Hostname, groupText),
@@ -273,6 +290,22 @@ func (dm *disruptionManager) getGroup(machine mdb.Machine) (
 	return group, makeGroupText(groupIdentifier)
 }
 
+// getDenialMessage returns a message explaining why disruption is denied for
+// the machine, or an empty string if there is no particular reason.
+func (dm *disruptionManager) getDenialMessage(machine mdb.Machine) string {
+	window := dm.getMaintenanceWindow(machine)
+	if window == nil {
+		return ""
+	}
+	now := time.Now()
+	if window.Contains(now) {
+		return ""
+	}
+	start, _ := window.Next(now)
+	return fmt.Sprintf("outside maintenance window: %s, next window opens: %s",
+		window, start.Format(time.RFC3339))
+}
+
 func (dm *disruptionManager) getGroupList() *groupListType {
 	dm.mutex.Lock()
 	defer dm.mutex.Unlock()
@@ -320,6 +353,27 @@ func (dm *disruptionManager) getGroupList() *groupListType {
 	return &groupList
 }
 
+// getMaintenanceWindow returns the maintenance window for the machine, or nil
+// if disruption is permitted at any time.
+func (dm *disruptionManager) getMaintenanceWindow(
+	machine mdb.Machine) *timewindow.Windows {
+	window, err := dm.maintenanceWindows.Get(machine.Tags)
+	if err != nil {
+		dm.logger.Printf("%s: error parsing [%s]=%s: %s\n",
+			machine.Hostname, maintenancewindow.TagName,
+			machine.Tags[maintenancewindow.TagName], err)
+	}
+	return window
+}
+
+// inMaintenanceWindow returns true if disruption is permitted for the machine
+// at time t.
+func (dm *disruptionManager) inMaintenanceWindow(machine mdb.Machine,
+	t time.Time) bool {
+	window := dm.getMaintenanceWindow(machine)
+	return window == nil || window.Contains(t)
+}
+
 func (dm *disruptionManager) recalculateLoop(notifier <-chan struct{}) {
 	for {
 		for _, logLine := range dm.recalculateOnce() {
@@ -365,15 +419,25 @@ func (dm *disruptionManager) recalculateOnce() []string {
 			}
 		}
 		for hostname, lastRequestTime := range group.requested {
+			window := group.windows[hostname]
 			if lastRequestTime.Before(expireBefore) {
 				invalidate = true
 				delete(group.requested, hostname)
+				delete(group.windows, hostname)
 				dm.logger.Printf("%s: requested/expired->denied (%s)\n",
 					hostname, groupText)
+			} else if window != nil && !window.Contains(time.Now()) {
+				invalidate = true
+				delete(group.requested, hostname)
+				delete(group.windows, hostname)
+				logLines = append(logLines,
+					fmt.Sprintf("%s: requested->denied: outside window (%s)",
+						hostname, groupText))
 			} else if group.canPermit(nil) {
 				invalidate = true
 				group.permitted[hostname] = lastRequestTime
 				delete(group.requested, hostname)
+				delete(group.windows, hostname)
 				logLines = append(logLines,
 					fmt.Sprintf("%s: requested->permitted (%s)",
 						hostname, groupText))
@@ -393,8 +457,20 @@ func (dm *disruptionManager) request(machine mdb.Machine) (
 		return sub_proto.DisruptionStatePermitted, "", nil
 	}
 	var logMessage string
+	window := dm.getMaintenanceWindow(machine)
+	if window != nil && !window.Contains(time.Now()) {
+		if _, ok := group.requested[machine.Hostname]; ok {
+			logMessage = fmt.Sprintf(
+				"%s: requested->denied: outside window (%s)",
+				machine.Hostname, groupText)
+			delete(group.requested, machine.Hostname)
+			delete(group.windows, machine.Hostname)
+		}
+		return sub_proto.DisruptionStateDenied, logMessage, nil
+	}
 	if group.canPermit(machine.Tags) {
 		group.permitted[machine.Hostname] = time.Now()
+		delete(group.windows, machine.Hostname)
 		if _, ok := group.requested[machine.Hostname]; ok {
 			logMessage = fmt.Sprintf("%s: requested->permitted (%s)",
 				machine.Hostname, groupText)
@@ -410,6 +486,9 @@ func (dm *disruptionManager) request(machine mdb.Machine) (
 			machine.Hostname, groupText)
 	}
 	group.requested[machine.Hostname] = time.Now()
+	if window != nil {
+		group.windows[machine.Hostname] = window
+	}
 	return sub_proto.DisruptionStateRequested, logMessage, nil
 }
 
@@ -459,6 +538,7 @@ func newGroup() *groupInfoType {
 		permitted:    make(map[string]time.Time),
 		requested:    make(map[string]time.Time),
 		waiting:      make(map[string]*waitDataType),
+		windows:      make(map[string]*timewindow.Windows),
 	}
 }
 
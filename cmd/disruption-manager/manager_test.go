@@ -9,8 +9,10 @@ import (
 
 	"github.com/Cloud-Foundations/Dominator/lib/format"
 	"github.com/Cloud-Foundations/Dominator/lib/log/testlogger"
+	"github.com/Cloud-Foundations/Dominator/lib/maintenancewindow"
 	"github.com/Cloud-Foundations/Dominator/lib/mdb"
 	"github.com/Cloud-Foundations/Dominator/lib/tags"
+	"github.com/Cloud-Foundations/Dominator/lib/timewindow"
 	proto "github.com/Cloud-Foundations/Dominator/proto/sub"
 )
 
@@ -48,7 +50,7 @@ func okHandler(w http.ResponseWriter, req *http.Request) {
 
 func TestBasicSequence(t *testing.T) {
 	logger := testlogger.New(t)
-	dm, err := newDisruptionManager("", time.Second, logger)
+	dm, err := newDisruptionManager("", time.Second, nil, logger)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -129,7 +131,7 @@ func TestReadiness(t *testing.T) {
 	time.Sleep(time.Millisecond)
 	machine3.Tags["DisruptionManagerReadyUrl"] =
 		fmt.Sprintf("http://%s/readiness", listener.Addr())
-	dm, err := newDisruptionManager("", time.Second, logger)
+	dm, err := newDisruptionManager("", time.Second, nil, logger)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -181,7 +183,7 @@ func TestReadinessTimeout(t *testing.T) {
 	time.Sleep(time.Millisecond)
 	machine4.Tags["DisruptionManagerReadyUrl"] =
 		fmt.Sprintf("http://%s/readiness", listener.Addr())
-	dm, err := newDisruptionManager("", time.Second, logger)
+	dm, err := newDisruptionManager("", time.Second, nil, logger)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -230,3 +232,56 @@ func TestReadinessTimeout(t *testing.T) {
 			format.Duration(timeTaken))
 	}
 }
+
+// makeHourWindow returns a daily window of one hour, starting offset hours
+// after the start of the current hour.
+func makeHourWindow(offset int) string {
+	hour := (time.Now().UTC().Hour() + offset) % 24
+	return fmt.Sprintf("%02d:00-%02d:00 UTC", hour, (hour+1)%24)
+}
+
+func TestMaintenanceWindow(t *testing.T) {
+	defaultWindow, err := timewindow.Parse(makeHourWindow(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dm, err := newDisruptionManager("", time.Second, defaultWindow,
+		testlogger.New(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		window   string // Empty: no tag.
+		expected proto.DisruptionState
+	}{
+		{"", proto.DisruptionStateDenied},
+		{"any", proto.DisruptionStatePermitted},
+		{makeHourWindow(0), proto.DisruptionStatePermitted},
+		{makeHourWindow(12), proto.DisruptionStateDenied},
+		{"bad window", proto.DisruptionStateDenied},
+	}
+	for index, test := range tests {
+		// Each machine is in its own group, so only the window matters.
+		hostname := fmt.Sprintf("windowhost-%d", index)
+		machine := mdb.Machine{
+			Hostname: hostname,
+			Tags:     tags.Tags{"DisruptionManagerGroupIdentifier": hostname},
+		}
+		if test.window != "" {
+			machine.Tags[maintenancewindow.TagName] = test.window
+		}
+		state, _, err := dm.request(machine)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if state != test.expected {
+			t.Errorf("window: %q: state: %s, expected: %s",
+				test.window, state, test.expected)
+		}
+		denialMessage := dm.getDenialMessage(machine)
+		if (state == proto.DisruptionStateDenied) != (denialMessage != "") {
+			t.Errorf("window: %q: denial message: %q",
+				test.window, denialMessage)
+		}
+	}
+}
@@ -57,6 +57,9 @@ func (t *rpcType) Check(conn *srpc.Conn,
 	state, logMessage, err := t.disruptionManager.check(request.MDB)
 	reply.Error = errors.ErrorToString(err)
 	reply.Response = state
+	if state == sub_proto.DisruptionStateDenied {
+		reply.Message = t.disruptionManager.getDenialMessage(request.MDB)
+	}
 	if logMessage != "" {
 		t.disruptionManager.logger.Println(logMessage)
 	}
@@ -80,6 +83,9 @@ func (t *rpcType) Request(conn *srpc.Conn,
 	state, logMessage, err = t.disruptionManager.request(request.MDB)
 	reply.Error = errors.ErrorToString(err)
 	reply.Response = state
+	if state == sub_proto.DisruptionStateDenied {
+		reply.Message = t.disruptionManager.getDenialMessage(request.MDB)
+	}
 	if logMessage != "" {
 		t.disruptionManager.logger.Println(logMessage)
 	}
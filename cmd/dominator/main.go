@@ -5,6 +5,7 @@ import (
 	"fmt"
 	_ "net/http/pprof"
 	"os"
+	"os/signal"
 	"path"
 	"syscall"
 	"time"
@@ -12,6 +13,7 @@ import (
 	"github.com/Cloud-Foundations/Dominator/dom/herd"
 	"github.com/Cloud-Foundations/Dominator/dom/rpcd"
 	"github.com/Cloud-Foundations/Dominator/lib/constants"
+	"github.com/Cloud-Foundations/Dominator/lib/election"
 	"github.com/Cloud-Foundations/Dominator/lib/flags/loadflags"
 	"github.com/Cloud-Foundations/Dominator/lib/log"
 	"github.com/Cloud-Foundations/Dominator/lib/log/serverlogger"
@@ -27,6 +29,12 @@ import (
 const dirPerms = syscall.S_IRWXU
 
 var (
+	advertisedAddress = flag.String("advertisedAddress", "",
+		"Address of this dominator advertised to standbys (default hostname:portNum)")
+	checkpointFile = flag.String("checkpointFile", "herd-checkpoint",
+		"File containing the checkpoint of the herd state, relative to stateDir if not absolute")
+	checkpointInterval = flag.Duration("checkpointInterval", 5*time.Minute,
+		"Interval between writing checkpoints (0: disable)")
 	debug = flag.Bool("debug", false,
 		"If true, show debugging output")
 	fdLimit = flag.Uint64("fdLimit", getFdLimit(),
@@ -36,6 +44,10 @@ var (
 	imageServerPortNum = flag.Uint("imageServerPortNum",
 		constants.ImageServerPortNumber,
 		"Port number of image server")
+	leaseDuration = flag.Duration("leaseDuration", 30*time.Second,
+		"Duration of the leadership lease")
+	leaseFile = flag.String("leaseFile", "",
+		"File on shared storage containing the leadership lease (default: no standby)")
 	mdbFile = flag.String("mdbFile", constants.DefaultMdbFile,
 		"File to read MDB data from")
 	minInterval = flag.Uint("minInterval", 1,
@@ -46,8 +58,14 @@ var (
 		"If true, run in insecure mode. This gives remote access to all")
 	portNum = flag.Uint("portNum", constants.DominatorPortNumber,
 		"Port number to allocate and listen on for HTTP/RPC")
+	shutdownTimeout = flag.Duration("shutdownTimeout", 30*time.Second,
+		"Maximum time to wait for subs to not be busy before releasing the lease")
 	stateDir = flag.String("stateDir", "/var/lib/Dominator",
 		"Name of dominator state directory.")
+	updateFreezesFile = flag.String("updateFreezesFile", "update-freezes",
+		"File containing the update freezes, relative to stateDir if not absolute")
+	updateJournalDir = flag.String("updateJournalDir", "update-journal",
+		"Directory containing the journal of updates, relative to stateDir")
 )
 
 func showMdb(mdb *mdb.Mdb) {
@@ -71,6 +89,44 @@ func pathJoin(first, second string) string {
 	return path.Join(first, second)
 }
 
+func newElector(logger log.DebugLogger) (*election.Elector, error) {
+	identity := *advertisedAddress
+	if identity == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, err
+		}
+		identity = fmt.Sprintf("%s:%d", hostname, *portNum)
+	}
+	return election.New(election.Params{
+		Identity:      identity,
+		LeaseDuration: *leaseDuration,
+		Logger:        logger,
+		Store:         election.NewFileStore(*leaseFile),
+	}), nil
+}
+
+// releaseOnSignal will stop updating subs and release the leadership lease
+// when the process is terminated, so that a standby can take over without
+// waiting for the lease to expire.
+func releaseOnSignal(herd *herd.Herd, elector *election.Elector,
+	logger log.Logger) {
+	sigtermChannel := make(chan os.Signal, 1)
+	signal.Notify(sigtermChannel, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		<-sigtermChannel
+		logger.Println("Caught signal: stopping updates")
+		if !herd.StopUpdates(*shutdownTimeout) {
+			logger.Println("Timed out waiting for subs to not be busy")
+		}
+		if err := elector.Release(); err != nil {
+			logger.Printf("Error releasing leadership: %s\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}()
+}
+
 func newObjectServer(objectsDir string, logger log.DebugLogger) (
 	*objectserver.ObjectServer, error) {
 	fi, err := os.Stat(objectsDir)
@@ -135,6 +191,34 @@ func main() {
 	herd := herd.NewHerd(fmt.Sprintf("%s:%d", *imageServerHostname,
 		*imageServerPortNum), objectServer, metricsDir, logger)
 	herd.AddHtmlWriter(logger)
+	if *leaseFile != "" {
+		elector, err := newElector(logger)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot create elector: %s\n", err)
+			os.Exit(1)
+		}
+		herd.SetElector(elector)
+		herd.AddHtmlWriter(elector)
+		releaseOnSignal(herd, elector, logger)
+	}
+	err = herd.LoadUpdateFreezes(pathJoin(*stateDir, *updateFreezesFile))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot load update freezes: %s\n", err)
+		os.Exit(1)
+	}
+	err = herd.OpenUpdateJournal(path.Join(*stateDir, *updateJournalDir))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot open update journal: %s\n", err)
+		os.Exit(1)
+	}
+	if *checkpointInterval > 0 {
+		err := herd.StartCheckpointing(pathJoin(*stateDir, *checkpointFile),
+			*checkpointInterval)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot start checkpointing: %s\n", err)
+			os.Exit(1)
+		}
+	}
 	rpcd.Setup(herd, logger)
 	if err = herd.StartServer(*portNum, true); err != nil {
 		fmt.Fprintf(os.Stderr, "Unable to create http server: %s\n", err)
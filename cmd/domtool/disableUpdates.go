@@ -2,14 +2,38 @@ package main
 
 import (
 	"fmt"
+	"time"
 
 	domclient "github.com/Cloud-Foundations/Dominator/dom/client"
 	"github.com/Cloud-Foundations/Dominator/lib/log"
+	"github.com/Cloud-Foundations/Dominator/proto/dominator"
 )
 
 func disableUpdatesSubcommand(args []string, logger log.DebugLogger) error {
-	if err := domclient.DisableUpdates(getClient(), args[0]); err != nil {
+	if err := disableUpdates(args[0]); err != nil {
 		return fmt.Errorf("error disabling updates: %s", err)
 	}
 	return nil
 }
+
+func disableUpdates(reason string) error {
+	hostnames, err := getSubsFromFile()
+	if err != nil {
+		return err
+	}
+	request := dominator.DisableUpdatesRequest{
+		Hostnames:        hostnames,
+		LocationsToMatch: locationsToMatch,
+		Reason:           reason,
+		TagsToMatch:      tagsToMatch,
+	}
+	if *freezeDuration > 0 {
+		request.ExpiresAt = time.Now().Add(*freezeDuration)
+	}
+	freezeId, err := domclient.DisableUpdatesForSubs(getClient(), request)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Freeze ID: %d\n", freezeId)
+	return nil
+}
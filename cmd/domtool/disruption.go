@@ -73,7 +73,7 @@ func disruptionOperation(requestType sub_proto.DisruptionRequest,
 		if err := json.Read(resp.Body, &reply); err != nil {
 			return fmt.Errorf("error decoding response: %s", err)
 		}
-		fmt.Println(reply.Response)
+		printDisruptionState(reply.Response, reply.Message)
 		return nil
 	case "srpc":
 		client, err := srpc.DialHTTP("tcp", parsedUrl.Host, 0)
@@ -105,7 +105,7 @@ func disruptionOperation(requestType sub_proto.DisruptionRequest,
 			if err := errors.New(reply.Error); err != nil {
 				return err
 			}
-			fmt.Println(reply.Response)
+			printDisruptionState(reply.Response, reply.Message)
 		case sub_proto.DisruptionRequestRequest:
 			request := dm_proto.DisruptionRequestRequest{MDB: machine}
 			var reply dm_proto.DisruptionRequestResponse
@@ -117,10 +117,18 @@ func disruptionOperation(requestType sub_proto.DisruptionRequest,
 			if err := errors.New(reply.Error); err != nil {
 				return err
 			}
-			fmt.Println(reply.Response)
+			printDisruptionState(reply.Response, reply.Message)
 		}
 	default:
 		return fmt.Errorf("unsupported scheme: %s", *disruptionManagerUrl)
 	}
 	return nil
 }
+
+func printDisruptionState(state sub_proto.DisruptionState, message string) {
+	if message == "" {
+		fmt.Println(state)
+	} else {
+		fmt.Printf("%s: %s\n", state, message)
+	}
+}
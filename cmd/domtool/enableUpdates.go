@@ -2,14 +2,29 @@ package main
 
 import (
 	"fmt"
+	"strconv"
 
 	domclient "github.com/Cloud-Foundations/Dominator/dom/client"
 	"github.com/Cloud-Foundations/Dominator/lib/log"
 )
 
 func enableUpdatesSubcommand(args []string, logger log.DebugLogger) error {
-	if err := domclient.EnableUpdates(getClient(), args[0]); err != nil {
+	if err := enableUpdates(args); err != nil {
 		return fmt.Errorf("error enabling updates: %s", err)
 	}
 	return nil
 }
+
+func enableUpdates(args []string) error {
+	if len(args) < 2 {
+		return domclient.EnableUpdates(getClient(), args[0])
+	}
+	freezeId, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil {
+		return err
+	}
+	if freezeId == 0 {
+		return fmt.Errorf("invalid freeze ID: %s", args[1])
+	}
+	return domclient.RemoveUpdateFreeze(getClient(), freezeId, args[0])
+}
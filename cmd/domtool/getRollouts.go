@@ -0,0 +1,19 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	domclient "github.com/Cloud-Foundations/Dominator/dom/client"
+	"github.com/Cloud-Foundations/Dominator/lib/json"
+	"github.com/Cloud-Foundations/Dominator/lib/log"
+)
+
+func getRolloutsSubcommand(args []string, logger log.DebugLogger) error {
+	rollouts, err := domclient.GetRollouts(getClient())
+	if err != nil {
+		return fmt.Errorf("error getting rollouts: %s", err)
+	}
+	json.WriteWithIndent(os.Stdout, "    ", rollouts)
+	return nil
+}
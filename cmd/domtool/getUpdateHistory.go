@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	domclient "github.com/Cloud-Foundations/Dominator/dom/client"
+	"github.com/Cloud-Foundations/Dominator/lib/json"
+	"github.com/Cloud-Foundations/Dominator/lib/log"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	"github.com/Cloud-Foundations/Dominator/proto/dominator"
+)
+
+func getUpdateHistorySubcommand(args []string, logger log.DebugLogger) error {
+	var hostname string
+	if len(args) > 0 {
+		hostname = args[0]
+	}
+	if err := getUpdateHistory(getClient(), hostname); err != nil {
+		return fmt.Errorf("error getting update history: %s", err)
+	}
+	return nil
+}
+
+func getUpdateHistory(client srpc.ClientI, hostname string) error {
+	request := dominator.GetUpdateHistoryRequest{
+		Hostname:   hostname,
+		MaxRecords: *maxRecords,
+	}
+	if *historyDuration > 0 {
+		request.Since = time.Now().Add(-*historyDuration)
+	}
+	records, err := domclient.GetUpdateHistory(client, request)
+	if err != nil {
+		return err
+	}
+	return json.WriteWithIndent(os.Stdout, "    ", records)
+}
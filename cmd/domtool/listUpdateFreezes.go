@@ -0,0 +1,25 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	domclient "github.com/Cloud-Foundations/Dominator/dom/client"
+	"github.com/Cloud-Foundations/Dominator/lib/json"
+	"github.com/Cloud-Foundations/Dominator/lib/log"
+)
+
+func listUpdateFreezesSubcommand(args []string, logger log.DebugLogger) error {
+	if err := listUpdateFreezes(); err != nil {
+		return fmt.Errorf("error listing update freezes: %s", err)
+	}
+	return nil
+}
+
+func listUpdateFreezes() error {
+	freezes, err := domclient.ListUpdateFreezes(getClient())
+	if err != nil {
+		return err
+	}
+	return json.WriteWithIndent(os.Stdout, "    ", freezes)
+}
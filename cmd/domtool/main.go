@@ -6,10 +6,12 @@ import (
 	"os"
 	"time"
 
+	domclient "github.com/Cloud-Foundations/Dominator/dom/client"
 	"github.com/Cloud-Foundations/Dominator/lib/constants"
 	"github.com/Cloud-Foundations/Dominator/lib/flags/commands"
 	"github.com/Cloud-Foundations/Dominator/lib/flags/loadflags"
 	"github.com/Cloud-Foundations/Dominator/lib/flagutil"
+	"github.com/Cloud-Foundations/Dominator/lib/log"
 	"github.com/Cloud-Foundations/Dominator/lib/log/cmdlogger"
 	"github.com/Cloud-Foundations/Dominator/lib/srpc"
 	"github.com/Cloud-Foundations/Dominator/lib/srpc/setupclient"
@@ -27,9 +29,15 @@ var (
 		"Hostname of dominator")
 	domPortNum = flag.Uint("domPortNum", constants.DominatorPortNumber,
 		"Port number of dominator")
+	freezeDuration = flag.Duration("freezeDuration", 0,
+		"Duration of an update freeze (default: no expiry)")
 	forceDisruptiveUpdate = flag.Bool("forceDisruptiveUpdate", false,
 		"If true, force a disruptive update during a fast-update")
-	locationsToMatch  flagutil.StringList
+	historyDuration = flag.Duration("historyDuration", 0,
+		"Only show update history within this duration (default: no limit)")
+	locationsToMatch flagutil.StringList
+	maxRecords       = flag.Uint("maxRecords", 0,
+		"Maximum number of update history records (default 1000)")
 	mdbServerHostname = flag.String("mdbServerHostname", "",
 		"Hostname of MDB server (default same as domHostname)")
 	mdbServerPortNum = flag.Uint("mdbServerPortNum",
@@ -82,7 +90,7 @@ var subcommands = []commands.Command{
 	{"disruption-cancel", "sub", 1, 1, disruptionCancelSubcommand},
 	{"disruption-check", "sub", 1, 1, disruptionCheckSubcommand},
 	{"disruption-request", "sub", 1, 1, disruptionRequestSubcommand},
-	{"enable-updates", "reason", 1, 1, enableUpdatesSubcommand},
+	{"enable-updates", "reason [freezeId]", 1, 2, enableUpdatesSubcommand},
 	{"fast-update", "sub", 1, 1, fastUpdateSubcommand},
 	{"force-disruptive-update", "sub", 1, 1, forceDisruptiveUpdateSubcommand},
 	{"get-default-image", "", 0, 0, getDefaultImageSubcommand},
@@ -90,11 +98,16 @@ var subcommands = []commands.Command{
 	{"get-machine-from-mdb", "sub", 1, 1, getMachineMdbSubcommand},
 	{"get-mdb", "", 0, 0, getMdbSubcommand},
 	{"get-mdb-updates", "", 0, 0, getMdbUpdatesSubcommand},
+	{"get-rollouts", "", 0, 0, getRolloutsSubcommand},
 	{"get-subs-configuration", "", 0, 0, getSubsConfigurationSubcommand},
+	{"get-update-history", "[sub]", 0, 1, getUpdateHistorySubcommand},
 	{"list-subs", "", 0, 0, listSubsSubcommand},
+	{"list-update-freezes", "", 0, 0, listUpdateFreezesSubcommand},
 	{"pause-sub-updates", "sub reason", 2, 2, pauseSubUpdatesSubcommand},
+	{"preview-update", "sub", 1, 1, previewUpdateSubcommand},
 	{"resume-sub-updates", "sub", 1, 1, resumeSubUpdatesSubcommand},
 	{"set-default-image", "", 1, 1, setDefaultImageSubcommand},
+	{"watch-subs", "", 0, 0, watchSubsSubcommand},
 }
 
 func getClient() *srpc.Client {
@@ -111,6 +124,26 @@ func getClient() *srpc.Client {
 	return dominatorSrpcClient
 }
 
+// followLeader wraps a subcommand so that if it was rejected by a standby
+// dominator, it is run again with the leader. A standby rejects the first
+// request, so nothing was changed by the first run.
+func followLeader(cmdFunc commands.CommandFunc) commands.CommandFunc {
+	return func(args []string, logger log.DebugLogger) error {
+		err := cmdFunc(args, logger)
+		leader := domclient.GetLeaderFromError(err)
+		if leader == "" || dominatorSrpcClient == nil {
+			return err
+		}
+		logger.Debugf(0, "redirecting to leader: %s\n", leader)
+		dominatorSrpcClient.Close()
+		dominatorSrpcClient, err = srpc.DialHTTP("tcp", leader, 0)
+		if err != nil {
+			return fmt.Errorf("error dialing leader: %s: %s", leader, err)
+		}
+		return cmdFunc(args, logger)
+	}
+}
+
 func doMain() int {
 	if err := loadflags.LoadForCli("domtool"); err != nil {
 		fmt.Fprintln(os.Stderr, err)
@@ -128,6 +161,9 @@ func doMain() int {
 		fmt.Fprintln(os.Stderr, err)
 		return 1
 	}
+	for index := range subcommands {
+		subcommands[index].CmdFunc = followLeader(subcommands[index].CmdFunc)
+	}
 	return commands.RunCommands(subcommands, printUsage, logger)
 }
 
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	domclient "github.com/Cloud-Foundations/Dominator/dom/client"
+	"github.com/Cloud-Foundations/Dominator/lib/json"
+	"github.com/Cloud-Foundations/Dominator/lib/log"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	"github.com/Cloud-Foundations/Dominator/proto/dominator"
+)
+
+func previewUpdateSubcommand(args []string, logger log.DebugLogger) error {
+	if err := previewUpdate(getClient(), args[0]); err != nil {
+		return fmt.Errorf("error previewing update: %s", err)
+	}
+	return nil
+}
+
+func previewUpdate(client *srpc.Client, subHostname string) error {
+	reply, err := domclient.PreviewUpdate(client,
+		dominator.PreviewUpdateRequest{
+			Hostname:        subHostname,
+			Timeout:         *timeout,
+			UsePlannedImage: *usePlannedImage,
+		})
+	if err != nil {
+		return err
+	}
+	return json.WriteWithIndent(os.Stdout, "    ", reply)
+}
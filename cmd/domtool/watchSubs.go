@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	domclient "github.com/Cloud-Foundations/Dominator/dom/client"
+	"github.com/Cloud-Foundations/Dominator/lib/log"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	"github.com/Cloud-Foundations/Dominator/proto/dominator"
+)
+
+func watchSubsSubcommand(args []string, logger log.DebugLogger) error {
+	if err := watchSubs(getClient()); err != nil {
+		return fmt.Errorf("error watching subs: %s", err)
+	}
+	return nil
+}
+
+func watchSubs(client srpc.ClientI) error {
+	hostnames, err := getSubsFromFile()
+	if err != nil {
+		return err
+	}
+	request := dominator.WatchSubsRequest{
+		Hostnames:        hostnames,
+		LocationsToMatch: locationsToMatch,
+		StatusesToMatch:  statusesToMatch,
+		TagsToMatch:      tagsToMatch,
+	}
+	return domclient.WatchSubs(client, request,
+		func(subs []dominator.SubInfo) error {
+			for index := range subs {
+				printSubInfo("INIT", &subs[index])
+			}
+			fmt.Println("INITIAL snapshot received")
+			return nil
+		},
+		func(sub dominator.SubInfo, deleted bool) error {
+			if deleted {
+				printSubInfo("DELETE", &sub)
+			} else {
+				printSubInfo("CHANGE", &sub)
+			}
+			return nil
+		})
+}
+
+func printSubInfo(operation string, subInfo *dominator.SubInfo) {
+	var lastUpdate string
+	if !subInfo.LastUpdateTime.IsZero() {
+		lastUpdate = subInfo.LastUpdateTime.Format(time.RFC3339)
+	}
+	fmt.Printf(
+		"%s: %s status: %s, image: %s, last update: %s, disruption: %s\n",
+		operation, subInfo.Hostname, subInfo.Status,
+		subInfo.LastSuccessfulImage, lastUpdate, subInfo.LastDisruptionState)
+}
@@ -3,7 +3,9 @@ package main
 import (
 	"flag"
 	"fmt"
+	"net"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/Cloud-Foundations/Dominator/imageserver/httpd"
@@ -11,8 +13,8 @@ import (
 	"github.com/Cloud-Foundations/Dominator/imageserver/scanner"
 	"github.com/Cloud-Foundations/Dominator/lib/constants"
 	"github.com/Cloud-Foundations/Dominator/lib/flags/loadflags"
+	"github.com/Cloud-Foundations/Dominator/lib/flagutil"
 	"github.com/Cloud-Foundations/Dominator/lib/log/serverlogger"
-	"github.com/Cloud-Foundations/Dominator/lib/objectserver/filesystem"
 	"github.com/Cloud-Foundations/Dominator/lib/srpc"
 	"github.com/Cloud-Foundations/Dominator/lib/srpc/setupserver"
 	objectserverRpcd "github.com/Cloud-Foundations/Dominator/objectserver/rpcd"
@@ -28,7 +30,13 @@ var (
 		"If true, allow all users to call CheckObjects method")
 	allowPublicGetObjects = flag.Bool("allowPublicGetObjects", false,
 		"If true, allow all users to call GetObjects method")
-	debug    = flag.Bool("debug", false, "If true, show debugging output")
+	compressObjects = flag.Bool("compressObjects", true,
+		"If true, compress objects sent to and received from clients (objects larger than 4 MiB are not compressed, to bound memory usage)")
+	debug = flag.Bool("debug", false,
+		"If true, show debugging output")
+	deletedImageRetention = flag.Duration("deletedImageRetention",
+		30*24*time.Hour,
+		"Time to remember deleted images so that peers do not add them back")
 	imageDir = flag.String("imageDir", "/var/lib/imageserver",
 		"Name of image server data directory.")
 	imageServerHostname = flag.String("imageServerHostname", "",
@@ -46,14 +54,43 @@ var (
 	maximumExpirationDurationPrivileged = flag.Duration(
 		"maximumExpirationDurationPrivileged", 730*time.Hour,
 		"Maximum expiration time for privileged users")
+	mdbServerHostname = flag.String("mdbServerHostname", "",
+		"Hostname of MDB server. Images in the MDB are kept by retention policies")
+	mdbServerPortNum = flag.Uint("mdbServerPortNum",
+		constants.SimpleMdbServerPortNumber,
+		"Port number of MDB server")
 	objectDir = flag.String("objectDir", "/var/lib/objectserver",
 		"Name of image server data directory.")
+	objectServerBackend = flag.String("objectServerBackend", "filesystem",
+		"Object storage backend: filesystem or s3 (objectDir is a local cache)")
 	permitInsecureMode = flag.Bool("permitInsecureMode", false,
 		"If true, run in insecure mode. This gives remote access to all")
 	portNum = flag.Uint("portNum", constants.ImageServerPortNumber,
 		"Port number to allocate and listen on for HTTP/RPC")
+	retentionAuditLog = flag.String("retentionAuditLog", "",
+		"Filename to log images deleted by retention policies (default in imageDir)")
+	retentionCheckInterval = flag.Duration("retentionCheckInterval", 0,
+		"Interval between enforcing directory retention policies (0: never). Requires -mdbServerHostname")
+	s3Bucket = flag.String("s3Bucket", "",
+		"Name of bucket for the s3 object storage backend")
+	s3Endpoint = flag.String("s3Endpoint", "",
+		"URL of S3-compatible server (default AWS S3)")
+	s3KeyPrefix = flag.String("s3KeyPrefix", "",
+		"Prefix for object keys in the S3 bucket")
+	s3Region = flag.String("s3Region", "", "Region of the S3 bucket")
+
+	s3CacheSize flagutil.Size = 16 << 30
+
+	replicationPeers flagutil.StringList
 )
 
+func init() {
+	flag.Var(&replicationPeers, "replicationPeers",
+		"Comma separated list of peer image servers to exchange updates with")
+	flag.Var(&s3CacheSize, "s3CacheSize",
+		"Maximum size of the local object cache for the s3 backend")
+}
+
 func main() {
 	if os.Geteuid() == 0 {
 		fmt.Fprintln(os.Stderr, "Do not run the Image Server as root")
@@ -75,15 +112,7 @@ func main() {
 			logger.Fatalln(err)
 		}
 	}
-	objSrv, err := filesystem.NewObjectServerWithConfigAndParams(
-		filesystem.Config{
-			BaseDirectory:     *objectDir,
-			LockCheckInterval: *lockCheckInterval,
-			LockLogTimeout:    *lockLogTimeout,
-		},
-		filesystem.Params{
-			Logger: logger,
-		})
+	objSrv, err := newObjectServer(logger)
 	if err != nil {
 		logger.Fatalf("Cannot create ObjectServer: %s\n", err)
 	}
@@ -92,18 +121,34 @@ func main() {
 		imageServerAddress = fmt.Sprintf("%s:%d", *imageServerHostname,
 			*imageServerPortNum)
 	}
+	var peerAddresses []string
+	for _, peer := range replicationPeers {
+		if _, _, err := net.SplitHostPort(peer); err != nil {
+			peer = net.JoinHostPort(peer,
+				strconv.FormatUint(uint64(*imageServerPortNum), 10))
+		}
+		peerAddresses = append(peerAddresses, peer)
+	}
+	var listMdbImagesFunc func() (map[string]struct{}, error)
+	if *mdbServerHostname != "" {
+		listMdbImagesFunc = listMdbImages
+	}
 	imdb, err := scanner.Load(
 		scanner.Config{
 			BaseDirectory:                       *imageDir,
+			DeletedImageRetention:               *deletedImageRetention,
 			LockCheckInterval:                   *lockCheckInterval,
 			LockLogTimeout:                      *lockLogTimeout,
 			MaximumExpirationDuration:           *maximumExpirationDuration,
 			MaximumExpirationDurationPrivileged: *maximumExpirationDurationPrivileged,
 			ReplicationMaster:                   imageServerAddress,
+			RetentionAuditLog:                   *retentionAuditLog,
+			RetentionCheckInterval:              *retentionCheckInterval,
 		},
 		scanner.Params{
-			Logger:       logger,
-			ObjectServer: objSrv,
+			ListMdbImages: listMdbImagesFunc,
+			Logger:        logger,
+			ObjectServer:  objSrv,
 		})
 	if err != nil {
 		logger.Fatalf("Cannot load image database: %s\n", err)
@@ -112,7 +157,7 @@ func main() {
 		func() uint { return imdb.CountImages() },
 		units.None, "number of images")
 	imgSrvRpcHtmlWriter, err := imageserverRpcd.Setup(imdb, imageServerAddress,
-		objSrv, logger)
+		peerAddresses, objSrv, logger)
 	if err != nil {
 		logger.Fatalln(err)
 	}
@@ -121,6 +166,7 @@ func main() {
 			AllowPublicAddObjects:   *allowPublicAddObjects,
 			AllowPublicCheckObjects: *allowPublicCheckObjects,
 			AllowPublicGetObjects:   *allowPublicGetObjects,
+			CompressObjects:         *compressObjects,
 			ReplicationMaster:       imageServerAddress,
 		},
 		objectserverRpcd.Params{
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	"github.com/Cloud-Foundations/Dominator/proto/mdbserver"
+)
+
+// listMdbImages returns the names of the images which are required or planned
+// in the MDB.
+func listMdbImages() (map[string]struct{}, error) {
+	address := fmt.Sprintf("%s:%d", *mdbServerHostname, *mdbServerPortNum)
+	client, err := srpc.DialHTTP("tcp", address, 15*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	var reply mdbserver.ListImagesResponse
+	err = client.RequestReply("MdbServer.ListImages",
+		mdbserver.ListImagesRequest{}, &reply)
+	if err != nil {
+		return nil, err
+	}
+	imageNames := make(map[string]struct{},
+		len(reply.PlannedImages)+len(reply.RequiredImages))
+	for _, name := range reply.PlannedImages {
+		imageNames[name] = struct{}{}
+	}
+	for _, name := range reply.RequiredImages {
+		imageNames[name] = struct{}{}
+	}
+	return imageNames, nil
+}
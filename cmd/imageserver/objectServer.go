@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/Cloud-Foundations/Dominator/lib/log"
+	"github.com/Cloud-Foundations/Dominator/lib/objectserver"
+	"github.com/Cloud-Foundations/Dominator/lib/objectserver/filesystem"
+	"github.com/Cloud-Foundations/Dominator/lib/objectserver/s3"
+)
+
+type objectServer interface {
+	objectserver.FullObjectServer
+	objectserver.StashingObjectServer
+	WriteHtml(writer io.Writer)
+}
+
+// newObjectServer creates the object storage backend selected by the
+// -objectServerBackend flag.
+func newObjectServer(logger log.DebugLogger) (objectServer, error) {
+	switch *objectServerBackend {
+	case "filesystem":
+		return filesystem.NewObjectServerWithConfigAndParams(
+			filesystem.Config{
+				BaseDirectory:     *objectDir,
+				LockCheckInterval: *lockCheckInterval,
+				LockLogTimeout:    *lockLogTimeout,
+			},
+			filesystem.Params{
+				Logger: logger,
+			})
+	case "s3":
+		return s3.NewObjectServer(
+			s3.Config{
+				BaseDirectory:     *objectDir,
+				Bucket:            *s3Bucket,
+				Endpoint:          *s3Endpoint,
+				KeyPrefix:         *s3KeyPrefix,
+				LockCheckInterval: *lockCheckInterval,
+				LockLogTimeout:    *lockLogTimeout,
+				MaxCachedBytes:    uint64(s3CacheSize),
+				Region:            *s3Region,
+			},
+			s3.Params{
+				Logger: logger,
+			})
+	}
+	return nil, fmt.Errorf("unknown object server backend: %s",
+		*objectServerBackend)
+}
@@ -9,6 +9,7 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"os/user"
 	"strings"
 	"time"
 
@@ -21,10 +22,12 @@ import (
 	"github.com/Cloud-Foundations/Dominator/lib/format"
 	"github.com/Cloud-Foundations/Dominator/lib/hash"
 	"github.com/Cloud-Foundations/Dominator/lib/image"
+	"github.com/Cloud-Foundations/Dominator/lib/image/oci"
 	"github.com/Cloud-Foundations/Dominator/lib/log"
 	"github.com/Cloud-Foundations/Dominator/lib/mbr"
 	objectclient "github.com/Cloud-Foundations/Dominator/lib/objectserver/client"
 	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	"github.com/Cloud-Foundations/Dominator/lib/tags"
 	"github.com/Cloud-Foundations/Dominator/lib/triggers"
 	"github.com/Cloud-Foundations/Dominator/lib/wsyscall"
 )
@@ -46,6 +49,11 @@ func addImage(imageSClient *srpc.Client, name string, img *image.Image,
 	if err := img.VerifyRequiredPaths(requiredPaths); err != nil {
 		return err
 	}
+	if *signingKey != "" {
+		if err := signImage(img); err != nil {
+			return err
+		}
+	}
 	startTime := time.Now()
 	if err := client.AddImage(imageSClient, name, img); err != nil {
 		return errors.New("remote error: " + err.Error())
@@ -55,6 +63,18 @@ func addImage(imageSClient *srpc.Client, name string, img *image.Image,
 	return nil
 }
 
+func signImage(img *image.Image) error {
+	key, err := image.LoadSigningKey(*signingKey)
+	if err != nil {
+		return err
+	}
+	u, err := user.Current()
+	if err != nil {
+		return err
+	}
+	return img.Sign(key, u.Username)
+}
+
 func (h *hasher) Hash(reader io.Reader, length uint64) (
 	hash.Hash, error) {
 	startTime := time.Now()
@@ -71,21 +91,38 @@ func (h *hasher) Hash(reader io.Reader, length uint64) (
 func buildImage(imageSClient *srpc.Client, filter *filter.Filter,
 	imageFilename string,
 	logger log.DebugLogger) (*filesystem.FileSystem, error) {
+	fs, _, err := buildImageWithTags(imageSClient, filter, imageFilename,
+		logger)
+	return fs, err
+}
+
+// buildImageWithTags builds the file-system and uploads the objects. If the
+// image file is an OCI or Docker image, the labels are returned as tags. Only
+// layout directories are detected: tarfiles require the -ociImage option.
+func buildImageWithTags(imageSClient *srpc.Client, filter *filter.Filter,
+	imageFilename string,
+	logger log.DebugLogger) (*filesystem.FileSystem, tags.Tags, error) {
 	var h hasher
 	var err error
 	h.objQ, err = objectclient.NewObjectAdderQueue(imageSClient)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	startTime := time.Now()
-	fs, err := buildImageWithHasher(imageSClient, filter, imageFilename, &h)
+	var fs *filesystem.FileSystem
+	var labels tags.Tags
+	if *ociImage || oci.IsLayoutDirectory(imageFilename) {
+		fs, labels, err = oci.Decode(imageFilename, &h, filter)
+	} else {
+		fs, err = buildImageWithHasher(imageSClient, filter, imageFilename, &h)
+	}
 	if err != nil {
 		h.objQ.Close()
-		return nil, err
+		return nil, nil, err
 	}
 	err = h.objQ.Close()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	duration := time.Since(startTime)
 	speed := uint64(float64(fs.TotalDataBytes) / duration.Seconds())
@@ -93,7 +130,7 @@ func buildImage(imageSClient *srpc.Client, filter *filter.Filter,
 		"Scanned file-system and uploaded %d objects (%s) in %s (%s/s)\n",
 		fs.NumRegularInodes, format.FormatBytes(fs.TotalDataBytes),
 		format.Duration(duration), format.FormatBytes(speed))
-	return fs, nil
+	return fs, labels, nil
 }
 
 func buildImageWithHasher(imageSClient *srpc.Client, filter *filter.Filter,
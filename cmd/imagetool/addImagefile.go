@@ -38,8 +38,8 @@ func addImagefile(imageSClient *srpc.Client,
 		triggersFilename); err != nil {
 		return err
 	}
-	newImage.FileSystem, err = buildImage(imageSClient, newImage.Filter,
-		imageFilename, logger)
+	newImage.FileSystem, newImage.Tags, err = buildImageWithTags(imageSClient,
+		newImage.Filter, imageFilename, logger)
 	if err != nil {
 		return errors.New("error building image: " + err.Error())
 	}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/filesystem/util"
+	"github.com/Cloud-Foundations/Dominator/lib/format"
+	"github.com/Cloud-Foundations/Dominator/lib/image"
+	"github.com/Cloud-Foundations/Dominator/lib/image/oci"
+	"github.com/Cloud-Foundations/Dominator/lib/log"
+	"github.com/Cloud-Foundations/Dominator/lib/objectserver"
+	objectclient "github.com/Cloud-Foundations/Dominator/lib/objectserver/client"
+)
+
+func exportOciImageSubcommand(args []string, logger log.DebugLogger) error {
+	_, objectClient := getClients()
+	refName := "latest"
+	if len(args) > 2 {
+		refName = args[2]
+	}
+	err := exportOciImage(objectClient, args[0], args[1], refName, logger)
+	if err != nil {
+		return fmt.Errorf("error exporting image: %s", err)
+	}
+	return nil
+}
+
+func exportOciImage(objectClient *objectclient.ObjectClient, name,
+	dirname, refName string, logger log.DebugLogger) error {
+	ti, err := getTypedImageType(name)
+	if err != nil {
+		return err
+	}
+	fs, err := ti.getFileSystem()
+	if err != nil {
+		return err
+	}
+	img := &image.Image{FileSystem: fs}
+	if ti.image != nil { // Copy the metadata which is used for OCI images.
+		img.CreatedOn = ti.image.CreatedOn
+		img.Tags = ti.image.Tags
+	}
+	var objectsGetter objectserver.ObjectsGetter = objectClient
+	if *computedFilesRoot != "" {
+		objectsGetter, err = util.ReplaceComputedFiles(fs,
+			&util.ComputedFilesData{RootDirectory: *computedFilesRoot},
+			objectClient)
+		if err != nil {
+			return err
+		}
+	}
+	startTime := time.Now()
+	if err := oci.WriteLayout(dirname, img, refName, objectsGetter); err != nil {
+		return err
+	}
+	logger.Debugf(0, "Exported %d objects (%s) in %s\n",
+		fs.NumRegularInodes, format.FormatBytes(fs.TotalDataBytes),
+		format.Duration(time.Since(startTime)))
+	return nil
+}
@@ -30,12 +30,18 @@ func listDirectories(imageSClient *srpc.Client) error {
 		}
 	}
 	for _, directory := range directories {
-		if directory.Metadata.OwnerGroup == "" {
+		if directory.Metadata == (image.DirectoryMetadata{}) {
 			fmt.Println(directory.Name)
 			continue
 		}
-		fmt.Printf("%-*s  ", maxDirnameWidth, directory.Name)
-		fmt.Printf("OwnerGroup=%s", directory.Metadata.OwnerGroup)
+		fmt.Printf("%-*s", maxDirnameWidth, directory.Name)
+		if directory.Metadata.OwnerGroup != "" {
+			fmt.Printf("  OwnerGroup=%s", directory.Metadata.OwnerGroup)
+		}
+		if !directory.Metadata.RetentionPolicy.IsEmpty() {
+			fmt.Printf("  RetentionPolicy=\"%s\"",
+				directory.Metadata.RetentionPolicy)
+		}
 		fmt.Println()
 	}
 	return nil
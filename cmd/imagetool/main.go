@@ -75,6 +75,8 @@ var (
 	minFreeBytes      flagutil.Size = 4 << 20
 	objectAddInterval               = flag.Duration("objectAddInterval", 0,
 		"Interval between object uploads (for debugging)")
+	ociImage = flag.Bool("ociImage", false,
+		"If true, the image file is an OCI image layout or docker save tarfile")
 	overlayDirectory = flag.String("overlayDirectory", "",
 		"Directory tree of files to overlay on top of the image when making raw image")
 	releaseNotes = flag.String("releaseNotes", "",
@@ -87,12 +89,16 @@ var (
 	runTriggers = flag.Bool("runTriggers", false,
 		"If true, run image triggers when patching /")
 	scanExcludeList flagutil.StringList = constants.ScanExcludeList
-	skipFields                          = flag.String("skipFields", "",
-		"Fields to skip when showing or diffing images")
+	signingKey                          = flag.String("signingKey", "",
+		"Name of file containing ed25519 private key to sign added images")
+	skipFields = flag.String("skipFields", "",
+		"Fields to skip when showing or diffing images (any of: mlugstndx)")
 	tableType   mbr.TableType = mbr.TABLE_TYPE_MSDOS
 	tagsToMatch tags.MatchTags
 	timeout     = flag.Duration("timeout", 0,
 		"Timeout for get and wait subcommands")
+	trustedSigningKeys = flag.String("trustedSigningKeys", "",
+		"Name of file containing trusted public keys for show-signature")
 
 	logger            log.DebugLogger
 	minimumExpiration = 15 * time.Minute
@@ -174,6 +180,8 @@ var subcommands = []commands.Command{
 	{"diff-triggers", "          tool left right", 3, 3,
 		diffTriggersInImagesSubcommand},
 	{"estimate-usage", "         name", 1, 1, estimateImageUsageSubcommand},
+	{"export-oci", "             name directory [refname]", 2, 3,
+		exportOciImageSubcommand},
 	{"find-latest-image", "      directory", 1, 1, findLatestImageSubcommand},
 	{"get", "                    name directory", 2, 2, getImageSubcommand},
 	{"get-archive-data", "       name outfile", 2, 2,
@@ -205,6 +213,8 @@ var subcommands = []commands.Command{
 	{"save-to-file", "           name [outfile]", 1, 2, saveImageSubcommand},
 	{"scan-filtered-files", "    name directory", 2, 2,
 		scanFilteredFilesSubcommand},
+	{"set-retention-policy", "   dirname keepNewest keepNewerThan", 3, 3,
+		setRetentionPolicySubcommand},
 	{"show", "                   name", 1, 1, showImageSubcommand},
 	{"show-bad-computed-files", "", 0, 0, showBadComputedFilesSubcommand},
 	{"show-bad-image-subs", "", 0, 0, showBadImageSubsSubcommand},
@@ -214,6 +224,9 @@ var subcommands = []commands.Command{
 	{"show-inode", "             name inodePath", 2, 2,
 		showImageInodeSubcommand},
 	{"show-metadata", "          name", 1, 1, showImageMetadataSubcommand},
+	{"show-retention-report", "  [dirname]", 0, 1,
+		showRetentionReportSubcommand},
+	{"show-signature", "         name", 1, 1, showImageSignatureSubcommand},
 	{"show-triggers", "          name", 1, 1, showImageTriggersSubcommand},
 	{"showunrefobj", "", 0, 0, showUnreferencedObjectsSubcommand},
 	{"tar", "                    name [file]", 1, 2, tarImageSubcommand},
@@ -280,6 +293,8 @@ func makeListSelector(arg string) filesystem.ListSelector {
 			mask |= filesystem.ListSelectSkipName
 		case 'd':
 			mask |= filesystem.ListSelectSkipData
+		case 'x':
+			mask |= filesystem.ListSelectSkipXattrs
 		}
 	}
 	return mask
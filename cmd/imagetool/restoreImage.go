@@ -1,9 +1,6 @@
 package main
 
 import (
-	"bufio"
-	"bytes"
-	"encoding/gob"
 	"fmt"
 	"io"
 	"os"
@@ -11,6 +8,7 @@ import (
 
 	"github.com/Cloud-Foundations/Dominator/imageserver/client"
 	"github.com/Cloud-Foundations/Dominator/lib/format"
+	"github.com/Cloud-Foundations/Dominator/lib/image/bundle"
 	"github.com/Cloud-Foundations/Dominator/lib/log"
 	objectclient "github.com/Cloud-Foundations/Dominator/lib/objectserver/client"
 	proto "github.com/Cloud-Foundations/Dominator/proto/imageserver"
@@ -31,17 +29,11 @@ func restoreImage(filename string, logger log.DebugLogger) error {
 		return err
 	}
 	defer file.Close()
-	reader := bufio.NewReader(file)
-	decoder := gob.NewDecoder(reader)
-	var imageArchive []byte
-	if err := decoder.Decode(&imageArchive); err != nil {
-		return err
-	}
-	var archive proto.ImageArchive
-	err = gob.NewDecoder(bytes.NewReader(imageArchive)).Decode(&archive)
+	bundleReader, err := bundle.NewReader(file)
 	if err != nil {
 		return err
 	}
+	archive := bundleReader.Archive
 	exists, err := client.CheckImage(masterImageSClient, archive.ImageName)
 	if err != nil {
 		return err
@@ -65,8 +57,8 @@ func restoreImage(filename string, logger log.DebugLogger) error {
 		}
 	}()
 	for {
-		var object objectType
-		if err := decoder.Decode(&object); err != nil {
+		object, reader, err := bundleReader.NextObject()
+		if err != nil {
 			if err == io.EOF {
 				break
 			}
@@ -89,7 +81,7 @@ func restoreImage(filename string, logger log.DebugLogger) error {
 	response, err := client.RestoreImageFromArchive(masterImageSClient,
 		proto.RestoreImageFromArchiveRequest{
 			ExpiresAt:   time.Now().Add(timeout),
-			ArchiveData: imageArchive,
+			ArchiveData: bundleReader.ArchiveData,
 		})
 	if err != nil {
 		return err
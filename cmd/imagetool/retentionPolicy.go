@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/imageserver/client"
+	"github.com/Cloud-Foundations/Dominator/lib/image"
+	"github.com/Cloud-Foundations/Dominator/lib/log"
+)
+
+func setRetentionPolicySubcommand(args []string, logger log.DebugLogger) error {
+	if err := setRetentionPolicy(args[0], args[1], args[2]); err != nil {
+		return fmt.Errorf("error setting retention policy: %s", err)
+	}
+	return nil
+}
+
+func setRetentionPolicy(dirname, keepNewest, keepNewerThan string) error {
+	var policy image.RetentionPolicy
+	if value, err := strconv.ParseUint(keepNewest, 10, 32); err != nil {
+		return err
+	} else {
+		policy.KeepNewest = uint(value)
+	}
+	if duration, err := parseDays(keepNewerThan); err != nil {
+		return err
+	} else {
+		policy.KeepNewerThan = duration
+	}
+	imageSClient, _ := getClients()
+	return client.SetRetentionPolicy(imageSClient, dirname, policy)
+}
+
+// parseDays parses a duration which may also be specified in days, such as
+// "30d".
+func parseDays(value string) (time.Duration, error) {
+	if strings.HasSuffix(value, "d") {
+		days, err := strconv.ParseUint(value[:len(value)-1], 10, 32)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(value)
+}
+
+func showRetentionReportSubcommand(args []string,
+	logger log.DebugLogger) error {
+	var dirname string
+	if len(args) > 0 {
+		dirname = args[0]
+	}
+	if err := showRetentionReport(dirname); err != nil {
+		return fmt.Errorf("error showing retention report: %s", err)
+	}
+	return nil
+}
+
+func showRetentionReport(dirname string) error {
+	imageSClient, _ := getClients()
+	report, err := client.GetRetentionReport(imageSClient, dirname)
+	if err != nil {
+		return err
+	}
+	for _, name := range report.ImagesToDelete {
+		fmt.Printf("delete: %s\n", name)
+	}
+	for _, name := range report.ImagesKeptForMdb {
+		fmt.Printf("keep (in MDB): %s\n", name)
+	}
+	return nil
+}
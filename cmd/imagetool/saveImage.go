@@ -1,7 +1,6 @@
 package main
 
 import (
-	"bufio"
 	"bytes"
 	"encoding/gob"
 	"fmt"
@@ -10,17 +9,12 @@ import (
 
 	"github.com/Cloud-Foundations/Dominator/imageserver/client"
 	"github.com/Cloud-Foundations/Dominator/lib/hash"
+	"github.com/Cloud-Foundations/Dominator/lib/image/bundle"
 	"github.com/Cloud-Foundations/Dominator/lib/log"
 	"github.com/Cloud-Foundations/Dominator/lib/objectserver"
 	proto "github.com/Cloud-Foundations/Dominator/proto/imageserver"
 )
 
-// Multiple objectType objects are encoded after the encoded proto.ImageArchive.
-type objectType struct {
-	Hash   hash.Hash
-	Length uint64
-} // Object data are streamed afterwards.
-
 func saveImageSubcommand(args []string, logger log.DebugLogger) error {
 	var outFileName string
 	if len(args) > 1 {
@@ -49,20 +43,19 @@ func saveImage(imageName string, outFileName string,
 	if err := decoder.Decode(&img); err != nil {
 		return err
 	}
-	var writer *bufio.Writer
+	var writer io.Writer
 	if outFileName == "" {
-		writer = bufio.NewWriter(os.Stdout)
+		writer = os.Stdout
 	} else {
 		file, err := os.Create(outFileName)
 		if err != nil {
 			return err
 		}
 		defer file.Close()
-		writer = bufio.NewWriter(file)
+		writer = file
 	}
-	defer writer.Flush()
-	encoder := gob.NewEncoder(writer)
-	if err := encoder.Encode(response.ArchiveData); err != nil {
+	bundleWriter, err := bundle.NewWriter(writer, response.ArchiveData)
+	if err != nil {
 		return err
 	}
 	objectsMap := make(map[hash.Hash]struct{})
@@ -80,28 +73,20 @@ func saveImage(imageName string, outFileName string,
 	}
 	defer objectsReader.Close()
 	for _, hashVal := range objectsList {
-		err := saveObject(writer, encoder, objectsReader, hashVal)
+		err := saveObject(bundleWriter, objectsReader, hashVal)
 		if err != nil {
 			return err
 		}
 	}
-	return writer.Flush()
+	return bundleWriter.Flush()
 }
 
-func saveObject(writer io.Writer, encoder *gob.Encoder,
+func saveObject(bundleWriter *bundle.Writer,
 	objectsReader objectserver.ObjectsReader, hashVal hash.Hash) error {
 	receivedLength, readCloser, err := objectsReader.NextObject()
 	if err != nil {
 		return err
 	}
 	defer readCloser.Close()
-	object := objectType{
-		Hash:   hashVal,
-		Length: receivedLength,
-	}
-	if err := encoder.Encode(object); err != nil {
-		return err
-	}
-	_, err = io.Copy(writer, readCloser)
-	return err
+	return bundleWriter.WriteObject(hashVal, receivedLength, readCloser)
 }
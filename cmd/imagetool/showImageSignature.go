@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/Cloud-Foundations/Dominator/lib/image"
+	"github.com/Cloud-Foundations/Dominator/lib/log"
+)
+
+func showImageSignatureSubcommand(args []string,
+	logger log.DebugLogger) error {
+	if err := showImageSignature(args[0]); err != nil {
+		return fmt.Errorf("error showing image signature: %s", err)
+	}
+	return nil
+}
+
+func showImageSignature(imageName string) error {
+	img, err := getTypedImage(imageName)
+	if err != nil {
+		return err
+	}
+	signature := img.Signature
+	if signature == nil {
+		return errors.New("image is not signed")
+	}
+	fmt.Printf("Signed by:  %s\n", signature.SignedBy)
+	fmt.Printf("Signed on:  %s\n", signature.SignedOn)
+	fmt.Printf("Public key: %s\n",
+		base64.StdEncoding.EncodeToString(signature.PublicKey))
+	if *trustedSigningKeys != "" {
+		checker, err := image.NewSignatureChecker(
+			image.SignaturePolicyAllowUnsigned, *trustedSigningKeys)
+		if err != nil {
+			return err
+		}
+		if name := checker.GetTrustedName(signature); name == "" {
+			fmt.Println("Trusted as: UNTRUSTED KEY")
+		} else {
+			fmt.Printf("Trusted as: %s\n", name)
+		}
+	}
+	if err := img.VerifySignature(); err != nil {
+		fmt.Printf("Status:     %s\n", err)
+		return err
+	}
+	fmt.Println("Status:     valid")
+	return nil
+}
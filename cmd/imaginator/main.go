@@ -53,6 +53,8 @@ var (
 	presentationImageServerHostname = flag.String(
 		"presentationImageServerHostname", "",
 		"Hostname of image server for links presentation")
+	signingKey = flag.String("signingKey", "",
+		"Name of file containing ed25519 private key to sign built images")
 	slaveDriverConfigurationFile = flag.String("slaveDriverConfigurationFile",
 		"", "Name of configuration file for slave builders")
 	stateDir = flag.String("stateDir", "/var/lib/imaginator",
@@ -125,6 +127,7 @@ func main() {
 			MaximumExpirationDurationPrivileged: *maximumExpirationDurationPrivileged,
 			MinimumExpirationDuration:           *minimumExpirationDuration,
 			PresentationImageServerAddress:      presentationImageServerAddress,
+			SigningKeyFile:                      *signingKey,
 			StateDirectory:                      *stateDir,
 			VariablesFile:                       *variablesFile,
 		},
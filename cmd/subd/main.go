@@ -11,6 +11,7 @@ import (
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/Cloud-Foundations/Dominator/lib/constants"
 	"github.com/Cloud-Foundations/Dominator/lib/cpulimiter"
@@ -22,6 +23,7 @@ import (
 	"github.com/Cloud-Foundations/Dominator/lib/fsrateio"
 	"github.com/Cloud-Foundations/Dominator/lib/goroutine"
 	"github.com/Cloud-Foundations/Dominator/lib/html"
+	"github.com/Cloud-Foundations/Dominator/lib/log"
 	"github.com/Cloud-Foundations/Dominator/lib/log/serverlogger"
 	"github.com/Cloud-Foundations/Dominator/lib/memstats"
 	"github.com/Cloud-Foundations/Dominator/lib/netspeed"
@@ -38,6 +40,8 @@ import (
 )
 
 var (
+	compressObjects = flag.Bool("compressObjects", false,
+		"If true, compress objects served to peer subs which support it (objects larger than 4 MiB are not compressed, to bound memory usage)")
 	configDirectory = flag.String("configDirectory", "/etc/subd/conf.d",
 		"Directory of optional JSON configuration files")
 	defaultCpuPercent = flag.Uint("defaultCpuPercent", 0,
@@ -48,6 +52,10 @@ var (
 		"Scan speed as percentage of capacity (default 2)")
 	disruptionManager = flag.String("disruptionManager", "",
 		"Path to DisruptionManager tool")
+	fullScanInterval = flag.Duration("fullScanInterval", time.Hour,
+		"Interval between full scans if -incrementalScanning is true")
+	incrementalScanning = flag.Bool("incrementalScanning", false,
+		"If true, watch for changes and only scan changed files between full scans")
 	maxThreads = flag.Uint("maxThreads", 1,
 		"Maximum number of parallel OS threads to use")
 	noteGenerator = flag.String("noteGenerator", "",
@@ -62,12 +70,19 @@ var (
 	rootDir                  = flag.String("rootDir", "/",
 		"Name of root of directory tree to manage")
 	scanExcludeList flagutil.StringList
-	showStats       = flag.Bool("showStats", false,
+	serveObjects    = flag.Bool("serveObjects", false,
+		"If true, serve objects to peer subs")
+	showStats = flag.Bool("showStats", false,
 		"If true, show statistics after each cycle")
 	subdDir = flag.String("subdDir", ".subd",
 		"Name of subd private directory, relative to rootDir. This must be on the same file-system as rootDir")
 	testExternallyPatchable = flag.Bool("testExternallyPatchable", false,
 		"If true, test if externally patchable and exit=0 if so or exit=1 if not")
+	transactionalUpdates = flag.Bool("transactionalUpdates", false,
+		"If true, save replaced files so that updates can be rolled back")
+	triggerMemoryLimit flagutil.Size
+	useCgroups         = flag.Bool("useCgroups", false,
+		"If true and cgroup v2 is available, use control groups to limit resource usage (network reads are not limited)")
 )
 
 func init() {
@@ -75,6 +90,8 @@ func init() {
 	runtime.LockOSThread()
 	flag.Var(&rootDeviceBytesPerSecond, "rootDeviceBytesPerSecond",
 		"Fallback root device speed (default 0)")
+	flag.Var(&triggerMemoryLimit, "triggerMemoryLimit",
+		"Memory limit for triggers if using control groups (default no limit)")
 	flag.Var(&scanExcludeList, "scanExcludeList",
 		`Comma separated list of patterns to exclude from scanning (default `+strings.Join(constants.ScanExcludeList, ",")+`")`)
 }
@@ -222,6 +239,31 @@ func (fsh *DumpableFileSystemHistory) WriteHtml(writer io.Writer) {
 	fmt.Fprintln(writer, "</pre>")
 }
 
+// setupCgroups returns the control groups to limit resource usage, or nil if
+// they are not available, in which case the CPU limiter and rate limiting are
+// used.
+func setupCgroups(workingRootDir string, rootDeviceBytesPerSecond uint64,
+	workdirGoroutine *goroutine.Goroutine,
+	logger log.Logger) *scanner.Cgroups {
+	devnum, err := fsbench.GetDevnumForFile(workingRootDir)
+	if err != nil {
+		logger.Printf("Not using cgroups: %s\n", err)
+		return nil
+	}
+	cgroups, err := scanner.NewCgroups(devnum, rootDeviceBytesPerSecond,
+		uint64(triggerMemoryLimit))
+	if err != nil {
+		logger.Printf("Not using cgroups: %s\n", err)
+		return nil
+	}
+	workdirGoroutine.Run(func() { err = cgroups.MoveFetchThread() })
+	if err != nil {
+		logger.Printf("Error moving fetch thread to cgroup: %s\n", err)
+	}
+	logger.Println("Using cgroups to limit resource usage")
+	return cgroups
+}
+
 func gracefulCleanup() {
 	os.Remove(*pidfile)
 	os.Exit(1)
@@ -256,9 +298,15 @@ func main() {
 	subdDirPathname := path.Join(*rootDir, *subdDir)
 	workingRootDir := path.Join(subdDirPathname, "root")
 	objectsDir := path.Join(workingRootDir, *subdDir, "objects")
+	var rollbackDir string
+	if *transactionalUpdates {
+		rollbackDir = path.Join(workingRootDir, *subdDir, "rollback")
+	}
 	tmpDir := path.Join(subdDirPathname, "tmp")
+	driftReportFilename := path.Join(subdDirPathname, "drift-report")
 	netbenchFilename := path.Join(subdDirPathname, "netbench")
 	oldTriggersFilename := path.Join(subdDirPathname, "triggers.previous")
+	servableObjectsFilename := path.Join(subdDirPathname, "servable-objects")
 	if !createDirectory(workingRootDir) {
 		os.Exit(1)
 	}
@@ -333,6 +381,8 @@ func main() {
 	var configuration scanner.Configuration
 	configuration.CpuLimiter = cpulimiter.New(100)
 	configuration.DefaultCpuPercent = configParams.CpuPercent
+	configuration.FullScanInterval = *fullScanInterval
+	configuration.IncrementalScanning = *incrementalScanning
 	// Apply built-in defaults if nothing specified.
 	if configuration.DefaultCpuPercent < 1 {
 		configuration.DefaultCpuPercent = constants.DefaultCpuPercent
@@ -366,6 +416,10 @@ func main() {
 	if *showStats {
 		fmt.Println(configuration.FsScanContext)
 	}
+	if *useCgroups {
+		configuration.Cgroups = setupCgroups(workingRootDir, bytesPerSecond,
+			workdirGoroutine, logger)
+	}
 	var fsh scanner.FileSystemHistory
 	mainFunc := func(fsChannel <-chan *scanner.FileSystem,
 		disableScanner func(disableScanner bool)) {
@@ -373,6 +427,7 @@ func main() {
 			getCachedNetworkSpeed(netbenchFilename),
 			uint64(configParams.NetworkSpeedPercent), &rateio.ReadMeasurer{})
 		configuration.NetworkReaderContext = networkReaderContext
+		configuration.ApplyCgroupLimits(logger)
 		invalidateNextScanObjectCache := false
 		rescanFunc := func() {
 			invalidateNextScanObjectCache = true
@@ -382,12 +437,17 @@ func main() {
 		}
 		rpcdHtmlWriter := rpcd.Setup(
 			rpcd.Config{
+				CompressObjects:          *compressObjects,
 				DisruptionManager:        *disruptionManager,
+				DriftReportFilename:      driftReportFilename,
 				NetworkBenchmarkFilename: netbenchFilename,
 				NoteGeneratorCommand:     *noteGenerator,
 				ObjectsDirectoryName:     objectsDir,
 				OldTriggersFilename:      oldTriggersFilename,
+				RollbackDirectoryName:    rollbackDir,
 				RootDirectoryName:        workingRootDir,
+				ServableObjectsFilename:  servableObjectsFilename,
+				ServeObjects:             *serveObjects,
 				SubConfiguration:         configParams,
 			},
 			rpcd.Params{
@@ -478,6 +538,7 @@ func main() {
 				if firstScan {
 					configuration.FsScanContext.GetContext().SetSpeedPercent(
 						defaultSpeed)
+					configuration.ApplyCgroupLimits(logger)
 					firstScan = false
 					if *showStats {
 						fmt.Println(configuration.FsScanContext)
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/Cloud-Foundations/Dominator/lib/hash"
+	"github.com/Cloud-Foundations/Dominator/lib/image"
+	"github.com/Cloud-Foundations/Dominator/lib/image/bundle"
+	"github.com/Cloud-Foundations/Dominator/lib/log"
+	"github.com/Cloud-Foundations/Dominator/lib/objectserver"
+	objectserverfs "github.com/Cloud-Foundations/Dominator/lib/objectserver/filesystem"
+)
+
+type objectGetters []objectserver.ObjectGetter
+
+func (getters objectGetters) GetObject(hashVal hash.Hash) (
+	uint64, io.ReadCloser, error) {
+	var err error
+	for _, getter := range getters {
+		length, reader, e := getter.GetObject(hashVal)
+		if e == nil {
+			return length, reader, nil
+		}
+		err = e
+	}
+	return 0, nil, err
+}
+
+func applyBundleSubcommand(args []string, logger log.DebugLogger) error {
+	if err := applyBundle(args[0], logger); err != nil {
+		return fmt.Errorf("error applying bundle: %s: %s", args[0], err)
+	}
+	return nil
+}
+
+func applyBundle(filename string, logger log.DebugLogger) error {
+	objectsDir, err := ioutil.TempDir("", "subtool-bundle.")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(objectsDir)
+	objSrv, err := objectserverfs.NewObjectServer(objectsDir, logger)
+	if err != nil {
+		return err
+	}
+	startTime := showStart("readBundle()")
+	img, imageName, err := readBundle(filename, objSrv)
+	if err != nil {
+		showBlankLine()
+		return err
+	}
+	showTimeTaken(startTime)
+	logger.Debugf(0, "Read bundle for image: %s with %d objects\n",
+		imageName, objSrv.NumObjects())
+	srpcClient := getSubClientRetry(logger)
+	defer srpcClient.Close()
+	return pushImageToSub(srpcClient, imageName, "", objSrv,
+		func() *image.Image { return img })
+}
+
+// readBundle reads the image from the bundle file and adds the objects to
+// objSrv, verifying their hashes.
+func readBundle(filename string, objSrv *objectserverfs.ObjectServer) (
+	*image.Image, string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, "", err
+	}
+	defer file.Close()
+	bundleReader, err := bundle.NewReader(file)
+	if err != nil {
+		return nil, "", err
+	}
+	for {
+		object, reader, err := bundleReader.NextObject()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, "", err
+		}
+		_, _, err = objSrv.AddObject(reader, object.Length, &object.Hash)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	img := &bundleReader.Archive.Image
+	if err := prepareImage(img); err != nil {
+		return nil, "", err
+	}
+	return img, bundleReader.Archive.ImageName, nil
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	domlib "github.com/Cloud-Foundations/Dominator/dom/lib"
+	"github.com/Cloud-Foundations/Dominator/lib/filesystem"
+	"github.com/Cloud-Foundations/Dominator/lib/filter"
+	"github.com/Cloud-Foundations/Dominator/lib/hash"
+	"github.com/Cloud-Foundations/Dominator/lib/image"
+	"github.com/Cloud-Foundations/Dominator/lib/image/bundle"
+	"github.com/Cloud-Foundations/Dominator/lib/log/testlogger"
+	objectserverfs "github.com/Cloud-Foundations/Dominator/lib/objectserver/filesystem"
+	proto "github.com/Cloud-Foundations/Dominator/proto/imageserver"
+)
+
+var testObjects = [][]byte{
+	[]byte("file0 contents"),
+	[]byte("file1 contents, which are longer"),
+}
+
+// writeTestBundle writes a bundle for an image containing the test objects.
+// If corruptIndex is non-negative, the data for that object do not match its
+// hash.
+func writeTestBundle(t *testing.T, corruptIndex int) string {
+	fs := &filesystem.FileSystem{InodeTable: filesystem.InodeTable{}}
+	for index, data := range testObjects {
+		inum := uint64(index + 1)
+		fs.InodeTable[inum] = &filesystem.RegularInode{
+			Size: uint64(len(data)),
+			Hash: hash.Hash(sha512.Sum512(data)),
+		}
+		fs.EntryList = append(fs.EntryList, &filesystem.DirectoryEntry{
+			Name:        fmt.Sprintf("file%d", index),
+			InodeNumber: inum,
+		})
+	}
+	emptyFilter, _ := filter.New(nil)
+	archive := proto.ImageArchive{
+		ImageName: "stream/image",
+		Image:     image.Image{Filter: emptyFilter, FileSystem: fs},
+	}
+	archiveData := &bytes.Buffer{}
+	if err := gob.NewEncoder(archiveData).Encode(archive); err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(t.TempDir(), "bundle")
+	file, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	writer, err := bundle.NewWriter(file, archiveData.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for index, data := range testObjects {
+		hashVal := hash.Hash(sha512.Sum512(data))
+		if index == corruptIndex {
+			data = append([]byte{}, data...)
+			data[0] ^= 0xff
+		}
+		err := writer.WriteObject(hashVal, uint64(len(data)),
+			bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func newTestObjectServer(t *testing.T) *objectserverfs.ObjectServer {
+	objSrv, err := objectserverfs.NewObjectServer(t.TempDir(),
+		testlogger.New(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return objSrv
+}
+
+func TestReadBundle(t *testing.T) {
+	objSrv := newTestObjectServer(t)
+	img, imageName, err := readBundle(writeTestBundle(t, -1), objSrv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imageName != "stream/image" {
+		t.Errorf("image name: %s", imageName)
+	}
+	if numObjects := objSrv.NumObjects(); numObjects != 2 {
+		t.Errorf("read %d objects, expected 2", numObjects)
+	}
+	if _, ok := img.FileSystem.FilenameToInodeTable()["/file1"]; !ok {
+		t.Error("image not prepared")
+	}
+}
+
+func TestReadBundleCorruptedObject(t *testing.T) {
+	objSrv := newTestObjectServer(t)
+	_, _, err := readBundle(writeTestBundle(t, 0), objSrv)
+	if err == nil {
+		t.Error("corrupted object not detected")
+	}
+}
+
+// TestPushBundleObjects checks that without an image server, the objects
+// missing from the sub are pushed from the bundle.
+func TestPushBundleObjects(t *testing.T) {
+	objSrv := newTestObjectServer(t)
+	img, _, err := readBundle(writeTestBundle(t, -1), objSrv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subFS := &filesystem.FileSystem{InodeTable: filesystem.InodeTable{}}
+	if err := prepareImage(&image.Image{FileSystem: subFS}); err != nil {
+		t.Fatal(err)
+	}
+	subObj := domlib.Sub{
+		FileSystem:   subFS,
+		ObjectGetter: objectGetters{objSrv, nullObjectGetterType{}},
+	}
+	objectsToFetch, objectsToPush := domlib.BuildMissingLists(subObj, img,
+		false, true, testlogger.New(t))
+	if len(objectsToFetch) != len(testObjects) {
+		t.Fatalf("%d objects to fetch, expected %d",
+			len(objectsToFetch), len(testObjects))
+	}
+	objectsToPush = pushInsteadOfFetch(objectsToFetch, objectsToPush)
+	if len(objectsToPush) != len(testObjects) {
+		t.Fatalf("%d objects to push, expected %d",
+			len(objectsToPush), len(testObjects))
+	}
+	for hashVal := range objectsToPush {
+		length, reader, err := subObj.ObjectGetter.GetObject(hashVal)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if uint64(len(data)) != length ||
+			hash.Hash(sha512.Sum512(data)) != hashVal {
+			t.Errorf("object: %x has bad data", hashVal)
+		}
+	}
+	if _, _, err := subObj.ObjectGetter.GetObject(hash.Hash{}); err == nil {
+		t.Error("got object not in the bundle")
+	}
+	if pushInsteadOfFetch(nil, nil) != nil {
+		t.Error("objects to push created without objects to fetch")
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+
+	"github.com/Cloud-Foundations/Dominator/lib/log"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	"github.com/Cloud-Foundations/Dominator/sub/client"
+)
+
+const timeFormat = "2006-01-02 15:04:05 MST"
+
+func driftReportSubcommand(args []string, logger log.DebugLogger) error {
+	srpcClient := getSubClient(logger)
+	defer srpcClient.Close()
+	if err := driftReport(srpcClient); err != nil {
+		return fmt.Errorf("error getting drift report: %s", err)
+	}
+	return nil
+}
+
+func driftReport(srpcClient *srpc.Client) error {
+	report, err := client.GetDriftReport(srpcClient)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(os.Stdout)
+	defer writer.Flush()
+	if len(report.CorrectedFiles) > 0 {
+		fmt.Fprintln(writer, "Corrected files:")
+		for _, file := range report.CorrectedFiles {
+			fmt.Fprintf(writer, "  %s %-7s %s (image: %s)\n",
+				file.Time.Local().Format(timeFormat), file.Action,
+				file.Pathname, file.ImageName)
+		}
+	} else {
+		fmt.Fprintln(writer, "No corrected files")
+	}
+	if report.NewFilesSince.IsZero() {
+		fmt.Fprintln(writer, "No scan since last update")
+		return nil
+	}
+	if len(report.NewFiles) < 1 {
+		fmt.Fprintf(writer, "No new files since: %s\n",
+			report.NewFilesSince.Local().Format(timeFormat))
+		return nil
+	}
+	fmt.Fprintf(writer, "New files since: %s\n",
+		report.NewFilesSince.Local().Format(timeFormat))
+	for _, pathname := range report.NewFiles {
+		fmt.Fprintf(writer, "  %s\n", pathname)
+	}
+	if numShown := uint64(len(report.NewFiles)); report.NumNewFiles > numShown {
+		fmt.Fprintf(writer, "  ... and %d more\n",
+			report.NumNewFiles-numShown)
+	}
+	return nil
+}
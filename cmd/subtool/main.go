@@ -10,6 +10,7 @@ import (
 	"github.com/Cloud-Foundations/Dominator/lib/flags/commands"
 	"github.com/Cloud-Foundations/Dominator/lib/flags/loadflags"
 	"github.com/Cloud-Foundations/Dominator/lib/flagutil"
+	"github.com/Cloud-Foundations/Dominator/lib/image"
 	"github.com/Cloud-Foundations/Dominator/lib/log"
 	"github.com/Cloud-Foundations/Dominator/lib/log/cmdlogger"
 	"github.com/Cloud-Foundations/Dominator/lib/log/debuglogger"
@@ -73,13 +74,18 @@ var (
 		"Replacement triggers file to apply when pushing image")
 	triggersString = flag.String("triggersString", "",
 		"Replacement triggers string to apply when pushing image (ignored if triggersFile is set)")
+	trustedSigningKeys = flag.String("trustedSigningKeys", "",
+		"Filename containing public keys trusted to sign images")
 	wait = flag.Uint("wait", 0, "Seconds to sleep after last Poll")
 
-	logger      *debuglogger.Logger
-	timeoutTime time.Time
+	imageSignaturePolicy image.SignaturePolicy
+	logger               *debuglogger.Logger
+	timeoutTime          time.Time
 )
 
 func init() {
+	flag.Var(&imageSignaturePolicy, "imageSignaturePolicy",
+		"Policy for image signatures: ignore, allow-unsigned or require")
 	flag.Var(&scanExcludeList, "scanExcludeList",
 		"Comma separated list of patterns to exclude from scanning")
 }
@@ -124,10 +130,12 @@ func getSubClientRetry(logger log.DebugLogger) *srpc.Client {
 }
 
 var subcommands = []commands.Command{
+	{"apply-bundle", "filename", 1, 1, applyBundleSubcommand},
 	{"boost-cpu-limit", "", 0, 0, boostCpuLimitSubcommand},
 	{"boost-scan-limit", "", 0, 0, boostScanLimitSubcommand},
 	{"cleanup", "", 0, 0, cleanupSubcommand},
 	{"delete", "pathname...", 1, 1, deleteSubcommand},
+	{"drift-report", "", 0, 0, driftReportSubcommand},
 	{"fetch", "hashesFile", 1, 1, fetchSubcommand},
 	{"fetch-image", "image", 1, 1, fetchImageSubcommand},
 	{"get-config", "", 0, 0, getConfigSubcommand},
@@ -138,6 +146,7 @@ var subcommands = []commands.Command{
 	{"push-image", "image", 1, 1, pushImageSubcommand},
 	{"push-missing-objects", "image", 1, 1, pushMissingObjectsSubcommand},
 	{"restart-service", "name", 1, 1, restartServiceSubcommand},
+	{"rollback", "", 0, 0, rollbackSubcommand},
 	{"set-config", "", 0, 0, setConfigSubcommand},
 	{"show-update-request", "image", 1, 1, showUpdateRequestSubcommand},
 	{"wait-for-image", "image", 1, 1, waitForImageSubcommand},
@@ -18,6 +18,7 @@ import (
 	"github.com/Cloud-Foundations/Dominator/lib/image"
 	"github.com/Cloud-Foundations/Dominator/lib/log"
 	"github.com/Cloud-Foundations/Dominator/lib/objectcache"
+	"github.com/Cloud-Foundations/Dominator/lib/objectserver"
 	"github.com/Cloud-Foundations/Dominator/lib/srpc"
 	"github.com/Cloud-Foundations/Dominator/lib/triggers"
 	"github.com/Cloud-Foundations/Dominator/proto/sub"
@@ -62,11 +63,28 @@ func expectUpdateToDisconnect(request sub.UpdateRequest) bool {
 }
 
 func pushImage(srpcClient *srpc.Client, imageName string) error {
-	computedInodes := make(map[string]*filesystem.RegularInode)
 	// Start querying the imageserver for the image.
 	imageServerAddress := fmt.Sprintf("%s:%d",
 		*imageServerHostname, *imageServerPortNum)
 	imgChannel := getImageChannel(imageServerAddress, imageName, timeoutTime)
+	return pushImageToSub(srpcClient, imageName, imageServerAddress, nil,
+		func() *image.Image {
+			startTime := showStart("<-imgChannel")
+			imageResult := <-imgChannel
+			showTimeTaken(startTime)
+			logger.Printf("Background image fetch took %s\n",
+				format.Duration(imageResult.duration))
+			return imageResult.image
+		})
+}
+
+// pushImageToSub will update the sub to the image returned by getImage. If
+// bundleObjects is not nil, objects are pushed from it rather than fetched by
+// the sub from the image server.
+func pushImageToSub(srpcClient *srpc.Client, imageName string,
+	imageServerAddress string, bundleObjects objectserver.ObjectGetter,
+	getImage func() *image.Image) error {
+	computedInodes := make(map[string]*filesystem.RegularInode)
 	if !*forceImageChange {
 		subImageName, err := getSubImage(srpcClient)
 		if err != nil {
@@ -104,13 +122,27 @@ func pushImage(srpcClient *srpc.Client, imageName string) error {
 			}
 		}
 	}
-	startTime := showStart("<-imgChannel")
-	imageResult := <-imgChannel
-	showTimeTaken(startTime)
-	logger.Printf("Background image fetch took %s\n",
-		format.Duration(imageResult.duration))
-	img := imageResult.image
-	var err error
+	if bundleObjects != nil {
+		subObj.ObjectGetter = objectGetters{bundleObjects, subObj.ObjectGetter}
+	}
+	signatureChecker, err := image.NewSignatureChecker(imageSignaturePolicy,
+		*trustedSigningKeys)
+	if err != nil {
+		return err
+	}
+	img := getImage()
+	if img.Signature != nil {
+		if err := img.VerifySignature(); err != nil {
+			return err
+		}
+	}
+	if err := signatureChecker.CheckImage(img); err != nil {
+		return fmt.Errorf("%s: %s", imageName, err)
+	}
+	if imageSignaturePolicy == image.SignaturePolicyRequire &&
+		(*filterFile != "" || *triggersFile != "" || *triggersString != "") {
+		return errors.New("cannot replace filter or triggers of a signed image")
+	}
 	if *filterFile != "" {
 		img.Filter, err = filter.Load(*filterFile)
 		if err != nil {
@@ -185,14 +217,9 @@ func getImageRetry(clientName, imageName string,
 		if err != nil {
 			return nil, err
 		} else if img != nil {
-			if err := img.FileSystem.RebuildInodePointers(); err != nil {
+			if err := prepareImage(img); err != nil {
 				return nil, err
 			}
-			img.FileSystem.InodeToFilenamesTable()
-			img.FileSystem.FilenameToInodeTable()
-			img.FileSystem.HashToInodesTable()
-			img.FileSystem.ComputeTotalDataBytes()
-			img.FileSystem.BuildEntryMap()
 			return img, nil
 		} else if firstTime {
 			logger.Printf("Image: %s not found, will retry\n", imageName)
@@ -202,6 +229,18 @@ func getImageRetry(clientName, imageName string,
 	return nil, errors.New("timed out getting image")
 }
 
+func prepareImage(img *image.Image) error {
+	if err := img.FileSystem.RebuildInodePointers(); err != nil {
+		return err
+	}
+	img.FileSystem.InodeToFilenamesTable()
+	img.FileSystem.FilenameToInodeTable()
+	img.FileSystem.HashToInodesTable()
+	img.FileSystem.ComputeTotalDataBytes()
+	img.FileSystem.BuildEntryMap()
+	return nil
+}
+
 func pollFetchAndPush(subObj *domlib.Sub, img *image.Image,
 	imageServerAddress string, timeoutTime time.Time, singleFetch bool,
 	generationCount, lastGenerationCount, lastScanCount *uint64,
@@ -282,6 +321,10 @@ func pollFetchAndPush(subObj *domlib.Sub, img *image.Image,
 			return nil
 		}
 		objectsNeeded = true
+		if imageServerAddress == "" {
+			objectsToPush = pushInsteadOfFetch(objectsToFetch, objectsToPush)
+			objectsToFetch = nil
+		}
 		if len(objectsToFetch) > 0 {
 			logger.Debugf(0, "Fetch(%d)\n", len(objectsToFetch))
 			startTime := showStart("Fetch()")
@@ -316,6 +359,22 @@ func pollFetchAndPush(subObj *domlib.Sub, img *image.Image,
 	return errors.New("timed out fetching and pushing objects")
 }
 
+// pushInsteadOfFetch adds the objects the sub would fetch to the objects to
+// push. It is used when there is no image server to fetch from.
+func pushInsteadOfFetch(objectsToFetch map[hash.Hash]uint64,
+	objectsToPush map[hash.Hash]struct{}) map[hash.Hash]struct{} {
+	if len(objectsToFetch) < 1 {
+		return objectsToPush
+	}
+	if objectsToPush == nil {
+		objectsToPush = make(map[hash.Hash]struct{}, len(objectsToFetch))
+	}
+	for hashVal := range objectsToFetch {
+		objectsToPush[hashVal] = struct{}{}
+	}
+	return objectsToPush
+}
+
 // pollFetchPushAndUpdate will:
 // - poll the sub until it has a completed scan
 // - compute updates required
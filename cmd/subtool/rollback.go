@@ -0,0 +1,23 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Cloud-Foundations/Dominator/lib/log"
+	"github.com/Cloud-Foundations/Dominator/sub/client"
+)
+
+func rollbackSubcommand(args []string, logger log.DebugLogger) error {
+	srpcClient := getSubClient(logger)
+	defer srpcClient.Close()
+	imageName, err := client.Rollback(srpcClient)
+	if err != nil {
+		return fmt.Errorf("error rolling back: %s", err)
+	}
+	if imageName == "" {
+		logger.Println("Rolled back")
+	} else {
+		logger.Printf("Rolled back to: %s\n", imageName)
+	}
+	return nil
+}
@@ -20,6 +20,13 @@ func DisableUpdates(client srpc.ClientI, reason string) error {
 	return disableUpdates(client, reason)
 }
 
+// DisableUpdatesForSubs will create an update freeze for the subs matching
+// the selectors in request. The freeze ID is returned.
+func DisableUpdatesForSubs(client srpc.ClientI,
+	request proto.DisableUpdatesRequest) (uint64, error) {
+	return disableUpdatesForSubs(client, request)
+}
+
 func EnableUpdates(client srpc.ClientI, reason string) error {
 	return enableUpdates(client, reason)
 }
@@ -42,15 +49,61 @@ func GetInfoForSubs(client srpc.ClientI, request proto.GetInfoForSubsRequest) (
 	return getInfoForSubs(client, request)
 }
 
+// GetLeader returns the address of the leader dominator (or an empty string if
+// there is no known leader) and true if the dominator is the leader.
+func GetLeader(client srpc.ClientI) (string, bool, error) {
+	return getLeader(client)
+}
+
+// GetLeaderFromError returns the address of the leader dominator if err was
+// returned because the dominator is a standby, else an empty string.
+func GetLeaderFromError(err error) string {
+	return getLeaderFromError(err)
+}
+
+func GetRollouts(client srpc.ClientI) ([]proto.RolloutInfo, error) {
+	return getRollouts(client)
+}
+
 func GetSubsConfiguration(client srpc.ClientI) (subproto.Configuration, error) {
 	return getSubsConfiguration(client)
 }
 
+func GetUpdateHistory(client srpc.ClientI,
+	request proto.GetUpdateHistoryRequest) ([]proto.UpdateRecord, error) {
+	return getUpdateHistory(client, request)
+}
+
 func ListSubs(client srpc.ClientI, request proto.ListSubsRequest) (
 	[]string, error) {
 	return listSubs(client, request)
 }
 
+func ListUpdateFreezes(client srpc.ClientI) ([]proto.UpdateFreeze, error) {
+	return listUpdateFreezes(client)
+}
+
+func PreviewUpdate(client srpc.ClientI,
+	request proto.PreviewUpdateRequest) (proto.PreviewUpdateResponse, error) {
+	return previewUpdate(client, request)
+}
+
+// RemoveUpdateFreeze will remove the update freeze with the specified ID.
+func RemoveUpdateFreeze(client srpc.ClientI, freezeId uint64,
+	reason string) error {
+	return removeUpdateFreeze(client, freezeId, reason)
+}
+
 func SetDefaultImage(client srpc.ClientI, imageName string) error {
 	return setDefaultImage(client, imageName)
 }
+
+// WatchSubs watches the subs matching request. The initial snapshot of the
+// matching subs is passed to snapshotFunc, followed by each change passed to
+// changeFunc. WatchSubs returns when an error occurs or when changeFunc returns
+// an error.
+func WatchSubs(client srpc.ClientI, request proto.WatchSubsRequest,
+	snapshotFunc func(subs []proto.SubInfo) error,
+	changeFunc func(sub proto.SubInfo, deleted bool) error) error {
+	return watchSubs(client, request, snapshotFunc, changeFunc)
+}
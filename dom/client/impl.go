@@ -2,6 +2,7 @@ package client
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/Cloud-Foundations/Dominator/lib/errors"
 	"github.com/Cloud-Foundations/Dominator/lib/log"
@@ -24,21 +25,26 @@ func configureSubs(client srpc.ClientI,
 }
 
 func disableUpdates(client srpc.ClientI, reason string) error {
-	if reason == "" {
-		return errors.New("cannot disable updates: no reason given")
+	_, err := disableUpdatesForSubs(client,
+		proto.DisableUpdatesRequest{Reason: reason})
+	return err
+}
+
+func disableUpdatesForSubs(client srpc.ClientI,
+	request proto.DisableUpdatesRequest) (uint64, error) {
+	if request.Reason == "" {
+		return 0, errors.New("cannot disable updates: no reason given")
 	}
-	request := proto.DisableUpdatesRequest{Reason: reason}
 	var reply proto.DisableUpdatesResponse
-	return client.RequestReply("Dominator.DisableUpdates", request, &reply)
+	err := client.RequestReply("Dominator.DisableUpdates", request, &reply)
+	if err != nil {
+		return 0, err
+	}
+	return reply.FreezeId, nil
 }
 
 func enableUpdates(client srpc.ClientI, reason string) error {
-	if reason == "" {
-		return errors.New("cannot enable updates: no reason given")
-	}
-	request := proto.EnableUpdatesRequest{Reason: reason}
-	var reply proto.EnableUpdatesResponse
-	return client.RequestReply("Dominator.EnableUpdates", request, &reply)
+	return removeUpdateFreeze(client, 0, reason)
 }
 
 func fastUpdate(client srpc.ClientI, request proto.FastUpdateRequest,
@@ -101,6 +107,43 @@ func getInfoForSubs(client srpc.ClientI, request proto.GetInfoForSubsRequest) (
 	return reply, nil
 }
 
+func getLeader(client srpc.ClientI) (string, bool, error) {
+	var request proto.GetLeaderRequest
+	var reply proto.GetLeaderResponse
+	err := client.RequestReply("Dominator.GetLeader", request, &reply)
+	if err != nil {
+		return "", false, err
+	}
+	return reply.Address, reply.IsLeader, nil
+}
+
+func getLeaderFromError(err error) string {
+	if err == nil {
+		return ""
+	}
+	_, leader, found := strings.Cut(err.Error(), proto.NotLeaderErrorPrefix)
+	if !found {
+		return ""
+	}
+	if fields := strings.Fields(leader); len(fields) > 0 {
+		return fields[0]
+	}
+	return ""
+}
+
+func getRollouts(client srpc.ClientI) ([]proto.RolloutInfo, error) {
+	var request proto.GetRolloutsRequest
+	var reply proto.GetRolloutsResponse
+	err := client.RequestReply("Dominator.GetRollouts", request, &reply)
+	if err != nil {
+		return nil, err
+	}
+	if err := errors.New(reply.Error); err != nil {
+		return nil, err
+	}
+	return reply.Rollouts, nil
+}
+
 func getSubsConfiguration(client srpc.ClientI) (subproto.Configuration, error) {
 	var request proto.GetSubsConfigurationRequest
 	var reply proto.GetSubsConfigurationResponse
@@ -112,6 +155,19 @@ func getSubsConfiguration(client srpc.ClientI) (subproto.Configuration, error) {
 	return subproto.Configuration(reply), nil
 }
 
+func getUpdateHistory(client srpc.ClientI,
+	request proto.GetUpdateHistoryRequest) ([]proto.UpdateRecord, error) {
+	var reply proto.GetUpdateHistoryResponse
+	err := client.RequestReply("Dominator.GetUpdateHistory", request, &reply)
+	if err != nil {
+		return nil, err
+	}
+	if err := errors.New(reply.Error); err != nil {
+		return nil, err
+	}
+	return reply.Records, nil
+}
+
 func listSubs(client srpc.ClientI, request proto.ListSubsRequest) (
 	[]string, error) {
 	var reply proto.ListSubsResponse
@@ -125,9 +181,87 @@ func listSubs(client srpc.ClientI, request proto.ListSubsRequest) (
 	return reply.Hostnames, nil
 }
 
+func listUpdateFreezes(client srpc.ClientI) ([]proto.UpdateFreeze, error) {
+	var request proto.ListUpdateFreezesRequest
+	var reply proto.ListUpdateFreezesResponse
+	err := client.RequestReply("Dominator.ListUpdateFreezes", request, &reply)
+	if err != nil {
+		return nil, err
+	}
+	if err := errors.New(reply.Error); err != nil {
+		return nil, err
+	}
+	return reply.Freezes, nil
+}
+
+func previewUpdate(client srpc.ClientI,
+	request proto.PreviewUpdateRequest) (proto.PreviewUpdateResponse, error) {
+	var reply proto.PreviewUpdateResponse
+	err := client.RequestReply("Dominator.PreviewUpdate", request, &reply)
+	if err != nil {
+		return proto.PreviewUpdateResponse{}, err
+	}
+	if err := errors.New(reply.Error); err != nil {
+		return proto.PreviewUpdateResponse{}, err
+	}
+	return reply, nil
+}
+
+func removeUpdateFreeze(client srpc.ClientI, freezeId uint64,
+	reason string) error {
+	if reason == "" {
+		return errors.New("cannot enable updates: no reason given")
+	}
+	request := proto.EnableUpdatesRequest{FreezeId: freezeId, Reason: reason}
+	var reply proto.EnableUpdatesResponse
+	return client.RequestReply("Dominator.EnableUpdates", request, &reply)
+}
+
 func setDefaultImage(client srpc.ClientI, imageName string) error {
 	request := proto.SetDefaultImageRequest{ImageName: imageName}
 	var reply proto.SetDefaultImageResponse
 	err := client.RequestReply("Dominator.SetDefaultImage", request, &reply)
 	return err
 }
+
+func watchSubs(client srpc.ClientI, request proto.WatchSubsRequest,
+	snapshotFunc func(subs []proto.SubInfo) error,
+	changeFunc func(sub proto.SubInfo, deleted bool) error) error {
+	conn, err := client.Call("Dominator.WatchSubs")
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := conn.Encode(request); err != nil {
+		return err
+	}
+	if err := conn.Flush(); err != nil {
+		return err
+	}
+	var snapshot []proto.SubInfo
+	var snapshotReceived bool
+	for {
+		var reply proto.WatchSubsResponse
+		if err := conn.Decode(&reply); err != nil {
+			return fmt.Errorf("error decoding: %s", err)
+		}
+		if err := errors.New(reply.Error); err != nil {
+			return err
+		}
+		if reply.Sub == nil { // Initial snapshot has been sent.
+			snapshotReceived = true
+			if err := snapshotFunc(snapshot); err != nil {
+				return err
+			}
+			snapshot = nil
+			continue
+		}
+		if !snapshotReceived {
+			snapshot = append(snapshot, *reply.Sub)
+			continue
+		}
+		if err := changeFunc(*reply.Sub, reply.Deleted); err != nil {
+			return err
+		}
+	}
+}
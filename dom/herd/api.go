@@ -2,21 +2,27 @@ package herd
 
 import (
 	"io"
+	"os"
 	"sync"
 	"time"
 
 	"github.com/Cloud-Foundations/Dominator/dom/images"
 	"github.com/Cloud-Foundations/Dominator/lib/cpusharer"
+	"github.com/Cloud-Foundations/Dominator/lib/election"
 	filegenclient "github.com/Cloud-Foundations/Dominator/lib/filegen/client"
 	"github.com/Cloud-Foundations/Dominator/lib/filesystem"
+	"github.com/Cloud-Foundations/Dominator/lib/filter"
+	"github.com/Cloud-Foundations/Dominator/lib/flagutil"
 	"github.com/Cloud-Foundations/Dominator/lib/image"
 	"github.com/Cloud-Foundations/Dominator/lib/log"
+	"github.com/Cloud-Foundations/Dominator/lib/maintenancewindow"
 	"github.com/Cloud-Foundations/Dominator/lib/mdb"
 	"github.com/Cloud-Foundations/Dominator/lib/net"
 	"github.com/Cloud-Foundations/Dominator/lib/objectcache"
 	"github.com/Cloud-Foundations/Dominator/lib/objectserver"
 	"github.com/Cloud-Foundations/Dominator/lib/queue"
 	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	"github.com/Cloud-Foundations/Dominator/lib/tags/tagmatcher"
 	domproto "github.com/Cloud-Foundations/Dominator/proto/dominator"
 	filegenproto "github.com/Cloud-Foundations/Dominator/proto/filegenerator"
 	subproto "github.com/Cloud-Foundations/Dominator/proto/sub"
@@ -66,6 +72,7 @@ const (
 	statusMissingComputedFile
 	statusUpdatesDisabled
 	statusUnsafeUpdate
+	statusWaitingForRollout
 	statusDisruptionRequested
 	statusDisruptionDenied
 	statusUpdating
@@ -73,6 +80,9 @@ const (
 	statusFailedToUpdate
 	statusWaitingForNextFullPoll
 	statusSynced
+	statusRolledBack
+	statusUnhealthyAfterUpdate
+	statusNotLeader
 )
 
 type HtmlWriter interface {
@@ -80,56 +90,73 @@ type HtmlWriter interface {
 }
 
 type Sub struct {
-	herd                         *Herd
-	mdb                          mdb.Machine
-	requiredImageName            string       // Updated only by sub goroutine.
-	requiredImage                *image.Image // Updated only by sub goroutine.
-	plannedImageName             string       // Updated only by sub goroutine.
-	plannedImage                 *image.Image // Updated only by sub goroutine.
-	clientResource               *srpc.ClientResource
-	computedInodes               map[string]*filesystem.RegularInode
-	fileUpdateReceiver           queue.Receiver[[]filegenproto.FileInfo]
-	busyFlagMutex                sync.Mutex
-	busy                         bool
-	deletingFlagMutex            sync.Mutex
-	deleting                     bool
-	busyStartTime                time.Time
-	busyStopTime                 time.Time
-	cancelChannel                chan struct{}
-	havePlannedImage             bool
-	startTime                    time.Time
-	pollTime                     time.Time
-	fileSystem                   *filesystem.FileSystem
-	objectCache                  objectcache.ObjectCache
-	generationCount              uint64
-	freeSpaceThreshold           *uint64
-	computedFilesChangeTime      time.Time
-	scanCountAtLastUpdateEnd     uint64
-	configToRestore              *subproto.Configuration
-	isInsecure                   bool
-	status                       subStatus
-	publishedStatus              subStatus
-	pendingForceDisruptiveUpdate bool
-	pendingSafetyClear           bool
-	lastAddress                  string
-	lastConnectionStartTime      time.Time
-	lastReachableTime            time.Time
-	lastConnectionSucceededTime  time.Time
-	lastConnectDuration          time.Duration
-	lastDisruptionState          subproto.DisruptionState
-	lastPollStartTime            time.Time
-	lastPollSucceededTime        time.Time
-	lastShortPollDuration        time.Duration
-	lastFullPollDuration         time.Duration
-	lastPollWasFull              bool
-	lastScanDuration             time.Duration
-	lastComputeUpdateCpuDuration time.Duration
-	lastUpdateTime               time.Time
-	lastSyncTime                 time.Time
-	lastSuccessfulImageName      string
-	lastNote                     string
-	lastWriteError               string
-	systemUptime                 *time.Duration
+	herd                          *Herd
+	mdb                           mdb.Machine
+	requiredImageName             string       // Updated only by sub goroutine.
+	requiredImage                 *image.Image // Updated only by sub goroutine.
+	plannedImageName              string       // Updated only by sub goroutine.
+	plannedImage                  *image.Image // Updated only by sub goroutine.
+	clientResource                *srpc.ClientResource
+	computedInodes                map[string]*filesystem.RegularInode
+	fileUpdateReceiver            queue.Receiver[[]filegenproto.FileInfo]
+	busyFlagMutex                 sync.Mutex
+	busy                          bool
+	deletingFlagMutex             sync.Mutex
+	deleting                      bool
+	busyStartTime                 time.Time
+	busyStopTime                  time.Time
+	cancelChannel                 chan struct{}
+	havePlannedImage              bool
+	startTime                     time.Time
+	pollTime                      time.Time
+	fileSystem                    *filesystem.FileSystem
+	objectCache                   objectcache.ObjectCache
+	generationCount               uint64
+	freeSpaceThreshold            *uint64
+	computedFilesChangeTime       time.Time
+	correctedFileCount            uint64
+	newFileCount                  uint64
+	scanCountAtLastUpdateEnd      uint64
+	configToRestore               *subproto.Configuration
+	isInsecure                    bool
+	status                        subStatus
+	publishedStatus               subStatus
+	pendingForceDisruptiveUpdate  bool
+	pendingSafetyClear            bool
+	lastAddress                   string
+	lastConnectionStartTime       time.Time
+	lastReachableTime             time.Time
+	lastConnectionSucceededTime   time.Time
+	lastConnectDuration           time.Duration
+	lastDisruptionState           subproto.DisruptionState
+	lastPollStartTime             time.Time
+	lastPollSucceededTime         time.Time
+	lastShortPollDuration         time.Duration
+	lastFullPollDuration          time.Duration
+	lastPollWasFull               bool
+	lastScanDuration              time.Duration
+	lastComputeUpdateCpuDuration  time.Duration
+	lastUpdateHadTriggerFailures  bool
+	lastUpdateHealthProbeResults  []subproto.HealthProbeResult
+	lastUpdateTime                time.Time
+	lastSyncTime                  time.Time
+	lastSuccessfulImageName       string
+	lastNote                      string
+	lastWriteError                string
+	updatesDisabledReason         string
+	unsafeUpdateReason            string
+	lastWatchState                subWatchState
+	pendingFetchRecord            *domproto.UpdateRecord
+	pendingUpdateRecord           *domproto.UpdateRecord
+	fastUpdateUsername            string
+	forceDisruptiveUpdateUsername string
+	rollbackImageName             string // Last good image before updating.
+	rolledBack                    *rollbackState
+	restoredFromCheckpoint        bool
+	servesObjects                 bool
+	peerKey                       *peerKey // Protected by herd.peersLock.
+	checkpointComputedInodes      map[string]*filesystem.RegularInode
+	systemUptime                  *time.Duration
 }
 
 func (sub *Sub) String() string {
@@ -140,18 +167,35 @@ type Herd struct {
 	sync.RWMutex             // Protect map and slice mutations.
 	imageManager             *images.Manager
 	objectServer             objectserver.ObjectServer
+	checkpoint               map[string]*subCheckpoint // nil after 1st MDB.
+	checkpointFilename       string
+	checkpointModTime        time.Time           // Of the last checkpoint loaded.
+	checkpointRollouts       []rolloutCheckpoint // nil after 1st MDB.
 	computedFilesManager     *filegenclient.Manager
+	elector                  *election.Elector // nil: always the leader.
+	stopChannel              chan struct{}     // Closed to stop updates.
+	wasLeader                bool              // Used only by pollNextSub.
 	logger                   log.DebugLogger
 	htmlWriters              []HtmlWriter
-	updatesDisabledReason    string
-	updatesDisabledBy        string
-	updatesDisabledTime      time.Time
+	updateFreezesLock        sync.RWMutex             // Protect freezes.
+	updateFreezes            map[uint64]*updateFreeze // Key: freeze ID.
+	nextUpdateFreezeId       uint64
+	updateFreezesFilename    string
+	updateFreezesModTime     time.Time // Of the last freezes loaded or saved.
 	defaultImageName         string
 	nextDefaultImageName     string
 	configurationForSubs     subproto.Configuration
 	nextSubToPoll            uint
+	rolloutPolicy            *rolloutPolicy // Default policy.
+	safetyPolicy             *safetyPolicy
+	maintenanceWindows       *maintenancewindow.Cache
+	rollouts                 map[string]*rollout // Key: image name.
+	peersLock                sync.Mutex          // Protect peers (addresses).
+	peers                    map[peerKey]map[*Sub]string
 	subsByName               map[string]*Sub
 	subsByIndex              []*Sub // Sorted by Sub.hostname.
+	subWatchersLock          sync.Mutex
+	subWatchers              map[<-chan domproto.WatchSubsResponse]*subWatcher
 	pollSemaphore            chan struct{}
 	fastUpdateSemaphore      chan struct{}
 	pushSemaphore            chan struct{}
@@ -164,6 +208,91 @@ type Herd struct {
 	subdInstallerQueueDelete chan<- string
 	subdInstallerQueueErase  chan<- string
 	totalScanDuration        time.Duration
+	updateJournal            *updateJournal
+}
+
+type rollbackCriteria struct {
+	healthProbeFailures bool
+	triggerFailures     bool
+	unreachableTimeout  time.Duration // Zero: do not roll back if unreachable.
+}
+
+type rollbackState struct {
+	failedImageName string // The RequiredImage which was rolled back.
+	imageName       string // The image which was rolled back to.
+	reason          string
+	time            time.Time
+}
+
+type rolloutWave struct {
+	percent  uint // Cumulative percentage of subs.
+	soakTime time.Duration
+}
+
+type rolloutPolicy struct {
+	minimumSuccessPercent uint
+	waves                 []rolloutWave
+}
+
+type rollout struct {
+	sync.Mutex     // Protect everything below.
+	imageName      string
+	policy         rolloutPolicy
+	startTime      time.Time
+	currentWave    uint
+	aborted        bool // A wave timed out: the remaining subs are held.
+	halted         bool
+	members        map[string]uint // Key: hostname, value: wave.
+	waveSizes      []uint
+	waveStartTimes []time.Time
+	waveReadyTimes []time.Time
+	// Results from the checkpoint (true: failed), used until subs are polled.
+	checkpointResults map[string]bool
+}
+
+type updateJournal struct {
+	sync.Mutex // Protect everything below.
+	dirname    string
+	file       *os.File
+	filename   string
+	fileSize   flagutil.Size
+	logger     log.DebugLogger
+}
+
+type updateFreeze struct {
+	domproto.UpdateFreeze
+	hostnames  map[string]struct{}
+	selectFunc func(*Sub) bool
+}
+
+type subWatcher struct {
+	channel    chan domproto.WatchSubsResponse
+	hostnames  map[string]struct{}
+	selectFunc func(*Sub) bool
+}
+
+type subWatchState struct {
+	status                  subStatus
+	lastSuccessfulImageName string
+	lastUpdateTime          time.Time
+	lastDisruptionState     subproto.DisruptionState
+}
+
+type safetyRule struct {
+	name                  string
+	locationsToMatch      []string
+	locationsMap          map[string]struct{}
+	tagsToMatch           *tagmatcher.TagMatcher
+	maxBytesChanged       *uint64
+	maxDeletedFraction    float64
+	maxHighImpactTriggers *uint
+	protectedPaths        *filter.Filter
+	protectedPathsList    []string
+}
+
+type safetyPolicy struct {
+	defaultRule *safetyRule
+	rules       []*safetyRule
 }
 
 type subCounter struct {
@@ -189,12 +318,14 @@ func (herd *Herd) ConfigureSubs(configuration subproto.Configuration) error {
 	return herd.configureSubs(configuration)
 }
 
-func (herd *Herd) DisableUpdates(username, reason string) error {
-	return herd.disableUpdates(username, reason)
+func (herd *Herd) DisableUpdates(username string,
+	request domproto.DisableUpdatesRequest) (uint64, error) {
+	return herd.disableUpdates(username, request)
 }
 
-func (herd *Herd) EnableUpdates() error {
-	return herd.enableUpdates()
+func (herd *Herd) EnableUpdates(freezeId uint64,
+	authInfo *srpc.AuthInformation) error {
+	return herd.enableUpdates(freezeId, authInfo)
 }
 
 func (herd *Herd) FastUpdate(request domproto.FastUpdateRequest,
@@ -211,6 +342,21 @@ func (herd *Herd) GetDefaultImage() string {
 	return herd.defaultImageName
 }
 
+// GetLeader returns the address of the leader and true if this dominator is
+// the leader.
+func (herd *Herd) GetLeader() (string, bool) {
+	return herd.getLeader()
+}
+
+func (herd *Herd) GetRollouts() []domproto.RolloutInfo {
+	return herd.getRollouts()
+}
+
+func (herd *Herd) GetUpdateHistory(request domproto.GetUpdateHistoryRequest) (
+	[]domproto.UpdateRecord, error) {
+	return herd.getUpdateHistory(request)
+}
+
 func (herd *Herd) GetSubsConfiguration() subproto.Configuration {
 	return herd.getSubsConfiguration()
 }
@@ -224,6 +370,10 @@ func (herd *Herd) ListSubs(request domproto.ListSubsRequest) ([]string, error) {
 	return herd.listSubs(request)
 }
 
+func (herd *Herd) ListUpdateFreezes() []domproto.UpdateFreeze {
+	return herd.listUpdateFreezes()
+}
+
 func (herd *Herd) LockWithTimeout(timeout time.Duration) {
 	herd.lockWithTimeout(timeout)
 }
@@ -232,18 +382,71 @@ func (herd *Herd) MdbUpdate(mdb *mdb.Mdb) {
 	herd.mdbUpdate(mdb)
 }
 
+// OpenUpdateJournal will open the journal of updates in the specified
+// directory. If it is not called, updates are not journalled.
+func (herd *Herd) OpenUpdateJournal(dirname string) error {
+	return herd.openUpdateJournal(dirname)
+}
+
 func (herd *Herd) PollNextSub() bool {
 	return herd.pollNextSub()
 }
 
+func (herd *Herd) PreviewUpdate(request domproto.PreviewUpdateRequest,
+	authInfo *srpc.AuthInformation) (domproto.PreviewUpdateResponse, error) {
+	return herd.previewUpdate(request, authInfo)
+}
+
 func (herd *Herd) RLockWithTimeout(timeout time.Duration) {
 	herd.rLockWithTimeout(timeout)
 }
 
+// RegisterSubWatcher returns a channel on which changes to the subs matching
+// request are sent, along with a snapshot of the matching subs. The channel is
+// closed if the receiver falls too far behind.
+func (herd *Herd) RegisterSubWatcher(request domproto.WatchSubsRequest) (
+	<-chan domproto.WatchSubsResponse, []domproto.SubInfo) {
+	return herd.registerSubWatcher(request)
+}
+
 func (herd *Herd) SetDefaultImage(imageName string) error {
 	return herd.setDefaultImage(imageName)
 }
 
+// SetElector sets the leader elector. Only the leader will poll and update
+// subs. This must be called before polling is started.
+func (herd *Herd) SetElector(elector *election.Elector) {
+	herd.elector = elector
+}
+
+// LoadUpdateFreezes loads the update freezes from filename and saves them there
+// whenever they are changed. This should be called before polling is started.
+func (herd *Herd) LoadUpdateFreezes(filename string) error {
+	return herd.loadUpdateFreezes(filename)
+}
+
+// StopUpdates stops polling and updating subs and waits until the subs are no
+// longer busy or the timeout expires. It returns false if the timeout expired.
+// This should be called before releasing the leadership lease, so that the new
+// leader does not start updating subs which are still being updated.
+func (herd *Herd) StopUpdates(timeout time.Duration) bool {
+	return herd.stopUpdates(timeout)
+}
+
+// StartCheckpointing loads the checkpoint of the state of the subs from
+// filename, which is applied when the subs are created by the first MDB
+// update, and starts writing a checkpoint every interval. This must be called
+// before the first MDB update.
+func (herd *Herd) StartCheckpointing(filename string,
+	interval time.Duration) error {
+	return herd.startCheckpointing(filename, interval)
+}
+
 func (herd *Herd) StartServer(portNum uint, daemon bool) error {
 	return herd.startServer(portNum, daemon)
 }
+
+func (herd *Herd) UnregisterSubWatcher(
+	channel <-chan domproto.WatchSubsResponse) {
+	herd.unregisterSubWatcher(channel)
+}
@@ -0,0 +1,324 @@
+package herd
+
+import (
+	"bufio"
+	"encoding/gob"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/filesystem"
+	"github.com/Cloud-Foundations/Dominator/lib/fsutil"
+	"github.com/Cloud-Foundations/Dominator/lib/objectcache"
+	proto "github.com/Cloud-Foundations/Dominator/proto/dominator"
+	subproto "github.com/Cloud-Foundations/Dominator/proto/sub"
+)
+
+const checkpointVersion = 1
+
+type checkpointType struct {
+	Version  uint
+	Rollouts []rolloutCheckpoint
+	Subs     []subCheckpoint
+}
+
+type rolloutCheckpoint struct {
+	Aborted               bool
+	CurrentWave           uint
+	FailedHosts           []string
+	ImageName             string
+	Members               map[string]uint // Key: hostname, value: wave.
+	MinimumSuccessPercent uint
+	StartTime             time.Time
+	SyncedHosts           []string
+	Waves                 []rolloutWaveCheckpoint
+}
+
+type rolloutWaveCheckpoint struct {
+	Percent   uint
+	ReadyTime time.Time
+	SoakTime  time.Duration
+	StartTime time.Time
+}
+
+type subCheckpoint struct {
+	Hostname                string
+	ComputedInodes          map[string]*filesystem.RegularInode
+	FileSystem              *filesystem.FileSystem
+	GenerationCount         uint64
+	HealthProbeResults      []subproto.HealthProbeResult
+	LastSuccessfulImageName string
+	LastSyncTime            time.Time
+	LastUpdateTime          time.Time
+	ObjectCache             objectcache.ObjectCache
+	PendingFetchRecord      *proto.UpdateRecord
+	PendingUpdateRecord     *proto.UpdateRecord
+	PlannedImageName        string
+	RequiredImageName       string
+	RolledBack              *rollbackCheckpoint
+	StartTime               time.Time
+	Synced                  bool // Synced, rolled back or unhealthy.
+}
+
+type rollbackCheckpoint struct {
+	FailedImageName string
+	ImageName       string
+	Reason          string
+	Time            time.Time
+}
+
+// startCheckpointing loads the checkpoint from filename, to be applied to the
+// subs created by the first MDB update, and then starts a goroutine which will
+// write a checkpoint every interval.
+func (herd *Herd) startCheckpointing(filename string,
+	interval time.Duration) error {
+	herd.checkpointFilename = filename
+	if fi, err := os.Stat(filename); err == nil {
+		herd.checkpointModTime = fi.ModTime()
+	}
+	if checkpoint, err := readCheckpoint(filename); err != nil {
+		if !os.IsNotExist(err) {
+			herd.logger.Printf("Error reading checkpoint, ignoring: %s\n", err)
+		}
+	} else {
+		herd.checkpoint = checkpoint.makeMap()
+		herd.checkpointRollouts = checkpoint.Rollouts
+		herd.logger.Printf("Loaded checkpoint for %d subs\n",
+			len(herd.checkpoint))
+	}
+	go herd.checkpointLoop(filename, interval)
+	return nil
+}
+
+// reloadCheckpoint loads the checkpoint if it was written (by another leader)
+// since it was last loaded and applies it to the subs which have not been
+// polled. This should be called when this dominator becomes the leader.
+func (herd *Herd) reloadCheckpoint() {
+	if herd.checkpointFilename == "" {
+		return
+	}
+	fi, err := os.Stat(herd.checkpointFilename)
+	if err != nil || !fi.ModTime().After(herd.checkpointModTime) {
+		return
+	}
+	herd.checkpointModTime = fi.ModTime()
+	checkpoint, err := readCheckpoint(herd.checkpointFilename)
+	if err != nil {
+		herd.logger.Printf("Error reloading checkpoint, ignoring: %s\n", err)
+		return
+	}
+	checkpoints := checkpoint.makeMap()
+	herd.Lock()
+	defer herd.Unlock()
+	if len(herd.subsByName) < 1 { // Apply when the first MDB is processed.
+		herd.checkpoint = checkpoints
+		herd.checkpointRollouts = checkpoint.Rollouts
+		herd.logger.Printf("Loaded checkpoint for %d subs\n",
+			len(herd.checkpoint))
+		return
+	}
+	var numRestored uint
+	for _, sub := range herd.subsByIndex {
+		checkpoint := checkpoints[sub.mdb.Hostname]
+		if checkpoint == nil || !sub.lastPollStartTime.IsZero() {
+			continue // Polled by this dominator, so the state is current.
+		}
+		if !sub.tryMakeBusy() {
+			continue
+		}
+		sub.restoreCheckpoint(checkpoint)
+		sub.makeUnbusy()
+		numRestored++
+	}
+	herd.logger.Printf("Reloaded checkpoint for %d subs\n", numRestored)
+	herd.restoreRollouts(checkpoint.Rollouts)
+}
+
+func readCheckpoint(filename string) (*checkpointType, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var checkpoint checkpointType
+	err = gob.NewDecoder(bufio.NewReader(file)).Decode(&checkpoint)
+	if err != nil {
+		return nil, err
+	}
+	if checkpoint.Version != checkpointVersion {
+		return nil, fmt.Errorf("checkpoint version: %d, expected: %d",
+			checkpoint.Version, checkpointVersion)
+	}
+	return &checkpoint, nil
+}
+
+func (checkpoint *checkpointType) makeMap() map[string]*subCheckpoint {
+	checkpoints := make(map[string]*subCheckpoint, len(checkpoint.Subs))
+	for index := range checkpoint.Subs {
+		subCheckpoint := &checkpoint.Subs[index]
+		checkpoints[subCheckpoint.Hostname] = subCheckpoint
+	}
+	return checkpoints
+}
+
+func (herd *Herd) checkpointLoop(filename string, interval time.Duration) {
+	for range time.Tick(interval) {
+		if !herd.isLeader() {
+			continue
+		}
+		startTime := time.Now()
+		if err := herd.writeCheckpoint(filename); err != nil {
+			herd.logger.Printf("Error writing checkpoint: %s\n", err)
+		} else {
+			herd.logger.Debugf(0, "Wrote checkpoint in %s\n",
+				time.Since(startTime))
+		}
+	}
+}
+
+func (herd *Herd) writeCheckpoint(filename string) error {
+	herd.RLock()
+	checkpoint := checkpointType{
+		Version: checkpointVersion,
+		Subs:    make([]subCheckpoint, 0, len(herd.subsByIndex)),
+	}
+	for _, sub := range herd.subsByIndex {
+		checkpoint.Subs = append(checkpoint.Subs, sub.makeCheckpoint())
+	}
+	for _, r := range herd.rollouts {
+		checkpoint.Rollouts = append(checkpoint.Rollouts,
+			r.makeCheckpoint(herd))
+	}
+	herd.RUnlock()
+	writer, err := fsutil.CreateRenamingWriter(filename,
+		fsutil.PrivateFilePerms)
+	if err != nil {
+		return err
+	}
+	defer writer.Close()
+	bufferedWriter := bufio.NewWriter(writer)
+	if err := gob.NewEncoder(bufferedWriter).Encode(checkpoint); err != nil {
+		writer.Abort()
+		return err
+	}
+	if err := bufferedWriter.Flush(); err != nil {
+		writer.Abort()
+		return err
+	}
+	return writer.Close()
+}
+
+// takeCheckpoint returns and forgets the checkpoint for the sub. The herd lock
+// must be held.
+func (herd *Herd) takeCheckpoint(hostname string) *subCheckpoint {
+	if herd.checkpoint == nil {
+		return nil
+	}
+	subCheckpoint := herd.checkpoint[hostname]
+	delete(herd.checkpoint, hostname)
+	return subCheckpoint
+}
+
+func (sub *Sub) makeCheckpoint() subCheckpoint {
+	// Holding the busy flag lock prevents the sub from becoming busy.
+	sub.busyFlagMutex.Lock()
+	defer sub.busyFlagMutex.Unlock()
+	checkpoint := subCheckpoint{
+		Hostname:                sub.mdb.Hostname,
+		HealthProbeResults:      sub.lastUpdateHealthProbeResults,
+		LastSuccessfulImageName: sub.lastSuccessfulImageName,
+		LastSyncTime:            sub.lastSyncTime,
+		LastUpdateTime:          sub.lastUpdateTime,
+	}
+	if sub.busy {
+		return checkpoint // The state is changing: a full poll will be needed.
+	}
+	checkpoint.FileSystem = sub.fileSystem
+	checkpoint.ObjectCache = sub.objectCache
+	checkpoint.PendingFetchRecord = sub.pendingFetchRecord
+	checkpoint.PendingUpdateRecord = sub.pendingUpdateRecord
+	checkpoint.PlannedImageName = sub.plannedImageName
+	checkpoint.RequiredImageName = sub.requiredImageName
+	if len(sub.computedInodes) > 0 {
+		checkpoint.ComputedInodes = make(map[string]*filesystem.RegularInode,
+			len(sub.computedInodes))
+		for pathname, inode := range sub.computedInodes {
+			checkpoint.ComputedInodes[pathname] = inode
+		}
+	}
+	if rolledBack := sub.rolledBack; rolledBack != nil {
+		checkpoint.RolledBack = &rollbackCheckpoint{
+			FailedImageName: rolledBack.failedImageName,
+			ImageName:       rolledBack.imageName,
+			Reason:          rolledBack.reason,
+			Time:            rolledBack.time,
+		}
+	}
+	// Only a sub which was synced can safely skip the first full poll.
+	switch sub.publishedStatus {
+	case statusSynced, statusRolledBack, statusUnhealthyAfterUpdate:
+		checkpoint.GenerationCount = sub.generationCount
+		checkpoint.StartTime = sub.startTime
+		checkpoint.Synced = true
+	}
+	return checkpoint
+}
+
+// restoreCheckpoint restores the state of a new sub from a checkpoint.
+func (sub *Sub) restoreCheckpoint(checkpoint *subCheckpoint) {
+	sub.lastSuccessfulImageName = checkpoint.LastSuccessfulImageName
+	sub.lastUpdateHealthProbeResults = checkpoint.HealthProbeResults
+	sub.lastSyncTime = checkpoint.LastSyncTime
+	sub.lastUpdateTime = checkpoint.LastUpdateTime
+	// The records are completed when a poll shows the operations are done.
+	sub.pendingFetchRecord = checkpoint.PendingFetchRecord
+	sub.pendingUpdateRecord = checkpoint.PendingUpdateRecord
+	if rolledBack := checkpoint.RolledBack; rolledBack != nil &&
+		rolledBack.FailedImageName == sub.mdb.RequiredImage {
+		sub.rolledBack = &rollbackState{
+			failedImageName: rolledBack.FailedImageName,
+			imageName:       rolledBack.ImageName,
+			reason:          rolledBack.Reason,
+			time:            rolledBack.Time,
+		}
+	}
+	requiredImageName, plannedImageName := sub.getImageNames(false)
+	if requiredImageName == "" {
+		requiredImageName = sub.herd.defaultImageName
+	}
+	if !checkpoint.Synced ||
+		checkpoint.RequiredImageName != requiredImageName ||
+		checkpoint.PlannedImageName != plannedImageName {
+		return // The sub will need a full poll.
+	}
+	if fs := checkpoint.FileSystem; fs != nil {
+		if err := fs.RebuildInodePointers(); err != nil {
+			return
+		}
+		fs.BuildEntryMap()
+		sub.fileSystem = fs
+		sub.objectCache = checkpoint.ObjectCache
+	}
+	sub.checkpointComputedInodes = checkpoint.ComputedInodes
+	sub.generationCount = checkpoint.GenerationCount
+	sub.requiredImageName = requiredImageName
+	sub.plannedImageName = plannedImageName
+	sub.restoredFromCheckpoint = true
+	sub.startTime = checkpoint.StartTime
+	if sub.rolledBack != nil {
+		sub.status = statusRolledBack
+	} else if sub.lastUpdateWasUnhealthy() {
+		sub.status = statusUnhealthyAfterUpdate
+	} else {
+		sub.status = statusSynced
+	}
+	sub.publishedStatus = sub.status
+}
+
+func sameComputedInode(left, right *filesystem.RegularInode) bool {
+	return left.Mode == right.Mode &&
+		left.Uid == right.Uid &&
+		left.Gid == right.Gid &&
+		left.Size == right.Size &&
+		left.Hash == right.Hash
+}
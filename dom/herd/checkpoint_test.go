@@ -0,0 +1,252 @@
+package herd
+
+import (
+	"encoding/gob"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/filesystem"
+	"github.com/Cloud-Foundations/Dominator/lib/log/testlogger"
+	"github.com/Cloud-Foundations/Dominator/lib/mdb"
+	proto "github.com/Cloud-Foundations/Dominator/proto/dominator"
+	subproto "github.com/Cloud-Foundations/Dominator/proto/sub"
+)
+
+func makeCheckpointTestSub(herd *Herd, status subStatus) *Sub {
+	sub := &Sub{
+		herd: herd,
+		mdb: mdb.Machine{
+			Hostname:      "sub-" + status.String(),
+			RequiredImage: "image",
+		},
+		publishedStatus:   status,
+		requiredImageName: "image",
+		status:            status,
+	}
+	if status == statusUnhealthyAfterUpdate {
+		sub.lastUpdateHealthProbeResults = []subproto.HealthProbeResult{
+			{Error: "connection refused", Probe: "tcp: :80", Service: "web"},
+		}
+	}
+	return sub
+}
+
+func TestCheckpointStatus(t *testing.T) {
+	herd := &Herd{}
+	for _, status := range []subStatus{statusSynced,
+		statusUnhealthyAfterUpdate, statusUpdating} {
+		herd.subsByIndex = append(herd.subsByIndex,
+			makeCheckpointTestSub(herd, status))
+	}
+	filename := filepath.Join(t.TempDir(), "checkpoint")
+	if err := herd.writeCheckpoint(filename); err != nil {
+		t.Fatal(err)
+	}
+	checkpoint, err := readCheckpoint(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checkpoint.Subs) != len(herd.subsByIndex) {
+		t.Fatalf("read: %d subs, expected: %d",
+			len(checkpoint.Subs), len(herd.subsByIndex))
+	}
+	expectedStatuses := []subStatus{statusSynced, statusUnhealthyAfterUpdate,
+		statusUnknown}
+	for index, oldSub := range herd.subsByIndex {
+		sub := &Sub{herd: herd, mdb: oldSub.mdb}
+		sub.restoreCheckpoint(&checkpoint.Subs[index])
+		if sub.status != expectedStatuses[index] {
+			t.Errorf("%s: restored status: %s, expected: %s",
+				sub.mdb.Hostname, sub.status, expectedStatuses[index])
+		}
+		if sub.publishedStatus != sub.status {
+			t.Errorf("%s: published status: %s, expected: %s",
+				sub.mdb.Hostname, sub.publishedStatus, sub.status)
+		}
+	}
+	if results := checkpoint.Subs[1].HealthProbeResults; len(results) != 1 {
+		t.Errorf("health probe results not saved: %v", results)
+	}
+}
+
+func TestCheckpointPendingRecords(t *testing.T) {
+	herd := &Herd{}
+	oldSub := makeCheckpointTestSub(herd, statusUpdating)
+	oldSub.pendingUpdateRecord = &proto.UpdateRecord{
+		Hostname:  oldSub.mdb.Hostname,
+		ImageName: "image",
+		Operation: proto.UpdateOperationUpdate,
+	}
+	herd.subsByIndex = []*Sub{oldSub}
+	filename := filepath.Join(t.TempDir(), "checkpoint")
+	if err := herd.writeCheckpoint(filename); err != nil {
+		t.Fatal(err)
+	}
+	checkpoint, err := readCheckpoint(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := &Sub{herd: herd, mdb: oldSub.mdb}
+	sub.restoreCheckpoint(&checkpoint.Subs[0])
+	if sub.pendingFetchRecord != nil {
+		t.Errorf("pending fetch record restored: %v", sub.pendingFetchRecord)
+	}
+	if record := sub.pendingUpdateRecord; record == nil ||
+		record.ImageName != "image" {
+		t.Errorf("pending update record not restored: %v", record)
+	}
+}
+
+func TestCheckpointImageChanged(t *testing.T) {
+	herd := &Herd{}
+	oldSub := makeCheckpointTestSub(herd, statusSynced)
+	oldSub.computedInodes = map[string]*filesystem.RegularInode{
+		"/etc/computed": {Size: 1},
+	}
+	oldSub.lastSuccessfulImageName = "image"
+	oldSub.lastSyncTime = time.Now()
+	herd.subsByIndex = []*Sub{oldSub}
+	filename := filepath.Join(t.TempDir(), "checkpoint")
+	if err := herd.writeCheckpoint(filename); err != nil {
+		t.Fatal(err)
+	}
+	checkpoint, err := readCheckpoint(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := &Sub{herd: herd, mdb: oldSub.mdb}
+	sub.restoreCheckpoint(&checkpoint.Subs[0])
+	if !sub.restoredFromCheckpoint || len(sub.checkpointComputedInodes) != 1 {
+		t.Error("synced sub not fully restored")
+	}
+	// A sub with a new RequiredImage needs a full poll.
+	sub = &Sub{herd: herd, mdb: oldSub.mdb}
+	sub.mdb.RequiredImage = "new-image"
+	sub.restoreCheckpoint(&checkpoint.Subs[0])
+	if sub.restoredFromCheckpoint || sub.status != statusUnknown {
+		t.Errorf("sub with new image restored, status: %s", sub.status)
+	}
+	if sub.lastSuccessfulImageName != oldSub.lastSuccessfulImageName ||
+		!sub.lastSyncTime.Equal(oldSub.lastSyncTime) {
+		t.Error("history not restored")
+	}
+}
+
+func TestCheckpointRolledBack(t *testing.T) {
+	herd := &Herd{}
+	oldSub := makeCheckpointTestSub(herd, statusRolledBack)
+	oldSub.requiredImageName = "old-image"
+	oldSub.rolledBack = &rollbackState{
+		failedImageName: "image",
+		imageName:       "old-image",
+		reason:          "unhealthy",
+		time:            time.Now(),
+	}
+	herd.subsByIndex = []*Sub{oldSub}
+	filename := filepath.Join(t.TempDir(), "checkpoint")
+	if err := herd.writeCheckpoint(filename); err != nil {
+		t.Fatal(err)
+	}
+	checkpoint, err := readCheckpoint(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := &Sub{herd: herd, mdb: oldSub.mdb}
+	sub.restoreCheckpoint(&checkpoint.Subs[0])
+	if sub.rolledBack == nil || sub.status != statusRolledBack {
+		t.Errorf("rollback not restored, status: %s", sub.status)
+	}
+	if sub.requiredImageName != "old-image" {
+		t.Errorf("required image: %s, expected: old-image",
+			sub.requiredImageName)
+	}
+	// The rollback no longer applies once the RequiredImage changes.
+	sub = &Sub{herd: herd, mdb: oldSub.mdb}
+	sub.mdb.RequiredImage = "new-image"
+	sub.restoreCheckpoint(&checkpoint.Subs[0])
+	if sub.rolledBack != nil {
+		t.Error("stale rollback restored")
+	}
+}
+
+func TestCheckpointVersion(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "checkpoint")
+	if _, err := readCheckpoint(filename); !os.IsNotExist(err) {
+		t.Errorf("missing checkpoint: %v, expected not exist", err)
+	}
+	file, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = gob.NewEncoder(file).Encode(checkpointType{
+		Version: checkpointVersion + 1,
+		Subs:    []subCheckpoint{{Hostname: "sub"}},
+	})
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = readCheckpoint(filename)
+	if err == nil || os.IsNotExist(err) {
+		t.Errorf("version mismatch: %v, expected distinct error", err)
+	}
+}
+
+func TestTakeCheckpoint(t *testing.T) {
+	herd := &Herd{}
+	if herd.takeCheckpoint("sub") != nil {
+		t.Error("checkpoint taken without checkpoint")
+	}
+	herd.checkpoint = map[string]*subCheckpoint{"sub": {Hostname: "sub"}}
+	if herd.takeCheckpoint("sub") == nil {
+		t.Error("checkpoint not taken")
+	}
+	if herd.takeCheckpoint("sub") != nil {
+		t.Error("checkpoint taken twice")
+	}
+}
+
+func TestReloadCheckpoint(t *testing.T) {
+	oldHerd := &Herd{}
+	oldHerd.subsByIndex = append(oldHerd.subsByIndex,
+		makeCheckpointTestSub(oldHerd, statusSynced))
+	filename := filepath.Join(t.TempDir(), "checkpoint")
+	if err := oldHerd.writeCheckpoint(filename); err != nil {
+		t.Fatal(err)
+	}
+	// A standby which has not yet processed an MDB update.
+	herd := &Herd{checkpointFilename: filename, logger: testlogger.New(t)}
+	herd.reloadCheckpoint()
+	if len(herd.checkpoint) != 1 {
+		t.Errorf("checkpoint for: %d subs, expected 1", len(herd.checkpoint))
+	}
+	// A standby which has processed an MDB update.
+	herd = &Herd{
+		checkpointFilename: filename,
+		logger:             testlogger.New(t),
+		subsByName:         make(map[string]*Sub),
+	}
+	sub := &Sub{herd: herd, mdb: oldHerd.subsByIndex[0].mdb}
+	herd.subsByName[sub.mdb.Hostname] = sub
+	herd.subsByIndex = append(herd.subsByIndex, sub)
+	herd.reloadCheckpoint()
+	if sub.status != statusSynced {
+		t.Errorf("restored status: %s, expected: %s",
+			sub.status, subStatus(statusSynced))
+	}
+	// An unchanged checkpoint is not reloaded.
+	sub.status = statusUnknown
+	herd.reloadCheckpoint()
+	if sub.status != statusUnknown {
+		t.Error("unchanged checkpoint reloaded")
+	}
+	// A sub polled by this dominator is not restored.
+	sub.lastPollStartTime = time.Now()
+	herd.checkpointModTime = time.Time{}
+	herd.reloadCheckpoint()
+	if sub.status != statusUnknown {
+		t.Error("checkpoint restored to polled sub")
+	}
+}
@@ -12,11 +12,14 @@ import (
 	"github.com/Cloud-Foundations/Dominator/lib/constants"
 	"github.com/Cloud-Foundations/Dominator/lib/cpusharer"
 	filegenclient "github.com/Cloud-Foundations/Dominator/lib/filegen/client"
+	"github.com/Cloud-Foundations/Dominator/lib/image"
 	"github.com/Cloud-Foundations/Dominator/lib/log"
+	"github.com/Cloud-Foundations/Dominator/lib/maintenancewindow"
 	libnet "github.com/Cloud-Foundations/Dominator/lib/net"
 	"github.com/Cloud-Foundations/Dominator/lib/net/reverseconnection"
 	"github.com/Cloud-Foundations/Dominator/lib/objectserver"
 	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	"github.com/Cloud-Foundations/Dominator/lib/timewindow"
 	"github.com/Cloud-Foundations/Dominator/lib/url"
 	domproto "github.com/Cloud-Foundations/Dominator/proto/dominator"
 	subproto "github.com/Cloud-Foundations/Dominator/proto/sub"
@@ -36,21 +39,65 @@ var (
 		"Time to wait before reattempting to install subd")
 	subdInstaller = flag.String("subdInstaller", "",
 		"Path to programme used to install subd if connections fail")
+	trustedSigningKeys = flag.String("trustedSigningKeys", "",
+		"Filename containing public keys trusted to sign images")
+
+	imageSignaturePolicy image.SignaturePolicy
 )
 
+func init() {
+	flag.Var(&imageSignaturePolicy, "imageSignaturePolicy",
+		"Policy for image signatures: ignore, allow-unsigned or require")
+}
+
 func newHerd(imageServerAddress string, objectServer objectserver.ObjectServer,
 	metricsDir *tricorder.DirectorySpec, logger log.DebugLogger) *Herd {
 	var herd Herd
-	herd.imageManager = images.New(imageServerAddress, logger)
+	signatureChecker, err := image.NewSignatureChecker(imageSignaturePolicy,
+		*trustedSigningKeys)
+	if err != nil {
+		logger.Fatalf("Error loading trusted signing keys: %s\n", err)
+	}
+	herd.imageManager = images.NewWithSignatureChecker(imageServerAddress,
+		signatureChecker, logger)
 	herd.objectServer = objectServer
 	herd.computedFilesManager = filegenclient.New(objectServer, logger)
 	herd.logger = logger
+	herd.updateFreezes = make(map[uint64]*updateFreeze)
 	if *disableUpdatesAtStartup {
-		herd.updatesDisabledReason = "by default"
+		herd.addUpdateFreeze(domproto.UpdateFreeze{
+			CreatedAt: time.Now(),
+			Reason:    "disabled at startup",
+		})
 	}
 	herd.configurationForSubs.ScanExclusionList =
 		constants.ScanExcludeList
+	if *rolloutConfigFile != "" {
+		policy, err := loadRolloutPolicy(*rolloutConfigFile)
+		if err != nil {
+			logger.Fatalf("Error loading rollout policy: %s\n", err)
+		}
+		herd.rolloutPolicy = policy
+	}
+	herd.rollouts = make(map[string]*rollout)
+	herd.peers = make(map[peerKey]map[*Sub]string)
+	if policy, err := loadSafetyPolicy(*safetyPolicyFile); err != nil {
+		logger.Fatalf("Error loading safety policy: %s\n", err)
+	} else {
+		herd.safetyPolicy = policy
+	}
+	var maintenanceWindow *timewindow.Windows
+	if *defaultMaintenanceWindow != "" {
+		window, err := timewindow.Parse(*defaultMaintenanceWindow)
+		if err != nil {
+			logger.Fatalf("Error parsing maintenance window: %s\n", err)
+		}
+		maintenanceWindow = window
+	}
+	herd.maintenanceWindows = maintenancewindow.NewCache(maintenanceWindow)
+	herd.stopChannel = make(chan struct{})
 	herd.subsByName = make(map[string]*Sub)
+	herd.subWatchers = make(map[<-chan domproto.WatchSubsResponse]*subWatcher)
 	numPollSlots := uint(runtime.NumCPU()) * *pollSlotsPerCPU
 	herd.pollSemaphore = make(chan struct{}, numPollSlots)
 	herd.pushSemaphore = make(chan struct{}, runtime.NumCPU())
@@ -64,6 +111,7 @@ func newHerd(imageServerAddress string, objectServer objectserver.ObjectServer,
 	herd.currentScanStartTime = time.Now()
 	herd.setupMetrics(metricsDir)
 	go herd.subdInstallerLoop()
+	go herd.rolloutLoop()
 	return &herd
 }
 
@@ -85,21 +133,6 @@ func (herd *Herd) configureSubs(configuration subproto.Configuration) error {
 	return nil
 }
 
-func (herd *Herd) disableUpdates(username, reason string) error {
-	if reason == "" {
-		return errors.New("error disabling updates: no reason given")
-	}
-	herd.updatesDisabledBy = username
-	herd.updatesDisabledReason = "because: " + reason
-	herd.updatesDisabledTime = time.Now()
-	return nil
-}
-
-func (herd *Herd) enableUpdates() error {
-	herd.updatesDisabledReason = ""
-	return nil
-}
-
 func (herd *Herd) fastUpdate(request domproto.FastUpdateRequest,
 	authInfo *srpc.AuthInformation) (<-chan FastUpdateMessage, error) {
 	if request.Timeout < time.Millisecond {
@@ -136,6 +169,17 @@ func (herd *Herd) lockWithTimeout(timeout time.Duration) {
 }
 
 func (herd *Herd) pollNextSub() bool {
+	if !herd.isLeader() {
+		herd.wasLeader = false
+		return true // Only the leader polls subs.
+	}
+	if !herd.wasLeader {
+		herd.wasLeader = true
+		// The previous leader may have written a newer checkpoint and changed
+		// the update freezes.
+		herd.reloadCheckpoint()
+		herd.reloadUpdateFreezes()
+	}
 	if herd.nextSubToPoll >= uint(len(herd.subsByIndex)) {
 		herd.nextSubToPoll = 0
 		scanDuration := time.Since(herd.currentScanStartTime)
@@ -270,7 +314,9 @@ func (herd *Herd) setDefaultImage(imageName string) error {
 	for _, sub := range herd.subsByIndex {
 		if sub.mdb.RequiredImage == "" {
 			sub.sendCancel()
-			if sub.status == statusSynced { // Synced to previous default image.
+			// Synced to previous default image.
+			if sub.status == statusSynced ||
+				sub.status == statusUnhealthyAfterUpdate {
 				sub.status = statusWaitingToPoll
 			}
 			if sub.status == statusImageUndefined {
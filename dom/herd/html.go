@@ -29,13 +29,13 @@ func (d uDuration) selector(sub *Sub) bool {
 }
 
 func (herd *Herd) writeHtml(writer io.Writer) {
-	if herd.updatesDisabledReason != "" {
-		herd.writeDisableStatus(writer)
+	if herd.writeDisableStatus(writer) {
 		fmt.Fprintln(writer, "<br>")
 	}
 	herd.computedFilesManager.WriteHtml(writer)
 	var numAliveSubs, numCompliantSubs, numDeviantSubs uint64
 	var numLikelyCompliantSubs, numDisruptionWaitingSubs uint64
+	var numUnhealthySubs uint64
 	var reachableMinuteSubs, reachable10MinuteSubs, reachableHourSubs uint64
 	var reachableDaySubs, reachableWeekSubs, reachableMonthSubs uint64
 	var unreachableMinuteSubs, unreachable10MinuteSubs uint64
@@ -47,6 +47,7 @@ func (herd *Herd) writeHtml(writer io.Writer) {
 		{&numDeviantSubs, selectDeviantSub},
 		{&numLikelyCompliantSubs, selectLikelyCompliantSub},
 		{&numDisruptionWaitingSubs, selectDisruptionWaitingSub},
+		{&numUnhealthySubs, selectUnhealthySub},
 		{&reachableMinuteSubs, rDuration(time.Minute).selector},
 		{&reachable10MinuteSubs, rDuration(10 * time.Minute).selector},
 		{&reachableHourSubs, rDuration(time.Hour).selector},
@@ -137,6 +138,25 @@ func (herd *Herd) writeHtml(writer io.Writer) {
 	fmt.Fprintf(writer,
 		", <a href=\"showLikelyCompliantSubs\">%d</a>(likely)<br>\n",
 		numLikelyCompliantSubs)
+	if numUnhealthySubs > 0 {
+		fmt.Fprintf(writer,
+			"Number of unhealthy subs: <a href=\"showUnhealthySubs\">%d</a>",
+			numUnhealthySubs)
+		fmt.Fprintf(writer,
+			" (<a href=\"showUnhealthySubs?output=json\">JSON</a>")
+		fmt.Fprintf(writer,
+			", <a href=\"showUnhealthySubs?output=csv\">CSV</a>)<br>\n")
+	}
+	numDriftingSubs := len(herd.getSelectedSubs(selectDriftingSub))
+	if numDriftingSubs > 0 {
+		fmt.Fprintf(writer,
+			"Number of subs with drift: <a href=\"showDriftingSubs\">%d</a>",
+			numDriftingSubs)
+		fmt.Fprintf(writer,
+			" (<a href=\"showDriftingSubs?output=text\">text</a>")
+		fmt.Fprintf(writer,
+			", <a href=\"showDriftingSubs?output=json\">JSON</a>)<br>\n")
+	}
 	if numDisruptionWaitingSubs > 0 {
 		fmt.Fprintf(writer,
 			"Number of subs waiting to disrupt: <a href=\"showAllSubs?status=disruption%%20requested&status=disruption%%20denied\">%d</a>",
@@ -146,6 +166,27 @@ func (herd *Herd) writeHtml(writer io.Writer) {
 		fmt.Fprintf(writer,
 			", <a href=\"showAllSubs?status=disruption%%20requested&status=disruption%%20denied&output=csv\">CSV</a>)<br>\n")
 	}
+	herd.RLock()
+	numRollouts := len(herd.rollouts)
+	herd.RUnlock()
+	if numRollouts > 0 {
+		fmt.Fprintf(writer,
+			"Staged rollouts in progress: <a href=\"showRollouts\">%d</a>",
+			numRollouts)
+		fmt.Fprintf(writer,
+			" (<a href=\"showRollouts?output=json\">JSON</a>)<br>\n")
+	}
+	if numFreezes := len(herd.getUpdateFreezes()); numFreezes > 0 {
+		fmt.Fprintf(writer,
+			"Update freezes: <a href=\"showUpdateFreezes\">%d</a>",
+			numFreezes)
+		fmt.Fprintf(writer,
+			" (<a href=\"showUpdateFreezes?output=json\">JSON</a>)<br>\n")
+	}
+	if herd.updateJournal != nil {
+		fmt.Fprintln(writer,
+			"<a href=\"showUpdateHistory\">Update history</a><br>")
+	}
 	fmt.Fprintf(writer,
 		"Image status for subs: <a href=\"showImagesForSubs\">dashboard</a>")
 	fmt.Fprintf(writer,
@@ -175,20 +216,27 @@ func (herd *Herd) writeHtml(writer io.Writer) {
 		format.Duration(time.Since(stats.LastYieldEvent)))
 }
 
-func (herd *Herd) writeDisableStatus(writer io.Writer) {
-	timeString := ""
-	if !herd.updatesDisabledTime.IsZero() {
-		timeString = " at " + herd.updatesDisabledTime.Format(timeFormat)
-	}
-	if herd.updatesDisabledBy == "" {
-		fmt.Fprintf(writer,
-			"<font color=\"red\">Updates disabled %s</font>%s\n",
-			herd.updatesDisabledReason, timeString)
-	} else {
-		fmt.Fprintf(writer,
-			"<font color=\"red\">Updates disabled by: %s %s</font>%s",
-			herd.updatesDisabledBy, herd.updatesDisabledReason, timeString)
+// writeDisableStatus writes the freezes which cover the whole herd. It returns
+// true if anything was written.
+func (herd *Herd) writeDisableStatus(writer io.Writer) bool {
+	freezes := herd.getHerdWideUpdateFreezes()
+	for index, freeze := range freezes {
+		if index > 0 {
+			fmt.Fprintln(writer, "<br>")
+		}
+		fmt.Fprintf(writer, "<font color=\"red\">Updates disabled")
+		if freeze.CreatedBy != "" {
+			fmt.Fprintf(writer, " by: %s", freeze.CreatedBy)
+		}
+		fmt.Fprintf(writer, " because: %s</font> at %s",
+			freeze.Reason, freeze.CreatedAt.Format(timeFormat))
+		if !freeze.ExpiresAt.IsZero() {
+			fmt.Fprintf(writer, ", expires in %s",
+				format.Duration(time.Until(freeze.ExpiresAt)))
+		}
+		fmt.Fprintln(writer)
 	}
+	return len(freezes) > 0
 }
 
 func (herd *Herd) writeReachableSubsLink(writer io.Writer,
@@ -259,19 +307,28 @@ func selectDeviantSub(sub *Sub) bool {
 		return true
 	case statusUpdatesDisabled:
 		return true
+	case statusWaitingForRollout:
+		return true
 	case statusUpdating:
 		return true
 	case statusUpdateDenied:
 		return true
 	case statusFailedToUpdate:
 		return true
+	case statusRolledBack:
+		return true // Running the previous image, not the RequiredImage.
 	}
 	return false
 }
 
 func selectCompliantSub(sub *Sub) bool {
-	if sub.publishedStatus == statusSynced {
+	switch sub.publishedStatus {
+	case statusSynced:
 		return true
+	case statusRolledBack:
+		return false // Running the previous image, not the RequiredImage.
+	case statusUnhealthyAfterUpdate:
+		return false // Has the image, but failed verification.
 	}
 	return false
 }
@@ -286,6 +343,10 @@ func selectDisruptionWaitingSub(sub *Sub) bool {
 	return false
 }
 
+func selectUnhealthySub(sub *Sub) bool {
+	return sub.publishedStatus == statusUnhealthyAfterUpdate
+}
+
 func selectLikelyCompliantSub(sub *Sub) bool {
 	switch sub.publishedStatus {
 	case statusWaitingToPoll, statusPolling:
@@ -26,13 +26,19 @@ func (herd *Herd) startServer(portNum uint, daemon bool) error {
 		herd.makeShowSubsHandler(selectCompliantSub, "compliant "))
 	html.HandleFunc("/showLikelyCompliantSubs",
 		herd.makeShowSubsHandler(selectLikelyCompliantSub, "likely compliant "))
+	html.HandleFunc("/showDriftingSubs", herd.showDriftingSubsHandler)
 	html.HandleFunc("/showDeviantSubs",
 		herd.makeShowSubsHandler(selectDeviantSub, "deviant "))
 	html.HandleFunc("/showImagesForSubs",
 		html.BenchmarkedHandler(herd.showImagesForSubsHandler))
+	html.HandleFunc("/showRollouts", herd.showRolloutsHandler)
 	html.HandleFunc("/showReachableSubs", herd.showReachableSubsHandler)
+	html.HandleFunc("/showUnhealthySubs",
+		herd.makeShowSubsHandler(selectUnhealthySub, "unhealthy "))
 	html.HandleFunc("/showUnreachableSubs", herd.showUnreachableSubsHandler)
 	html.HandleFunc("/showSub", herd.showSubHandler)
+	html.HandleFunc("/showUpdateFreezes", herd.showUpdateFreezesHandler)
+	html.HandleFunc("/showUpdateHistory", herd.showUpdateHistoryHandler)
 	if daemon {
 		go http.Serve(listener, nil)
 	} else {
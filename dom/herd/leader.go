@@ -0,0 +1,48 @@
+package herd
+
+import (
+	"time"
+)
+
+// getLeader returns the address of the leader and true if this dominator is
+// the leader. If there is no elector, this dominator is always the leader.
+// Once updates are stopped, this dominator is no longer the leader.
+func (herd *Herd) getLeader() (string, bool) {
+	if herd.elector == nil {
+		return "", !herd.updatesStopped()
+	}
+	return herd.elector.GetLeader(),
+		herd.elector.IsLeader() && !herd.updatesStopped()
+}
+
+func (herd *Herd) isLeader() bool {
+	_, isLeader := herd.getLeader()
+	return isLeader
+}
+
+func (herd *Herd) updatesStopped() bool {
+	select {
+	case <-herd.stopChannel:
+		return true
+	default:
+		return false
+	}
+}
+
+func (herd *Herd) stopUpdates(timeout time.Duration) bool {
+	herd.Lock()
+	if !herd.updatesStopped() {
+		close(herd.stopChannel)
+	}
+	subs := make([]*Sub, 0, len(herd.subsByIndex))
+	subs = append(subs, herd.subsByIndex...)
+	herd.Unlock()
+	stopTime := time.Now().Add(timeout)
+	for _, sub := range subs {
+		// The subs are left busy so that nothing else is started.
+		if !sub.tryMakeBusyWithTimeout(time.Until(stopTime)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,29 @@
+package herd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStopUpdates(t *testing.T) {
+	herd := &Herd{stopChannel: make(chan struct{})}
+	sub := &Sub{herd: herd}
+	herd.subsByIndex = append(herd.subsByIndex, sub)
+	if !herd.isLeader() {
+		t.Fatal("not leader without elector")
+	}
+	sub.makeBusy()
+	if herd.stopUpdates(50 * time.Millisecond) {
+		t.Error("stopped updates while sub busy")
+	}
+	if herd.isLeader() {
+		t.Error("still leader after stopping updates")
+	}
+	sub.makeUnbusy()
+	if !herd.stopUpdates(time.Second) {
+		t.Error("timed out stopping updates")
+	}
+	if sub.tryMakeBusy() {
+		t.Error("sub not left busy")
+	}
+}
@@ -0,0 +1,54 @@
+package herd
+
+import (
+	"flag"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/format"
+	"github.com/Cloud-Foundations/Dominator/lib/maintenancewindow"
+	"github.com/Cloud-Foundations/Dominator/lib/timewindow"
+)
+
+var (
+	defaultMaintenanceWindow = flag.String("defaultMaintenanceWindow", "",
+		"Maintenance window for subs without the "+maintenancewindow.TagName+
+			" MDB tag. This should match the disruption-manager")
+)
+
+// getMaintenanceWindow returns the window when disruptive updates are
+// permitted for the sub, or nil if they are permitted at any time.
+func (sub *Sub) getMaintenanceWindow() *timewindow.Windows {
+	window, err := sub.herd.maintenanceWindows.Get(sub.mdb.Tags)
+	if err != nil {
+		sub.herd.logger.Debugf(0, "%s: error parsing %s tag: %s\n",
+			sub, maintenancewindow.TagName, err)
+	}
+	return window
+}
+
+// getNextMaintenanceWindow returns the maintenance window for the sub and the
+// start and end times of the current or next window. If there is no window,
+// nil is returned.
+func (sub *Sub) getNextMaintenanceWindow() (
+	*timewindow.Windows, time.Time, time.Time) {
+	window := sub.getMaintenanceWindow()
+	if window == nil {
+		return nil, time.Time{}, time.Time{}
+	}
+	start, end := window.Next(time.Now())
+	return window, start, end
+}
+
+// describeNextMaintenanceWindow returns a short description of when the next
+// maintenance window for the sub opens, or an empty string if there is no
+// window.
+func (sub *Sub) describeNextMaintenanceWindow() string {
+	window, start, end := sub.getNextMaintenanceWindow()
+	if window == nil {
+		return ""
+	}
+	if time.Until(start) <= 0 {
+		return "open, closes in " + format.Duration(time.Until(end))
+	}
+	return "opens in " + format.Duration(time.Until(start))
+}
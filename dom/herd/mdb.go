@@ -63,6 +63,7 @@ func (herd *Herd) mdbUpdateGetLock(mdb *mdb.Mdb) (
 	herd.subsByIndex = make([]*Sub, 0, len(mdb.Machines))
 	// Mark for delete all current subs, then later unmark ones in the new MDB.
 	subsToDelete := make(map[string]struct{})
+	var subsWithNewImage []*Sub
 	for _, sub := range herd.subsByName {
 		subsToDelete[sub.mdb.Hostname] = struct{}{}
 	}
@@ -84,19 +85,34 @@ func (herd *Herd) mdbUpdateGetLock(mdb *mdb.Mdb) (
 			sub.fileUpdateReceiver =
 				herd.computedFilesManager.AddAndGetReceiver(
 					filegenclient.Machine{machine, getComputedFiles(img)})
+			checkpoint := herd.takeCheckpoint(machine.Hostname)
+			if checkpoint != nil {
+				sub.restoreCheckpoint(checkpoint)
+			}
+			herd.notifySubWatchers(sub, nil, false)
 			numNew++
 		} else {
+			previousStatus := sub.status
+			requiredImageChanged := false
 			if sub.mdb.RequiredImage != machine.RequiredImage {
-				if sub.status == statusSynced {
+				if sub.status == statusSynced ||
+					sub.status == statusUnhealthyAfterUpdate {
 					sub.status = statusWaitingToPoll
 				}
+				sub.clearRollback()
+				requiredImageChanged = true
 			}
 			if !reflect.DeepEqual(sub.mdb, machine) {
+				previous := makeWatchedSub(sub.mdb, previousStatus)
 				sub.mdb = machine
+				if requiredImageChanged {
+					subsWithNewImage = append(subsWithNewImage, sub)
+				}
 				sub.generationCount = 0 // Force a full poll.
 				herd.computedFilesManager.Update(
 					filegenclient.Machine{machine, getComputedFiles(img)})
 				sub.sendCancel()
+				herd.notifySubWatchers(sub, previous, false)
 				numChanged++
 			}
 		}
@@ -109,6 +125,12 @@ func (herd *Herd) mdbUpdateGetLock(mdb *mdb.Mdb) (
 			sub.havePlannedImage = true
 		}
 	}
+	herd.addSubsToRollouts(subsWithNewImage)
+	if herd.checkpointRollouts != nil {
+		herd.restoreRollouts(herd.checkpointRollouts)
+		herd.checkpointRollouts = nil
+	}
+	herd.checkpoint = nil // Only applies to the subs in the first MDB update.
 	// Delete flagged subs (those not in the new MDB).
 	clientResourcesToDelete := make([]*srpc.ClientResource, 0)
 	for subHostname := range subsToDelete {
@@ -123,6 +145,9 @@ func (herd *Herd) mdbUpdateGetLock(mdb *mdb.Mdb) (
 		herd.computedFilesManager.Remove(subHostname)
 		delete(herd.subsByName, subHostname)
 		herd.eraseSubFromInstallerQueue(subHostname)
+		herd.removeSubFromRollouts(subHostname)
+		sub.removePeer()
+		herd.notifySubWatchers(sub, nil, true)
 		numDeleted++
 	}
 	mdbUpdateTimeDistribution.Add(time.Since(startTime))
@@ -0,0 +1,110 @@
+package herd
+
+import "math/rand"
+
+// peerKey identifies the subs which may serve the objects for an image to
+// other subs in a location.
+type peerKey struct {
+	location  string
+	imageName string
+}
+
+// isPeerCandidate returns true if the sub may serve objects for its last
+// successful image. The sub contents match that image even if it was rolled
+// back or is unhealthy after the update.
+func (sub *Sub) isPeerCandidate() bool {
+	if !sub.servesObjects || sub.mdb.Location == "" ||
+		sub.lastSuccessfulImageName == "" {
+		return false
+	}
+	switch sub.publishedStatus {
+	case statusSynced, statusRolledBack, statusUnhealthyAfterUpdate:
+		return true
+	}
+	return false
+}
+
+// removePeer removes the sub from the index of peers.
+func (sub *Sub) removePeer() {
+	sub.herd.peersLock.Lock()
+	defer sub.herd.peersLock.Unlock()
+	sub.removePeerWithLock()
+}
+
+// This must be called with the herd.peersLock held.
+func (sub *Sub) removePeerWithLock() {
+	if sub.peerKey == nil {
+		return
+	}
+	peers := sub.herd.peers[*sub.peerKey]
+	delete(peers, sub)
+	if len(peers) < 1 {
+		delete(sub.herd.peers, *sub.peerKey)
+	}
+	sub.peerKey = nil
+}
+
+// selectPeer returns the address of a random sub in the same location which
+// serves objects and is synced to the image, or "" if there is none.
+func (sub *Sub) selectPeer(imageName string) string {
+	if sub.mdb.Location == "" || imageName == "" {
+		return ""
+	}
+	herd := sub.herd
+	herd.peersLock.Lock()
+	defer herd.peersLock.Unlock()
+	peers := herd.peers[peerKey{sub.mdb.Location, imageName}]
+	numPeers := len(peers)
+	if _, ok := peers[sub]; ok {
+		numPeers--
+	}
+	if numPeers < 1 {
+		return ""
+	}
+	index := rand.Intn(numPeers)
+	for peer, address := range peers {
+		if peer == sub {
+			continue
+		}
+		if index < 1 {
+			return address
+		}
+		index--
+	}
+	return ""
+}
+
+// updatePeer updates the index of peers. It must be called by the sub
+// goroutine after the status changes, so that selecting a peer does not read
+// the fields of other subs.
+func (sub *Sub) updatePeer() {
+	if !sub.isPeerCandidate() {
+		sub.removePeer()
+		return
+	}
+	key := peerKey{sub.mdb.Location, sub.lastSuccessfulImageName}
+	address := sub.address()
+	herd := sub.herd
+	herd.peersLock.Lock()
+	defer herd.peersLock.Unlock()
+	if sub.peerKey != nil && *sub.peerKey == key &&
+		herd.peers[key][sub] == address {
+		return
+	}
+	sub.removePeerWithLock()
+	// Check with the lock held, since removePeer is called after the sub is
+	// flagged for deletion.
+	sub.deletingFlagMutex.Lock()
+	deleting := sub.deleting
+	sub.deletingFlagMutex.Unlock()
+	if deleting {
+		return
+	}
+	peers := herd.peers[key]
+	if peers == nil {
+		peers = make(map[*Sub]string)
+		herd.peers[key] = peers
+	}
+	peers[sub] = address
+	sub.peerKey = &key
+}
@@ -0,0 +1,58 @@
+package herd
+
+import (
+	"testing"
+
+	"github.com/Cloud-Foundations/Dominator/lib/mdb"
+)
+
+func makePeerTestSub(herd *Herd, hostname, location string) *Sub {
+	return &Sub{
+		herd:                    herd,
+		mdb:                     mdb.Machine{Hostname: hostname, Location: location},
+		lastSuccessfulImageName: "image",
+		publishedStatus:         statusSynced,
+		servesObjects:           true,
+	}
+}
+
+func TestSelectPeer(t *testing.T) {
+	herd := &Herd{peers: make(map[peerKey]map[*Sub]string)}
+	fetcher := makePeerTestSub(herd, "fetcher", "dc1")
+	fetcher.lastSuccessfulImageName = "old-image"
+	peer := makePeerTestSub(herd, "peer", "dc1")
+	remote := makePeerTestSub(herd, "remote", "dc2")
+	for _, sub := range []*Sub{fetcher, peer, remote} {
+		sub.updatePeer()
+	}
+	if address := fetcher.selectPeer("image"); address != peer.address() {
+		t.Errorf("expected peer: %s, got: %s", peer.address(), address)
+	}
+	if address := peer.selectPeer("image"); address != "" {
+		t.Errorf("selected self or remote peer: %s", address)
+	}
+	if address := peer.selectPeer("old-image"); address != fetcher.address() {
+		t.Errorf("expected peer: %s, got: %s", fetcher.address(), address)
+	}
+	peer.publishedStatus = statusUnhealthyAfterUpdate
+	peer.updatePeer()
+	if address := fetcher.selectPeer("image"); address != peer.address() {
+		t.Errorf("unhealthy peer not selected: %s", address)
+	}
+	peer.publishedStatus = statusFailedToPoll
+	peer.updatePeer()
+	if address := fetcher.selectPeer("image"); address != "" {
+		t.Errorf("unavailable peer selected: %s", address)
+	}
+	peer.publishedStatus = statusSynced
+	peer.deleting = true
+	peer.updatePeer()
+	if address := fetcher.selectPeer("image"); address != "" {
+		t.Errorf("deleted peer selected: %s", address)
+	}
+	fetcher.removePeer()
+	remote.removePeer()
+	if len(herd.peers) > 0 {
+		t.Errorf("peers not removed: %v", herd.peers)
+	}
+}
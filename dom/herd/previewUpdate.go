@@ -0,0 +1,162 @@
+package herd
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/dom/lib"
+	"github.com/Cloud-Foundations/Dominator/lib/filesystem"
+	"github.com/Cloud-Foundations/Dominator/lib/image"
+	"github.com/Cloud-Foundations/Dominator/lib/objectcache"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	"github.com/Cloud-Foundations/Dominator/lib/triggers"
+	domproto "github.com/Cloud-Foundations/Dominator/proto/dominator"
+	subproto "github.com/Cloud-Foundations/Dominator/proto/sub"
+)
+
+func (herd *Herd) previewUpdate(request domproto.PreviewUpdateRequest,
+	authInfo *srpc.AuthInformation) (domproto.PreviewUpdateResponse, error) {
+	if request.Timeout < time.Millisecond {
+		request.Timeout = time.Minute
+	}
+	sub := herd.getSub(request.Hostname)
+	if sub == nil {
+		return domproto.PreviewUpdateResponse{},
+			errors.New("unknown sub: " + request.Hostname)
+	}
+	return sub.previewUpdate(request, authInfo)
+}
+
+// matchTriggers returns the triggers which would be matched by the update.
+// A private copy of the triggers is used so that the matching state of the
+// image triggers is not disturbed.
+func matchTriggers(imageTriggers *triggers.Triggers,
+	request subproto.UpdateRequest) []*triggers.Trigger {
+	if imageTriggers == nil || len(imageTriggers.Triggers) < 1 {
+		return nil
+	}
+	trigs := triggers.New()
+	for _, trigger := range imageTriggers.Triggers {
+		trigger := *trigger
+		trigs.Triggers = append(trigs.Triggers, &trigger)
+	}
+	for _, inode := range request.DirectoriesToMake {
+		trigs.Match(inode.Name)
+	}
+	for _, inode := range request.InodesToMake {
+		trigs.Match(inode.Name)
+	}
+	for _, hardlink := range request.HardlinksToMake {
+		trigs.Match(hardlink.NewLink)
+	}
+	for _, pathname := range request.PathsToDelete {
+		trigs.Match(pathname)
+	}
+	for _, inode := range request.InodesToChange {
+		trigs.Match(inode.Name)
+	}
+	return trigs.GetMatchedTriggers()
+}
+
+// tryMakeBusyWithTimeout waits until it makes the sub busy or the timeout
+// expires. It returns true if it made the sub busy.
+func (sub *Sub) tryMakeBusyWithTimeout(timeout time.Duration) bool {
+	stopTime := time.Now().Add(timeout)
+	for ; time.Until(stopTime) > 0; time.Sleep(100 * time.Millisecond) {
+		if sub.tryMakeBusy() {
+			return true
+		}
+	}
+	return false
+}
+
+func (sub *Sub) previewUpdate(request domproto.PreviewUpdateRequest,
+	authInfo *srpc.AuthInformation) (domproto.PreviewUpdateResponse, error) {
+	var response domproto.PreviewUpdateResponse
+	if !sub.checkAdminAccess(authInfo) {
+		return response, errors.New("no access to sub")
+	}
+	if !sub.tryMakeBusyWithTimeout(request.Timeout) {
+		return response, errors.New("timed out waiting for sub to not be busy")
+	}
+	defer sub.makeUnbusy()
+	var imageName string
+	var img *image.Image
+	if request.UsePlannedImage {
+		imageName = sub.plannedImageName
+		img = sub.plannedImage
+	} else {
+		imageName = sub.requiredImageName
+		img = sub.requiredImage
+	}
+	if imageName == "" {
+		return response, errors.New("no image specified")
+	}
+	if img == nil {
+		return response, fmt.Errorf("image: %s not available", imageName)
+	}
+	// Use the state from the last poll rather than polling again, which
+	// would fetch the whole file-system and hold up normal polling.
+	fs := sub.fileSystem
+	if fs == nil {
+		return response, errors.New("no poll data for sub")
+	}
+	sub.herd.cpuSharer.GrabCpu()
+	defer sub.herd.cpuSharer.ReleaseCpu()
+	subObj := lib.Sub{
+		Hostname:       sub.mdb.Hostname,
+		FileSystem:     fs,
+		ComputedInodes: sub.computedInodes,
+		ObjectCache:    sub.objectCache,
+		ObjectGetter:   sub.herd.objectServer,
+	}
+	objectsToFetch, objectsToPush := lib.BuildMissingLists(subObj, img, true,
+		request.UsePlannedImage, sub.herd.logger)
+	if objectsToPush == nil {
+		return response, errors.New("missing computed file(s)")
+	}
+	// Compute the update as if the missing objects had already been fetched
+	// and pushed.
+	response.NumObjectsToFetch = uint(len(objectsToFetch))
+	for hashVal := range objectsToPush {
+		objectsToFetch[hashVal] = 0
+	}
+	objectCache := make(objectcache.ObjectCache, 0,
+		len(sub.objectCache)+len(objectsToFetch))
+	objectCache = append(objectCache, sub.objectCache...)
+	objectCache = append(objectCache,
+		objectcache.ObjectMapToCache(objectsToFetch)...)
+	updateRequest, idle, missing := sub.buildUpdateRequest(fs, objectCache,
+		imageName, img, request.UsePlannedImage)
+	if missing {
+		return response, errors.New("missing computed file(s)")
+	}
+	response.ImageName = imageName
+	response.MatchedTriggers = matchTriggers(img.Triggers, updateRequest)
+	response.UpdateNeeded = !idle
+	response.UpdateRequest = updateRequest
+	computedFiles := getComputedFiles(img)
+	if len(computedFiles) > 0 {
+		response.ComputedFiles = make(map[string]*filesystem.RegularInode,
+			len(computedFiles))
+		for _, computedFile := range computedFiles {
+			response.ComputedFiles[computedFile.Pathname] =
+				sub.computedInodes[computedFile.Pathname]
+		}
+	}
+	if response.UpdateNeeded {
+		if reason := sub.getUpdatesDisabledReason(); reason != "" {
+			response.BlockedBy = subStatus(statusUpdatesDisabled).String() +
+				": " + reason
+		} else if !request.UsePlannedImage &&
+			!sub.herd.checkRolloutPermitsUpdate(sub) {
+			response.BlockedBy = subStatus(statusWaitingForRollout).String()
+		} else if reason := sub.herd.checkForUnsafeChange(sub.mdb, img, fs,
+			updateRequest); reason != "" {
+			response.BlockedBy = subStatus(statusUnsafeUpdate).String() +
+				": " + reason
+		}
+	}
+	return response, nil
+}
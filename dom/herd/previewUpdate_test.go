@@ -0,0 +1,208 @@
+package herd
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/cpusharer"
+	"github.com/Cloud-Foundations/Dominator/lib/filesystem"
+	"github.com/Cloud-Foundations/Dominator/lib/filter"
+	"github.com/Cloud-Foundations/Dominator/lib/hash"
+	"github.com/Cloud-Foundations/Dominator/lib/image"
+	"github.com/Cloud-Foundations/Dominator/lib/log/testlogger"
+	"github.com/Cloud-Foundations/Dominator/lib/mdb"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	"github.com/Cloud-Foundations/Dominator/lib/tags"
+	"github.com/Cloud-Foundations/Dominator/lib/triggers"
+	domproto "github.com/Cloud-Foundations/Dominator/proto/dominator"
+	subproto "github.com/Cloud-Foundations/Dominator/proto/sub"
+)
+
+var (
+	previewHash0 = hash.Hash{0x01}
+	previewHash1 = hash.Hash{0x02}
+)
+
+func makePreviewTestTriggers() *triggers.Triggers {
+	trigs := triggers.New()
+	trigs.Triggers = []*triggers.Trigger{
+		{MatchLines: []string{"/etc/.*"}, Service: "etc"},
+		{MatchLines: []string{"/file0"}, Service: "file0"},
+		{MatchLines: []string{"/file1"}, Service: "file1"},
+		{MatchLines: []string{"/unused"}, Service: "unused"},
+	}
+	return trigs
+}
+
+func makePreviewTestFileSystem(name string, size uint64,
+	hashVal hash.Hash) *filesystem.FileSystem {
+	fs := &filesystem.FileSystem{
+		InodeTable: filesystem.InodeTable{
+			1: &filesystem.RegularInode{Size: size, Hash: hashVal},
+		},
+		DirectoryInode: filesystem.DirectoryInode{
+			EntryList: []*filesystem.DirectoryEntry{
+				{Name: name, InodeNumber: 1},
+			},
+		},
+	}
+	if err := fs.RebuildInodePointers(); err != nil {
+		panic(err)
+	}
+	fs.BuildEntryMap()
+	return fs
+}
+
+func makePreviewTestSub(t *testing.T) (*Sub, *image.Image) {
+	policy, err := loadSafetyPolicy("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	herd := &Herd{
+		cpuSharer:    cpusharer.NewFifoCpuSharer(),
+		logger:       testlogger.New(t),
+		rollouts:     make(map[string]*rollout),
+		safetyPolicy: policy,
+		subsByName:   make(map[string]*Sub),
+	}
+	emptyFilter, _ := filter.New(nil)
+	img := &image.Image{
+		FileSystem: makePreviewTestFileSystem("file1", 101, previewHash1),
+		Filter:     emptyFilter,
+		Triggers:   makePreviewTestTriggers(),
+	}
+	sub := &Sub{
+		herd: herd,
+		mdb: mdb.Machine{
+			Hostname:      "host0",
+			RequiredImage: "image",
+		},
+		fileSystem: makePreviewTestFileSystem("file0", 100,
+			previewHash0),
+		requiredImage:     img,
+		requiredImageName: "image",
+	}
+	herd.subsByName[sub.mdb.Hostname] = sub
+	return sub, img
+}
+
+func getTriggerServices(trigs []*triggers.Trigger) map[string]struct{} {
+	services := make(map[string]struct{}, len(trigs))
+	for _, trigger := range trigs {
+		services[trigger.Service] = struct{}{}
+	}
+	return services
+}
+
+func TestMatchTriggers(t *testing.T) {
+	imageTriggers := makePreviewTestTriggers()
+	request := subproto.UpdateRequest{
+		DirectoriesToMake: []subproto.Inode{{Name: "/etc/dir"}},
+		PathsToDelete:     []string{"/file0"},
+	}
+	services := getTriggerServices(matchTriggers(imageTriggers, request))
+	for _, service := range []string{"etc", "file0"} {
+		if _, ok := services[service]; !ok {
+			t.Errorf("trigger for: %s not matched", service)
+		}
+	}
+	if len(services) != 2 {
+		t.Errorf("expected 2 matched triggers, got: %v", services)
+	}
+	if matched := imageTriggers.GetMatchedTriggers(); len(matched) > 0 {
+		t.Errorf("image triggers were modified: %d matched", len(matched))
+	}
+	request = subproto.UpdateRequest{
+		HardlinksToMake: []subproto.Hardlink{{NewLink: "/file1"}},
+		InodesToChange:  []subproto.Inode{{Name: "/etc/file"}},
+	}
+	services = getTriggerServices(matchTriggers(imageTriggers, request))
+	if len(services) != 2 {
+		t.Errorf("expected 2 matched triggers, got: %v", services)
+	}
+	if matched := matchTriggers(nil, request); matched != nil {
+		t.Errorf("matched triggers without image triggers: %v", matched)
+	}
+}
+
+func TestPreviewUpdate(t *testing.T) {
+	sub, img := makePreviewTestSub(t)
+	sub.pendingForceDisruptiveUpdate = true
+	authInfo := &srpc.AuthInformation{HaveMethodAccess: true}
+	request := domproto.PreviewUpdateRequest{
+		Hostname: "host0",
+		Timeout:  time.Second,
+	}
+	if _, err := sub.previewUpdate(request, nil); err == nil {
+		t.Error("preview without access did not fail")
+	}
+	response, err := sub.herd.previewUpdate(request, authInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !response.UpdateNeeded {
+		t.Error("update not needed")
+	}
+	if response.NumObjectsToFetch != 1 {
+		t.Errorf("expected 1 object to fetch, got: %d",
+			response.NumObjectsToFetch)
+	}
+	if response.UpdateRequest.Triggers != img.Triggers {
+		t.Error("image triggers not included in update request")
+	}
+	if !response.UpdateRequest.ForceDisruption {
+		t.Error("pending disruptive update not included in update request")
+	}
+	services := getTriggerServices(response.MatchedTriggers)
+	if len(services) != 2 {
+		t.Errorf("expected 2 matched triggers, got: %v", services)
+	}
+	for _, service := range []string{"file0", "file1"} {
+		if _, ok := services[service]; !ok {
+			t.Errorf("trigger for: %s not matched", service)
+		}
+	}
+	if !strings.HasPrefix(response.BlockedBy,
+		subStatus(statusUnsafeUpdate).String()) {
+		t.Errorf("update not blocked as unsafe: %s", response.BlockedBy)
+	}
+	sub.mdb.Tags = tags.Tags{"DisableSafetyCheck": ""}
+	response, err = sub.herd.previewUpdate(request, authInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.BlockedBy != "" {
+		t.Errorf("update blocked by: %s", response.BlockedBy)
+	}
+	if !sub.tryMakeBusy() {
+		t.Error("sub left busy")
+	}
+	sub.makeUnbusy()
+}
+
+func TestPreviewUpdateIdle(t *testing.T) {
+	sub, img := makePreviewTestSub(t)
+	sub.fileSystem = img.FileSystem
+	sub.lastSuccessfulImageName = "image"
+	response, err := sub.previewUpdate(
+		domproto.PreviewUpdateRequest{Timeout: time.Second},
+		&srpc.AuthInformation{HaveMethodAccess: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.UpdateNeeded {
+		t.Errorf("update needed: %v", response.UpdateRequest)
+	}
+}
+
+func TestPreviewUpdateNoPollData(t *testing.T) {
+	sub, _ := makePreviewTestSub(t)
+	sub.fileSystem = nil
+	_, err := sub.previewUpdate(
+		domproto.PreviewUpdateRequest{Timeout: time.Second},
+		&srpc.AuthInformation{HaveMethodAccess: true})
+	if err == nil {
+		t.Error("preview without poll data did not fail")
+	}
+}
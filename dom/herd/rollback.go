@@ -0,0 +1,183 @@
+package herd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/format"
+	subproto "github.com/Cloud-Foundations/Dominator/proto/sub"
+)
+
+const defaultRollbackCriteria = "TriggerFailures"
+
+// parseRollbackCriteria parses the value of the AutoRollback MDB tag. The
+// value is a comma-separated list of criteria:
+//
+//	HealthProbeFailures:    roll back if any health probes failed
+//	TriggerFailures:        roll back if any triggers failed
+//	Unreachable=<duration>: roll back if unreachable for the duration
+func parseRollbackCriteria(value string) (rollbackCriteria, error) {
+	var criteria rollbackCriteria
+	if value == "" {
+		value = defaultRollbackCriteria
+	}
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		name, arg, haveArg := strings.Cut(field, "=")
+		switch {
+		case strings.EqualFold(name, "HealthProbeFailures") && !haveArg:
+			criteria.healthProbeFailures = true
+		case strings.EqualFold(name, "TriggerFailures") && !haveArg:
+			criteria.triggerFailures = true
+		case strings.EqualFold(name, "Unreachable") && haveArg:
+			timeout, err := time.ParseDuration(arg)
+			if err != nil {
+				return rollbackCriteria{}, err
+			}
+			if timeout <= 0 {
+				return rollbackCriteria{},
+					fmt.Errorf("bad Unreachable duration: %s", arg)
+			}
+			criteria.unreachableTimeout = timeout
+		default:
+			return rollbackCriteria{}, fmt.Errorf("bad criterion: %s", field)
+		}
+	}
+	return criteria, nil
+}
+
+// getRollbackCriteria returns the rollback criteria for the sub and true if
+// automatic rollback is enabled for the sub.
+func (sub *Sub) getRollbackCriteria() (rollbackCriteria, bool) {
+	value, ok := sub.mdb.Tags["AutoRollback"]
+	if !ok {
+		return rollbackCriteria{}, false
+	}
+	criteria, err := parseRollbackCriteria(value)
+	if err != nil {
+		sub.herd.logger.Printf("%s: error parsing AutoRollback tag: %s\n",
+			sub, err)
+		return rollbackCriteria{}, false
+	}
+	return criteria, true
+}
+
+// clearRollback forgets that the sub was rolled back, so that the next update
+// retries the RequiredImage. It returns true if the sub was rolled back.
+func (sub *Sub) clearRollback() bool {
+	if sub.rolledBack == nil {
+		return false
+	}
+	sub.rolledBack = nil
+	if sub.status == statusRolledBack {
+		sub.status = statusWaitingToPoll
+	}
+	sub.generationCount = 0 // Force a full poll.
+	return true
+}
+
+// getRequiredImageName returns the RequiredImage from the MDB data, unless the
+// sub has been rolled back from that image.
+func (sub *Sub) getRequiredImageName() string {
+	requiredImageName := sub.mdb.RequiredImage
+	if rolledBack := sub.rolledBack; rolledBack != nil &&
+		rolledBack.failedImageName == requiredImageName {
+		return rolledBack.imageName
+	}
+	return requiredImageName
+}
+
+// armRollback records the last successful image of the sub before an update
+// to a different image, so that the sub can be rolled back to it later.
+func (sub *Sub) armRollback() {
+	if sub.rolledBack != nil || sub.rollbackImageName != "" {
+		return
+	}
+	if sub.mdb.RequiredImage == "" || sub.lastSuccessfulImageName == "" ||
+		sub.lastSuccessfulImageName == sub.requiredImageName {
+		return
+	}
+	if _, ok := sub.getRollbackCriteria(); ok {
+		sub.rollbackImageName = sub.lastSuccessfulImageName
+	}
+}
+
+// checkUpdateForRollback rolls back the sub if the update which just completed
+// meets the rollback criteria.
+func (sub *Sub) checkUpdateForRollback(reply subproto.PollResponse) {
+	if sub.rollbackImageName == "" {
+		return
+	}
+	criteria, ok := sub.getRollbackCriteria()
+	if !ok {
+		sub.rollbackImageName = ""
+		return
+	}
+	if criteria.triggerFailures && reply.LastUpdateHadTriggerFailures {
+		sub.rollBack("trigger failures")
+	} else if criteria.healthProbeFailures && sub.lastUpdateWasUnhealthy() {
+		sub.rollBack("health probe failures")
+	}
+}
+
+// checkUnreachableForRollback rolls back the sub if it has been unreachable
+// for too long since the last update.
+func (sub *Sub) checkUnreachableForRollback() {
+	if sub.rollbackImageName == "" {
+		return
+	}
+	criteria, ok := sub.getRollbackCriteria()
+	if !ok {
+		sub.rollbackImageName = ""
+		return
+	}
+	timeout := criteria.unreachableTimeout
+	if timeout <= 0 {
+		return
+	}
+	if time.Since(sub.lastReachableTime) >= timeout &&
+		time.Since(sub.lastUpdateTime) >= timeout {
+		sub.rollBack("unreachable for " +
+			format.Duration(time.Since(sub.lastReachableTime)))
+	}
+}
+
+// lastUpdateWasUnhealthy returns true if any health probes failed after the
+// last update.
+func (sub *Sub) lastUpdateWasUnhealthy() bool {
+	for _, result := range sub.lastUpdateHealthProbeResults {
+		if result.Error != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func (sub *Sub) rollBack(reason string) {
+	imageName := sub.rollbackImageName
+	sub.rollbackImageName = ""
+	sub.herd.cpuSharer.ReleaseCpu()
+	img := sub.herd.imageManager.GetNoError(imageName)
+	sub.herd.cpuSharer.GrabCpu()
+	if img == nil {
+		sub.herd.logger.Printf(
+			"Cannot roll back %s to: %s (%s): image not available\n",
+			sub, imageName, reason)
+		return
+	}
+	sub.herd.logger.Printf("Rolling back %s from: %s to: %s (%s)\n",
+		sub, sub.mdb.RequiredImage, imageName, reason)
+	sub.rolledBack = &rollbackState{
+		failedImageName: sub.mdb.RequiredImage,
+		imageName:       imageName,
+		reason:          reason,
+		time:            time.Now(),
+	}
+	sub.generationCount = 0 // Force a full poll.
+}
+
+func (state *rollbackState) string() string {
+	return fmt.Sprintf("from %s to %s %s ago: %s", state.failedImageName,
+		state.imageName, format.Duration(time.Since(state.time)), state.reason)
+}
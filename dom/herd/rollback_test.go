@@ -0,0 +1,78 @@
+package herd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/mdb"
+)
+
+func TestParseRollbackCriteria(t *testing.T) {
+	criteria, err := parseRollbackCriteria("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !criteria.triggerFailures || criteria.unreachableTimeout != 0 {
+		t.Errorf("bad default criteria: %+v", criteria)
+	}
+	criteria, err = parseRollbackCriteria("Unreachable=10m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if criteria.triggerFailures ||
+		criteria.unreachableTimeout != 10*time.Minute {
+		t.Errorf("bad criteria: %+v", criteria)
+	}
+	criteria, err = parseRollbackCriteria("triggerfailures, Unreachable=1h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !criteria.triggerFailures || criteria.unreachableTimeout != time.Hour {
+		t.Errorf("bad criteria: %+v", criteria)
+	}
+	criteria, err = parseRollbackCriteria("HealthProbeFailures")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !criteria.healthProbeFailures || criteria.triggerFailures {
+		t.Errorf("bad criteria: %+v", criteria)
+	}
+	for _, value := range []string{"Unreachable", "Unreachable=0s",
+		"TriggerFailures=1", "Sometimes"} {
+		if _, err := parseRollbackCriteria(value); err == nil {
+			t.Errorf("%s did not fail", value)
+		}
+	}
+}
+
+func TestClearRollback(t *testing.T) {
+	sub := &Sub{
+		generationCount: 10,
+		mdb:             mdb.Machine{RequiredImage: "new"},
+		rolledBack: &rollbackState{
+			failedImageName: "new",
+			imageName:       "old",
+		},
+		status: statusRolledBack,
+	}
+	if name := sub.getRequiredImageName(); name != "old" {
+		t.Errorf("required image: %s, expected: old", name)
+	}
+	sub.publishedStatus = sub.status
+	if selectCompliantSub(sub) || !selectDeviantSub(sub) {
+		t.Error("rolled back sub is compliant")
+	}
+	if !sub.clearRollback() {
+		t.Fatal("rollback not cleared")
+	}
+	if sub.status != statusWaitingToPoll || sub.generationCount != 0 {
+		t.Errorf("status: %s, generation: %d after clearing",
+			sub.status, sub.generationCount)
+	}
+	if name := sub.getRequiredImageName(); name != "new" {
+		t.Errorf("required image: %s, expected: new", name)
+	}
+	if sub.clearRollback() {
+		t.Error("rollback cleared twice")
+	}
+}
@@ -0,0 +1,558 @@
+package herd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/json"
+	"github.com/Cloud-Foundations/Dominator/lib/mdb"
+	proto "github.com/Cloud-Foundations/Dominator/proto/dominator"
+)
+
+const (
+	defaultMinimumSuccessPercent = 95
+	rolloutCheckInterval         = 10 * time.Second
+)
+
+var (
+	rolloutConfigFile = flag.String("rolloutConfigFile", "",
+		"Name of file containing the default staged rollout policy")
+	rolloutWaveTimeout = flag.Duration("rolloutWaveTimeout", 24*time.Hour,
+		"Maximum time for a staged rollout wave to succeed (0: no limit)")
+)
+
+type rolloutConfigType struct { // JSON file format.
+	MinimumSuccessPercent uint
+	Waves                 []rolloutWaveConfigType
+}
+
+type rolloutWaveConfigType struct {
+	Percent  uint
+	SoakTime string // Parsed with time.ParseDuration().
+}
+
+// loadRolloutPolicy reads the default rollout policy from a JSON file.
+func loadRolloutPolicy(filename string) (*rolloutPolicy, error) {
+	var config rolloutConfigType
+	if err := json.ReadFromFile(filename, &config); err != nil {
+		return nil, err
+	}
+	policy := &rolloutPolicy{
+		minimumSuccessPercent: config.MinimumSuccessPercent,
+	}
+	for _, waveConfig := range config.Waves {
+		wave := rolloutWave{percent: waveConfig.Percent}
+		if waveConfig.SoakTime != "" {
+			soakTime, err := time.ParseDuration(waveConfig.SoakTime)
+			if err != nil {
+				return nil, err
+			}
+			wave.soakTime = soakTime
+		}
+		policy.waves = append(policy.waves, wave)
+	}
+	if err := policy.normalise(); err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	return policy, nil
+}
+
+// parseRolloutWaves parses a wave specification of the form
+// "percent[:soakTime],...", such as "1:30m,10:1h,100".
+func parseRolloutWaves(value string) ([]rolloutWave, error) {
+	var waves []rolloutWave
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		var wave rolloutWave
+		splitField := strings.SplitN(field, ":", 2)
+		percent, err := strconv.ParseUint(
+			strings.TrimSuffix(splitField[0], "%"), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("bad wave percentage: %s", splitField[0])
+		}
+		wave.percent = uint(percent)
+		if len(splitField) > 1 {
+			wave.soakTime, err = time.ParseDuration(splitField[1])
+			if err != nil {
+				return nil, err
+			}
+		}
+		waves = append(waves, wave)
+	}
+	return waves, nil
+}
+
+// normalise checks the policy, fills in defaults and ensures the final wave
+// covers all subs.
+func (policy *rolloutPolicy) normalise() error {
+	if policy.minimumSuccessPercent < 1 {
+		policy.minimumSuccessPercent = defaultMinimumSuccessPercent
+	} else if policy.minimumSuccessPercent > 100 {
+		return errors.New("minimum success percentage exceeds 100")
+	}
+	if len(policy.waves) < 1 {
+		return errors.New("no rollout waves specified")
+	}
+	var lastPercent uint
+	for _, wave := range policy.waves {
+		if wave.percent <= lastPercent {
+			return errors.New("rollout wave percentages must increase")
+		}
+		if wave.percent > 100 {
+			return errors.New("rollout wave percentage exceeds 100")
+		}
+		lastPercent = wave.percent
+	}
+	if lastPercent < 100 {
+		policy.waves = append(policy.waves, rolloutWave{percent: 100})
+	}
+	return nil
+}
+
+// getRolloutPolicy returns the rollout policy for the machine. The
+// RolloutWaves and RolloutMinimumSuccessPercent MDB tags take precedence over
+// the default policy. If nil is returned, no staged rollout is performed.
+func (herd *Herd) getRolloutPolicy(machine mdb.Machine) *rolloutPolicy {
+	value, ok := machine.Tags["RolloutWaves"]
+	if !ok {
+		return herd.rolloutPolicy
+	}
+	if value == "" || strings.EqualFold(value, "none") {
+		return nil
+	}
+	waves, err := parseRolloutWaves(value)
+	if err != nil {
+		herd.logger.Printf("%s: error parsing RolloutWaves tag: %s\n",
+			machine.Hostname, err)
+		return herd.rolloutPolicy
+	}
+	policy := &rolloutPolicy{waves: waves}
+	if value := machine.Tags["RolloutMinimumSuccessPercent"]; value != "" {
+		percent, err := strconv.ParseUint(strings.TrimSuffix(value, "%"), 10,
+			32)
+		if err != nil {
+			herd.logger.Printf(
+				"%s: error parsing RolloutMinimumSuccessPercent tag: %s\n",
+				machine.Hostname, err)
+			return herd.rolloutPolicy
+		}
+		policy.minimumSuccessPercent = uint(percent)
+	}
+	if err := policy.normalise(); err != nil {
+		herd.logger.Printf("%s: bad rollout policy: %s\n",
+			machine.Hostname, err)
+		return herd.rolloutPolicy
+	}
+	return policy
+}
+
+// addSubsToRollouts adds subs whose RequiredImage has changed to the rollout
+// for their new image. The herd lock must be held.
+func (herd *Herd) addSubsToRollouts(subs []*Sub) {
+	if len(subs) < 1 {
+		return
+	}
+	// Shuffle subs deterministically so that the early waves are not biased
+	// towards a particular part of the namespace.
+	sort.Slice(subs, func(left, right int) bool {
+		return hashHostname(subs[left].mdb.Hostname) <
+			hashHostname(subs[right].mdb.Hostname)
+	})
+	for _, sub := range subs {
+		herd.removeSubFromRollouts(sub.mdb.Hostname)
+		if sub.mdb.RequiredImage == "" {
+			continue
+		}
+		policy := herd.getRolloutPolicy(sub.mdb)
+		if policy == nil {
+			continue
+		}
+		r := herd.rollouts[sub.mdb.RequiredImage]
+		if r == nil {
+			r = newRollout(sub.mdb.RequiredImage, *policy)
+			herd.rollouts[sub.mdb.RequiredImage] = r
+			herd.logger.Printf("Starting staged rollout of image: %s\n",
+				r.imageName)
+		}
+		r.addMember(sub.mdb.Hostname)
+	}
+}
+
+// removeSubFromRollouts removes the sub from all rollouts. The herd lock must
+// be held.
+func (herd *Herd) removeSubFromRollouts(hostname string) {
+	for _, r := range herd.rollouts {
+		r.removeMember(hostname)
+	}
+}
+
+// checkRolloutPermitsUpdate returns true if the sub may be updated to its
+// required image. Rollouts are keyed by the RequiredImage in the MDB, which a
+// sub which was rolled back is still a member of.
+func (herd *Herd) checkRolloutPermitsUpdate(sub *Sub) bool {
+	herd.RLock()
+	r := herd.rollouts[sub.mdb.RequiredImage]
+	herd.RUnlock()
+	if r == nil {
+		return true
+	}
+	return r.permitsUpdate(sub.mdb.Hostname)
+}
+
+func (herd *Herd) getRolloutWave(sub *Sub) (*rollout, uint, bool) {
+	herd.RLock()
+	r := herd.rollouts[sub.mdb.RequiredImage]
+	herd.RUnlock()
+	if r == nil {
+		return nil, 0, false
+	}
+	r.Lock()
+	defer r.Unlock()
+	wave, ok := r.members[sub.mdb.Hostname]
+	return r, wave, ok
+}
+
+func (herd *Herd) getRollouts() []proto.RolloutInfo {
+	herd.RLock()
+	rollouts := make([]*rollout, 0, len(herd.rollouts))
+	for _, r := range herd.rollouts {
+		rollouts = append(rollouts, r)
+	}
+	herd.RUnlock()
+	sort.Slice(rollouts, func(left, right int) bool {
+		return rollouts[left].imageName < rollouts[right].imageName
+	})
+	rolloutInfos := make([]proto.RolloutInfo, 0, len(rollouts))
+	for _, r := range rollouts {
+		rolloutInfos = append(rolloutInfos, r.getInfo(herd))
+	}
+	return rolloutInfos
+}
+
+func (herd *Herd) rolloutLoop() {
+	for range time.Tick(rolloutCheckInterval) {
+		herd.RLock()
+		rollouts := make([]*rollout, 0, len(herd.rollouts))
+		for _, r := range herd.rollouts {
+			rollouts = append(rollouts, r)
+		}
+		herd.RUnlock()
+		for _, r := range rollouts {
+			if !r.advance(herd) {
+				continue
+			}
+			herd.Lock()
+			if herd.rollouts[r.imageName] == r {
+				delete(herd.rollouts, r.imageName)
+			}
+			herd.Unlock()
+			herd.logger.Printf("Completed staged rollout of image: %s\n",
+				r.imageName)
+		}
+	}
+}
+
+func hashHostname(hostname string) uint64 {
+	hasher := fnv.New64a()
+	hasher.Write([]byte(hostname))
+	return hasher.Sum64()
+}
+
+func newRollout(imageName string, policy rolloutPolicy) *rollout {
+	r := &rollout{
+		imageName:      imageName,
+		policy:         policy,
+		startTime:      time.Now(),
+		members:        make(map[string]uint),
+		waveSizes:      make([]uint, len(policy.waves)),
+		waveStartTimes: make([]time.Time, len(policy.waves)),
+		waveReadyTimes: make([]time.Time, len(policy.waves)),
+	}
+	r.waveStartTimes[0] = r.startTime
+	return r
+}
+
+// addMember assigns the sub to the earliest wave which has not yet reached its
+// quota. The rollout lock is grabbed.
+func (r *rollout) addMember(hostname string) {
+	r.Lock()
+	defer r.Unlock()
+	if _, ok := r.members[hostname]; ok {
+		return
+	}
+	numMembers := uint(len(r.members)) + 1
+	lastWave := uint(len(r.policy.waves)) - 1
+	var numInWaves uint
+	for index, wave := range r.policy.waves {
+		numInWaves += r.waveSizes[index]
+		quota := (wave.percent*numMembers + 99) / 100
+		if numInWaves < quota || uint(index) == lastWave {
+			r.members[hostname] = uint(index)
+			r.waveSizes[index]++
+			return
+		}
+	}
+}
+
+func (r *rollout) removeMember(hostname string) {
+	r.Lock()
+	defer r.Unlock()
+	if wave, ok := r.members[hostname]; ok {
+		delete(r.members, hostname)
+		r.waveSizes[wave]--
+	}
+}
+
+func (r *rollout) permitsUpdate(hostname string) bool {
+	r.Lock()
+	defer r.Unlock()
+	wave, ok := r.members[hostname]
+	if !ok {
+		return true
+	}
+	return wave <= r.currentWave
+}
+
+// computeStatistics returns the number of synced and failed subs for each
+// wave.
+func (r *rollout) computeStatistics(herd *Herd) ([]uint, []uint) {
+	r.Lock()
+	members := make(map[string]uint, len(r.members))
+	for hostname, wave := range r.members {
+		members[hostname] = wave
+	}
+	r.Unlock()
+	numSynced := make([]uint, len(r.policy.waves))
+	numFailed := make([]uint, len(r.policy.waves))
+	herd.RLock()
+	defer herd.RUnlock()
+	for hostname, wave := range members {
+		sub := herd.subsByName[hostname]
+		if sub == nil {
+			continue
+		}
+		synced, failed := r.getSubResult(sub)
+		if failed {
+			numFailed[wave]++
+		} else if synced {
+			numSynced[wave]++
+		}
+	}
+	return numSynced, numFailed
+}
+
+// getSubResult returns whether the sub has synced to the image and whether it
+// failed. Until the sub is polled, the result from the checkpoint is used. The
+// herd lock must be held.
+func (r *rollout) getSubResult(sub *Sub) (bool, bool) {
+	haveImage := sub.lastSuccessfulImageName == r.imageName
+	if haveImage && (sub.lastUpdateHadTriggerFailures ||
+		sub.lastUpdateWasUnhealthy()) {
+		return false, true
+	} else if sub.publishedStatus == statusFailedToUpdate {
+		return false, true
+	} else if rolledBack := sub.rolledBack; rolledBack != nil &&
+		rolledBack.failedImageName == r.imageName {
+		return false, true
+	} else if haveImage && sub.publishedStatus == statusSynced {
+		return true, false
+	}
+	if sub.lastPollSucceededTime.IsZero() {
+		if failed, ok := r.checkpointResults[sub.mdb.Hostname]; ok {
+			return !failed, failed
+		}
+	}
+	return false, false
+}
+
+// makeCheckpoint returns the state of the rollout. The herd lock must be held.
+func (r *rollout) makeCheckpoint(herd *Herd) rolloutCheckpoint {
+	r.Lock()
+	defer r.Unlock()
+	checkpoint := rolloutCheckpoint{
+		Aborted:               r.aborted,
+		CurrentWave:           r.currentWave,
+		ImageName:             r.imageName,
+		Members:               make(map[string]uint, len(r.members)),
+		MinimumSuccessPercent: r.policy.minimumSuccessPercent,
+		StartTime:             r.startTime,
+	}
+	for hostname, wave := range r.members {
+		checkpoint.Members[hostname] = wave
+		sub := herd.subsByName[hostname]
+		if sub == nil {
+			continue
+		}
+		synced, failed := r.getSubResult(sub)
+		if failed {
+			checkpoint.FailedHosts = append(checkpoint.FailedHosts, hostname)
+		} else if synced {
+			checkpoint.SyncedHosts = append(checkpoint.SyncedHosts, hostname)
+		}
+	}
+	for index, wave := range r.policy.waves {
+		checkpoint.Waves = append(checkpoint.Waves, rolloutWaveCheckpoint{
+			Percent:   wave.percent,
+			ReadyTime: r.waveReadyTimes[index],
+			SoakTime:  wave.soakTime,
+			StartTime: r.waveStartTimes[index],
+		})
+	}
+	return checkpoint
+}
+
+// restoreRollouts restores the rollouts from a checkpoint, replacing any
+// rollouts for the same images. Only subs which still require the image are
+// restored. The herd lock must be held.
+func (herd *Herd) restoreRollouts(checkpoints []rolloutCheckpoint) {
+	for _, checkpoint := range checkpoints {
+		policy := rolloutPolicy{
+			minimumSuccessPercent: checkpoint.MinimumSuccessPercent,
+		}
+		for _, wave := range checkpoint.Waves {
+			policy.waves = append(policy.waves, rolloutWave{
+				percent:  wave.Percent,
+				soakTime: wave.SoakTime,
+			})
+		}
+		if checkpoint.CurrentWave >= uint(len(policy.waves)) {
+			continue
+		}
+		r := newRollout(checkpoint.ImageName, policy)
+		r.aborted = checkpoint.Aborted
+		r.currentWave = checkpoint.CurrentWave
+		r.startTime = checkpoint.StartTime
+		for index, wave := range checkpoint.Waves {
+			r.waveReadyTimes[index] = wave.ReadyTime
+			r.waveStartTimes[index] = wave.StartTime
+		}
+		for hostname, wave := range checkpoint.Members {
+			sub := herd.subsByName[hostname]
+			if sub == nil || sub.mdb.RequiredImage != checkpoint.ImageName ||
+				wave >= uint(len(policy.waves)) {
+				continue
+			}
+			herd.removeSubFromRollouts(hostname)
+			r.members[hostname] = wave
+			r.waveSizes[wave]++
+		}
+		if len(r.members) < 1 {
+			continue
+		}
+		r.checkpointResults = make(map[string]bool,
+			len(checkpoint.FailedHosts)+len(checkpoint.SyncedHosts))
+		for _, hostname := range checkpoint.FailedHosts {
+			r.checkpointResults[hostname] = true
+		}
+		for _, hostname := range checkpoint.SyncedHosts {
+			r.checkpointResults[hostname] = false
+		}
+		herd.rollouts[r.imageName] = r
+		herd.logger.Printf("Restored staged rollout of image: %s at wave: %d\n",
+			r.imageName, r.currentWave)
+	}
+}
+
+// advance moves the rollout to the next wave if the current and all previous
+// waves have succeeded and have soaked long enough. It returns true if the
+// rollout has completed. Once the final wave has started, the rollout is
+// completed when every sub has synced or failed, or when the wave times out.
+// If an earlier wave times out, the rollout is aborted: the remaining subs
+// are held back until their RequiredImage is changed.
+func (r *rollout) advance(herd *Herd) bool {
+	numSynced, numFailed := r.computeStatistics(herd)
+	r.Lock()
+	defer r.Unlock()
+	if len(r.members) < 1 {
+		return true
+	}
+	lastWave := uint(len(r.policy.waves)) - 1
+	var admitted, synced, failed uint
+	for index := uint(0); index <= r.currentWave; index++ {
+		admitted += r.waveSizes[index]
+		synced += numSynced[index]
+		failed += numFailed[index]
+	}
+	r.halted = failed*100 > admitted*(100-r.policy.minimumSuccessPercent)
+	timedOut := *rolloutWaveTimeout > 0 &&
+		time.Since(r.waveStartTimes[r.currentWave]) >= *rolloutWaveTimeout
+	if r.currentWave >= lastWave {
+		if synced+failed >= admitted {
+			if failed > 0 {
+				herd.logger.Printf("Rollout of image: %s: %d subs failed\n",
+					r.imageName, failed)
+			}
+			return true
+		}
+		if timedOut {
+			herd.logger.Printf(
+				"Rollout of image: %s: timed out with %d subs not synced\n",
+				r.imageName, admitted-synced-failed)
+		}
+		return timedOut
+	}
+	if r.aborted {
+		return false
+	}
+	if r.halted || synced*100 < admitted*r.policy.minimumSuccessPercent {
+		r.waveReadyTimes[r.currentWave] = time.Time{}
+		if timedOut {
+			r.aborted = true
+			herd.logger.Printf(
+				"Aborted rollout of image: %s: wave: %d did not succeed\n",
+				r.imageName, r.currentWave)
+		}
+		return false
+	}
+	if r.waveReadyTimes[r.currentWave].IsZero() {
+		r.waveReadyTimes[r.currentWave] = time.Now()
+	}
+	soakTime := r.policy.waves[r.currentWave].soakTime
+	if time.Since(r.waveReadyTimes[r.currentWave]) < soakTime {
+		return false
+	}
+	r.currentWave++
+	r.waveStartTimes[r.currentWave] = time.Now()
+	herd.logger.Printf("Rollout of image: %s advancing to wave: %d (%d%%)\n",
+		r.imageName, r.currentWave, r.policy.waves[r.currentWave].percent)
+	return false
+}
+
+func (r *rollout) getInfo(herd *Herd) proto.RolloutInfo {
+	numSynced, numFailed := r.computeStatistics(herd)
+	r.Lock()
+	defer r.Unlock()
+	info := proto.RolloutInfo{
+		Aborted:               r.aborted,
+		CurrentWave:           r.currentWave,
+		Halted:                r.halted,
+		ImageName:             r.imageName,
+		MinimumSuccessPercent: r.policy.minimumSuccessPercent,
+		NumSubs:               uint(len(r.members)),
+		StartTime:             r.startTime,
+		Waves:                 make([]proto.RolloutWaveInfo, 0, len(r.waveSizes)),
+	}
+	for index, wave := range r.policy.waves {
+		info.NumFailed += numFailed[index]
+		info.NumSynced += numSynced[index]
+		info.Waves = append(info.Waves, proto.RolloutWaveInfo{
+			NumFailed: numFailed[index],
+			NumSubs:   r.waveSizes[index],
+			NumSynced: numSynced[index],
+			Percent:   wave.percent,
+			ReadyTime: r.waveReadyTimes[index],
+			SoakTime:  wave.soakTime,
+			StartTime: r.waveStartTimes[index],
+		})
+	}
+	return info
+}
@@ -0,0 +1,258 @@
+package herd
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/log/testlogger"
+	"github.com/Cloud-Foundations/Dominator/lib/mdb"
+)
+
+func TestParseRolloutWaves(t *testing.T) {
+	waves, err := parseRolloutWaves("1:30m, 10%:1h,100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []rolloutWave{
+		{1, 30 * time.Minute},
+		{10, time.Hour},
+		{100, 0},
+	}
+	if len(waves) != len(expected) {
+		t.Fatalf("expected %d waves, got %d", len(expected), len(waves))
+	}
+	for index, wave := range waves {
+		if wave != expected[index] {
+			t.Errorf("wave %d: expected: %v, got: %v",
+				index, expected[index], wave)
+		}
+	}
+	if _, err := parseRolloutWaves("1,x"); err == nil {
+		t.Error("bad percentage did not fail")
+	}
+	if _, err := parseRolloutWaves("1:forever"); err == nil {
+		t.Error("bad soak time did not fail")
+	}
+}
+
+func TestNormaliseRolloutPolicy(t *testing.T) {
+	policy := rolloutPolicy{waves: []rolloutWave{{percent: 5}}}
+	if err := policy.normalise(); err != nil {
+		t.Fatal(err)
+	}
+	if policy.minimumSuccessPercent != defaultMinimumSuccessPercent {
+		t.Errorf("minimumSuccessPercent: %d", policy.minimumSuccessPercent)
+	}
+	if len(policy.waves) != 2 || policy.waves[1].percent != 100 {
+		t.Errorf("final wave not added: %v", policy.waves)
+	}
+	policy = rolloutPolicy{waves: []rolloutWave{{percent: 10}, {percent: 5}}}
+	if err := policy.normalise(); err == nil {
+		t.Error("decreasing percentages did not fail")
+	}
+	policy = rolloutPolicy{}
+	if err := policy.normalise(); err == nil {
+		t.Error("empty policy did not fail")
+	}
+}
+
+func TestRolloutWaveAssignment(t *testing.T) {
+	policy := rolloutPolicy{
+		waves: []rolloutWave{{percent: 1}, {percent: 10}, {percent: 100}},
+	}
+	if err := policy.normalise(); err != nil {
+		t.Fatal(err)
+	}
+	r := newRollout("image", policy)
+	for index := 0; index < 200; index++ {
+		r.addMember(fmt.Sprintf("host%d", index))
+	}
+	expected := []uint{2, 18, 180}
+	for index, size := range r.waveSizes {
+		if size != expected[index] {
+			t.Errorf("wave %d: expected %d subs, got %d",
+				index, expected[index], size)
+		}
+	}
+	if !r.permitsUpdate("host0") {
+		t.Error("first sub not in canary wave")
+	}
+	if r.permitsUpdate("host199") {
+		t.Error("last sub permitted during canary wave")
+	}
+	if !r.permitsUpdate("unknown") {
+		t.Error("non-member not permitted")
+	}
+	r.removeMember("host0")
+	if r.waveSizes[0] != 1 {
+		t.Errorf("wave 0 size after removal: %d", r.waveSizes[0])
+	}
+}
+
+// makeRolloutTestHerd returns a herd with a rollout of image to numSubs subs
+// in two waves.
+func makeRolloutTestHerd(t *testing.T, numSubs int) (*Herd, *rollout) {
+	policy := rolloutPolicy{
+		minimumSuccessPercent: 50,
+		waves:                 []rolloutWave{{percent: 50}, {percent: 100}},
+	}
+	herd := &Herd{
+		logger:     testlogger.New(t),
+		rollouts:   make(map[string]*rollout),
+		subsByName: make(map[string]*Sub),
+	}
+	r := newRollout("image", policy)
+	herd.rollouts["image"] = r
+	for index := 0; index < numSubs; index++ {
+		sub := &Sub{
+			herd: herd,
+			mdb: mdb.Machine{
+				Hostname:      fmt.Sprintf("host%d", index),
+				RequiredImage: "image",
+			},
+		}
+		herd.subsByName[sub.mdb.Hostname] = sub
+		r.addMember(sub.mdb.Hostname)
+	}
+	return herd, r
+}
+
+func setRolloutTestSubs(herd *Herd, r *rollout, wave uint, status subStatus) {
+	for hostname, memberWave := range r.members {
+		if memberWave != wave {
+			continue
+		}
+		sub := herd.subsByName[hostname]
+		sub.publishedStatus = status
+		if status == statusSynced {
+			sub.lastSuccessfulImageName = r.imageName
+		}
+	}
+}
+
+func TestRolloutCompletesWithFailures(t *testing.T) {
+	herd, r := makeRolloutTestHerd(t, 4)
+	setRolloutTestSubs(herd, r, 0, statusSynced)
+	if r.advance(herd) || r.currentWave != 1 {
+		t.Fatalf("rollout did not advance, wave: %d", r.currentWave)
+	}
+	// A permanently failed sub must not prevent completion.
+	setRolloutTestSubs(herd, r, 1, statusSynced)
+	findRolloutTestSub(herd, r, 1).publishedStatus = statusFailedToUpdate
+	if !r.advance(herd) {
+		t.Error("rollout with a failed sub did not complete")
+	}
+}
+
+// findRolloutTestSub returns a sub in the specified wave.
+func findRolloutTestSub(herd *Herd, r *rollout, wave uint) *Sub {
+	for hostname, memberWave := range r.members {
+		if memberWave == wave {
+			return herd.subsByName[hostname]
+		}
+	}
+	return nil
+}
+
+func TestRolloutWaveTimeout(t *testing.T) {
+	herd, r := makeRolloutTestHerd(t, 4)
+	if r.advance(herd) || r.aborted {
+		t.Fatal("rollout completed or aborted before timeout")
+	}
+	r.waveStartTimes[0] = time.Now().Add(-*rolloutWaveTimeout)
+	if r.advance(herd) {
+		t.Fatal("rollout completed after wave timed out")
+	}
+	if !r.aborted {
+		t.Fatal("rollout not aborted after wave timed out")
+	}
+	// Aborted rollouts still hold back the later waves, even if the earlier
+	// waves then succeed.
+	setRolloutTestSubs(herd, r, 0, statusSynced)
+	if r.advance(herd) || r.currentWave != 0 {
+		t.Errorf("aborted rollout advanced to wave: %d", r.currentWave)
+	}
+	if herd.checkRolloutPermitsUpdate(findRolloutTestSub(herd, r, 1)) {
+		t.Error("sub in later wave permitted after abort")
+	}
+	// The final wave is completed when it times out.
+	r.aborted = false
+	r.currentWave = 1
+	r.waveStartTimes[1] = time.Now().Add(-*rolloutWaveTimeout)
+	if !r.advance(herd) {
+		t.Error("final wave did not complete after timing out")
+	}
+}
+
+func TestRolloutUsesMdbImage(t *testing.T) {
+	herd, r := makeRolloutTestHerd(t, 4)
+	sub := findRolloutTestSub(herd, r, 1)
+	// The required image may be a rollback image or not yet loaded.
+	sub.requiredImageName = "other-image"
+	if herd.checkRolloutPermitsUpdate(sub) {
+		t.Error("sub in wave 1 permitted during wave 0")
+	}
+}
+
+func TestRolloutCheckpoint(t *testing.T) {
+	oldHerd, oldRollout := makeRolloutTestHerd(t, 4)
+	setRolloutTestSubs(oldHerd, oldRollout, 0, statusSynced)
+	if oldRollout.advance(oldHerd) || oldRollout.currentWave != 1 {
+		t.Fatalf("rollout did not advance, wave: %d", oldRollout.currentWave)
+	}
+	// Subs are assigned in order: host0 and host2 are in wave 0.
+	oldHerd.subsByName["host1"].publishedStatus = statusFailedToUpdate
+	filename := filepath.Join(t.TempDir(), "checkpoint")
+	if err := oldHerd.writeCheckpoint(filename); err != nil {
+		t.Fatal(err)
+	}
+	checkpoint, err := readCheckpoint(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The restarted herd has the same subs, except that host3 has been changed
+	// to another image.
+	herd, _ := makeRolloutTestHerd(t, 4)
+	delete(herd.rollouts, "image")
+	herd.subsByName["host3"].mdb.RequiredImage = "other-image"
+	herd.restoreRollouts(checkpoint.Rollouts)
+	r := herd.rollouts["image"]
+	if r == nil {
+		t.Fatal("rollout not restored")
+	}
+	if r.currentWave != 1 {
+		t.Errorf("restored wave: %d, expected: 1", r.currentWave)
+	}
+	if !r.startTime.Equal(oldRollout.startTime) {
+		t.Errorf("restored start time: %s, expected: %s",
+			r.startTime, oldRollout.startTime)
+	}
+	if len(r.members) != 3 {
+		t.Errorf("restored %d members, expected 3", len(r.members))
+	}
+	for hostname, wave := range r.members {
+		if wave != oldRollout.members[hostname] {
+			t.Errorf("%s: restored wave: %d, expected: %d",
+				hostname, wave, oldRollout.members[hostname])
+		}
+	}
+	// Until the subs are polled, the results from the checkpoint are used.
+	numSynced, numFailed := r.computeStatistics(herd)
+	if numSynced[0] != oldRollout.waveSizes[0] {
+		t.Errorf("synced in wave 0: %d, expected: %d",
+			numSynced[0], oldRollout.waveSizes[0])
+	}
+	if numFailed[1] != 1 {
+		t.Errorf("failed in wave 1: %d, expected: 1", numFailed[1])
+	}
+	for _, sub := range herd.subsByName {
+		sub.lastPollSucceededTime = time.Now()
+	}
+	numSynced, numFailed = r.computeStatistics(herd)
+	if numSynced[0] != 0 || numFailed[1] != 0 {
+		t.Errorf("checkpoint results used after polling: %v, %v",
+			numSynced, numFailed)
+	}
+}
@@ -0,0 +1,191 @@
+package herd
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/Cloud-Foundations/Dominator/lib/filesystem"
+	"github.com/Cloud-Foundations/Dominator/lib/filter"
+	"github.com/Cloud-Foundations/Dominator/lib/format"
+	"github.com/Cloud-Foundations/Dominator/lib/image"
+	"github.com/Cloud-Foundations/Dominator/lib/json"
+	"github.com/Cloud-Foundations/Dominator/lib/mdb"
+	"github.com/Cloud-Foundations/Dominator/lib/stringutil"
+	"github.com/Cloud-Foundations/Dominator/lib/tags"
+	"github.com/Cloud-Foundations/Dominator/lib/tags/tagmatcher"
+	subproto "github.com/Cloud-Foundations/Dominator/proto/sub"
+)
+
+const defaultMaxDeletedFraction = 0.5
+
+var (
+	safetyPolicyFile = flag.String("safetyPolicyFile", "",
+		"Name of file containing the safety check policy")
+)
+
+type safetyPolicyConfigType struct { // JSON file format.
+	Default safetyRuleConfigType
+	Rules   []safetyRuleConfigType // The first matching rule is used.
+}
+
+// Unspecified limits in a rule are inherited from the default rule. Protected
+// paths are added to those of the default rule.
+type safetyRuleConfigType struct {
+	Name                  string         `json:",omitempty"`
+	LocationsToMatch      []string       `json:",omitempty"`
+	TagsToMatch           tags.MatchTags `json:",omitempty"`
+	MaxBytesChanged       *uint64        `json:",omitempty"` // nil: no limit.
+	MaxDeletedFraction    *float64       `json:",omitempty"` // nil: 0.5.
+	MaxHighImpactTriggers *uint          `json:",omitempty"` // nil: no limit.
+	ProtectedPaths        []string       `json:",omitempty"` // Regexps.
+}
+
+// loadSafetyPolicy reads the safety check policy from a JSON file. If filename
+// is empty, the built-in policy is returned.
+func loadSafetyPolicy(filename string) (*safetyPolicy, error) {
+	var config safetyPolicyConfigType
+	if filename != "" {
+		if err := json.ReadFromFile(filename, &config); err != nil {
+			return nil, err
+		}
+	}
+	if config.Default.Name == "" {
+		config.Default.Name = "default"
+	}
+	defaultRule, err := makeSafetyRule(config.Default, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	policy := &safetyPolicy{defaultRule: defaultRule}
+	for index, ruleConfig := range config.Rules {
+		if ruleConfig.Name == "" {
+			ruleConfig.Name = fmt.Sprintf("rule %d", index)
+		}
+		rule, err := makeSafetyRule(ruleConfig, defaultRule)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", filename, err)
+		}
+		policy.rules = append(policy.rules, rule)
+	}
+	return policy, nil
+}
+
+func makeSafetyRule(config safetyRuleConfigType,
+	defaultRule *safetyRule) (*safetyRule, error) {
+	rule := &safetyRule{
+		name:             config.Name,
+		locationsToMatch: config.LocationsToMatch,
+		locationsMap: stringutil.ConvertListToMap(config.LocationsToMatch,
+			false),
+		tagsToMatch:        tagmatcher.New(config.TagsToMatch, false),
+		maxDeletedFraction: defaultMaxDeletedFraction,
+	}
+	protectedPaths := config.ProtectedPaths
+	if defaultRule != nil {
+		rule.maxBytesChanged = defaultRule.maxBytesChanged
+		rule.maxDeletedFraction = defaultRule.maxDeletedFraction
+		rule.maxHighImpactTriggers = defaultRule.maxHighImpactTriggers
+		protectedPaths = append(append([]string(nil),
+			defaultRule.protectedPathsList...), protectedPaths...)
+	}
+	if config.MaxBytesChanged != nil {
+		rule.maxBytesChanged = config.MaxBytesChanged
+	}
+	if config.MaxDeletedFraction != nil {
+		fraction := *config.MaxDeletedFraction
+		if fraction <= 0 || fraction > 1 {
+			return nil, fmt.Errorf("%s: MaxDeletedFraction: %g not in (0,1]",
+				config.Name, fraction)
+		}
+		rule.maxDeletedFraction = fraction
+	}
+	if config.MaxHighImpactTriggers != nil {
+		rule.maxHighImpactTriggers = config.MaxHighImpactTriggers
+	}
+	if len(protectedPaths) > 0 {
+		protected, err := filter.New(protectedPaths)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", config.Name, err)
+		}
+		rule.protectedPaths = protected
+		rule.protectedPathsList = protectedPaths
+	}
+	return rule, nil
+}
+
+// getRule returns the first rule matching the machine, or the default rule.
+func (policy *safetyPolicy) getRule(machine mdb.Machine) *safetyRule {
+	for _, rule := range policy.rules {
+		if rule.matches(machine) {
+			return rule
+		}
+	}
+	return policy.defaultRule
+}
+
+func (rule *safetyRule) matches(machine mdb.Machine) bool {
+	if len(rule.locationsToMatch) > 0 &&
+		!matchLocation(machine.Location, rule.locationsToMatch,
+			rule.locationsMap) {
+		return false
+	}
+	return rule.tagsToMatch.MatchEach(machine.Tags)
+}
+
+// check returns a description of the rule which the change violates, or an
+// empty string if the change is safe.
+func (rule *safetyRule) check(img *image.Image, fs *filesystem.FileSystem,
+	request subproto.UpdateRequest) string {
+	if img.Filter != nil { // Sparse images have no deletions.
+		numSubInodes := len(fs.InodeTable)
+		numImageInodes := len(img.FileSystem.InodeTable)
+		minImageInodes := int(float64(numSubInodes) *
+			(1 - rule.maxDeletedFraction))
+		if numImageInodes < minImageInodes {
+			return fmt.Sprintf("%s: image has %d inodes, sub has %d, "+
+				"maximum deleted fraction: %g", rule.name, numImageInodes,
+				numSubInodes, rule.maxDeletedFraction)
+		}
+		maxDeleted := int(float64(numSubInodes) * rule.maxDeletedFraction)
+		if len(request.PathsToDelete) > maxDeleted {
+			return fmt.Sprintf("%s: deleting %d of %d inodes, "+
+				"maximum deleted fraction: %g", rule.name,
+				len(request.PathsToDelete), numSubInodes,
+				rule.maxDeletedFraction)
+		}
+	}
+	if rule.protectedPaths != nil {
+		for _, pathname := range request.PathsToDelete {
+			if rule.protectedPaths.Match(pathname) {
+				return fmt.Sprintf("%s: deleting protected path: %s",
+					rule.name, pathname)
+			}
+		}
+	}
+	if rule.maxBytesChanged != nil {
+		var numBytes uint64
+		for _, inode := range request.InodesToMake {
+			if inode, ok := inode.GenericInode.(*filesystem.RegularInode); ok {
+				numBytes += inode.Size
+			}
+		}
+		if numBytes > *rule.maxBytesChanged {
+			return fmt.Sprintf("%s: changing %s, maximum: %s",
+				rule.name, format.FormatBytes(numBytes),
+				format.FormatBytes(*rule.maxBytesChanged))
+		}
+	}
+	if rule.maxHighImpactTriggers != nil {
+		var numHighImpact uint
+		for _, trigger := range matchTriggers(img.Triggers, request) {
+			if trigger.HighImpact {
+				numHighImpact++
+			}
+		}
+		if numHighImpact > *rule.maxHighImpactTriggers {
+			return fmt.Sprintf("%s: %d high-impact triggers, maximum: %d",
+				rule.name, numHighImpact, *rule.maxHighImpactTriggers)
+		}
+	}
+	return ""
+}
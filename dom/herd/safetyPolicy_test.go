@@ -0,0 +1,85 @@
+package herd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Cloud-Foundations/Dominator/lib/filesystem"
+	"github.com/Cloud-Foundations/Dominator/lib/filter"
+	"github.com/Cloud-Foundations/Dominator/lib/image"
+	"github.com/Cloud-Foundations/Dominator/lib/mdb"
+	"github.com/Cloud-Foundations/Dominator/lib/tags"
+	subproto "github.com/Cloud-Foundations/Dominator/proto/sub"
+)
+
+func makeTestFileSystem(numInodes int) *filesystem.FileSystem {
+	fs := &filesystem.FileSystem{InodeTable: make(filesystem.InodeTable)}
+	for index := 0; index < numInodes; index++ {
+		fs.InodeTable[uint64(index)] = &filesystem.RegularInode{}
+	}
+	return fs
+}
+
+func TestSafetyPolicy(t *testing.T) {
+	maxBytes := uint64(1000)
+	maxDeleted := 0.1
+	defaultRule, err := makeSafetyRule(safetyRuleConfigType{
+		Name:           "default",
+		ProtectedPaths: []string{"/etc/ssh/.*"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbRule, err := makeSafetyRule(safetyRuleConfigType{
+		Name:               "db",
+		TagsToMatch:        tags.MatchTags{"Service": {"db"}},
+		MaxBytesChanged:    &maxBytes,
+		MaxDeletedFraction: &maxDeleted,
+	}, defaultRule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := &safetyPolicy{
+		defaultRule: defaultRule,
+		rules:       []*safetyRule{dbRule},
+	}
+	dbMachine := mdb.Machine{Tags: tags.Tags{"Service": "db"}}
+	if rule := policy.getRule(dbMachine); rule != dbRule {
+		t.Fatalf("wrong rule: %s", rule.name)
+	}
+	if rule := policy.getRule(mdb.Machine{}); rule != defaultRule {
+		t.Fatalf("wrong rule: %s", rule.name)
+	}
+	img := &image.Image{Filter: &filter.Filter{}, FileSystem: makeTestFileSystem(90)}
+	fs := makeTestFileSystem(100)
+	request := subproto.UpdateRequest{
+		PathsToDelete: []string{"/a", "/b", "/c", "/d", "/e", "/f", "/g",
+			"/h", "/i", "/j"},
+	}
+	if reason := defaultRule.check(img, fs, request); reason != "" {
+		t.Errorf("default rule failed: %s", reason)
+	}
+	if reason := dbRule.check(img, fs, request); reason != "" {
+		t.Errorf("db rule failed: %s", reason)
+	}
+	request.PathsToDelete = append(request.PathsToDelete, "/k")
+	if reason := dbRule.check(img, fs, request); reason == "" {
+		t.Error("excess deletions not caught")
+	}
+	request.PathsToDelete = []string{"/etc/ssh/sshd_config"}
+	reason := dbRule.check(img, fs, request)
+	if !strings.Contains(reason, "protected path") {
+		t.Errorf("inherited protected path not caught: %s", reason)
+	}
+	request.PathsToDelete = nil
+	request.InodesToMake = []subproto.Inode{{
+		Name:         "/big",
+		GenericInode: &filesystem.RegularInode{Size: 2000},
+	}}
+	if reason := defaultRule.check(img, fs, request); reason != "" {
+		t.Errorf("default rule failed: %s", reason)
+	}
+	if reason := dbRule.check(img, fs, request); reason == "" {
+		t.Error("excess bytes changed not caught")
+	}
+}
@@ -0,0 +1,95 @@
+package herd
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/html"
+	"github.com/Cloud-Foundations/Dominator/lib/json"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	"github.com/Cloud-Foundations/Dominator/lib/url"
+	proto "github.com/Cloud-Foundations/Dominator/proto/dominator"
+)
+
+func selectDriftingSub(sub *Sub) bool {
+	return sub.correctedFileCount > 0 || sub.newFileCount > 0
+}
+
+// getDriftingSubs returns the subs with drift, the most corrected files first,
+// then the most new files.
+func (herd *Herd) getDriftingSubs() []*Sub {
+	subs := herd.getSelectedSubs(selectDriftingSub)
+	sort.SliceStable(subs, func(left, right int) bool {
+		if subs[left].correctedFileCount != subs[right].correctedFileCount {
+			return subs[left].correctedFileCount >
+				subs[right].correctedFileCount
+		}
+		return subs[left].newFileCount > subs[right].newFileCount
+	})
+	return subs
+}
+
+func (herd *Herd) showDriftingSubsHandler(w http.ResponseWriter,
+	req *http.Request) {
+	writer := bufio.NewWriter(w)
+	defer writer.Flush()
+	subs := herd.getDriftingSubs()
+	parsedQuery := url.ParseQuery(req.URL)
+	switch parsedQuery.OutputType() {
+	case url.OutputTypeHtml:
+		herd.showDriftingSubsHTML(writer, subs)
+	case url.OutputTypeJson:
+		output := make([]proto.SubInfo, 0, len(subs))
+		for _, sub := range subs {
+			output = append(output, sub.makeInfo())
+		}
+		json.WriteWithIndent(writer, "    ", output)
+	case url.OutputTypeText:
+		for _, sub := range subs {
+			fmt.Fprintf(writer, "%s %d %d\n",
+				sub, sub.correctedFileCount, sub.newFileCount)
+		}
+	default:
+		fmt.Fprintln(writer, "Unsupported output type")
+	}
+}
+
+func (herd *Herd) showDriftingSubsHTML(writer *bufio.Writer, subs []*Sub) {
+	fmt.Fprintln(writer, "<title>Dominator subs with drift</title>")
+	fmt.Fprintln(writer, `<style>
+                          table, th, td {
+                          border-collapse: collapse;
+                          }
+                          </style>`)
+	if srpc.CheckTlsRequired() {
+		fmt.Fprintln(writer, "<body>")
+	} else {
+		fmt.Fprintln(writer, "<body bgcolor=\"#ffb0b0\">")
+		fmt.Fprintln(writer,
+			`<h1><center><font color="red">Running in insecure mode. You can get pwned!!!</center></font></h1>`)
+	}
+	defer fmt.Fprintln(writer, "</body>")
+	if len(subs) < 1 {
+		fmt.Fprintln(writer, "No subs with drift<br>")
+		return
+	}
+	fmt.Fprintln(writer, `<table border="1">`)
+	tw, _ := html.NewTableWriter(writer, true, "Name", "Corrected Files",
+		"New Files", "Status", "Last Image Update", "Last Update")
+	timeNow := time.Now()
+	for _, sub := range subs {
+		tw.OpenRow("", "")
+		tw.WriteData("", fmt.Sprintf("<a href=\"showSub?%s\">%s</a>",
+			sub.mdb.Hostname, sub))
+		tw.WriteData("", fmt.Sprintf("%d", sub.correctedFileCount))
+		tw.WriteData("", fmt.Sprintf("%d", sub.newFileCount))
+		tw.WriteData("", sub.publishedStatus.html())
+		tw.WriteData("", sub.lastSuccessfulImageName)
+		showSince(tw, timeNow, sub.lastUpdateTime)
+		tw.CloseRow()
+	}
+	tw.Close()
+}
@@ -88,7 +88,7 @@ func (herd *Herd) showImagesForSubsHTML(writer io.Writer,
 		fmt.Fprintln(writer,
 			`<h1><center><font color="red">Running in insecure mode. You can get pwned!!!</center></font></h1>`)
 	}
-	if herd.updatesDisabledReason != "" {
+	if len(herd.getHerdWideUpdateFreezes()) > 0 {
 		fmt.Fprintf(writer, "<center>")
 		herd.writeDisableStatus(writer)
 		fmt.Fprintln(writer, "</center>")
@@ -0,0 +1,105 @@
+package herd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/format"
+	"github.com/Cloud-Foundations/Dominator/lib/html"
+	"github.com/Cloud-Foundations/Dominator/lib/json"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	"github.com/Cloud-Foundations/Dominator/lib/url"
+	proto "github.com/Cloud-Foundations/Dominator/proto/dominator"
+)
+
+func (herd *Herd) showRolloutsHandler(w http.ResponseWriter,
+	req *http.Request) {
+	writer := bufio.NewWriter(w)
+	defer writer.Flush()
+	rollouts := herd.getRollouts()
+	parsedQuery := url.ParseQuery(req.URL)
+	switch parsedQuery.OutputType() {
+	case url.OutputTypeHtml:
+		herd.showRolloutsHTML(writer, rollouts)
+	case url.OutputTypeJson:
+		json.WriteWithIndent(writer, "    ", rollouts)
+	default:
+		fmt.Fprintln(writer, "Unsupported output type")
+	}
+}
+
+func (herd *Herd) showRolloutsHTML(writer *bufio.Writer,
+	rollouts []proto.RolloutInfo) {
+	fmt.Fprintln(writer, "<title>Dominator staged rollouts</title>")
+	fmt.Fprintln(writer, `<style>
+                          table, th, td {
+                          border-collapse: collapse;
+                          }
+                          </style>`)
+	if srpc.CheckTlsRequired() {
+		fmt.Fprintln(writer, "<body>")
+	} else {
+		fmt.Fprintln(writer, "<body bgcolor=\"#ffb0b0\">")
+		fmt.Fprintln(writer,
+			`<h1><center><font color="red">Running in insecure mode. You can get pwned!!!</center></font></h1>`)
+	}
+	defer fmt.Fprintln(writer, "</body>")
+	if len(rollouts) < 1 {
+		fmt.Fprintln(writer, "No staged rollouts in progress<br>")
+		return
+	}
+	for _, rollout := range rollouts {
+		herd.showRolloutHTML(writer, rollout)
+	}
+}
+
+func (herd *Herd) showRolloutHTML(writer io.Writer,
+	rollout proto.RolloutInfo) {
+	fmt.Fprintf(writer,
+		"<h3>Image: <a href=\"http://%s/showImage?%s\">%s</a></h3>\n",
+		herd.imageManager, rollout.ImageName, rollout.ImageName)
+	fmt.Fprintf(writer, "Started: %s ago, %d subs, %d synced, %d failed",
+		format.Duration(time.Since(rollout.StartTime)), rollout.NumSubs,
+		rollout.NumSynced, rollout.NumFailed)
+	fmt.Fprintf(writer, ", minimum success: %d%%",
+		rollout.MinimumSuccessPercent)
+	if rollout.Aborted {
+		fmt.Fprint(writer,
+			", <font color=\"red\">aborted: wave timed out</font>")
+	} else if rollout.Halted {
+		fmt.Fprint(writer, ", <font color=\"red\">halted by failures</font>")
+	}
+	fmt.Fprintln(writer, "<br>")
+	fmt.Fprintln(writer, `<table border="1">`)
+	tw, _ := html.NewTableWriter(writer, true, "Wave", "Percent", "Subs",
+		"Synced", "Failed", "Started", "Soak Time", "Soaked")
+	for index, wave := range rollout.Waves {
+		var background string
+		if uint(index) == rollout.CurrentWave {
+			background = "lightgreen"
+		}
+		var started, soaked, soakTime string
+		if !wave.StartTime.IsZero() {
+			started = format.Duration(time.Since(wave.StartTime)) + " ago"
+		}
+		if !wave.ReadyTime.IsZero() {
+			soaked = format.Duration(time.Since(wave.ReadyTime))
+		}
+		if wave.SoakTime > 0 {
+			soakTime = format.Duration(wave.SoakTime)
+		}
+		tw.WriteRow("", background,
+			fmt.Sprintf("%d", index),
+			fmt.Sprintf("%d%%", wave.Percent),
+			fmt.Sprintf("%d", wave.NumSubs),
+			fmt.Sprintf("%d", wave.NumSynced),
+			fmt.Sprintf("%d", wave.NumFailed),
+			started,
+			soakTime,
+			soaked)
+	}
+	tw.Close()
+}
@@ -31,23 +31,8 @@ func makeSelector(locationsToMatch []string, statusesToMatch []string,
 	statusesToMatchMap := stringutil.ConvertListToMap(statusesToMatch, false)
 	return func(sub *Sub) bool {
 		if len(locationsToMatch) > 0 {
-			subLocationLength := len(sub.mdb.Location)
-			if subLocationLength < 1 {
-				return false
-			}
-			_, matched := locationsToMatchMap[sub.mdb.Location]
-			if !matched {
-				for _, locationToMatch := range locationsToMatch {
-					index := len(locationToMatch)
-					if index < subLocationLength &&
-						sub.mdb.Location[index] == '/' &&
-						strings.HasPrefix(sub.mdb.Location, locationToMatch) {
-						matched = true
-						break
-					}
-				}
-			}
-			if !matched {
+			if !matchLocation(sub.mdb.Location, locationsToMatch,
+				locationsToMatchMap) {
 				return false
 			}
 		}
@@ -63,6 +48,28 @@ func makeSelector(locationsToMatch []string, statusesToMatch []string,
 	}
 }
 
+// matchLocation returns true if location is one of locationsToMatch or is
+// below one of them.
+func matchLocation(location string, locationsToMatch []string,
+	locationsToMatchMap map[string]struct{}) bool {
+	locationLength := len(location)
+	if locationLength < 1 {
+		return false
+	}
+	if _, ok := locationsToMatchMap[location]; ok {
+		return true
+	}
+	for _, locationToMatch := range locationsToMatch {
+		index := len(locationToMatch)
+		if index < locationLength &&
+			location[index] == '/' &&
+			strings.HasPrefix(location, locationToMatch) {
+			return true
+		}
+	}
+	return false
+}
+
 func makeUrlQuerySelector(queryValues map[string][]string) func(sub *Sub) bool {
 	if len(queryValues) < 1 {
 		return selectAll
@@ -170,7 +177,7 @@ func (herd *Herd) showSubsHTML(writer *bufio.Writer, selectFunc func(*Sub) bool,
 		fmt.Fprintln(writer,
 			`<h1><center><font color="red">Running in insecure mode. You can get pwned!!!</center></font></h1>`)
 	}
-	if herd.updatesDisabledReason != "" {
+	if len(herd.getHerdWideUpdateFreezes()) > 0 {
 		fmt.Fprintf(writer, "<center>")
 		herd.writeDisableStatus(writer)
 		fmt.Fprintln(writer, "</center>")
@@ -179,7 +186,7 @@ func (herd *Herd) showSubsHTML(writer *bufio.Writer, selectFunc func(*Sub) bool,
 	tw, _ := html.NewTableWriter(writer, true, "Name", "Required Image",
 		"Planned Image", "Busy", "Status", "Uptime", "Last Scan Duration",
 		"Staleness", "Last Update", "Last Sync", "Connect", "Short Poll",
-		"Full Poll", "Update Compute")
+		"Full Poll", "Update Compute", "Maintenance Window")
 	subs := herd.getSelectedSubs(selectFunc)
 	for _, sub := range subs {
 		showSub(tw, sub)
@@ -207,18 +214,43 @@ func (herd *Herd) showSubsText(writer io.Writer,
 }
 
 func (sub *Sub) makeInfo() proto.SubInfo {
+	var unsafeUpdateReason, updatesDisabledReason string
+	switch sub.publishedStatus {
+	case statusUnsafeUpdate:
+		unsafeUpdateReason = sub.unsafeUpdateReason
+	case statusUpdatesDisabled:
+		updatesDisabledReason = sub.updatesDisabledReason
+	}
+	var rolledBackFromImage, rollbackReason string
+	if rolledBack := sub.rolledBack; rolledBack != nil {
+		rolledBackFromImage = rolledBack.failedImageName
+		rollbackReason = rolledBack.reason
+	}
+	var maintenanceWindow string
+	window, nextWindowStart, _ := sub.getNextMaintenanceWindow()
+	if window != nil {
+		maintenanceWindow = window.String()
+	}
 	return proto.SubInfo{
-		Machine:             sub.mdb,
-		LastAddress:         sub.lastAddress,
-		LastDisruptionState: sub.lastDisruptionState,
-		LastNote:            sub.lastNote,
-		LastScanDuration:    sub.lastScanDuration,
-		LastSuccessfulImage: sub.lastSuccessfulImageName,
-		LastSyncTime:        sub.lastSyncTime,
-		LastUpdateTime:      sub.lastUpdateTime,
-		StartTime:           sub.startTime,
-		Status:              sub.publishedStatus.String(),
-		SystemUptime:        sub.systemUptime,
+		Machine:               sub.mdb,
+		CorrectedFileCount:    sub.correctedFileCount,
+		NewFileCount:          sub.newFileCount,
+		LastAddress:           sub.lastAddress,
+		LastDisruptionState:   sub.lastDisruptionState,
+		LastNote:              sub.lastNote,
+		LastScanDuration:      sub.lastScanDuration,
+		LastSuccessfulImage:   sub.lastSuccessfulImageName,
+		LastSyncTime:          sub.lastSyncTime,
+		LastUpdateTime:        sub.lastUpdateTime,
+		MaintenanceWindow:     maintenanceWindow,
+		NextMaintenanceWindow: nextWindowStart,
+		RollbackReason:        rollbackReason,
+		RolledBackFromImage:   rolledBackFromImage,
+		StartTime:             sub.startTime,
+		Status:                sub.publishedStatus.String(),
+		SystemUptime:          sub.systemUptime,
+		UnsafeUpdateReason:    unsafeUpdateReason,
+		UpdatesDisabledReason: updatesDisabledReason,
 	}
 }
 
@@ -248,6 +280,7 @@ func showSub(tw *html.TableWriter, sub *Sub) {
 	showDuration(tw, sub.lastShortPollDuration, !sub.lastPollWasFull)
 	showDuration(tw, sub.lastFullPollDuration, sub.lastPollWasFull)
 	showDuration(tw, sub.lastComputeUpdateCpuDuration, false)
+	tw.WriteData("", sub.describeNextMaintenanceWindow())
 }
 
 func (herd *Herd) showImage(tw *html.TableWriter, name string,
@@ -295,7 +328,7 @@ func (herd *Herd) showSubHandler(writer http.ResponseWriter,
 		fmt.Fprintln(w,
 			`<h1><center><font color="red">Running in insecure mode. You can get pwned!!!</center></font></h1>`)
 	}
-	if herd.updatesDisabledReason != "" {
+	if len(herd.getHerdWideUpdateFreezes()) > 0 {
 		fmt.Fprintf(w, "<center>")
 		herd.writeDisableStatus(w)
 		fmt.Fprintln(w, "</center>")
@@ -316,6 +349,20 @@ func (herd *Herd) showSubHandler(writer http.ResponseWriter,
 	sub.herd.showImage(tw, sub.mdb.PlannedImage, false)
 	newRow(w, "Last successful image update", false)
 	sub.herd.showImage(tw, sub.lastSuccessfulImageName, false)
+	if rollout, wave, ok := herd.getRolloutWave(sub); ok {
+		newRow(w, "Staged rollout", false)
+		rollout.Lock()
+		currentWave := rollout.currentWave
+		rollout.Unlock()
+		if wave <= currentWave {
+			tw.WriteData("", fmt.Sprintf(
+				"<a href=\"showRollouts\">wave %d</a> (admitted)", wave))
+		} else {
+			tw.WriteData("", fmt.Sprintf(
+				"<a href=\"showRollouts\">wave %d</a> (current wave: %d)",
+				wave, currentWave))
+		}
+	}
 	if sub.lastNote != "" {
 		newRow(w, "Last note", false)
 		tw.WriteData("", sub.lastNote)
@@ -324,6 +371,44 @@ func (herd *Herd) showSubHandler(writer http.ResponseWriter,
 	sub.showBusy(tw)
 	newRow(w, "Status", false)
 	tw.WriteData("", sub.publishedStatus.html())
+	if sub.publishedStatus == statusUpdatesDisabled &&
+		sub.updatesDisabledReason != "" {
+		newRow(w, "Updates disabled reason", false)
+		tw.WriteData("", sub.updatesDisabledReason)
+	}
+	if sub.publishedStatus == statusUnsafeUpdate &&
+		sub.unsafeUpdateReason != "" {
+		newRow(w, "Unsafe update reason", false)
+		tw.WriteData("", sub.unsafeUpdateReason)
+	}
+	for _, result := range sub.lastUpdateHealthProbeResults {
+		if result.Error != "" {
+			newRow(w, "Failed health probe", false)
+			tw.WriteData("", fmt.Sprintf("%s (%s): %s",
+				result.Service, result.Probe, result.Error))
+		}
+	}
+	if rolledBack := sub.rolledBack; rolledBack != nil {
+		newRow(w, "Rolled back", false)
+		tw.WriteData("", rolledBack.string())
+	}
+	if window := sub.getMaintenanceWindow(); window != nil {
+		newRow(w, "Maintenance window", false)
+		tw.WriteData("", fmt.Sprintf("%s (%s)",
+			window, sub.describeNextMaintenanceWindow()))
+	}
+	if herd.updateJournal != nil {
+		newRow(w, "Update history", false)
+		tw.WriteData("", fmt.Sprintf(
+			"<a href=\"showUpdateHistory?hostname=%s\">show</a>",
+			sub.mdb.Hostname))
+	}
+	if sub.correctedFileCount > 0 || sub.newFileCount > 0 {
+		newRow(w, "Drift", false)
+		tw.WriteData("", fmt.Sprintf(
+			"%d corrected files, %d new files (see subtool drift-report)",
+			sub.correctedFileCount, sub.newFileCount))
+	}
 	if sub.lastWriteError != "" {
 		newRow(w, "Last write error", false)
 		tw.WriteData("", sub.lastWriteError)
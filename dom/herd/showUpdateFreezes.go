@@ -0,0 +1,79 @@
+package herd
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/format"
+	"github.com/Cloud-Foundations/Dominator/lib/html"
+	"github.com/Cloud-Foundations/Dominator/lib/json"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	"github.com/Cloud-Foundations/Dominator/lib/url"
+	proto "github.com/Cloud-Foundations/Dominator/proto/dominator"
+)
+
+func (herd *Herd) showUpdateFreezesHandler(w http.ResponseWriter,
+	req *http.Request) {
+	writer := bufio.NewWriter(w)
+	defer writer.Flush()
+	freezes := herd.listUpdateFreezes()
+	parsedQuery := url.ParseQuery(req.URL)
+	switch parsedQuery.OutputType() {
+	case url.OutputTypeHtml:
+		herd.showUpdateFreezesHTML(writer, freezes)
+	case url.OutputTypeJson:
+		json.WriteWithIndent(writer, "    ", freezes)
+	default:
+		fmt.Fprintln(writer, "Unsupported output type")
+	}
+}
+
+func (herd *Herd) showUpdateFreezesHTML(writer *bufio.Writer,
+	freezes []proto.UpdateFreeze) {
+	fmt.Fprintln(writer, "<title>Dominator update freezes</title>")
+	fmt.Fprintln(writer, `<style>
+                          table, th, td {
+                          border-collapse: collapse;
+                          }
+                          </style>`)
+	if srpc.CheckTlsRequired() {
+		fmt.Fprintln(writer, "<body>")
+	} else {
+		fmt.Fprintln(writer, "<body bgcolor=\"#ffb0b0\">")
+		fmt.Fprintln(writer,
+			`<h1><center><font color="red">Running in insecure mode. You can get pwned!!!</center></font></h1>`)
+	}
+	defer fmt.Fprintln(writer, "</body>")
+	if len(freezes) < 1 {
+		fmt.Fprintln(writer, "No update freezes<br>")
+		return
+	}
+	fmt.Fprintln(writer, `<table border="1">`)
+	tw, _ := html.NewTableWriter(writer, true, "ID", "Created By", "Created",
+		"Expires", "Reason", "Hostnames", "Locations", "Tags")
+	for _, freeze := range freezes {
+		var expires string
+		if !freeze.ExpiresAt.IsZero() {
+			expires = "in " + format.Duration(time.Until(freeze.ExpiresAt))
+		}
+		tags := make([]string, 0, len(freeze.TagsToMatch))
+		for key, values := range freeze.TagsToMatch {
+			tags = append(tags, key+"="+strings.Join(values, "|"))
+		}
+		sort.Strings(tags)
+		tw.WriteRow("", "",
+			fmt.Sprintf("%d", freeze.Id),
+			freeze.CreatedBy,
+			format.Duration(time.Since(freeze.CreatedAt))+" ago",
+			expires,
+			freeze.Reason,
+			strings.Join(freeze.Hostnames, " "),
+			strings.Join(freeze.LocationsToMatch, " "),
+			strings.Join(tags, " "))
+	}
+	tw.Close()
+}
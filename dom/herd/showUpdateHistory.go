@@ -0,0 +1,111 @@
+package herd
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Cloud-Foundations/Dominator/lib/html"
+	"github.com/Cloud-Foundations/Dominator/lib/json"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	"github.com/Cloud-Foundations/Dominator/lib/url"
+	proto "github.com/Cloud-Foundations/Dominator/proto/dominator"
+)
+
+func (herd *Herd) showUpdateHistoryHandler(w http.ResponseWriter,
+	req *http.Request) {
+	writer := bufio.NewWriter(w)
+	defer writer.Flush()
+	queryValues := req.URL.Query()
+	request := proto.GetUpdateHistoryRequest{
+		Hostname: queryValues.Get("hostname"),
+	}
+	if value := queryValues.Get("maxRecords"); value != "" {
+		if maxRecords, err := strconv.ParseUint(value, 10, 32); err == nil {
+			request.MaxRecords = uint(maxRecords)
+		}
+	}
+	records, err := herd.getUpdateHistory(request)
+	if err != nil {
+		fmt.Fprintln(writer, err)
+		return
+	}
+	parsedQuery := url.ParseQuery(req.URL)
+	switch parsedQuery.OutputType() {
+	case url.OutputTypeHtml:
+		herd.showUpdateHistoryHTML(writer, request.Hostname, records)
+	case url.OutputTypeJson:
+		json.WriteWithIndent(writer, "    ", records)
+	default:
+		fmt.Fprintln(writer, "Unsupported output type")
+	}
+}
+
+func (herd *Herd) showUpdateHistoryHTML(writer *bufio.Writer,
+	hostname string, records []proto.UpdateRecord) {
+	if hostname == "" {
+		fmt.Fprintln(writer, "<title>Dominator update history</title>")
+	} else {
+		fmt.Fprintf(writer, "<title>Dominator update history for: %s</title>\n",
+			hostname)
+	}
+	fmt.Fprintln(writer, `<style>
+                          table, th, td {
+                          border-collapse: collapse;
+                          }
+                          </style>`)
+	if srpc.CheckTlsRequired() {
+		fmt.Fprintln(writer, "<body>")
+	} else {
+		fmt.Fprintln(writer, "<body bgcolor=\"#ffb0b0\">")
+		fmt.Fprintln(writer,
+			`<h1><center><font color="red">Running in insecure mode. You can get pwned!!!</center></font></h1>`)
+	}
+	defer fmt.Fprintln(writer, "</body>")
+	if len(records) < 1 {
+		fmt.Fprintln(writer, "No updates recorded<br>")
+		return
+	}
+	fmt.Fprintln(writer, `<table border="1">`)
+	tw, _ := html.NewTableWriter(writer, true, "Start Time", "Sub",
+		"Operation", "Image", "Objects", "Changed", "Deleted", "Triggers",
+		"Result", "User")
+	// Show the newest records first.
+	for index := len(records) - 1; index >= 0; index-- {
+		record := records[index]
+		var background string
+		if record.Error != "" || record.TriggerFailures ||
+			record.HealthProbeFailures {
+			background = "#ff8080"
+		}
+		result := record.Result
+		if record.Error != "" {
+			result += ": " + record.Error
+		}
+		if record.TriggerFailures {
+			result += " (trigger failures)"
+		}
+		if record.HealthProbeFailures {
+			result += " (unhealthy after update)"
+		}
+		operation := record.Operation
+		if record.ForcedDisruption {
+			operation += " (forced disruption)"
+		}
+		tw.WriteRow("", background,
+			record.StartTime.Format(timeFormat),
+			fmt.Sprintf("<a href=\"showSub?%s\">%s</a>",
+				record.Hostname, record.Hostname),
+			operation,
+			record.ImageName,
+			fmt.Sprintf("%d", record.NumObjects),
+			fmt.Sprintf("%d", record.NumChanged),
+			fmt.Sprintf("%d", record.NumDeleted),
+			strings.Join(record.Triggers, " "),
+			result,
+			record.Username)
+	}
+	tw.Close()
+}
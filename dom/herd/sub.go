@@ -17,6 +17,7 @@ import (
 	"github.com/Cloud-Foundations/Dominator/lib/format"
 	"github.com/Cloud-Foundations/Dominator/lib/hash"
 	"github.com/Cloud-Foundations/Dominator/lib/image"
+	"github.com/Cloud-Foundations/Dominator/lib/mdb"
 	"github.com/Cloud-Foundations/Dominator/lib/objectcache"
 	"github.com/Cloud-Foundations/Dominator/lib/resourcepool"
 	"github.com/Cloud-Foundations/Dominator/lib/srpc"
@@ -197,7 +198,12 @@ func (sub *Sub) connectAndPoll2(swapImages bool,
 	})
 	defer func() {
 		timer.Stop()
+		if sub.lastReachableTime.Before(sub.lastConnectionStartTime) {
+			sub.checkUnreachableForRollback()
+		}
 		sub.publishedStatus = sub.status
+		sub.updatePeer()
+		sub.checkWatchState()
 		switch sub.status {
 		case statusUnknown:
 		case statusConnecting:
@@ -348,10 +354,11 @@ func (sub *Sub) restoreScanSpeed(fastMessageChannel chan<- FastUpdateMessage) {
 // getImageNames returns the required and planned image names from the MDB data.
 // If swapImages is true, the image names are swapped.
 func (sub *Sub) getImageNames(swapImages bool) (string, string) {
+	requiredImageName := sub.getRequiredImageName()
 	if swapImages {
-		return sub.mdb.PlannedImage, sub.mdb.RequiredImage
+		return sub.mdb.PlannedImage, requiredImageName
 	} else {
-		return sub.mdb.RequiredImage, sub.mdb.PlannedImage
+		return requiredImageName, sub.mdb.PlannedImage
 	}
 }
 
@@ -367,9 +374,20 @@ func (sub *Sub) loadConfiguration(swapImages bool) bool {
 	plannedImage := sub.herd.imageManager.GetNoError(plannedImageName)
 	sub.herd.cpuSharer.GrabCpu()
 	var changed bool
-	if sub.requiredImage != requiredImage || sub.plannedImage != plannedImage {
+	if sub.restoredFromCheckpoint {
+		// The images were not loaded yet: only a change of name matters.
+		sub.restoredFromCheckpoint = false
+		if sub.requiredImageName != requiredImageName ||
+			sub.plannedImageName != plannedImageName {
+			changed = true
+		}
+	} else if sub.requiredImage != requiredImage ||
+		sub.plannedImage != plannedImage {
 		changed = true
 	}
+	if changed {
+		sub.checkpointComputedInodes = nil
+	}
 	sub.requiredImageName = requiredImageName
 	sub.requiredImage = requiredImage
 	sub.plannedImageName = plannedImageName
@@ -431,6 +449,12 @@ func (sub *Sub) processFileUpdates() bool {
 				Hash:         fileInfo.Hash,
 			}
 			sub.computedInodes[fileInfo.Pathname] = rInode
+			if inode, ok := sub.checkpointComputedInodes[fileInfo.Pathname]; ok {
+				delete(sub.checkpointComputedInodes, fileInfo.Pathname)
+				if sameComputedInode(inode, rInode) {
+					continue // Unchanged since the checkpoint.
+				}
+			}
 			haveUpdates = true
 		}
 	}
@@ -445,21 +469,31 @@ func (sub *Sub) poll(srpcClient *srpc.Client, previousStatus subStatus,
 		sub.herd.logger.Printf("poll(%s): error setting timeout: %s\n", sub)
 	}
 	// If the planned image has just become available, force a full poll.
-	if previousStatus == statusSynced &&
+	if (previousStatus == statusSynced ||
+		previousStatus == statusRolledBack ||
+		previousStatus == statusUnhealthyAfterUpdate) &&
 		!sub.havePlannedImage &&
 		sub.plannedImage != nil {
 		sub.havePlannedImage = true
 		sub.generationCount = 0 // Force a full poll.
 	}
 	// If the computed files have changed since the last sync, force a full poll
-	if previousStatus == statusSynced &&
+	if (previousStatus == statusSynced ||
+		previousStatus == statusRolledBack ||
+		previousStatus == statusUnhealthyAfterUpdate) &&
 		sub.computedFilesChangeTime.After(sub.lastSyncTime) {
 		sub.generationCount = 0 // Force a full poll.
 	}
 	// If the last update was disabled and updates are enabled now, force a full
 	// poll.
 	if previousStatus == statusUpdatesDisabled &&
-		sub.herd.updatesDisabledReason == "" && !sub.mdb.DisableUpdates {
+		sub.getUpdatesDisabledReason() == "" {
+		sub.generationCount = 0 // Force a full poll.
+	}
+	// If the last update was held back by a staged rollout and the rollout has
+	// since advanced, force a full poll.
+	if previousStatus == statusWaitingForRollout &&
+		sub.herd.checkRolloutPermitsUpdate(sub) {
 		sub.generationCount = 0 // Force a full poll.
 	}
 	// If the last update was disabled due to a safety check and there is a
@@ -510,6 +544,11 @@ func (sub *Sub) poll(srpcClient *srpc.Client, previousStatus subStatus,
 	sub.lastDisruptionState = reply.DisruptionState
 	sub.lastPollSucceededTime = time.Now()
 	sub.lastSuccessfulImageName = reply.LastSuccessfulImageName
+	sub.servesObjects = reply.ServesObjects
+	sub.correctedFileCount = reply.CorrectedFileCount
+	sub.newFileCount = reply.NewFileCount
+	sub.lastUpdateHadTriggerFailures = reply.LastUpdateHadTriggerFailures
+	sub.lastUpdateHealthProbeResults = reply.LastUpdateHealthProbeResults
 	sub.lastNote = reply.LastNote
 	sub.lastWriteError = reply.LastWriteError
 	sub.systemUptime = reply.SystemUptime
@@ -554,10 +593,12 @@ func (sub *Sub) poll(srpcClient *srpc.Client, previousStatus subStatus,
 		sub.status = statusFetching
 		return false
 	}
-	if reply.UpdateInProgress {
+	// The update is not complete until the health probes have finished.
+	if reply.UpdateInProgress || reply.HealthProbesInProgress {
 		sub.status = statusUpdating
 		return false
 	}
+	sub.completeUpdateRecords(reply)
 	if reply.LastWriteError != "" {
 		sub.status = statusUnwritable
 		sub.reclaim()
@@ -601,6 +642,7 @@ func (sub *Sub) poll(srpcClient *srpc.Client, previousStatus subStatus,
 			sub.status = statusFailedToUpdate
 		}
 		sub.scanCountAtLastUpdateEnd = reply.ScanCount
+		sub.checkUpdateForRollback(reply)
 		sub.reclaim()
 		return false
 	}
@@ -661,7 +703,7 @@ func (sub *Sub) poll(srpcClient *srpc.Client, previousStatus subStatus,
 			return false
 		}
 		sub.status = statusComputingUpdate
-		if idle, status := sub.sendUpdate(srpcClient); !idle {
+		if idle, status := sub.sendUpdate(srpcClient, fast); !idle {
 			sub.status = status
 			sub.reclaim()
 			return false
@@ -685,7 +727,14 @@ func (sub *Sub) poll(srpcClient *srpc.Client, previousStatus subStatus,
 		!sub.lastUpdateTime.IsZero() {
 		sub.lastSyncTime = time.Now()
 	}
-	sub.status = statusSynced
+	if sub.rolledBack != nil {
+		sub.status = statusRolledBack
+	} else if sub.lastUpdateWasUnhealthy() {
+		sub.status = statusUnhealthyAfterUpdate
+	} else {
+		sub.status = statusSynced
+		sub.rollbackImageName = "" // The update has been verified.
+	}
 	sub.cleanup(srpcClient)
 	sub.reclaim()
 	return false
@@ -797,15 +846,41 @@ func (sub *Sub) fetchMissingObjects(srpcClient *srpc.Client, img *image.Image,
 			request.SpeedPercent = 100
 		}
 		var response subproto.FetchResponse
+		record := &domproto.UpdateRecord{
+			Hostname:   sub.mdb.Hostname,
+			NumObjects: uint(len(objectsToFetch)),
+			Operation:  domproto.UpdateOperationFetch,
+			StartTime:  time.Now(),
+			Username:   sub.fastUpdateUsername,
+		}
+		if isRequiredImage {
+			record.ImageName = sub.requiredImageName
+		} else {
+			record.ImageName = sub.plannedImageName
+		}
+		peerAddress := sub.selectPeer(record.ImageName)
+		if peerAddress != "" {
+			request.PeerAddress = peerAddress
+			logger.Debugf(0, "%s will try fetching from peer: %s\n",
+				sub, peerAddress)
+		}
+		if !sub.herd.isLeader() {
+			return false, statusNotLeader
+		}
 		err := client.CallFetch(srpcClient, request, &response)
 		if err != nil {
 			srpcClient.Close()
 			logger.Printf("Error calling %s:Subd.Fetch(): %s\n", sub, err)
+			record.CompletionTime = time.Now()
+			record.Error = err.Error()
+			record.Result = domproto.UpdateResultFailedToStart
+			sub.herd.writeUpdateRecord(*record)
 			if err == srpc.ErrorAccessToMethodDenied {
 				return false, statusFetchDenied
 			}
 			return false, statusFailedToFetch
 		}
+		sub.pendingFetchRecord = record
 		returnAvailable = false
 		returnStatus = statusFetching
 	}
@@ -837,66 +912,78 @@ func (sub *Sub) fetchMissingObjects(srpcClient *srpc.Client, img *image.Image,
 }
 
 // Returns true if no update needs to be performed.
-func (sub *Sub) sendUpdate(srpcClient *srpc.Client) (bool, subStatus) {
+// If fast is true, staged rollouts are bypassed.
+func (sub *Sub) sendUpdate(srpcClient *srpc.Client, fast bool) (
+	bool, subStatus) {
 	logger := sub.herd.logger
-	var request subproto.UpdateRequest
 	var reply subproto.UpdateResponse
-	if idle, missing := sub.buildUpdateRequest(&request); missing {
+	request, idle, missing := sub.computeUpdateRequest()
+	if missing {
 		return false, statusMissingComputedFile
 	} else if idle {
 		return true, statusSynced
 	}
-	if sub.mdb.DisableUpdates || sub.herd.updatesDisabledReason != "" {
+	sub.updatesDisabledReason = sub.getUpdatesDisabledReason()
+	if sub.updatesDisabledReason != "" {
 		return false, statusUpdatesDisabled
 	}
+	if !fast && !sub.herd.checkRolloutPermitsUpdate(sub) {
+		return false, statusWaitingForRollout
+	}
 	if !sub.pendingSafetyClear {
-		// Perform a cheap safety check: if over half the inodes will be deleted
-		// then mark the update as unsafe.
-		if sub.checkForUnsafeChange(request) {
+		// Perform a cheap safety check against the safety policy (by default:
+		// if over half the inodes will be deleted) and if it fails then mark
+		// the update as unsafe.
+		sub.unsafeUpdateReason = sub.checkForUnsafeChange(request)
+		if sub.unsafeUpdateReason != "" {
+			logger.Printf("Unsafe update for: %s: %s\n",
+				sub, sub.unsafeUpdateReason)
 			return false, statusUnsafeUpdate
 		}
 	}
-	if value, ok := sub.mdb.Tags["ForceDisruptiveUpdate"]; ok {
-		if strings.EqualFold(value, "true") {
-			request.ForceDisruption = true
-		}
-	}
-	if sub.pendingForceDisruptiveUpdate {
-		request.ForceDisruption = true
+	if !sub.herd.isLeader() {
+		return false, statusNotLeader
 	}
 	sub.status = statusSendingUpdate
 	sub.lastUpdateTime = time.Now()
+	record := sub.makeUpdateRecord(request, fast)
 	logger.Printf("Calling %s:Subd.Update() for image: %s\n",
 		sub, sub.requiredImageName)
 	if err := client.CallUpdate(srpcClient, request, &reply); err != nil {
 		srpcClient.Close()
 		logger.Printf("Error calling %s:Subd.Update(): %s\n", sub, err)
+		record.CompletionTime = time.Now()
+		record.Error = err.Error()
+		record.Result = domproto.UpdateResultFailedToStart
+		sub.herd.writeUpdateRecord(*record)
 		if err == srpc.ErrorAccessToMethodDenied {
 			return false, statusUpdateDenied
 		}
 		return false, statusFailedToUpdate
 	}
+	sub.pendingUpdateRecord = record
+	sub.armRollback()
 	sub.pendingSafetyClear = false
 	sub.pendingForceDisruptiveUpdate = false
+	sub.forceDisruptiveUpdateUsername = ""
 	return false, statusUpdating
 }
 
-// Returns true if the change is unsafe (very large number of deletions).
-func (sub *Sub) checkForUnsafeChange(request subproto.UpdateRequest) bool {
-	if sub.requiredImage.Filter == nil {
-		return false // Sparse image: no deletions.
-	}
-	if _, ok := sub.mdb.Tags["DisableSafetyCheck"]; ok {
-		return false // This sub doesn't need a safety check.
-	}
-	if len(sub.requiredImage.FileSystem.InodeTable) <
-		len(sub.fileSystem.InodeTable)>>1 {
-		return true
-	}
-	if len(request.PathsToDelete) > len(sub.fileSystem.InodeTable)>>1 {
-		return true
-	}
-	return false
+// Returns a description of the safety rule violated by the change, or an empty
+// string if the change is safe.
+func (sub *Sub) checkForUnsafeChange(request subproto.UpdateRequest) string {
+	return sub.herd.checkForUnsafeChange(sub.mdb, sub.requiredImage,
+		sub.fileSystem, request)
+}
+
+// Returns a description of the safety rule violated by the change from fs to
+// img, or an empty string if the change is safe.
+func (herd *Herd) checkForUnsafeChange(machine mdb.Machine, img *image.Image,
+	fs *filesystem.FileSystem, request subproto.UpdateRequest) string {
+	if _, ok := machine.Tags["DisableSafetyCheck"]; ok {
+		return "" // This sub doesn't need a safety check.
+	}
+	return herd.safetyPolicy.getRule(machine).check(img, fs, request)
 }
 
 // cleanup will tell the Sub to remove unused objects and that any disruptive
@@ -1013,7 +1100,7 @@ func (sub *Sub) fastUpdate(request domproto.FastUpdateRequest,
 		}
 	}
 	progressChannel := make(chan FastUpdateMessage, 16)
-	go sub.processFastUpdate(progressChannel, request)
+	go sub.processFastUpdate(progressChannel, request, authInfo.Username)
 	return progressChannel, nil
 }
 
@@ -1027,6 +1114,7 @@ func (sub *Sub) forceDisruptiveUpdate(authInfo *srpc.AuthInformation) error {
 	if !sub.checkAdminAccess(authInfo) {
 		return errors.New("no access to sub")
 	}
+	sub.forceDisruptiveUpdateUsername = authInfo.Username
 	sub.pendingForceDisruptiveUpdate = true
 	return nil
 }
@@ -1039,7 +1127,7 @@ func (sub *Sub) sendCancel() {
 }
 
 func (sub *Sub) processFastUpdate(progressChannel chan<- FastUpdateMessage,
-	request domproto.FastUpdateRequest) {
+	request domproto.FastUpdateRequest, username string) {
 	defer close(progressChannel)
 	select {
 	case sub.herd.fastUpdateSemaphore <- struct{}{}:
@@ -1061,6 +1149,8 @@ func (sub *Sub) processFastUpdate(progressChannel chan<- FastUpdateMessage,
 	}
 	defer sub.makeUnbusy()
 	sub.sendFastUpdateMessage(progressChannel, "made sub busy")
+	sub.fastUpdateUsername = username
+	defer func() { sub.fastUpdateUsername = "" }()
 	sub.herd.cpuSharer.GrabCpu()
 	defer sub.herd.cpuSharer.ReleaseCpu()
 	if request.UsePlannedImage && sub.plannedImage != nil {
@@ -1082,9 +1172,17 @@ func (sub *Sub) processFastUpdate(progressChannel chan<- FastUpdateMessage,
 	var prevStatus subStatus
 	timeoutTime := time.Now().Add(request.Timeout)
 	defer sub.restoreScanSpeed(progressChannel)
-	if sub.status == statusSynced {
+	if sub.status == statusSynced ||
+		sub.status == statusUnhealthyAfterUpdate {
 		sub.status = statusWaitingToPoll
 	}
+	// A fast update to the RequiredImage retries the image the sub was rolled
+	// back from.
+	if !request.UsePlannedImage && sub.clearRollback() {
+		sub.herd.logger.Printf("%s: cleared rollback for fast update by: %s\n",
+			sub, username)
+		sub.sendFastUpdateMessage(progressChannel, "cleared rollback")
+	}
 	for ; time.Until(timeoutTime) > 0; sleeper.Sleep() {
 		if sub.deleting {
 			sub.sendFastUpdateMessage(progressChannel, "deleting")
@@ -1096,7 +1194,8 @@ func (sub *Sub) processFastUpdate(progressChannel chan<- FastUpdateMessage,
 			sleeper.Reset()
 		}
 		switch sub.status {
-		case statusSynced, statusUpdatesDisabled, statusUnsafeUpdate:
+		case statusSynced, statusRolledBack, statusUnhealthyAfterUpdate,
+			statusUpdatesDisabled, statusUnsafeUpdate:
 			return
 		default:
 		}
@@ -68,6 +68,8 @@ func (status subStatus) string() string {
 		return "updates disabled"
 	case statusUnsafeUpdate:
 		return "unsafe update"
+	case statusWaitingForRollout:
+		return "waiting for rollout"
 	case statusDisruptionRequested:
 		return "disruption requested"
 	case statusDisruptionDenied:
@@ -82,6 +84,12 @@ func (status subStatus) string() string {
 		return "waiting for next full poll"
 	case statusSynced:
 		return "synced"
+	case statusRolledBack:
+		return "rolled back"
+	case statusUnhealthyAfterUpdate:
+		return "unhealthy after update"
+	case statusNotLeader:
+		return "not leader"
 	default:
 		panic(fmt.Sprintf("unknown status: %d", status))
 	}
@@ -89,7 +97,7 @@ func (status subStatus) string() string {
 
 func (status subStatus) html() string {
 	switch status {
-	case statusUnsafeUpdate:
+	case statusUnsafeUpdate, statusRolledBack, statusUnhealthyAfterUpdate:
 		return `<font color="red">` + status.String() + "</font>"
 	default:
 		return status.String()
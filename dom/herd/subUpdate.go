@@ -1,30 +1,29 @@
 package herd
 
 import (
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/Cloud-Foundations/Dominator/dom/lib"
+	"github.com/Cloud-Foundations/Dominator/lib/filesystem"
 	"github.com/Cloud-Foundations/Dominator/lib/format"
+	"github.com/Cloud-Foundations/Dominator/lib/image"
+	"github.com/Cloud-Foundations/Dominator/lib/objectcache"
 	subproto "github.com/Cloud-Foundations/Dominator/proto/sub"
 )
 
-// Returns (idle, missing), idle=true if no update needs to be performed.
-func (sub *Sub) buildUpdateRequest(request *subproto.UpdateRequest) (
-	bool, bool) {
-	request.ImageName = sub.requiredImageName
-	request.Triggers = sub.requiredImage.Triggers
+// computeUpdateRequest builds the update to the required image from the last
+// poll and records how long that took. It returns the request, idle=true if no
+// update needs to be performed and missing=true if computed files are missing.
+func (sub *Sub) computeUpdateRequest() (subproto.UpdateRequest, bool, bool) {
 	var rusageStart, rusageStop syscall.Rusage
 	computeStartTime := time.Now()
 	syscall.Getrusage(syscall.RUSAGE_SELF, &rusageStart)
-	subObj := lib.Sub{
-		Hostname:       sub.mdb.Hostname,
-		FileSystem:     sub.fileSystem,
-		ComputedInodes: sub.computedInodes,
-		ObjectCache:    sub.objectCache}
-	if lib.BuildUpdateRequest(subObj, sub.requiredImage, request, false, false,
-		sub.herd.logger) {
-		return false, true
+	request, idle, missing := sub.buildUpdateRequest(sub.fileSystem,
+		sub.objectCache, sub.requiredImageName, sub.requiredImage, false)
+	if missing {
+		return request, false, true
 	}
 	syscall.Getrusage(syscall.RUSAGE_SELF, &rusageStop)
 	timeTaken := time.Since(computeStartTime)
@@ -35,18 +34,50 @@ func (sub *Sub) buildUpdateRequest(request *subproto.UpdateRequest) (
 		time.Duration(rusageStart.Utime.Sec)*time.Second -
 		time.Duration(rusageStart.Utime.Usec)*time.Microsecond
 	computeCpuTimeDistribution.Add(sub.lastComputeUpdateCpuDuration)
+	if !idle {
+		sub.herd.logger.Debugf(0,
+			"buildUpdateRequest(%s) took: %s user CPU time in %s\n",
+			sub, sub.lastComputeUpdateCpuDuration, format.Duration(timeTaken))
+	}
+	return request, idle, false
+}
+
+// buildUpdateRequest builds the update which would be sent to the sub to
+// change fs to img, given the objects in the object cache of the sub. It
+// returns the request, idle=true if no update needs to be performed and
+// missing=true if computed files are missing. The busy flag must be held.
+func (sub *Sub) buildUpdateRequest(fs *filesystem.FileSystem,
+	objectCache objectcache.ObjectCache, imageName string, img *image.Image,
+	ignoreMissingComputedFiles bool) (subproto.UpdateRequest, bool, bool) {
+	request := subproto.UpdateRequest{
+		ImageName: imageName,
+		Triggers:  img.Triggers,
+	}
+	subObj := lib.Sub{
+		Hostname:       sub.mdb.Hostname,
+		FileSystem:     fs,
+		ComputedInodes: sub.computedInodes,
+		ObjectCache:    objectCache}
+	if lib.BuildUpdateRequest(subObj, img, &request, false,
+		ignoreMissingComputedFiles, sub.herd.logger) {
+		return request, false, true
+	}
+	if value, ok := sub.mdb.Tags["ForceDisruptiveUpdate"]; ok {
+		if strings.EqualFold(value, "true") {
+			request.ForceDisruption = true
+		}
+	}
+	if sub.pendingForceDisruptiveUpdate {
+		request.ForceDisruption = true
+	}
 	if len(request.FilesToCopyToCache) > 0 ||
 		len(request.InodesToMake) > 0 ||
 		len(request.HardlinksToMake) > 0 ||
 		len(request.PathsToDelete) > 0 ||
 		len(request.DirectoriesToMake) > 0 ||
 		len(request.InodesToChange) > 0 ||
-		(sub.requiredImage.Filter != nil &&
-			sub.lastSuccessfulImageName != sub.requiredImageName) {
-		sub.herd.logger.Debugf(0,
-			"buildUpdateRequest(%s) took: %s user CPU time in %s\n",
-			sub, sub.lastComputeUpdateCpuDuration, format.Duration(timeTaken))
-		return false, false
+		(img.Filter != nil && sub.lastSuccessfulImageName != imageName) {
+		return request, false, false
 	}
-	return true, false
+	return request, true, false
 }
@@ -0,0 +1,310 @@
+package herd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/fsutil"
+	"github.com/Cloud-Foundations/Dominator/lib/json"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	"github.com/Cloud-Foundations/Dominator/lib/stringutil"
+	"github.com/Cloud-Foundations/Dominator/lib/tags/tagmatcher"
+	proto "github.com/Cloud-Foundations/Dominator/proto/dominator"
+)
+
+func newUpdateFreeze(freeze proto.UpdateFreeze) *updateFreeze {
+	return &updateFreeze{
+		UpdateFreeze: freeze,
+		hostnames:    stringutil.ConvertListToMap(freeze.Hostnames, false),
+		selectFunc: makeSelector(freeze.LocationsToMatch, nil,
+			tagmatcher.New(freeze.TagsToMatch, false)),
+	}
+}
+
+// checkAccess returns true if the freeze may be removed by the user. Only the
+// user who created the freeze or an administrator may remove it.
+func (freeze *updateFreeze) checkAccess(authInfo *srpc.AuthInformation) bool {
+	if authInfo == nil {
+		return false
+	}
+	if authInfo.HaveMethodAccess {
+		return true
+	}
+	return freeze.CreatedBy != "" && authInfo.Username == freeze.CreatedBy
+}
+
+func (freeze *updateFreeze) hasExpired(now time.Time) bool {
+	return !freeze.ExpiresAt.IsZero() && now.After(freeze.ExpiresAt)
+}
+
+// isHerdWide returns true if the freeze covers all subs.
+func (freeze *updateFreeze) isHerdWide() bool {
+	return len(freeze.Hostnames) < 1 &&
+		len(freeze.LocationsToMatch) < 1 &&
+		len(freeze.TagsToMatch) < 1
+}
+
+func (freeze *updateFreeze) matches(sub *Sub) bool {
+	if len(freeze.hostnames) > 0 {
+		if _, ok := freeze.hostnames[sub.mdb.Hostname]; !ok {
+			return false
+		}
+	}
+	return freeze.selectFunc(sub)
+}
+
+func (freeze *updateFreeze) string() string {
+	var by, expires string
+	if freeze.CreatedBy != "" {
+		by = " by: " + freeze.CreatedBy
+	}
+	if !freeze.ExpiresAt.IsZero() {
+		expires = ", expires at " + freeze.ExpiresAt.Format(timeFormat)
+	}
+	return fmt.Sprintf("updates disabled%s because: %s (freeze %d%s)",
+		by, freeze.Reason, freeze.Id, expires)
+}
+
+func (herd *Herd) addUpdateFreeze(freeze proto.UpdateFreeze) uint64 {
+	herd.updateFreezesLock.Lock()
+	defer herd.updateFreezesLock.Unlock()
+	herd.nextUpdateFreezeId++
+	freeze.Id = herd.nextUpdateFreezeId
+	herd.updateFreezes[freeze.Id] = newUpdateFreeze(freeze)
+	herd.saveUpdateFreezes()
+	return freeze.Id
+}
+
+func (herd *Herd) disableUpdates(username string,
+	request proto.DisableUpdatesRequest) (uint64, error) {
+	if request.Reason == "" {
+		return 0, errors.New("error disabling updates: no reason given")
+	}
+	now := time.Now()
+	if !request.ExpiresAt.IsZero() && request.ExpiresAt.Before(now) {
+		return 0, errors.New("error disabling updates: expiry time in past")
+	}
+	return herd.addUpdateFreeze(proto.UpdateFreeze{
+		CreatedAt:        now,
+		CreatedBy:        username,
+		ExpiresAt:        request.ExpiresAt,
+		Hostnames:        request.Hostnames,
+		LocationsToMatch: request.LocationsToMatch,
+		Reason:           request.Reason,
+		TagsToMatch:      request.TagsToMatch,
+	}), nil
+}
+
+// enableUpdates removes the specified freeze. If freezeId is zero, all freezes
+// which cover the whole herd are removed. Only the user who created a freeze or
+// an administrator may remove it.
+func (herd *Herd) enableUpdates(freezeId uint64,
+	authInfo *srpc.AuthInformation) error {
+	herd.updateFreezesLock.Lock()
+	defer herd.updateFreezesLock.Unlock()
+	if freezeId != 0 {
+		freeze, ok := herd.updateFreezes[freezeId]
+		if !ok {
+			return fmt.Errorf("unknown freeze: %d", freezeId)
+		}
+		if !freeze.checkAccess(authInfo) {
+			return fmt.Errorf("no access to freeze: %d", freezeId)
+		}
+		delete(herd.updateFreezes, freezeId)
+		herd.saveUpdateFreezes()
+		return nil
+	}
+	var deniedIds []uint64
+	for id, freeze := range herd.updateFreezes {
+		if !freeze.isHerdWide() {
+			continue
+		}
+		if freeze.checkAccess(authInfo) {
+			delete(herd.updateFreezes, id)
+		} else {
+			deniedIds = append(deniedIds, id)
+		}
+	}
+	herd.saveUpdateFreezes()
+	if len(deniedIds) > 0 {
+		sort.Slice(deniedIds, func(left, right int) bool {
+			return deniedIds[left] < deniedIds[right]
+		})
+		return fmt.Errorf("no access to freezes: %v", deniedIds)
+	}
+	return nil
+}
+
+// getHerdWideUpdateFreezes returns the active freezes which cover the whole
+// herd, sorted by ID.
+func (herd *Herd) getHerdWideUpdateFreezes() []*updateFreeze {
+	var freezes []*updateFreeze
+	for _, freeze := range herd.getUpdateFreezes() {
+		if freeze.isHerdWide() {
+			freezes = append(freezes, freeze)
+		}
+	}
+	return freezes
+}
+
+// getUpdateFreeze returns the oldest active freeze which covers the sub, or
+// nil if updates are not frozen for the sub.
+func (herd *Herd) getUpdateFreeze(sub *Sub) *updateFreeze {
+	for _, freeze := range herd.getUpdateFreezes() {
+		if freeze.matches(sub) {
+			return freeze
+		}
+	}
+	return nil
+}
+
+// getUpdateFreezes returns the active freezes, sorted by ID. Expired freezes
+// are removed.
+func (herd *Herd) getUpdateFreezes() []*updateFreeze {
+	now := time.Now()
+	herd.updateFreezesLock.RLock()
+	freezes := make([]*updateFreeze, 0, len(herd.updateFreezes))
+	numExpired := 0
+	for _, freeze := range herd.updateFreezes {
+		if freeze.hasExpired(now) {
+			numExpired++
+		} else {
+			freezes = append(freezes, freeze)
+		}
+	}
+	herd.updateFreezesLock.RUnlock()
+	if numExpired > 0 {
+		herd.updateFreezesLock.Lock()
+		for id, freeze := range herd.updateFreezes {
+			if freeze.hasExpired(now) {
+				herd.logger.Printf("Update freeze: %d expired\n", id)
+				delete(herd.updateFreezes, id)
+			}
+		}
+		herd.updateFreezesLock.Unlock()
+	}
+	sort.Slice(freezes, func(left, right int) bool {
+		return freezes[left].Id < freezes[right].Id
+	})
+	return freezes
+}
+
+// getUpdatesDisabledReason returns the reason updates are disabled for the sub,
+// or an empty string if they are not disabled.
+func (sub *Sub) getUpdatesDisabledReason() string {
+	if sub.mdb.DisableUpdates {
+		return "updates disabled in MDB"
+	}
+	if freeze := sub.herd.getUpdateFreeze(sub); freeze != nil {
+		return freeze.string()
+	}
+	return ""
+}
+
+// loadUpdateFreezes loads the freezes from filename, which is used to save
+// freezes from now on. Freezes which were added before loading (such as when
+// updates are disabled at startup) are kept and given new IDs.
+func (herd *Herd) loadUpdateFreezes(filename string) error {
+	var freezes []proto.UpdateFreeze
+	err := json.ReadFromFile(filename, &freezes)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	herd.updateFreezesLock.Lock()
+	defer herd.updateFreezesLock.Unlock()
+	herd.updateFreezesFilename = filename
+	localFreezes := make([]*updateFreeze, 0, len(herd.updateFreezes))
+	for _, freeze := range herd.updateFreezes {
+		localFreezes = append(localFreezes, freeze)
+	}
+	sort.Slice(localFreezes, func(left, right int) bool {
+		return localFreezes[left].Id < localFreezes[right].Id
+	})
+	herd.replaceUpdateFreezes(freezes)
+	for _, freeze := range localFreezes {
+		herd.nextUpdateFreezeId++
+		freeze.Id = herd.nextUpdateFreezeId
+		herd.updateFreezes[freeze.Id] = freeze
+	}
+	if len(freezes) > 0 {
+		herd.logger.Printf("Loaded %d update freezes\n", len(freezes))
+	}
+	herd.saveUpdateFreezes()
+	return nil
+}
+
+// reloadUpdateFreezes replaces the freezes with those saved (by another leader)
+// since they were last loaded or saved. This should be called when this
+// dominator becomes the leader.
+func (herd *Herd) reloadUpdateFreezes() {
+	herd.updateFreezesLock.Lock()
+	defer herd.updateFreezesLock.Unlock()
+	if herd.updateFreezesFilename == "" {
+		return
+	}
+	fi, err := os.Stat(herd.updateFreezesFilename)
+	if err != nil || !fi.ModTime().After(herd.updateFreezesModTime) {
+		return
+	}
+	var freezes []proto.UpdateFreeze
+	err = json.ReadFromFile(herd.updateFreezesFilename, &freezes)
+	if err != nil {
+		herd.logger.Printf("Error reloading update freezes: %s\n", err)
+		return
+	}
+	herd.updateFreezesModTime = fi.ModTime()
+	herd.replaceUpdateFreezes(freezes)
+	herd.logger.Printf("Reloaded %d update freezes\n", len(freezes))
+}
+
+// replaceUpdateFreezes replaces the freezes. Expired freezes are dropped. The
+// lock must be held.
+func (herd *Herd) replaceUpdateFreezes(freezes []proto.UpdateFreeze) {
+	now := time.Now()
+	herd.updateFreezes = make(map[uint64]*updateFreeze, len(freezes))
+	for _, freeze := range freezes {
+		if freeze.Id > herd.nextUpdateFreezeId {
+			herd.nextUpdateFreezeId = freeze.Id
+		}
+		updateFreeze := newUpdateFreeze(freeze)
+		if !updateFreeze.hasExpired(now) {
+			herd.updateFreezes[freeze.Id] = updateFreeze
+		}
+	}
+}
+
+// saveUpdateFreezes writes the freezes to the file, if one was loaded. Errors
+// are logged. The lock must be held.
+func (herd *Herd) saveUpdateFreezes() {
+	if herd.updateFreezesFilename == "" {
+		return
+	}
+	freezes := make([]proto.UpdateFreeze, 0, len(herd.updateFreezes))
+	for _, freeze := range herd.updateFreezes {
+		freezes = append(freezes, freeze.UpdateFreeze)
+	}
+	sort.Slice(freezes, func(left, right int) bool {
+		return freezes[left].Id < freezes[right].Id
+	})
+	err := json.WriteToFile(herd.updateFreezesFilename,
+		fsutil.PrivateFilePerms, "    ", freezes)
+	if err != nil {
+		herd.logger.Printf("Error saving update freezes: %s\n", err)
+		return
+	}
+	if fi, err := os.Stat(herd.updateFreezesFilename); err == nil {
+		herd.updateFreezesModTime = fi.ModTime()
+	}
+}
+
+func (herd *Herd) listUpdateFreezes() []proto.UpdateFreeze {
+	freezes := herd.getUpdateFreezes()
+	list := make([]proto.UpdateFreeze, 0, len(freezes))
+	for _, freeze := range freezes {
+		list = append(list, freeze.UpdateFreeze)
+	}
+	return list
+}
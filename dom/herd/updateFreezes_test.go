@@ -0,0 +1,201 @@
+package herd
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/log/testlogger"
+	"github.com/Cloud-Foundations/Dominator/lib/mdb"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	"github.com/Cloud-Foundations/Dominator/lib/tags"
+	proto "github.com/Cloud-Foundations/Dominator/proto/dominator"
+)
+
+func makeFreezeTestHerd(t *testing.T) *Herd {
+	return &Herd{
+		logger:        testlogger.New(t),
+		updateFreezes: make(map[uint64]*updateFreeze),
+	}
+}
+
+func TestUpdateFreezeMatches(t *testing.T) {
+	sub := &Sub{
+		mdb: mdb.Machine{
+			Hostname: "host0",
+			Location: "us-east/rack1",
+			Tags:     tags.Tags{"Team": "web"},
+		},
+	}
+	tests := []struct {
+		name    string
+		freeze  proto.UpdateFreeze
+		matches bool
+	}{
+		{"herd-wide", proto.UpdateFreeze{}, true},
+		{"hostname", proto.UpdateFreeze{Hostnames: []string{"host0"}}, true},
+		{"other hostname",
+			proto.UpdateFreeze{Hostnames: []string{"host1"}}, false},
+		{"location",
+			proto.UpdateFreeze{LocationsToMatch: []string{"us-east"}}, true},
+		{"other location",
+			proto.UpdateFreeze{LocationsToMatch: []string{"us-west"}}, false},
+		{"tag",
+			proto.UpdateFreeze{
+				TagsToMatch: tags.MatchTags{"Team": {"db", "web"}},
+			}, true},
+		{"other tag",
+			proto.UpdateFreeze{TagsToMatch: tags.MatchTags{"Team": {"db"}}},
+			false},
+		{"hostname and other location",
+			proto.UpdateFreeze{
+				Hostnames:        []string{"host0"},
+				LocationsToMatch: []string{"us-west"},
+			}, false},
+	}
+	for _, test := range tests {
+		freeze := newUpdateFreeze(test.freeze)
+		if matches := freeze.matches(sub); matches != test.matches {
+			t.Errorf("%s: matches: %t, expected: %t",
+				test.name, matches, test.matches)
+		}
+		if isHerdWide := freeze.isHerdWide(); isHerdWide !=
+			(test.name == "herd-wide") {
+			t.Errorf("%s: herd-wide: %t", test.name, isHerdWide)
+		}
+	}
+}
+
+func TestUpdateFreezeHasExpired(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name      string
+		expiresAt time.Time
+		expired   bool
+	}{
+		{"never", time.Time{}, false},
+		{"future", now.Add(time.Minute), false},
+		{"past", now.Add(-time.Minute), true},
+	}
+	for _, test := range tests {
+		freeze := newUpdateFreeze(proto.UpdateFreeze{ExpiresAt: test.expiresAt})
+		if expired := freeze.hasExpired(now); expired != test.expired {
+			t.Errorf("%s: expired: %t, expected: %t",
+				test.name, expired, test.expired)
+		}
+	}
+}
+
+func TestEnableUpdates(t *testing.T) {
+	herd := makeFreezeTestHerd(t)
+	admin := &srpc.AuthInformation{HaveMethodAccess: true}
+	herdWideId := herd.addUpdateFreeze(proto.UpdateFreeze{Reason: "all"})
+	scopedId := herd.addUpdateFreeze(proto.UpdateFreeze{
+		Hostnames: []string{"host0"},
+		Reason:    "one",
+	})
+	if err := herd.enableUpdates(scopedId+1, admin); err == nil {
+		t.Error("removing an unknown freeze did not fail")
+	}
+	if err := herd.enableUpdates(0, admin); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := herd.updateFreezes[herdWideId]; ok {
+		t.Error("herd-wide freeze not removed")
+	}
+	if _, ok := herd.updateFreezes[scopedId]; !ok {
+		t.Fatal("scoped freeze removed")
+	}
+	if err := herd.enableUpdates(scopedId, admin); err != nil {
+		t.Fatal(err)
+	}
+	if len(herd.updateFreezes) != 0 {
+		t.Errorf("%d freezes remain", len(herd.updateFreezes))
+	}
+}
+
+func TestEnableUpdatesAccess(t *testing.T) {
+	herd := makeFreezeTestHerd(t)
+	owner := &srpc.AuthInformation{Username: "alice"}
+	other := &srpc.AuthInformation{Username: "bob"}
+	ownedId := herd.addUpdateFreeze(proto.UpdateFreeze{CreatedBy: "alice"})
+	otherId := herd.addUpdateFreeze(proto.UpdateFreeze{CreatedBy: "bob"})
+	anonymousId := herd.addUpdateFreeze(proto.UpdateFreeze{})
+	if err := herd.enableUpdates(ownedId, nil); err == nil {
+		t.Error("freeze removed without authentication")
+	}
+	if err := herd.enableUpdates(ownedId, other); err == nil {
+		t.Error("freeze removed by another user")
+	}
+	// Only the freezes owned by the user are removed.
+	if err := herd.enableUpdates(0, owner); err == nil {
+		t.Error("removing freezes owned by others did not fail")
+	}
+	if _, ok := herd.updateFreezes[ownedId]; ok {
+		t.Error("owned freeze not removed")
+	}
+	if len(herd.updateFreezes) != 2 {
+		t.Errorf("%d freezes remain, expected 2", len(herd.updateFreezes))
+	}
+	if err := herd.enableUpdates(otherId, other); err != nil {
+		t.Error(err)
+	}
+	if err := herd.enableUpdates(anonymousId, other); err == nil {
+		t.Error("freeze without owner removed by non-administrator")
+	}
+}
+
+func TestUpdateFreezesPersistence(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "update-freezes")
+	herd := makeFreezeTestHerd(t)
+	if err := herd.loadUpdateFreezes(filename); err != nil {
+		t.Fatal(err)
+	}
+	herd.addUpdateFreeze(proto.UpdateFreeze{CreatedBy: "alice", Reason: "a"})
+	herd.addUpdateFreeze(proto.UpdateFreeze{
+		ExpiresAt: time.Now().Add(time.Hour),
+		Hostnames: []string{"host0"},
+		Reason:    "b",
+	})
+	herd.addUpdateFreeze(proto.UpdateFreeze{Reason: "c"})
+	if err := herd.enableUpdates(3, &srpc.AuthInformation{
+		HaveMethodAccess: true,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// A restarted herd with updates disabled at startup.
+	restartedHerd := makeFreezeTestHerd(t)
+	restartedHerd.addUpdateFreeze(proto.UpdateFreeze{Reason: "startup"})
+	if err := restartedHerd.loadUpdateFreezes(filename); err != nil {
+		t.Fatal(err)
+	}
+	freezes := restartedHerd.listUpdateFreezes()
+	expectedReasons := []string{"a", "b", "startup"}
+	if len(freezes) != len(expectedReasons) {
+		t.Fatalf("loaded %d freezes, expected %d",
+			len(freezes), len(expectedReasons))
+	}
+	for index, freeze := range freezes {
+		if freeze.Reason != expectedReasons[index] {
+			t.Errorf("freeze %d: reason: %s, expected: %s",
+				freeze.Id, freeze.Reason, expectedReasons[index])
+		}
+	}
+	if freezes[0].CreatedBy != "alice" {
+		t.Errorf("owner not saved: %s", freezes[0].CreatedBy)
+	}
+	if freezes[2].Id != 3 {
+		t.Errorf("startup freeze ID: %d, expected: 3", freezes[2].Id)
+	}
+	// Another leader removes a freeze, which is reloaded on taking over.
+	time.Sleep(10 * time.Millisecond) // Ensure a newer modification time.
+	if err := restartedHerd.enableUpdates(1, &srpc.AuthInformation{
+		Username: "alice",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	herd.reloadUpdateFreezes()
+	if freezes := herd.listUpdateFreezes(); len(freezes) != 2 {
+		t.Errorf("reloaded %d freezes, expected 2", len(freezes))
+	}
+}
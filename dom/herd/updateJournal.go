@@ -0,0 +1,331 @@
+package herd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/flagutil"
+	"github.com/Cloud-Foundations/Dominator/lib/fsutil"
+	"github.com/Cloud-Foundations/Dominator/lib/log"
+	proto "github.com/Cloud-Foundations/Dominator/proto/dominator"
+	subproto "github.com/Cloud-Foundations/Dominator/proto/sub"
+)
+
+const (
+	defaultMaxUpdateRecords = 1000
+	journalFilePerms        = fsutil.PrivateFilePerms
+	journalFilenameLayout   = "20060102-150405.000000"
+	journalFilenameSuffix   = ".journal"
+)
+
+var (
+	updateJournalMaxFileSize flagutil.Size = 16 << 20
+	updateJournalQuota       flagutil.Size = 256 << 20
+)
+
+func init() {
+	flag.Var(&updateJournalMaxFileSize, "updateJournalMaxFileSize",
+		"Maximum size of an update journal file before rotating")
+	flag.Var(&updateJournalQuota, "updateJournalQuota",
+		"Update journal quota. If exceeded, old journal files are deleted")
+}
+
+func (herd *Herd) openUpdateJournal(dirname string) error {
+	if err := os.MkdirAll(dirname, fsutil.PrivateDirPerms); err != nil {
+		return err
+	}
+	journal := &updateJournal{dirname: dirname, logger: herd.logger}
+	if err := journal.openNewFile(); err != nil {
+		return err
+	}
+	if err := journal.enforceQuota(); err != nil {
+		return err
+	}
+	herd.updateJournal = journal
+	return nil
+}
+
+func (herd *Herd) getUpdateHistory(request proto.GetUpdateHistoryRequest) (
+	[]proto.UpdateRecord, error) {
+	if herd.updateJournal == nil {
+		return nil, errors.New("update journal not enabled")
+	}
+	if request.MaxRecords < 1 {
+		request.MaxRecords = defaultMaxUpdateRecords
+	}
+	return herd.updateJournal.read(request)
+}
+
+func (herd *Herd) writeUpdateRecord(record proto.UpdateRecord) {
+	if herd.updateJournal == nil {
+		return
+	}
+	if err := herd.updateJournal.write(record); err != nil {
+		herd.logger.Printf("Error writing update journal: %s\n", err)
+	}
+}
+
+// listFiles returns the journal files, oldest first.
+func (journal *updateJournal) listFiles() ([]string, error) {
+	names, err := fsutil.ReadDirnames(journal.dirname, false)
+	if err != nil {
+		return nil, err
+	}
+	filenames := make([]string, 0, len(names))
+	for _, name := range names {
+		if strings.HasSuffix(name, journalFilenameSuffix) {
+			filenames = append(filenames, name)
+		}
+	}
+	sort.Strings(filenames)
+	return filenames, nil
+}
+
+// This should be called with the lock held.
+func (journal *updateJournal) enforceQuota() error {
+	filenames, err := journal.listFiles()
+	if err != nil {
+		return err
+	}
+	var usage flagutil.Size
+	for index := len(filenames) - 1; index >= 0; index-- {
+		pathname := filepath.Join(journal.dirname, filenames[index])
+		fi, err := os.Stat(pathname)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+		usage += flagutil.Size(fi.Size())
+		if usage > updateJournalQuota && index < len(filenames)-1 {
+			if err := os.Remove(pathname); err != nil {
+				return err
+			}
+			journal.logger.Printf("Deleted update journal file: %s\n",
+				filenames[index])
+		}
+	}
+	return nil
+}
+
+// This should be called with the lock held.
+func (journal *updateJournal) openNewFile() error {
+	filename := time.Now().UTC().Format(journalFilenameLayout) +
+		journalFilenameSuffix
+	file, err := os.OpenFile(filepath.Join(journal.dirname, filename),
+		os.O_CREATE|os.O_WRONLY|os.O_APPEND, journalFilePerms)
+	if err != nil {
+		return err
+	}
+	if journal.file != nil {
+		journal.file.Close()
+	}
+	journal.file = file
+	journal.filename = filename
+	journal.fileSize = 0
+	return nil
+}
+
+// read returns the matching records. The journal files are read without
+// holding the lock, so that writing records is not blocked. The file being
+// written is only read up to the last complete record.
+func (journal *updateJournal) read(request proto.GetUpdateHistoryRequest) (
+	[]proto.UpdateRecord, error) {
+	journal.Lock()
+	filenames, err := journal.listFiles()
+	currentFilename := journal.filename
+	currentFileSize := journal.fileSize
+	journal.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	var records []proto.UpdateRecord
+	// Read newest files first, stopping once enough records are collected.
+	for index := len(filenames) - 1; index >= 0; index-- {
+		maxSize := int64(-1)
+		if filenames[index] == currentFilename {
+			maxSize = int64(currentFileSize)
+		}
+		fileRecords, done, err := readJournalFile(
+			filepath.Join(journal.dirname, filenames[index]), maxSize,
+			request, journal.logger)
+		if err != nil {
+			return nil, err
+		}
+		records = append(fileRecords, records...)
+		if done || uint(len(records)) >= request.MaxRecords {
+			break
+		}
+	}
+	if uint(len(records)) > request.MaxRecords {
+		records = records[uint(len(records))-request.MaxRecords:]
+	}
+	return records, nil
+}
+
+func (journal *updateJournal) write(record proto.UpdateRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	journal.Lock()
+	defer journal.Unlock()
+	newSize := journal.fileSize + flagutil.Size(len(data))
+	if journal.fileSize > 0 && newSize > updateJournalMaxFileSize {
+		if err := journal.openNewFile(); err != nil {
+			return err
+		}
+		if err := journal.enforceQuota(); err != nil {
+			return err
+		}
+	}
+	nWritten, err := journal.file.Write(data)
+	if err == nil {
+		err = journal.file.Sync()
+	}
+	if err != nil {
+		// Remove any partial record, so that the next record is not appended
+		// to it.
+		if nWritten > 0 {
+			e := journal.file.Truncate(int64(journal.fileSize))
+			if e != nil {
+				journal.logger.Printf("Error truncating %s: %s\n",
+					journal.filename, e)
+			}
+		}
+		return err
+	}
+	journal.fileSize += flagutil.Size(nWritten)
+	return nil
+}
+
+// readJournalFile returns the matching records in the first maxSize bytes of
+// the file, or in the whole file if maxSize is negative. If the file contains
+// records older than request.Since, done is true. A malformed record at the end
+// of the file (left by a crash while writing) is logged and ignored.
+func readJournalFile(pathname string, maxSize int64,
+	request proto.GetUpdateHistoryRequest, logger log.Logger) (
+	[]proto.UpdateRecord, bool, error) {
+	file, err := os.Open(pathname)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, false, nil // Deleted by quota enforcement.
+		}
+		return nil, false, err
+	}
+	defer file.Close()
+	var reader io.Reader = file
+	if maxSize >= 0 {
+		reader = io.LimitReader(file, maxSize)
+	}
+	decoder := json.NewDecoder(bufio.NewReader(reader))
+	var done bool
+	var records []proto.UpdateRecord
+	for {
+		var record proto.UpdateRecord
+		if err := decoder.Decode(&record); err != nil {
+			if err == io.EOF {
+				break
+			}
+			if _, ok := err.(*json.SyntaxError); ok ||
+				err == io.ErrUnexpectedEOF {
+				logger.Printf("Ignoring malformed record at end of %s: %s\n",
+					pathname, err)
+				break
+			}
+			return nil, false, err
+		}
+		if !request.Since.IsZero() &&
+			record.CompletionTime.Before(request.Since) {
+			done = true
+			continue
+		}
+		if request.Hostname != "" && record.Hostname != request.Hostname {
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, done, nil
+}
+
+// completeUpdateRecords writes the pending update records for the sub. It
+// should be called once the Fetch and Update operations are no longer in
+// progress.
+func (sub *Sub) completeUpdateRecords(reply subproto.PollResponse) {
+	if record := sub.pendingFetchRecord; record != nil {
+		sub.pendingFetchRecord = nil
+		record.CompletionTime = time.Now()
+		record.Error = reply.LastFetchError
+		if record.Error == "" {
+			record.Result = proto.UpdateResultSucceeded
+		} else {
+			record.Result = proto.UpdateResultFailed
+		}
+		sub.herd.writeUpdateRecord(*record)
+	}
+	if record := sub.pendingUpdateRecord; record != nil {
+		sub.pendingUpdateRecord = nil
+		record.CompletionTime = time.Now()
+		record.TriggerFailures = reply.LastUpdateHadTriggerFailures
+		for _, result := range reply.LastUpdateHealthProbeResults {
+			if result.Error != "" {
+				record.HealthProbeFailures = true
+			}
+		}
+		switch reply.LastUpdateError {
+		case "":
+			record.Result = proto.UpdateResultSucceeded
+		case subproto.ErrorDisruptionPending:
+			record.Result = proto.UpdateResultDisruptionPending
+		case subproto.ErrorDisruptionDenied:
+			record.Result = proto.UpdateResultDisruptionDenied
+		default:
+			record.Error = reply.LastUpdateError
+			record.Result = proto.UpdateResultFailed
+		}
+		sub.herd.writeUpdateRecord(*record)
+	}
+}
+
+// makeUpdateRecord returns a record for an Update operation with the details
+// of the request filled in.
+func (sub *Sub) makeUpdateRecord(request subproto.UpdateRequest,
+	fast bool) *proto.UpdateRecord {
+	record := &proto.UpdateRecord{
+		Hostname:   sub.mdb.Hostname,
+		ImageName:  request.ImageName,
+		NumDeleted: uint(len(request.PathsToDelete)),
+		NumChanged: uint(len(request.DirectoriesToMake) +
+			len(request.InodesToMake) + len(request.HardlinksToMake) +
+			len(request.InodesToChange)),
+		Operation: proto.UpdateOperationUpdate,
+		StartTime: time.Now(),
+		Username:  sub.fastUpdateUsername,
+	}
+	if fast {
+		record.Operation = proto.UpdateOperationFastUpdate
+	}
+	if request.ForceDisruption && sub.pendingForceDisruptiveUpdate {
+		record.ForcedDisruption = true
+		if record.Username == "" {
+			record.Username = sub.forceDisruptiveUpdateUsername
+		}
+	}
+	if sub.requiredImage != nil {
+		for _, trigger := range matchTriggers(sub.requiredImage.Triggers,
+			request) {
+			record.Triggers = append(record.Triggers, trigger.Service)
+		}
+	}
+	return record
+}
@@ -0,0 +1,123 @@
+package herd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/log/testlogger"
+	proto "github.com/Cloud-Foundations/Dominator/proto/dominator"
+)
+
+func TestUpdateJournal(t *testing.T) {
+	dirname, err := ioutil.TempDir("", "updateJournal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirname)
+	herd := &Herd{logger: testlogger.New(t)}
+	if err := herd.openUpdateJournal(dirname); err != nil {
+		t.Fatal(err)
+	}
+	savedMaxFileSize := updateJournalMaxFileSize
+	defer func() { updateJournalMaxFileSize = savedMaxFileSize }()
+	updateJournalMaxFileSize = 1024 // Force rotation.
+	startTime := time.Now()
+	for index := 0; index < 40; index++ {
+		herd.writeUpdateRecord(proto.UpdateRecord{
+			CompletionTime: startTime.Add(time.Duration(index) * time.Second),
+			Hostname:       fmt.Sprintf("host%d", index%4),
+			Operation:      proto.UpdateOperationUpdate,
+			Result:         proto.UpdateResultSucceeded,
+		})
+	}
+	filenames, err := herd.updateJournal.listFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(filenames) < 2 {
+		t.Errorf("journal not rotated: %d files", len(filenames))
+	}
+	records, err := herd.getUpdateHistory(proto.GetUpdateHistoryRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 40 {
+		t.Fatalf("expected 40 records, got %d", len(records))
+	}
+	records, err = herd.getUpdateHistory(proto.GetUpdateHistoryRequest{
+		Hostname:   "host1",
+		MaxRecords: 3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records, got %d", len(records))
+	}
+	expected := startTime.Add(37 * time.Second)
+	if !records[2].CompletionTime.Equal(expected) {
+		t.Errorf("newest record: %s, expected: %s",
+			records[2].CompletionTime, expected)
+	}
+	records, err = herd.getUpdateHistory(proto.GetUpdateHistoryRequest{
+		Since: startTime.Add(30 * time.Second),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 10 {
+		t.Errorf("expected 10 records since cutoff, got %d", len(records))
+	}
+}
+
+func TestUpdateJournalPartialRecord(t *testing.T) {
+	herd := &Herd{logger: testlogger.New(t)}
+	if err := herd.openUpdateJournal(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	herd.writeUpdateRecord(proto.UpdateRecord{Hostname: "host0"})
+	// Simulate a record which is being written while the journal is read.
+	journal := herd.updateJournal
+	if _, err := journal.file.Write([]byte(`{"Hostname":"ho`)); err != nil {
+		t.Fatal(err)
+	}
+	records, err := herd.getUpdateHistory(proto.GetUpdateHistoryRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Hostname != "host0" {
+		t.Errorf("records: %v, expected only host0", records)
+	}
+}
+
+func TestUpdateJournalMalformedTrailingRecord(t *testing.T) {
+	herd := &Herd{logger: testlogger.New(t)}
+	if err := herd.openUpdateJournal(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	herd.writeUpdateRecord(proto.UpdateRecord{Hostname: "host0"})
+	// Simulate a crash while writing a record, followed by a restart.
+	journal := herd.updateJournal
+	if _, err := journal.file.Write([]byte(`{"Hostname":"ho`)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond) // Ensure a new filename.
+	journal.Lock()
+	err := journal.openNewFile()
+	journal.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	herd.writeUpdateRecord(proto.UpdateRecord{Hostname: "host1"})
+	records, err := herd.getUpdateHistory(proto.GetUpdateHistoryRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Hostname != "host0" ||
+		records[1].Hostname != "host1" {
+		t.Errorf("records: %v, expected host0 and host1", records)
+	}
+}
@@ -0,0 +1,102 @@
+package herd
+
+import (
+	"github.com/Cloud-Foundations/Dominator/lib/mdb"
+	"github.com/Cloud-Foundations/Dominator/lib/stringutil"
+	"github.com/Cloud-Foundations/Dominator/lib/tags/tagmatcher"
+	proto "github.com/Cloud-Foundations/Dominator/proto/dominator"
+)
+
+const subWatcherBufferSize = 4096
+
+func (herd *Herd) registerSubWatcher(request proto.WatchSubsRequest) (
+	<-chan proto.WatchSubsResponse, []proto.SubInfo) {
+	channel := make(chan proto.WatchSubsResponse, subWatcherBufferSize)
+	watcher := &subWatcher{
+		channel:   channel,
+		hostnames: stringutil.ConvertListToMap(request.Hostnames, false),
+		selectFunc: makeSelector(request.LocationsToMatch,
+			request.StatusesToMatch, tagmatcher.New(request.TagsToMatch, false)),
+	}
+	// Register before taking the snapshot so that no changes are missed.
+	herd.subWatchersLock.Lock()
+	herd.subWatchers[channel] = watcher
+	herd.subWatchersLock.Unlock()
+	herd.RLock()
+	defer herd.RUnlock()
+	subInfos := make([]proto.SubInfo, 0, len(herd.subsByIndex))
+	for _, sub := range herd.subsByIndex {
+		if watcher.matches(sub) {
+			subInfos = append(subInfos, sub.makeInfo())
+		}
+	}
+	return channel, subInfos
+}
+
+func (herd *Herd) unregisterSubWatcher(
+	channel <-chan proto.WatchSubsResponse) {
+	herd.subWatchersLock.Lock()
+	defer herd.subWatchersLock.Unlock()
+	delete(herd.subWatchers, channel)
+}
+
+// notifySubWatchers sends the sub to the watchers which match it. If previous
+// is not nil, the sub is also sent to the watchers which matched its previous
+// state, so that they learn when it stops matching. Watchers which have fallen
+// too far behind have their channel closed and are removed.
+func (herd *Herd) notifySubWatchers(sub *Sub, previous *Sub, deleted bool) {
+	herd.subWatchersLock.Lock()
+	defer herd.subWatchersLock.Unlock()
+	if len(herd.subWatchers) < 1 {
+		return
+	}
+	subInfo := sub.makeInfo()
+	for key, watcher := range herd.subWatchers {
+		if !watcher.matches(sub) &&
+			(previous == nil || !watcher.matches(previous)) {
+			continue
+		}
+		select {
+		case watcher.channel <- proto.WatchSubsResponse{
+			Deleted: deleted,
+			Sub:     &subInfo,
+		}:
+		default:
+			herd.logger.Println("Sub watcher fell behind, disconnecting")
+			close(watcher.channel)
+			delete(herd.subWatchers, key)
+		}
+	}
+}
+
+func (watcher *subWatcher) matches(sub *Sub) bool {
+	if len(watcher.hostnames) > 0 {
+		if _, ok := watcher.hostnames[sub.mdb.Hostname]; !ok {
+			return false
+		}
+	}
+	return watcher.selectFunc(sub)
+}
+
+// checkWatchState notifies the watchers if any of the watched fields of the
+// sub have changed since the last notification.
+func (sub *Sub) checkWatchState() {
+	state := subWatchState{
+		status:                  sub.publishedStatus,
+		lastSuccessfulImageName: sub.lastSuccessfulImageName,
+		lastUpdateTime:          sub.lastUpdateTime,
+		lastDisruptionState:     sub.lastDisruptionState,
+	}
+	if state == sub.lastWatchState {
+		return
+	}
+	previous := makeWatchedSub(sub.mdb, sub.lastWatchState.status)
+	sub.lastWatchState = state
+	sub.herd.notifySubWatchers(sub, previous, false)
+}
+
+// makeWatchedSub returns a Sub with the fields which watchers match on, to
+// record the previous state of a sub.
+func makeWatchedSub(machine mdb.Machine, status subStatus) *Sub {
+	return &Sub{mdb: machine, status: status}
+}
@@ -0,0 +1,94 @@
+package herd
+
+import (
+	"testing"
+
+	"github.com/Cloud-Foundations/Dominator/lib/log/testlogger"
+	"github.com/Cloud-Foundations/Dominator/lib/mdb"
+	proto "github.com/Cloud-Foundations/Dominator/proto/dominator"
+)
+
+func receiveWatchResponses(
+	channel <-chan proto.WatchSubsResponse) []proto.WatchSubsResponse {
+	var responses []proto.WatchSubsResponse
+	for {
+		select {
+		case response := <-channel:
+			responses = append(responses, response)
+		default:
+			return responses
+		}
+	}
+}
+
+func TestWatchSubsStatusChange(t *testing.T) {
+	herd := &Herd{
+		logger:      testlogger.New(t),
+		subWatchers: make(map[<-chan proto.WatchSubsResponse]*subWatcher),
+	}
+	sub := &Sub{herd: herd, mdb: mdb.Machine{Hostname: "sub0"}}
+	herd.subsByIndex = []*Sub{sub}
+	sub.status = statusSynced
+	sub.publishedStatus = sub.status
+	sub.checkWatchState()
+	channel, subInfos := herd.registerSubWatcher(proto.WatchSubsRequest{
+		StatusesToMatch: []string{subStatus(statusSynced).String()},
+	})
+	defer herd.unregisterSubWatcher(channel)
+	if len(subInfos) != 1 {
+		t.Fatalf("snapshot: %d subs, expected 1", len(subInfos))
+	}
+	// The watcher must learn that the sub no longer matches.
+	sub.status = statusUpdating
+	sub.publishedStatus = sub.status
+	sub.checkWatchState()
+	responses := receiveWatchResponses(channel)
+	if len(responses) != 1 ||
+		responses[0].Sub.Status != subStatus(statusUpdating).String() {
+		t.Fatalf("responses after leaving: %v", responses)
+	}
+	// Changes while not matching are not sent.
+	sub.status = statusFailedToUpdate
+	sub.publishedStatus = sub.status
+	sub.checkWatchState()
+	if responses := receiveWatchResponses(channel); len(responses) > 0 {
+		t.Fatalf("responses while not matching: %v", responses)
+	}
+	sub.status = statusSynced
+	sub.publishedStatus = sub.status
+	sub.checkWatchState()
+	if responses := receiveWatchResponses(channel); len(responses) != 1 {
+		t.Fatalf("responses after returning: %v", responses)
+	}
+}
+
+func TestWatchSubsMdbChange(t *testing.T) {
+	herd := &Herd{
+		logger:      testlogger.New(t),
+		subWatchers: make(map[<-chan proto.WatchSubsResponse]*subWatcher),
+	}
+	channel, _ := herd.registerSubWatcher(proto.WatchSubsRequest{
+		LocationsToMatch: []string{"here"},
+	})
+	defer herd.unregisterSubWatcher(channel)
+	sub := &Sub{herd: herd, mdb: mdb.Machine{Hostname: "sub0"}}
+	previous := makeWatchedSub(sub.mdb, sub.status)
+	sub.mdb.Location = "here"
+	herd.notifySubWatchers(sub, previous, false)
+	previous = makeWatchedSub(sub.mdb, sub.status)
+	sub.mdb.Location = "there"
+	herd.notifySubWatchers(sub, previous, false)
+	previous = makeWatchedSub(sub.mdb, sub.status)
+	sub.mdb.Location = "elsewhere"
+	herd.notifySubWatchers(sub, previous, false)
+	responses := receiveWatchResponses(channel)
+	if len(responses) != 2 {
+		t.Fatalf("%d responses, expected 2", len(responses))
+	}
+	for index, location := range []string{"here", "there"} {
+		if responses[index].Sub.Location != location {
+			t.Errorf("response %d: location: %s, expected: %s",
+				index, responses[index].Sub.Location, location)
+		}
+	}
+}
@@ -20,10 +20,19 @@ type Manager struct {
 	imageExpireChannel   chan<- string
 	imagesByName         map[string]*image.Image
 	missingImages        map[string]error
+	rejectedImages       map[string]error // Failed the signature check.
+	signatureChecker     *image.SignatureChecker
 }
 
 func New(imageServerAddress string, logger log.Logger) *Manager {
-	return newManager(imageServerAddress, logger)
+	return newManager(imageServerAddress, nil, logger)
+}
+
+// NewWithSignatureChecker is like New, except that images which fail the
+// signature check are rejected.
+func NewWithSignatureChecker(imageServerAddress string,
+	signatureChecker *image.SignatureChecker, logger log.Logger) *Manager {
+	return newManager(imageServerAddress, signatureChecker, logger)
 }
 
 func (m *Manager) Get(name string, wait bool) (*image.Image, error) {
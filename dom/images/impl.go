@@ -10,7 +10,17 @@ import (
 	"github.com/Cloud-Foundations/Dominator/lib/stringutil"
 )
 
-func newManager(imageServerAddress string, logger log.Logger) *Manager {
+// rejectedError wraps the error for an image which failed the signature check.
+type rejectedError struct {
+	err error
+}
+
+func (e *rejectedError) Error() string {
+	return e.err.Error()
+}
+
+func newManager(imageServerAddress string,
+	signatureChecker *image.SignatureChecker, logger log.Logger) *Manager {
 	imageInterestChannel := make(chan map[string]struct{})
 	imageRequestChannel := make(chan string)
 	imageExpireChannel := make(chan string, 16)
@@ -23,6 +33,8 @@ func newManager(imageServerAddress string, logger log.Logger) *Manager {
 		imageExpireChannel:   imageExpireChannel,
 		imagesByName:         make(map[string]*image.Image),
 		missingImages:        make(map[string]error),
+		rejectedImages:       make(map[string]error),
+		signatureChecker:     signatureChecker,
 	}
 	go m.manager(imageInterestChannel, imageRequestChannel, imageExpireChannel)
 	return m
@@ -37,6 +49,9 @@ func (m *Manager) getNoWait(name string) (*image.Image, error) {
 	if err, ok := m.missingImages[name]; ok {
 		return nil, err
 	}
+	if err, ok := m.rejectedImages[name]; ok {
+		return nil, err
+	}
 	return nil, nil
 }
 
@@ -121,6 +136,13 @@ func (m *Manager) setInterest(imageClient *srpc.Client,
 			m.Unlock()
 		}
 	}
+	for name := range m.rejectedImages {
+		if _, ok := imageList[name]; !ok {
+			m.Lock()
+			delete(m.rejectedImages, name)
+			m.Unlock()
+		}
+	}
 	if deletedSome {
 		m.rebuildDeDuper()
 	}
@@ -132,6 +154,9 @@ func (m *Manager) requestImage(imageClient *srpc.Client,
 	if _, ok := m.imagesByName[name]; ok {
 		return imageClient
 	}
+	if _, ok := m.rejectedImages[name]; ok {
+		return imageClient // Images are immutable: do not fetch again.
+	}
 	var img *image.Image
 	var err error
 	imageClient, img, err = m.loadImage(imageClient, name)
@@ -143,6 +168,11 @@ func (m *Manager) requestImage(imageClient *srpc.Client,
 		return imageClient
 	}
 	delete(m.imagesByName, name)
+	if rejected, ok := err.(*rejectedError); ok {
+		delete(m.missingImages, name)
+		m.rejectedImages[name] = rejected.err
+		return imageClient
+	}
 	m.missingImages[name] = err
 	return imageClient
 }
@@ -170,6 +200,10 @@ func (m *Manager) loadImage(imageClient *srpc.Client, name string) (
 	if img == nil || m.scheduleExpiration(img, name) {
 		return imageClient, nil, nil
 	}
+	if err := m.signatureChecker.CheckImage(img); err != nil {
+		m.logger.Printf("Rejecting image: %s: %s\n", name, err)
+		return imageClient, nil, &rejectedError{err}
+	}
 	if err := img.FileSystem.RebuildInodePointers(); err != nil {
 		m.logger.Printf("Error building inode pointers for image: %s %s",
 			name, err)
@@ -22,6 +22,9 @@ func (sub *Sub) buildUpdateRequest(img *image.Image,
 	sub.requiredFS = img.FileSystem
 	sub.filter = img.Filter
 	request.SparseImage = img.Filter == nil
+	if img.Filter != nil && len(img.Filter.FilterLines) > 0 {
+		request.ImageFilterLines = img.Filter.FilterLines
+	}
 	request.Triggers = img.Triggers
 	sub.requiredInodeToSubInode = make(map[uint64]uint64)
 	sub.inodesMapped = make(map[uint64]struct{})
@@ -32,7 +35,9 @@ func (sub *Sub) buildUpdateRequest(img *image.Image,
 	for _, hash := range sub.ObjectCache {
 		sub.subObjectCacheUsage[hash] = 0
 	}
-	if !filesystem.CompareDirectoriesMetadata(&sub.FileSystem.DirectoryInode,
+	subRootInode := ignoreUnmanagedXattrs(&sub.FileSystem.DirectoryInode,
+		&sub.requiredFS.DirectoryInode).(*filesystem.DirectoryInode)
+	if !filesystem.CompareDirectoriesMetadata(subRootInode,
 		&sub.requiredFS.DirectoryInode, nil) {
 		makeDirectory(request, &sub.requiredFS.DirectoryInode, "/", false)
 	}
@@ -41,6 +46,18 @@ func (sub *Sub) buildUpdateRequest(img *image.Image,
 		"/", deleteMissingComputedFiles, ignoreMissingComputedFiles, logger) {
 		return true
 	}
+	// Tell the sub which files are computed, so that changes to them are not
+	// reported as drift.
+	for _, inode := range request.InodesToMake {
+		if _, ok := sub.ComputedInodes[inode.Name]; ok {
+			request.ComputedFiles = append(request.ComputedFiles, inode.Name)
+		}
+	}
+	for _, inode := range request.InodesToChange {
+		if _, ok := sub.ComputedInodes[inode.Name]; ok {
+			request.ComputedFiles = append(request.ComputedFiles, inode.Name)
+		}
+	}
 	// Look for multiply used objects and tell the sub.
 	for obj, useCount := range sub.subObjectCacheUsage {
 		if useCount > 1 {
@@ -168,8 +185,8 @@ func (sub *Sub) addEntry(request *subproto.UpdateRequest,
 func (sub *Sub) compareEntries(request *subproto.UpdateRequest,
 	subEntry, requiredEntry *filesystem.DirectoryEntry, myPathName string,
 	logger log.DebugLogger) {
-	subInode := subEntry.Inode()
 	requiredInode := requiredEntry.Inode()
+	subInode := ignoreUnmanagedXattrs(subEntry.Inode(), requiredInode)
 	sameType, sameMetadata, sameData := filesystem.CompareInodes(
 		subInode, requiredInode, nil)
 	if requiredInode, ok := requiredInode.(*filesystem.DirectoryInode); ok {
@@ -201,6 +218,55 @@ func (sub *Sub) compareEntries(request *subproto.UpdateRequest,
 	sub.addInode(request, requiredEntry, myPathName, logger)
 }
 
+// ignoreUnmanagedXattrs returns subInode, or a copy of it without the extended
+// attributes which requiredInode does not have. Extended attributes are only
+// managed if they are in the image, so that images which predate support for
+// them (or were built without SELinux labels) do not strip them.
+func ignoreUnmanagedXattrs(subInode,
+	requiredInode filesystem.GenericInode) filesystem.GenericInode {
+	subXattrs := getXattrs(subInode)
+	if len(subXattrs) < 1 {
+		return subInode
+	}
+	xattrs := filesystem.SelectXattrs(subXattrs, getXattrs(requiredInode))
+	if len(xattrs) == len(subXattrs) {
+		return subInode
+	}
+	switch inode := subInode.(type) {
+	case *filesystem.DirectoryInode:
+		newInode := *inode
+		newInode.Xattrs = xattrs
+		return &newInode
+	case *filesystem.RegularInode:
+		newInode := *inode
+		newInode.Xattrs = xattrs
+		return &newInode
+	case *filesystem.SpecialInode:
+		newInode := *inode
+		newInode.Xattrs = xattrs
+		return &newInode
+	case *filesystem.SymlinkInode:
+		newInode := *inode
+		newInode.Xattrs = xattrs
+		return &newInode
+	}
+	return subInode
+}
+
+func getXattrs(inode filesystem.GenericInode) map[string][]byte {
+	switch inode := inode.(type) {
+	case *filesystem.DirectoryInode:
+		return inode.Xattrs
+	case *filesystem.RegularInode:
+		return inode.Xattrs
+	case *filesystem.SpecialInode:
+		return inode.Xattrs
+	case *filesystem.SymlinkInode:
+		return inode.Xattrs
+	}
+	return nil
+}
+
 func (sub *Sub) relink(request *subproto.UpdateRequest,
 	subEntry, requiredEntry *filesystem.DirectoryEntry,
 	myPathName string, logger log.DebugLogger) bool {
@@ -266,6 +332,7 @@ func makeDirectory(request *subproto.UpdateRequest,
 	newDirectoryInode.Mode = requiredInode.Mode
 	newDirectoryInode.Uid = requiredInode.Uid
 	newDirectoryInode.Gid = requiredInode.Gid
+	newDirectoryInode.Xattrs = requiredInode.Xattrs
 	newInode.GenericInode = &newDirectoryInode
 	if create {
 		request.DirectoriesToMake = append(request.DirectoriesToMake, newInode)
@@ -295,7 +362,8 @@ func (sub *Sub) addInode(request *subproto.UpdateRequest,
 				continue
 			}
 			if inum, found := subFS.FilenameToInodeTable()[name]; found {
-				subInode := sub.FileSystem.InodeTable[inum]
+				subInode := ignoreUnmanagedXattrs(
+					sub.FileSystem.InodeTable[inum], requiredInode)
 				_, sameMetadata, sameData := filesystem.CompareInodes(
 					subInode, requiredInode, nil)
 				if sameMetadata && sameData {
@@ -46,6 +46,53 @@ func TestFileToChange(t *testing.T) {
 	}
 }
 
+func TestXattrsToChange(t *testing.T) {
+	subFS := testDataFile0(0)
+	imageFS := testDataFile0(0)
+	imageFS.InodeTable[1].(*filesystem.RegularInode).Xattrs =
+		map[string][]byte{"security.capability": {1}}
+	request := makeUpdateRequest(t, imageFS, subFS)
+	if len(request.InodesToChange) != 1 {
+		t.Error("Inode xattrs not being changed")
+	}
+}
+
+func TestUnmanagedXattrs(t *testing.T) {
+	subFS := testDataFile0(0)
+	subFS.InodeTable[1].(*filesystem.RegularInode).Xattrs =
+		map[string][]byte{"security.selinux": []byte("label")}
+	request := makeUpdateRequest(t, testDataFile0(0), subFS)
+	if !reflect.DeepEqual(request, subproto.UpdateRequest{
+		SparseImage: request.SparseImage,
+	}) {
+		t.Error("Unmanaged xattrs being changed")
+	}
+}
+
+func TestPartlyManagedXattrs(t *testing.T) {
+	subFS := testDataFile0(0)
+	subFS.InodeTable[1].(*filesystem.RegularInode).Xattrs =
+		map[string][]byte{
+			"security.capability": {1},
+			"security.selinux":    []byte("label"),
+		}
+	imageFS := testDataFile0(0)
+	imageFS.InodeTable[1].(*filesystem.RegularInode).Xattrs =
+		map[string][]byte{"security.capability": {1}}
+	request := makeUpdateRequest(t, imageFS, subFS)
+	if !reflect.DeepEqual(request, subproto.UpdateRequest{
+		SparseImage: request.SparseImage,
+	}) {
+		t.Error("Unmanaged xattrs being changed")
+	}
+	imageFS.InodeTable[1].(*filesystem.RegularInode).Xattrs =
+		map[string][]byte{"security.capability": {2}}
+	request = makeUpdateRequest(t, imageFS, subFS)
+	if len(request.InodesToChange) != 1 {
+		t.Error("Managed xattrs not being changed")
+	}
+}
+
 func TestSameOnlyDirectory(t *testing.T) {
 	request := makeUpdateRequest(t, testDataDirectory0(), testDataDirectory0())
 	if len(request.PathsToDelete) != 0 {
@@ -22,16 +22,28 @@ func Setup(herd *herd.Herd, logger log.Logger) {
 				"ClearSafetyShutoff":    1,
 				"ForceDisruptiveUpdate": 1,
 				"GetInfoForSubs":        1,
+				"GetRollouts":           1,
+				"GetUpdateHistory":      1,
 				"ListSubs":              1,
+				"ListUpdateFreezes":     1,
+				"PreviewUpdate":         1,
+				"WatchSubs":             1,
 			}),
 	}
 	srpc.RegisterNameWithOptions("Dominator", rpcObj,
 		srpc.ReceiverOptions{
 			PublicMethods: []string{
 				"ClearSafetyShutoff",
+				"EnableUpdates",
 				"FastUpdate",
 				"ForceDisruptiveUpdate",
 				"GetInfoForSubs",
+				"GetLeader",
+				"GetRollouts",
+				"GetUpdateHistory",
 				"ListSubs",
+				"ListUpdateFreezes",
+				"PreviewUpdate",
+				"WatchSubs",
 			}})
 }
@@ -0,0 +1,23 @@
+package rpcd
+
+import (
+	"errors"
+
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	proto "github.com/Cloud-Foundations/Dominator/proto/dominator"
+)
+
+// BlockMethod blocks all methods except GetLeader if this dominator is not the
+// leader, so that clients will use the leader instead.
+func (t *rpcType) BlockMethod(methodName string,
+	authInfo *srpc.AuthInformation) (func(), error) {
+	if methodName != "GetLeader" {
+		if leader, isLeader := t.herd.GetLeader(); !isLeader {
+			if leader == "" {
+				return nil, errors.New("not leader, no leader elected")
+			}
+			return nil, errors.New(proto.NotLeaderErrorPrefix + leader)
+		}
+	}
+	return t.PerUserMethodLimiter.BlockMethod(methodName, authInfo)
+}
@@ -14,5 +14,10 @@ func (t *rpcType) DisableUpdates(conn *srpc.Conn,
 		t.logger.Printf("DisableUpdates(%s): by %s\n",
 			request.Reason, conn.Username())
 	}
-	return t.herd.DisableUpdates(conn.Username(), request.Reason)
+	freezeId, err := t.herd.DisableUpdates(conn.Username(), request)
+	if err != nil {
+		return err
+	}
+	*reply = dominator.DisableUpdatesResponse{FreezeId: freezeId}
+	return nil
 }
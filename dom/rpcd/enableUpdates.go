@@ -9,10 +9,11 @@ func (t *rpcType) EnableUpdates(conn *srpc.Conn,
 	request dominator.EnableUpdatesRequest,
 	reply *dominator.EnableUpdatesResponse) error {
 	if conn.Username() == "" {
-		t.logger.Printf("EnableUpdates(%s)\n", request.Reason)
+		t.logger.Printf("EnableUpdates(%s, %d)\n",
+			request.Reason, request.FreezeId)
 	} else {
-		t.logger.Printf("EnableUpdates(%s): by %s\n",
-			request.Reason, conn.Username())
+		t.logger.Printf("EnableUpdates(%s, %d): by %s\n",
+			request.Reason, request.FreezeId, conn.Username())
 	}
-	return t.herd.EnableUpdates()
+	return t.herd.EnableUpdates(request.FreezeId, conn.GetAuthInformation())
 }
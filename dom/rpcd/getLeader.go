@@ -0,0 +1,13 @@
+package rpcd
+
+import (
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	"github.com/Cloud-Foundations/Dominator/proto/dominator"
+)
+
+func (t *rpcType) GetLeader(conn *srpc.Conn,
+	request dominator.GetLeaderRequest,
+	reply *dominator.GetLeaderResponse) error {
+	reply.Address, reply.IsLeader = t.herd.GetLeader()
+	return nil
+}
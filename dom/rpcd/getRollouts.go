@@ -0,0 +1,13 @@
+package rpcd
+
+import (
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	"github.com/Cloud-Foundations/Dominator/proto/dominator"
+)
+
+func (t *rpcType) GetRollouts(conn *srpc.Conn,
+	request dominator.GetRolloutsRequest,
+	reply *dominator.GetRolloutsResponse) error {
+	*reply = dominator.GetRolloutsResponse{Rollouts: t.herd.GetRollouts()}
+	return nil
+}
@@ -0,0 +1,18 @@
+package rpcd
+
+import (
+	"github.com/Cloud-Foundations/Dominator/lib/errors"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	"github.com/Cloud-Foundations/Dominator/proto/dominator"
+)
+
+func (t *rpcType) GetUpdateHistory(conn *srpc.Conn,
+	request dominator.GetUpdateHistoryRequest,
+	reply *dominator.GetUpdateHistoryResponse) error {
+	records, err := t.herd.GetUpdateHistory(request)
+	*reply = dominator.GetUpdateHistoryResponse{
+		Error:   errors.ErrorToString(err),
+		Records: records,
+	}
+	return nil
+}
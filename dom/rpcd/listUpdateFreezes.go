@@ -0,0 +1,15 @@
+package rpcd
+
+import (
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	"github.com/Cloud-Foundations/Dominator/proto/dominator"
+)
+
+func (t *rpcType) ListUpdateFreezes(conn *srpc.Conn,
+	request dominator.ListUpdateFreezesRequest,
+	reply *dominator.ListUpdateFreezesResponse) error {
+	*reply = dominator.ListUpdateFreezesResponse{
+		Freezes: t.herd.ListUpdateFreezes(),
+	}
+	return nil
+}
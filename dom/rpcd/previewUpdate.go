@@ -0,0 +1,22 @@
+package rpcd
+
+import (
+	"github.com/Cloud-Foundations/Dominator/lib/errors"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	"github.com/Cloud-Foundations/Dominator/proto/dominator"
+)
+
+func (t *rpcType) PreviewUpdate(conn *srpc.Conn,
+	request dominator.PreviewUpdateRequest,
+	reply *dominator.PreviewUpdateResponse) error {
+	if conn.Username() == "" {
+		t.logger.Printf("PreviewUpdate(%s)\n", request.Hostname)
+	} else {
+		t.logger.Printf("PreviewUpdate(%s): by %s\n",
+			request.Hostname, conn.Username())
+	}
+	response, err := t.herd.PreviewUpdate(request, conn.GetAuthInformation())
+	response.Error = errors.ErrorToString(err)
+	*reply = response
+	return nil
+}
@@ -0,0 +1,61 @@
+package rpcd
+
+import (
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	"github.com/Cloud-Foundations/Dominator/proto/dominator"
+)
+
+func (t *rpcType) WatchSubs(conn *srpc.Conn, decoder srpc.Decoder,
+	encoder srpc.Encoder) error {
+	var request dominator.WatchSubsRequest
+	if err := decoder.Decode(&request); err != nil {
+		return err
+	}
+	if conn.Username() == "" {
+		t.logger.Printf("WatchSubs() from: %s\n", conn.RemoteAddr())
+	} else {
+		t.logger.Printf("WatchSubs() from: %s: by %s\n",
+			conn.RemoteAddr(), conn.Username())
+	}
+	updateChannel, subInfos := t.herd.RegisterSubWatcher(request)
+	defer t.herd.UnregisterSubWatcher(updateChannel)
+	for index := range subInfos {
+		reply := dominator.WatchSubsResponse{Sub: &subInfos[index]}
+		if err := encoder.Encode(reply); err != nil {
+			return err
+		}
+	}
+	// Signal end of initial snapshot.
+	if err := encoder.Encode(dominator.WatchSubsResponse{}); err != nil {
+		return err
+	}
+	if err := conn.Flush(); err != nil {
+		return err
+	}
+	closeChannel := conn.GetCloseNotifier()
+	for {
+		select {
+		case reply, ok := <-updateChannel:
+			if !ok {
+				reply := dominator.WatchSubsResponse{
+					Error: "watcher fell behind",
+				}
+				if err := encoder.Encode(reply); err != nil {
+					return err
+				}
+				return conn.Flush()
+			}
+			if err := encoder.Encode(reply); err != nil {
+				return err
+			}
+			if len(updateChannel) > 0 {
+				continue
+			}
+		case err := <-closeChannel:
+			return err
+		}
+		if err := conn.Flush(); err != nil {
+			return err
+		}
+	}
+}
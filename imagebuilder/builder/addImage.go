@@ -2,6 +2,7 @@ package builder
 
 import (
 	"bytes"
+	"crypto/ed25519"
 	"errors"
 	"fmt"
 	"io"
@@ -98,10 +99,15 @@ func (h *hasher) ReadAndHash(inode *filesystem.RegularInode, file *os.File,
 }
 
 func addImage(client srpc.ClientI, request proto.BuildImageRequest,
-	img *image.Image) (string, error) {
+	img *image.Image, signingKey ed25519.PrivateKey) (string, error) {
 	if request.ExpiresIn > 0 {
 		img.ExpiresAt = time.Now().Add(request.ExpiresIn)
 	}
+	if signingKey != nil {
+		if err := img.Sign(signingKey, "imaginator"); err != nil {
+			return "", err
+		}
+	}
 	name := makeImageName(request.StreamName)
 	if err := imageclient.AddImage(client, name, img); err != nil {
 		return "", errors.New("remote error: " + err.Error())
@@ -2,6 +2,7 @@ package builder
 
 import (
 	"bytes"
+	"crypto/ed25519"
 	"io"
 	stdlog "log"
 	"regexp"
@@ -204,6 +205,7 @@ type BuildLocalOptions struct {
 	BindMounts        []string
 	ManifestDirectory string
 	MtimesCopyFilter  *filter.Filter
+	SigningKey        ed25519.PrivateKey // If nil, images are not signed.
 	Variables         map[string]string
 }
 
@@ -234,6 +236,7 @@ type Builder struct {
 	imageStreams                map[string]*imageStreamType
 	imageStreamsToAutoRebuild   []string
 	relationshipsQuickLinks     []WebLink
+	signingKey                  ed25519.PrivateKey
 	slaveDriver                 *slavedriver.SlaveDriver
 	buildResultsLock            sync.RWMutex
 	currentBuildInfos           map[string]*currentBuildInfo // Key: stream name.
@@ -254,6 +257,7 @@ type BuilderOptions struct {
 	MaximumExpirationDurationPrivileged time.Duration // Default: 1 month.
 	MinimumExpirationDuration           time.Duration // Def: 15 min. Min: 5 min
 	PresentationImageServerAddress      string
+	SigningKeyFile                      string // If set, sign built images.
 	StateDirectory                      string
 	VariablesFile                       string
 }
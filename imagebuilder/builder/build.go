@@ -326,7 +326,7 @@ func (b *Builder) buildWithLogger(builder imageBuilder, client srpc.ClientI,
 		img.CreatedFor = authInfo.Username
 	}
 	uploadStartTime := time.Now()
-	if name, err := addImage(client, request, img); err != nil {
+	if name, err := addImage(client, request, img, b.signingKey); err != nil {
 		fmt.Fprintln(buildLog, err)
 		return nil, "", err
 	} else {
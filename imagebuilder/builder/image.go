@@ -376,7 +376,7 @@ func buildImageFromManifestAndUpload(client srpc.ClientI,
 	if err != nil {
 		return nil, "", err
 	}
-	name, err := addImage(client, request, img)
+	name, err := addImage(client, request, img, options.SigningKey)
 	if err != nil {
 		return nil, "", err
 	}
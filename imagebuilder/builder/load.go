@@ -2,6 +2,7 @@ package builder
 
 import (
 	"bufio"
+	"crypto/ed25519"
 	"fmt"
 	"io"
 	"os"
@@ -18,6 +19,7 @@ import (
 	"github.com/Cloud-Foundations/Dominator/lib/filter"
 	"github.com/Cloud-Foundations/Dominator/lib/format"
 	"github.com/Cloud-Foundations/Dominator/lib/fsutil"
+	"github.com/Cloud-Foundations/Dominator/lib/image"
 	"github.com/Cloud-Foundations/Dominator/lib/json"
 	"github.com/Cloud-Foundations/Dominator/lib/log"
 	"github.com/Cloud-Foundations/Dominator/lib/srpc"
@@ -124,6 +126,13 @@ func load(options BuilderOptions, params BuilderParams) (*Builder, error) {
 			return nil, err
 		}
 	}
+	var signingKey ed25519.PrivateKey
+	if options.SigningKeyFile != "" {
+		signingKey, err = image.LoadSigningKey(options.SigningKeyFile)
+		if err != nil {
+			return nil, err
+		}
+	}
 	generateDependencyTrigger := make(chan chan<- struct{}, 1)
 	streamsLoadedChannel := make(chan struct{})
 	b := &Builder{
@@ -150,6 +159,7 @@ func load(options BuilderOptions, params BuilderParams) (*Builder, error) {
 		lastBuildResults:            make(map[string]buildResultType),
 		packagerTypes:               masterConfiguration.PackagerTypes,
 		relationshipsQuickLinks:     masterConfiguration.RelationshipsQuickLinks,
+		signingKey:                  signingKey,
 	}
 	if options.VariablesFile != "" {
 		rcChannel := fsutil.WatchFile(options.VariablesFile, params.Logger)
@@ -79,6 +79,11 @@ func GetReplicationMaster(client srpc.ClientI) (string, error) {
 	return getReplicationMaster(client)
 }
 
+func GetRetentionReport(client srpc.ClientI, dirname string) (
+	proto.RetentionReport, error) {
+	return getRetentionReport(client, dirname)
+}
+
 func GetImageWithTimeout(client srpc.ClientI, name string,
 	timeout time.Duration) (*image.Image, error) {
 	return getImage(client, name, timeout)
@@ -115,3 +120,8 @@ func RestoreImageFromArchive(client srpc.ClientI,
 	proto.RestoreImageFromArchiveResponse, error) {
 	return restoreImageFromArchive(client, request)
 }
+
+func SetRetentionPolicy(client srpc.ClientI, dirname string,
+	policy image.RetentionPolicy) error {
+	return setRetentionPolicy(client, dirname, policy)
+}
@@ -0,0 +1,38 @@
+package client
+
+import (
+	"github.com/Cloud-Foundations/Dominator/lib/errors"
+	"github.com/Cloud-Foundations/Dominator/lib/image"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	"github.com/Cloud-Foundations/Dominator/proto/imageserver"
+)
+
+func getRetentionReport(client srpc.ClientI, dirname string) (
+	imageserver.RetentionReport, error) {
+	request := imageserver.GetRetentionReportRequest{DirectoryName: dirname}
+	var reply imageserver.GetRetentionReportResponse
+	err := client.RequestReply("ImageServer.GetRetentionReport", request,
+		&reply)
+	if err != nil {
+		return imageserver.RetentionReport{}, err
+	}
+	if err := errors.New(reply.Error); err != nil {
+		return imageserver.RetentionReport{}, err
+	}
+	return reply.RetentionReport, nil
+}
+
+func setRetentionPolicy(client srpc.ClientI, dirname string,
+	policy image.RetentionPolicy) error {
+	request := imageserver.SetRetentionPolicyRequest{
+		DirectoryName:   dirname,
+		RetentionPolicy: policy,
+	}
+	var reply imageserver.SetRetentionPolicyResponse
+	err := client.RequestReply("ImageServer.SetRetentionPolicy", request,
+		&reply)
+	if err != nil {
+		return err
+	}
+	return errors.New(reply.Error)
+}
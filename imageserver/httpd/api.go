@@ -8,7 +8,7 @@ import (
 
 	"github.com/Cloud-Foundations/Dominator/imageserver/scanner"
 	"github.com/Cloud-Foundations/Dominator/lib/html"
-	"github.com/Cloud-Foundations/Dominator/lib/objectserver/filesystem"
+	"github.com/Cloud-Foundations/Dominator/lib/objectserver"
 )
 
 type HtmlWriter interface {
@@ -19,11 +19,11 @@ var htmlWriters []HtmlWriter
 
 type state struct {
 	imageDataBase *scanner.ImageDataBase
-	objectServer  *filesystem.ObjectServer
+	objectServer  objectserver.ObjectGetter
 }
 
 func StartServer(portNum uint, imdb *scanner.ImageDataBase,
-	objSrv *filesystem.ObjectServer, daemon bool) error {
+	objSrv objectserver.ObjectGetter, daemon bool) error {
 	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", portNum))
 	if err != nil {
 		return err
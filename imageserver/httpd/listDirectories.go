@@ -30,9 +30,15 @@ func (s state) listDirectoriesHandler(w http.ResponseWriter,
 	fmt.Fprintln(writer, "<body>")
 	fmt.Fprintln(writer, "<h3>")
 	fmt.Fprintln(writer, `<table border="1" style="width:100%">`)
-	tw, _ := html.NewTableWriter(writer, true, "Name", "Owner Group")
+	tw, _ := html.NewTableWriter(writer, true, "Name", "Owner Group",
+		"Retention Policy")
 	for _, directory := range directories {
-		tw.WriteRow("", "", directory.Name, directory.Metadata.OwnerGroup)
+		var retentionPolicy string
+		if !directory.Metadata.RetentionPolicy.IsEmpty() {
+			retentionPolicy = directory.Metadata.RetentionPolicy.String()
+		}
+		tw.WriteRow("", "", directory.Name, directory.Metadata.OwnerGroup,
+			retentionPolicy)
 	}
 	tw.Close()
 	fmt.Fprintln(writer, "</body>")
@@ -23,13 +23,22 @@ func (t *srpcType) AddImageTrusted(conn *srpc.Conn,
 	if t.imageDataBase.CheckImage(request.ImageName) {
 		return errors.New("image already exists")
 	}
+	if t.replicationMaster == "" && len(t.replicationSources) > 0 &&
+		t.imageDataBase.CheckDeletedImage(request.ImageName) {
+		// Peers will not accept the image, so names may not be reused.
+		return errors.New("image was deleted")
+	}
 	if request.Image == nil {
 		return errors.New("nil image")
 	}
 	if request.Image.FileSystem == nil {
 		return errors.New("nil file-system")
 	}
-	err := request.Image.VerifyObjects(t.imageDataBase.ObjectServer())
+	err := t.signatureChecker.CheckImage(request.Image)
+	if err != nil {
+		return err
+	}
+	err = request.Image.VerifyObjects(t.imageDataBase.ObjectServer())
 	if err != nil {
 		return err
 	}
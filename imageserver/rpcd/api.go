@@ -5,9 +5,11 @@ import (
 	"flag"
 	"io"
 	"sync"
+	"time"
 
 	"github.com/Cloud-Foundations/Dominator/imageserver/scanner"
 	"github.com/Cloud-Foundations/Dominator/lib/filter"
+	"github.com/Cloud-Foundations/Dominator/lib/image"
 	"github.com/Cloud-Foundations/Dominator/lib/log"
 	"github.com/Cloud-Foundations/Dominator/lib/objectserver"
 	"github.com/Cloud-Foundations/Dominator/lib/srpc"
@@ -22,15 +24,25 @@ var (
 		"Filename containing filter to exclude images from replication (default do not exclude any)")
 	replicationIncludeFilter = flag.String("replicationIncludeFilter", "",
 		"Filename containing filter to include images for replication (default include all)")
+	trustedSigningKeys = flag.String("trustedSigningKeys", "",
+		"Filename containing public keys trusted to sign images")
+
+	imageSignaturePolicy image.SignaturePolicy
 )
 
+func init() {
+	flag.Var(&imageSignaturePolicy, "imageSignaturePolicy",
+		"Policy for image signatures: ignore, allow-unsigned or require")
+}
+
 type srpcType struct {
 	imageDataBase             *scanner.ImageDataBase
 	excludeFilter             *filter.Filter
 	finishedReplication       <-chan struct{} // Closed when finished.
 	includeFilter             *filter.Filter
 	replicationMaster         string
-	imageserverResource       *srpc.ClientResource
+	replicationSources        []*replicationSource // Master or peers.
+	signatureChecker          *image.SignatureChecker
 	objSrv                    objectserver.FullObjectServer
 	archiveMode               bool
 	logger                    log.DebugLogger
@@ -38,6 +50,25 @@ type srpcType struct {
 	numReplicationClients     uint
 	imagesBeingInjectedLock   sync.Mutex // Protect imagesBeingInjected.
 	imagesBeingInjected       map[string]struct{}
+	directoryLock             sync.Mutex // Serialise directory changes.
+}
+
+// replicationSource is an imageserver which changes are replicated from:
+// either the replication master or a peer which also accepts changes.
+type replicationSource struct {
+	address        string
+	peer           bool
+	resource       *srpc.ClientResource
+	mutex          sync.Mutex // Protect everything below.
+	conflicts      map[string]struct{}
+	connected      bool
+	connectedSince time.Time
+	deletedSince   time.Time // Deletions up to this were replicated.
+	lastError      string
+	lastErrorTime  time.Time
+	lastLag        time.Duration
+	lastUpdateTime time.Time
+	synchronised   bool // True if the initial list was replicated.
 }
 
 type htmlWriter srpcType
@@ -49,24 +80,35 @@ func (hw *htmlWriter) WriteHtml(writer io.Writer) {
 var replicationMessage = "cannot make changes while under replication control" +
 	", go to master: "
 
+// Setup registers the ImageServer RPC methods. If replicationMaster is not
+// empty, changes are replicated from the master and are rejected locally.
+// Otherwise, if replicationPeers is not empty, changes are accepted locally and
+// exchanged with the peers.
 func Setup(imdb *scanner.ImageDataBase, replicationMaster string,
-	objSrv objectserver.FullObjectServer,
+	replicationPeers []string, objSrv objectserver.FullObjectServer,
 	logger log.DebugLogger) (*htmlWriter, error) {
 	if *archiveMode && replicationMaster == "" {
 		return nil, errors.New("replication master required in archive mode")
 	}
+	if replicationMaster != "" && len(replicationPeers) > 0 {
+		return nil, errors.New("cannot have replication master and peers")
+	}
 	finishedReplication := make(chan struct{})
 	srpcObj := &srpcType{
 		imageDataBase:       imdb,
 		finishedReplication: finishedReplication,
 		replicationMaster:   replicationMaster,
-		imageserverResource: srpc.NewClientResource("tcp", replicationMaster),
 		objSrv:              objSrv,
 		logger:              logger,
 		archiveMode:         *archiveMode,
 		imagesBeingInjected: make(map[string]struct{}),
 	}
 	var err error
+	srpcObj.signatureChecker, err = image.NewSignatureChecker(
+		imageSignaturePolicy, *trustedSigningKeys)
+	if err != nil {
+		return nil, err
+	}
 	if *replicationExcludeFilter != "" {
 		srpcObj.excludeFilter, err = filter.Load(*replicationExcludeFilter)
 		if err != nil {
@@ -94,12 +136,30 @@ func Setup(imdb *scanner.ImageDataBase, replicationMaster string,
 			"GetImageExpiration",
 			"GetImageUpdates",
 			"GetReplicationMaster",
+			"GetRetentionReport",
 			"ListDirectories",
 			"ListImages",
 			"ListSelectedImages",
+			"SetRetentionPolicy",
 		}})
 	if replicationMaster != "" {
-		go srpcObj.replicator(finishedReplication)
+		source := newReplicationSource(replicationMaster, false)
+		srpcObj.replicationSources = []*replicationSource{source}
+		go srpcObj.replicator(source, func() { close(finishedReplication) })
+	} else if len(replicationPeers) > 0 {
+		// Finished when each peer has been replicated from or has failed.
+		var waitGroup sync.WaitGroup
+		for _, address := range replicationPeers {
+			source := newReplicationSource(address, true)
+			srpcObj.replicationSources = append(srpcObj.replicationSources,
+				source)
+			waitGroup.Add(1)
+			go srpcObj.replicator(source, waitGroup.Done)
+		}
+		go func() {
+			waitGroup.Wait()
+			close(finishedReplication)
+		}()
 	} else {
 		close(finishedReplication)
 	}
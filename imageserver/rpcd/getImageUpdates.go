@@ -30,6 +30,9 @@ func (t *srpcType) getImageUpdates(conn *srpc.Conn,
 	select {
 	case <-t.finishedReplication:
 	default:
+		if request.Peer {
+			break // Peers replicate from each other: do not deadlock.
+		}
 		t.logger.Println(
 			"Blocking replication client until I've finished replicating")
 		<-t.finishedReplication
@@ -48,11 +51,7 @@ func (t *srpcType) getImageUpdates(conn *srpc.Conn,
 	directories := t.imageDataBase.ListDirectories()
 	image.SortDirectories(directories)
 	for _, directory := range directories {
-		imageUpdate := imageserver.ImageUpdate{
-			Directory: &directory,
-			Operation: imageserver.OperationMakeDirectory,
-		}
-		if err := conn.Encode(imageUpdate); err != nil {
+		if err := t.sendMakeDirectory(conn, directory); err != nil {
 			t.logger.Println(err)
 			return err
 		}
@@ -61,14 +60,27 @@ func (t *srpcType) getImageUpdates(conn *srpc.Conn,
 		if t.checkIgnoreImage(request.IgnoreExpiring, imageName) {
 			continue
 		}
-		imageUpdate := imageserver.ImageUpdate{Name: imageName}
-		if err := conn.Encode(imageUpdate); err != nil {
+		err := t.sendUpdate(conn, imageName, imageserver.OperationAddImage)
+		if err != nil {
 			t.logger.Println(err)
 			return err
 		}
 	}
+	if request.Peer {
+		// Deletions may have been missed while the peer was disconnected.
+		deletedImages := t.imageDataBase.ListDeletedImages(request.DeletedSince)
+		for _, imageName := range deletedImages {
+			err := t.sendUpdate(conn, imageName,
+				imageserver.OperationDeleteImage)
+			if err != nil {
+				t.logger.Println(err)
+				return err
+			}
+		}
+	}
 	// Signal end of initial image list.
-	if err := conn.Encode(imageserver.ImageUpdate{}); err != nil {
+	err := conn.Encode(imageserver.ImageUpdate{SentAt: startTime})
+	if err != nil {
 		t.logger.Println(err)
 		return err
 	}
@@ -86,19 +98,19 @@ func (t *srpcType) getImageUpdates(conn *srpc.Conn,
 			if t.checkIgnoreImage(request.IgnoreExpiring, imageName) {
 				break
 			}
-			if err := sendUpdate(conn, imageName,
+			if err := t.sendUpdate(conn, imageName,
 				imageserver.OperationAddImage); err != nil {
 				t.logger.Println(err)
 				return err
 			}
 		case imageName := <-deleteChannel:
-			if err := sendUpdate(conn, imageName,
+			if err := t.sendUpdate(conn, imageName,
 				imageserver.OperationDeleteImage); err != nil {
 				t.logger.Println(err)
 				return err
 			}
 		case directory := <-mkdirChannel:
-			if err := sendMakeDirectory(conn, directory); err != nil {
+			if err := t.sendMakeDirectory(conn, directory); err != nil {
 				t.logger.Println(err)
 				return err
 			}
@@ -142,15 +154,28 @@ func (t *srpcType) incrementNumReplicationClients(increment bool) {
 	}
 }
 
-func sendUpdate(encoder srpc.Encoder, name string, operation uint) error {
-	imageUpdate := imageserver.ImageUpdate{Name: name, Operation: operation}
+func (t *srpcType) sendUpdate(encoder srpc.Encoder, name string,
+	operation uint) error {
+	imageUpdate := imageserver.ImageUpdate{
+		Name:      name,
+		Operation: operation,
+		SentAt:    time.Now(),
+	}
+	if operation == imageserver.OperationAddImage {
+		if img := t.imageDataBase.GetImage(name); img != nil {
+			imageUpdate.CreatedOn = img.CreatedOn
+		}
+	}
 	return encoder.Encode(imageUpdate)
 }
 
-func sendMakeDirectory(encoder srpc.Encoder, directory image.Directory) error {
+func (t *srpcType) sendMakeDirectory(encoder srpc.Encoder,
+	directory image.Directory) error {
 	imageUpdate := imageserver.ImageUpdate{
-		Directory: &directory,
-		Operation: imageserver.OperationMakeDirectory,
+		Directory:  &directory,
+		ModifiedOn: t.imageDataBase.GetDirectoryModifiedOn(directory.Name),
+		Operation:  imageserver.OperationMakeDirectory,
+		SentAt:     time.Now(),
 	}
 	return encoder.Encode(imageUpdate)
 }
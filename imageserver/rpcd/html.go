@@ -3,6 +3,10 @@ package rpcd
 import (
 	"fmt"
 	"io"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/format"
+	"github.com/Cloud-Foundations/Dominator/lib/html"
 )
 
 func (hw *htmlWriter) writeHtml(writer io.Writer) {
@@ -12,6 +16,9 @@ func (hw *htmlWriter) writeHtml(writer io.Writer) {
 	}
 	fmt.Fprintf(writer, "Replication clients: %d<br>\n",
 		hw.getNumReplicationClients())
+	if len(hw.replicationSources) > 0 {
+		hw.writeReplicationSources(writer)
+	}
 }
 
 func (hw *htmlWriter) getNumReplicationClients() uint {
@@ -19,3 +26,60 @@ func (hw *htmlWriter) getNumReplicationClients() uint {
 	defer hw.numReplicationClientsLock.RUnlock()
 	return hw.numReplicationClients
 }
+
+func (hw *htmlWriter) writeReplicationSources(writer io.Writer) {
+	fmt.Fprintln(writer, `<table border="1">`)
+	tw, _ := html.NewTableWriter(writer, true, "Source", "Type", "State",
+		"Last Update", "Lag", "Conflicts", "Last Error")
+	for _, source := range hw.replicationSources {
+		source.writeHtmlRow(tw)
+	}
+	tw.Close()
+}
+
+func (source *replicationSource) writeHtmlRow(tw *html.TableWriter) {
+	source.mutex.Lock()
+	defer source.mutex.Unlock()
+	sourceType := "master"
+	if source.peer {
+		sourceType = "peer"
+	}
+	var background, state string
+	if !source.connected {
+		background = "#ffb0b0"
+		state = "disconnected"
+	} else if !source.synchronised {
+		background = "yellow"
+		state = "synchronising"
+	} else {
+		state = "synchronised, connected for " +
+			format.Duration(time.Since(source.connectedSince))
+	}
+	var conflicts, lastUpdate, lag, lastError string
+	if len(source.conflicts) > 0 {
+		background = "#ffb0b0"
+		conflicts = fmt.Sprintf(`<font color="red">%d</font>`,
+			len(source.conflicts))
+	}
+	if !source.lastUpdateTime.IsZero() {
+		lastUpdate = format.Duration(time.Since(source.lastUpdateTime)) +
+			" ago"
+	}
+	if source.lastLag > 0 {
+		lag = format.Duration(source.lastLag)
+	}
+	if source.lastError != "" {
+		lastError = fmt.Sprintf("%s (%s ago)", source.lastError,
+			format.Duration(time.Since(source.lastErrorTime)))
+	}
+	tw.OpenRow("", background)
+	tw.WriteData("", fmt.Sprintf(`<a href="http://%s/">%s</a>`,
+		source.address, source.address))
+	tw.WriteData("", sourceType)
+	tw.WriteData("", state)
+	tw.WriteData("", lastUpdate)
+	tw.WriteData("", lag)
+	tw.WriteData("", conflicts)
+	tw.WriteData("", lastError)
+	tw.CloseRow()
+}
@@ -0,0 +1,79 @@
+package rpcd
+
+import (
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	"github.com/Cloud-Foundations/Dominator/proto/imageserver"
+)
+
+func newReplicationSource(address string, peer bool) *replicationSource {
+	return &replicationSource{
+		address:  address,
+		peer:     peer,
+		resource: srpc.NewClientResource("tcp", address),
+	}
+}
+
+func (source *replicationSource) getDeletedSince() time.Time {
+	source.mutex.Lock()
+	defer source.mutex.Unlock()
+	return source.deletedSince
+}
+
+func (source *replicationSource) recordUpdate(
+	imageUpdate imageserver.ImageUpdate) {
+	source.mutex.Lock()
+	defer source.mutex.Unlock()
+	source.lastUpdateTime = time.Now()
+	// Only changes show the lag, the initial list may have been sent earlier.
+	if source.synchronised && !imageUpdate.SentAt.IsZero() {
+		source.lastLag = source.lastUpdateTime.Sub(imageUpdate.SentAt)
+	}
+}
+
+// setConflict records whether the image conflicts with the image on the source.
+func (source *replicationSource) setConflict(name string, conflict bool) {
+	source.mutex.Lock()
+	defer source.mutex.Unlock()
+	if !conflict {
+		delete(source.conflicts, name)
+	} else if source.conflicts == nil {
+		source.conflicts = map[string]struct{}{name: {}}
+	} else {
+		source.conflicts[name] = struct{}{}
+	}
+}
+
+func (source *replicationSource) setConnected() {
+	source.mutex.Lock()
+	defer source.mutex.Unlock()
+	source.conflicts = nil // Conflicts are found again from the initial list.
+	source.connected = true
+	source.connectedSince = time.Now()
+}
+
+func (source *replicationSource) setDisconnected() {
+	source.mutex.Lock()
+	defer source.mutex.Unlock()
+	source.connected = false
+	source.synchronised = false
+}
+
+func (source *replicationSource) setError(err error) {
+	source.mutex.Lock()
+	defer source.mutex.Unlock()
+	source.lastError = err.Error()
+	source.lastErrorTime = time.Now()
+}
+
+// setSynchronised records that the initial list was replicated. Deletions up to
+// sentAt need not be sent again.
+func (source *replicationSource) setSynchronised(sentAt time.Time) {
+	source.mutex.Lock()
+	defer source.mutex.Unlock()
+	source.synchronised = true
+	if !sentAt.IsZero() {
+		source.deletedSince = sentAt
+	}
+}
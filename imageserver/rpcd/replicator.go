@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"sync"
 	"time"
 
 	imageclient "github.com/Cloud-Foundations/Dominator/imageserver/client"
@@ -16,13 +17,21 @@ import (
 	"github.com/Cloud-Foundations/Dominator/proto/imageserver"
 )
 
-func (t *srpcType) replicator(finishedReplication chan<- struct{}) {
+// replicator replicates changes from the source. The finished function is
+// called once the initial list of images has been replicated. For a peer it is
+// also called if the first attempt fails, since peers may be down.
+func (t *srpcType) replicator(source *replicationSource, finished func()) {
+	var finishedOnce sync.Once
+	finishedFunc := func() { finishedOnce.Do(finished) }
 	initialTimeout := time.Second * 15
 	timeout := initialTimeout
 	var nextSleepStopTime time.Time
 	method := "ImageServer.GetImageUpdates"
 	var request *imageserver.GetFilteredImageUpdatesRequest
-	if t.archiveMode && !*archiveExpiringImages {
+	if source.peer {
+		method = "ImageServer.GetFilteredImageUpdates"
+		request = &imageserver.GetFilteredImageUpdatesRequest{Peer: true}
+	} else if t.archiveMode && !*archiveExpiringImages {
 		method = "ImageServer.GetFilteredImageUpdates"
 		request = &imageserver.GetFilteredImageUpdatesRequest{
 			IgnoreExpiring: true,
@@ -30,14 +39,16 @@ func (t *srpcType) replicator(finishedReplication chan<- struct{}) {
 	}
 	for {
 		nextSleepStopTime = time.Now().Add(timeout)
-		if client, err := srpc.DialHTTP("tcp", t.replicationMaster,
+		if client, err := srpc.DialHTTP("tcp", source.address,
 			timeout); err != nil {
-			t.logger.Printf("Error dialing: %s %s\n", t.replicationMaster, err)
+			t.logger.Printf("Error dialing: %s %s\n", source.address, err)
+			source.setError(err)
 		} else {
 			if conn, err := client.Call(method); err != nil {
 				t.logger.Println(err)
+				source.setError(err)
 			} else {
-				err := t.getUpdates(conn, &finishedReplication, request)
+				err := t.getUpdates(source, conn, finishedFunc, request)
 				if err != nil {
 					if err == io.EOF {
 						t.logger.Println(
@@ -48,11 +59,16 @@ func (t *srpcType) replicator(finishedReplication chan<- struct{}) {
 					} else {
 						t.logger.Println(err)
 					}
+					source.setError(err)
 				}
 				conn.Close()
 			}
 			client.Close()
 		}
+		source.setDisconnected()
+		if source.peer {
+			finishedFunc()
+		}
 		time.Sleep(nextSleepStopTime.Sub(time.Now()))
 		if timeout < time.Minute {
 			timeout *= 2
@@ -60,16 +76,19 @@ func (t *srpcType) replicator(finishedReplication chan<- struct{}) {
 	}
 }
 
-func (t *srpcType) getUpdates(conn *srpc.Conn,
-	finishedReplication *chan<- struct{},
-	request *imageserver.GetFilteredImageUpdatesRequest) error {
-	t.logger.Printf("Image replicator: connected to: %s\n", t.replicationMaster)
+func (t *srpcType) getUpdates(source *replicationSource, conn *srpc.Conn,
+	finished func(), request *imageserver.GetFilteredImageUpdatesRequest) error {
+	t.logger.Printf("Image replicator: connected to: %s\n", source.address)
+	source.setConnected()
 	replicationStartTime := time.Now()
 	initialImages := make(map[string]struct{})
-	if t.archiveMode {
+	if t.archiveMode || source.peer {
 		initialImages = nil
 	}
 	if request != nil {
+		if source.peer {
+			request.DeletedSince = source.getDeletedSince()
+		}
 		if err := conn.Encode(*request); err != nil {
 			return err
 		}
@@ -86,6 +105,7 @@ func (t *srpcType) getUpdates(conn *srpc.Conn,
 			}
 			return errors.New("decode err: " + err.Error())
 		}
+		source.recordUpdate(imageUpdate)
 		switch imageUpdate.Operation {
 		case imageserver.OperationAddImage:
 			if imageUpdate.Name == "" { // Initial list has been sent.
@@ -93,35 +113,24 @@ func (t *srpcType) getUpdates(conn *srpc.Conn,
 					t.deleteMissingImages(initialImages)
 					initialImages = nil
 				}
-				if *finishedReplication != nil {
-					close(*finishedReplication)
-					*finishedReplication = nil
-				}
+				finished()
 				if someImagesFailed {
 					t.logger.Printf("Partially replicated images in %s\n",
 						format.Duration(time.Since(replicationStartTime)))
 					return nil
 				}
+				source.setSynchronised(imageUpdate.SentAt)
 				t.logger.Printf("Replicated all current images in %s\n",
 					format.Duration(time.Since(replicationStartTime)))
 				continue
 			}
-			if t.excludeFilter != nil &&
-				t.excludeFilter.Match(imageUpdate.Name) {
-				t.logger.Debugf(0, "Excluding %s from replication\n",
-					imageUpdate.Name)
-				continue
-			}
-			if t.includeFilter != nil &&
-				!t.includeFilter.Match(imageUpdate.Name) {
-				t.logger.Debugf(0, "Not including %s in replication\n",
-					imageUpdate.Name)
+			if t.checkExcluded(imageUpdate.Name) {
 				continue
 			}
 			if initialImages != nil {
 				initialImages[imageUpdate.Name] = struct{}{}
 			}
-			if err := t.addImage(imageUpdate.Name); err != nil {
+			if err := t.addImage(source, imageUpdate); err != nil {
 				t.logger.Printf("error adding image: %s: %s\n",
 					imageUpdate.Name, err)
 				someImagesFailed = true
@@ -130,6 +139,19 @@ func (t *srpcType) getUpdates(conn *srpc.Conn,
 			if t.archiveMode {
 				continue
 			}
+			if source.peer {
+				if t.checkExcluded(imageUpdate.Name) {
+					continue
+				}
+				source.setConflict(imageUpdate.Name, false)
+				err := t.imageDataBase.DeleteReplicatedImage(imageUpdate.Name)
+				if err != nil {
+					t.logger.Printf("error deleting image: %s: %s\n",
+						imageUpdate.Name, err)
+					someImagesFailed = true
+				}
+				continue
+			}
 			t.logger.Printf("Replicator(%s): delete image\n", imageUpdate.Name)
 			err := t.imageDataBase.DeleteImage(imageUpdate.Name,
 				&srpc.AuthInformation{HaveMethodAccess: true})
@@ -141,13 +163,55 @@ func (t *srpcType) getUpdates(conn *srpc.Conn,
 			if directory == nil {
 				return errors.New("nil imageUpdate.Directory")
 			}
-			if err := t.imageDataBase.UpdateDirectory(*directory); err != nil {
+			err := t.updateDirectory(source, *directory,
+				imageUpdate.ModifiedOn)
+			if err != nil {
 				return err
 			}
 		}
 	}
 }
 
+// updateDirectory applies a directory change from the source. Concurrent
+// changes on peers are resolved by keeping the latest change.
+func (t *srpcType) updateDirectory(source *replicationSource,
+	directory image.Directory, modifiedOn time.Time) error {
+	t.directoryLock.Lock()
+	defer t.directoryLock.Unlock()
+	if source.peer {
+		metadata, ok := t.imageDataBase.GetDirectoryMetadata(directory.Name)
+		if ok {
+			localModifiedOn := t.imageDataBase.GetDirectoryModifiedOn(
+				directory.Name)
+			if metadata == directory.Metadata &&
+				!modifiedOn.After(localModifiedOn) {
+				return nil
+			}
+			if modifiedOn.Before(localModifiedOn) {
+				return nil
+			}
+			if modifiedOn.Equal(localModifiedOn) &&
+				directory.Metadata.OwnerGroup < metadata.OwnerGroup {
+				return nil
+			}
+		}
+	}
+	return t.imageDataBase.UpdateDirectory(directory, modifiedOn)
+}
+
+// checkExcluded returns true if the image is excluded from replication.
+func (t *srpcType) checkExcluded(name string) bool {
+	if t.excludeFilter != nil && t.excludeFilter.Match(name) {
+		t.logger.Debugf(0, "Excluding %s from replication\n", name)
+		return true
+	}
+	if t.includeFilter != nil && !t.includeFilter.Match(name) {
+		t.logger.Debugf(0, "Not including %s in replication\n", name)
+		return true
+	}
+	return false
+}
+
 func (t *srpcType) deleteMissingImages(imagesToKeep map[string]struct{}) {
 	missingImages := make([]string, 0)
 	for _, imageName := range t.imageDataBase.ListImages() {
@@ -165,10 +229,10 @@ func (t *srpcType) deleteMissingImages(imagesToKeep map[string]struct{}) {
 	}
 }
 
-func (t *srpcType) extendImageExpiration(name string,
-	img *image.Image) (bool, error) {
+func (t *srpcType) extendImageExpiration(source *replicationSource,
+	name string, img *image.Image) (bool, error) {
 	timeout := time.Second * 60
-	client, err := t.imageserverResource.GetHTTP(nil, timeout)
+	client, err := source.resource.GetHTTP(nil, timeout)
 	if err != nil {
 		return false, err
 	}
@@ -180,29 +244,47 @@ func (t *srpcType) extendImageExpiration(name string,
 		}
 		return false, err
 	}
+	// Peers may have an earlier expiration time: keep the latest.
+	if source.peer && !expiresAt.IsZero() && !expiresAt.After(img.ExpiresAt) {
+		return false, nil
+	}
 	return t.imageDataBase.ChangeImageExpiration(name, expiresAt,
 		&srpc.AuthInformation{HaveMethodAccess: true})
 }
 
-func (t *srpcType) addImage(name string) error {
+func (t *srpcType) addImage(source *replicationSource,
+	imageUpdate imageserver.ImageUpdate) error {
+	name := imageUpdate.Name
 	timeout := time.Second * 60
 	if t.checkImageBeingInjected(name) {
 		return nil
 	}
 	logger := prefixlogger.New(fmt.Sprintf("Replicator(%s): ", name), t.logger)
 	if img := t.imageDataBase.GetImage(name); img != nil {
+		if checkImageConflict(img, imageUpdate.CreatedOn) {
+			logger.Printf("ALERT: conflicting image on: %s created on: %s, "+
+				"keeping local image created on: %s\n",
+				source.address, imageUpdate.CreatedOn, img.CreatedOn)
+			source.setConflict(name, true)
+			return nil
+		}
+		source.setConflict(name, false)
 		if img.ExpiresAt.IsZero() {
 			return nil
 		}
-		if changed, err := t.extendImageExpiration(name, img); err != nil {
+		changed, err := t.extendImageExpiration(source, name, img)
+		if err != nil {
 			logger.Println(err)
 		} else if changed {
 			logger.Println("extended expiration time")
 		}
 		return nil
+	} else if source.peer && t.imageDataBase.CheckDeletedImage(name) {
+		return nil
+	} else {
+		logger.Println("add image")
 	}
-	logger.Println("add image")
-	client, err := t.imageserverResource.GetHTTP(nil, timeout)
+	client, err := source.resource.GetHTTP(nil, timeout)
 	if err != nil {
 		return err
 	}
@@ -229,18 +311,17 @@ func (t *srpcType) addImage(name string) error {
 		logger.Println("ignoring expiring image in archiver mode")
 		return nil
 	}
+	if err := t.signatureChecker.CheckImage(img); err != nil {
+		return err
+	}
 	img.FileSystem.RebuildInodePointers()
 	err = t.imageDataBase.DoWithPendingImage(img, func() error {
 		if err := t.getMissingObjects(img, client, logger); err != nil {
 			client.Close()
 			return err
 		}
-		err := t.imageDataBase.AddImage(img, name,
+		return t.imageDataBase.AddImage(img, name,
 			&srpc.AuthInformation{HaveMethodAccess: true})
-		if err != nil {
-			return err
-		}
-		return nil
 	})
 	if err != nil {
 		return err
@@ -249,6 +330,13 @@ func (t *srpcType) addImage(name string) error {
 	return nil
 }
 
+// checkImageConflict returns true if the local image differs from the image
+// with the same name on the source, which was created at createdOn. Image names
+// are immutable, so the local image is never replaced.
+func checkImageConflict(img *image.Image, createdOn time.Time) bool {
+	return !createdOn.IsZero() && !createdOn.Equal(img.CreatedOn)
+}
+
 func (t *srpcType) checkImageBeingInjected(name string) bool {
 	t.imagesBeingInjectedLock.Lock()
 	defer t.imagesBeingInjectedLock.Unlock()
@@ -0,0 +1,93 @@
+package rpcd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/imageserver/scanner"
+	"github.com/Cloud-Foundations/Dominator/lib/image"
+	"github.com/Cloud-Foundations/Dominator/lib/log/testlogger"
+	objectserver "github.com/Cloud-Foundations/Dominator/lib/objectserver/filesystem"
+)
+
+func TestCheckImageConflict(t *testing.T) {
+	createdOn := time.Now()
+	img := &image.Image{CreatedOn: createdOn}
+	if checkImageConflict(img, time.Time{}) {
+		t.Error("unknown creation time is a conflict")
+	}
+	if checkImageConflict(img, createdOn) {
+		t.Error("same image is a conflict")
+	}
+	if !checkImageConflict(img, createdOn.Add(-time.Second)) {
+		t.Error("earlier image is not a conflict")
+	}
+	if !checkImageConflict(img, createdOn.Add(time.Second)) {
+		t.Error("later image is not a conflict")
+	}
+	source := newReplicationSource("peer:6971", true)
+	source.setConflict("image", true)
+	if len(source.conflicts) != 1 {
+		t.Error("conflict not recorded")
+	}
+	source.setConflict("image", false)
+	if len(source.conflicts) != 0 {
+		t.Error("conflict not cleared")
+	}
+}
+
+func TestUpdateDirectory(t *testing.T) {
+	logger := testlogger.New(t)
+	objSrv, err := objectserver.NewObjectServer(t.TempDir(), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imdb, err := scanner.Load(scanner.Config{BaseDirectory: t.TempDir()},
+		scanner.Params{Logger: logger, ObjectServer: objSrv})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srpcObj := &srpcType{imageDataBase: imdb, logger: logger}
+	source := newReplicationSource("peer:6971", true)
+	now := time.Now().Truncate(time.Second)
+	tests := []struct {
+		name       string
+		ownerGroup string
+		modifiedOn time.Time
+		expected   string
+	}{
+		{"new directory", "b", now, "b"},
+		{"older change", "c", now.Add(-time.Minute), "b"},
+		{"tie, lower group", "a", now, "b"},
+		{"tie, higher group", "c", now, "c"},
+		{"newer change", "a", now.Add(time.Minute), "a"},
+	}
+	for _, test := range tests {
+		directory := image.Directory{
+			Name:     "dir",
+			Metadata: image.DirectoryMetadata{OwnerGroup: test.ownerGroup},
+		}
+		err := srpcObj.updateDirectory(source, directory, test.modifiedOn)
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		metadata, _ := imdb.GetDirectoryMetadata("dir")
+		if metadata.OwnerGroup != test.expected {
+			t.Errorf("%s: owner group: %s, expected: %s",
+				test.name, metadata.OwnerGroup, test.expected)
+		}
+	}
+	// The same change arriving later only advances the modification time.
+	directory := image.Directory{
+		Name:     "dir",
+		Metadata: image.DirectoryMetadata{OwnerGroup: "a"},
+	}
+	modifiedOn := now.Add(time.Hour)
+	if err := srpcObj.updateDirectory(source, directory,
+		modifiedOn); err != nil {
+		t.Fatal(err)
+	}
+	if got := imdb.GetDirectoryModifiedOn("dir"); !got.Equal(modifiedOn) {
+		t.Errorf("modified on: %s, expected: %s", got, modifiedOn)
+	}
+}
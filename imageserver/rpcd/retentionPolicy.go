@@ -0,0 +1,40 @@
+package rpcd
+
+import (
+	"github.com/Cloud-Foundations/Dominator/lib/errors"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	"github.com/Cloud-Foundations/Dominator/proto/imageserver"
+)
+
+func (t *srpcType) GetRetentionReport(conn *srpc.Conn,
+	request imageserver.GetRetentionReportRequest,
+	reply *imageserver.GetRetentionReportResponse) error {
+	report, err := t.imageDataBase.GetRetentionReport(request.DirectoryName)
+	if err != nil {
+		reply.Error = err.Error()
+		return nil
+	}
+	reply.RetentionReport = report
+	return nil
+}
+
+func (t *srpcType) SetRetentionPolicy(conn *srpc.Conn,
+	request imageserver.SetRetentionPolicyRequest,
+	reply *imageserver.SetRetentionPolicyResponse) error {
+	if err := t.checkMutability(); err != nil {
+		reply.Error = err.Error()
+		return nil
+	}
+	username := conn.Username()
+	if username == "" {
+		t.logger.Printf("SetRetentionPolicy(%s) to: %s\n",
+			request.DirectoryName, request.RetentionPolicy)
+	} else {
+		t.logger.Printf("SetRetentionPolicy(%s) to: %s by %s\n",
+			request.DirectoryName, request.RetentionPolicy, username)
+	}
+	reply.Error = errors.ErrorToString(t.imageDataBase.SetRetentionPolicy(
+		request.DirectoryName, request.RetentionPolicy,
+		conn.GetAuthInformation()))
+	return nil
+}
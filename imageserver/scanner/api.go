@@ -22,11 +22,14 @@ const metadataFile = ".metadata"
 
 type Config struct {
 	BaseDirectory                       string
+	DeletedImageRetention               time.Duration // Default: 30 days.
 	LockCheckInterval                   time.Duration
 	LockLogTimeout                      time.Duration
 	MaximumExpirationDuration           time.Duration // Default: 1 day.
 	MaximumExpirationDurationPrivileged time.Duration // Default: 1 month.
 	ReplicationMaster                   string
+	RetentionAuditLog                   string        // Default: in BaseDirectory.
+	RetentionCheckInterval              time.Duration // Zero: do not enforce.
 }
 
 type notifiers map[<-chan string]chan<- string
@@ -40,6 +43,7 @@ type ImageDataBase struct {
 	secret      []byte
 	sync.RWMutex
 	// Protected by main lock.
+	deletedImages   map[string]time.Time // Deletion times.
 	directoryMap    map[string]image.DirectoryMetadata
 	imageMap        map[string]*imageType // nil: write in progress.
 	addNotifiers    notifiers
@@ -48,6 +52,8 @@ type ImageDataBase struct {
 	// Unprotected by main lock.
 	pendingImageLock sync.Mutex
 	objectFetchLock  sync.Mutex
+	retentionLock    sync.Mutex // Protect retentionStatus.
+	retentionStatus  retentionStatus
 }
 
 type imageType struct {
@@ -60,6 +66,16 @@ type imageType struct {
 type Params struct {
 	Logger       log.DebugLogger
 	ObjectServer objectserver.FullObjectServer
+	// Optional. Images listed in the MDB are not deleted by retention policies.
+	// Retention policies are only enforced if this is set.
+	ListMdbImages func() (map[string]struct{}, error)
+}
+
+type retentionStatus struct {
+	lastCheck     time.Time
+	lastError     string
+	numDeleted    uint64
+	numKeptForMdb uint
 }
 
 func Load(config Config, params Params) (*ImageDataBase, error) {
@@ -93,6 +109,12 @@ func (imdb *ImageDataBase) CheckDirectory(name string) bool {
 	return imdb.checkDirectory(name)
 }
 
+// CheckDeletedImage returns true if the image was deleted within
+// Config.DeletedImageRetention. Peers will not add these images again.
+func (imdb *ImageDataBase) CheckDeletedImage(name string) bool {
+	return imdb.checkDeletedImage(name)
+}
+
 func (imdb *ImageDataBase) CheckImage(name string) bool {
 	return imdb.checkImage(name)
 }
@@ -115,6 +137,13 @@ func (imdb *ImageDataBase) DeleteImage(name string,
 	return imdb.deleteImage(name, authInfo)
 }
 
+// DeleteReplicatedImage deletes an image which was deleted on another
+// imageserver. Unlike DeleteImage, the deletion is recorded even if the image
+// does not exist, so that peers do not add the image later.
+func (imdb *ImageDataBase) DeleteReplicatedImage(name string) error {
+	return imdb.deleteReplicatedImage(name)
+}
+
 // DeleteUnreferencedObjects will delete some or all unreferenced objects.
 // Objects are randomly selected for deletion, until both the percentage and
 // bytes thresholds are satisfied.
@@ -136,6 +165,19 @@ func (imdb *ImageDataBase) FindLatestImage(
 	return imdb.findLatestImage(request)
 }
 
+// GetDirectoryModifiedOn returns the time of the last change to the directory
+// metadata, or the zero time if unknown.
+func (imdb *ImageDataBase) GetDirectoryModifiedOn(name string) time.Time {
+	return imdb.getDirectoryModifiedOn(name)
+}
+
+// GetDirectoryMetadata returns the metadata for the directory and true if it
+// exists, else false.
+func (imdb *ImageDataBase) GetDirectoryMetadata(name string) (
+	image.DirectoryMetadata, bool) {
+	return imdb.getDirectoryMetadata(name)
+}
+
 func (imdb *ImageDataBase) GetImage(name string) *image.Image {
 	return imdb.getImage(name)
 }
@@ -155,6 +197,14 @@ func (imdb *ImageDataBase) GetImageComputedFiles(name string) (
 	return imdb.getImageComputedFiles(name)
 }
 
+// GetRetentionReport returns the images in the directory which would be deleted
+// by its retention policy if it was enforced now. If dirname is empty, all
+// directories with a retention policy are included.
+func (imdb *ImageDataBase) GetRetentionReport(dirname string) (
+	proto.RetentionReport, error) {
+	return imdb.getRetentionReport(dirname)
+}
+
 func (imdb *ImageDataBase) GetUnreferencedObjectsStatistics() (uint64, uint64) {
 	return 0, 0
 }
@@ -163,6 +213,12 @@ func (imdb *ImageDataBase) ListDirectories() []image.Directory {
 	return imdb.listDirectories()
 }
 
+// ListDeletedImages returns the names of the images deleted after since.
+// Deletions are forgotten after Config.DeletedImageRetention.
+func (imdb *ImageDataBase) ListDeletedImages(since time.Time) []string {
+	return imdb.listDeletedImages(since)
+}
+
 func (imdb *ImageDataBase) ListImages() []string {
 	return imdb.listImages(proto.ListSelectedImagesRequest{})
 }
@@ -213,6 +269,13 @@ func (imdb *ImageDataBase) RestoreImageFromArchive(
 	return imdb.restoreImageFromArchive(request, authInfo)
 }
 
+// SetRetentionPolicy sets the retention policy for the directory. The policy is
+// enforced periodically if Config.RetentionCheckInterval is non-zero.
+func (imdb *ImageDataBase) SetRetentionPolicy(dirname string,
+	policy image.RetentionPolicy, authInfo *srpc.AuthInformation) error {
+	return imdb.setRetentionPolicy(dirname, policy, authInfo)
+}
+
 func (imdb *ImageDataBase) UnregisterAddNotifier(channel <-chan string) {
 	imdb.unregisterAddNotifier(channel)
 }
@@ -226,8 +289,11 @@ func (imdb *ImageDataBase) UnregisterMakeDirectoryNotifier(
 	imdb.unregisterMakeDirectoryNotifier(channel)
 }
 
-func (imdb *ImageDataBase) UpdateDirectory(directory image.Directory) error {
-	return imdb.makeDirectory(directory, nil, false)
+// UpdateDirectory applies a directory change from another imageserver, which
+// was made at modifiedOn (zero if unknown).
+func (imdb *ImageDataBase) UpdateDirectory(directory image.Directory,
+	modifiedOn time.Time) error {
+	return imdb.updateDirectory(directory, modifiedOn)
 }
 
 func (imdb *ImageDataBase) WriteHtml(writer io.Writer) {
@@ -0,0 +1,120 @@
+package scanner
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/fsutil"
+)
+
+const deletedImagesFile = ".deleted-images"
+
+// loadDeletedImages reads the journal of deleted images, dropping entries which
+// have expired or which were added again, and then compacts the journal.
+func (imdb *ImageDataBase) loadDeletedImages() error {
+	filename := filepath.Join(imdb.BaseDirectory, deletedImagesFile)
+	file, err := os.Open(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+	expireBefore := time.Now().Add(-imdb.DeletedImageRetention)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), " ", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		nanoseconds, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		deletedOn := time.Unix(0, nanoseconds)
+		name := fields[1]
+		if deletedOn.Before(expireBefore) {
+			continue
+		}
+		if _, ok := imdb.imageMap[name]; ok {
+			continue
+		}
+		if deletedOn.After(imdb.deletedImages[name]) {
+			imdb.deletedImages[name] = deletedOn
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return imdb.writeDeletedImages()
+}
+
+// listDeletedImages returns the names of the images deleted after since.
+// Expired deletions are forgotten.
+func (imdb *ImageDataBase) listDeletedImages(since time.Time) []string {
+	expireBefore := time.Now().Add(-imdb.DeletedImageRetention)
+	imdb.Lock()
+	defer imdb.Unlock()
+	names := make([]string, 0)
+	for name, deletedOn := range imdb.deletedImages {
+		if deletedOn.Before(expireBefore) {
+			delete(imdb.deletedImages, name)
+		} else if deletedOn.After(since) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// recordDeletedImage appends the deletion to the journal.
+// This must be called with the main lock held.
+func (imdb *ImageDataBase) recordDeletedImage(name string) error {
+	file, err := os.OpenFile(
+		filepath.Join(imdb.BaseDirectory, deletedImagesFile),
+		os.O_APPEND|os.O_CREATE|os.O_WRONLY, fsutil.PublicFilePerms)
+	if err != nil {
+		return err
+	}
+	deletedOn := time.Now()
+	if _, err := fmt.Fprintf(file, "%d %s\n", deletedOn.UnixNano(),
+		name); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	imdb.deletedImages[name] = deletedOn
+	return nil
+}
+
+// writeDeletedImages replaces the journal with the current deletions.
+func (imdb *ImageDataBase) writeDeletedImages() error {
+	filename := filepath.Join(imdb.BaseDirectory, deletedImagesFile)
+	if len(imdb.deletedImages) < 1 {
+		if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	writer, err := fsutil.CreateRenamingWriter(filename,
+		fsutil.PublicFilePerms)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(writer)
+	for name, deletedOn := range imdb.deletedImages {
+		fmt.Fprintf(w, "%d %s\n", deletedOn.UnixNano(), name)
+	}
+	if err := w.Flush(); err != nil {
+		writer.Abort()
+		return err
+	}
+	return writer.Close()
+}
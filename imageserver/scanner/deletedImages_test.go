@@ -0,0 +1,119 @@
+package scanner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/filesystem"
+	"github.com/Cloud-Foundations/Dominator/lib/image"
+	"github.com/Cloud-Foundations/Dominator/lib/log/testlogger"
+	objectserver "github.com/Cloud-Foundations/Dominator/lib/objectserver/filesystem"
+	"github.com/Cloud-Foundations/Dominator/lib/verstr"
+)
+
+func loadTestImdb(t *testing.T, baseDir string) *ImageDataBase {
+	logger := testlogger.New(t)
+	objSrv, err := objectserver.NewObjectServer(t.TempDir(), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imdb, err := loadImageDataBase(
+		Config{BaseDirectory: baseDir, DeletedImageRetention: time.Hour},
+		Params{Logger: logger, ObjectServer: objSrv})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return imdb
+}
+
+func TestDeletedImages(t *testing.T) {
+	baseDir := t.TempDir()
+	now := time.Now()
+	journal := fmt.Sprintf("%d expired\n%d deleted\nbad line\n%d readded\n",
+		now.Add(-2*time.Hour).UnixNano(), now.Add(-time.Minute).UnixNano(),
+		now.Add(-time.Minute).UnixNano())
+	err := os.WriteFile(filepath.Join(baseDir, deletedImagesFile),
+		[]byte(journal), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A zero-length file from an old deletion does not block the name.
+	err = os.WriteFile(filepath.Join(baseDir, "truncated"), nil, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := &image.Image{FileSystem: &filesystem.FileSystem{}}
+	if _, err := writeImage(filepath.Join(baseDir, "readded"), img,
+		true); err != nil {
+		t.Fatal(err)
+	}
+	imdb := loadTestImdb(t, baseDir)
+	if !imdb.CheckDeletedImage("deleted") {
+		t.Error("deleted image not recorded")
+	}
+	for _, name := range []string{"expired", "readded", "truncated"} {
+		if imdb.CheckDeletedImage(name) {
+			t.Errorf("%s recorded as deleted", name)
+		}
+	}
+	if err := imdb.DeleteReplicatedImage("other"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(baseDir, "other")); err == nil {
+		t.Error("file created for replicated deletion")
+	}
+	names := imdb.ListDeletedImages(time.Time{})
+	verstr.Sort(names)
+	if expected := []string{"deleted", "other"}; !reflect.DeepEqual(names,
+		expected) {
+		t.Errorf("deleted images: %v, expected: %v", names, expected)
+	}
+	names = imdb.ListDeletedImages(now)
+	if expected := []string{"other"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("deleted since: %v, expected: %v", names, expected)
+	}
+	// Reloading reads the compacted journal and the appended deletion.
+	imdb = loadTestImdb(t, baseDir)
+	names = imdb.ListDeletedImages(time.Time{})
+	verstr.Sort(names)
+	if expected := []string{"deleted", "other"}; !reflect.DeepEqual(names,
+		expected) {
+		t.Errorf("reloaded deleted images: %v, expected: %v", names, expected)
+	}
+	imdb.deletedImages["other"] = now.Add(-2 * time.Hour)
+	names = imdb.ListDeletedImages(time.Time{})
+	if expected := []string{"deleted"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("deleted images: %v, expected: %v", names, expected)
+	}
+	if _, ok := imdb.deletedImages["other"]; ok {
+		t.Error("expired deletion not forgotten")
+	}
+}
+
+func TestDirectoryModifiedOn(t *testing.T) {
+	baseDir := t.TempDir()
+	imdb := loadTestImdb(t, baseDir)
+	if !imdb.GetDirectoryModifiedOn("dir").IsZero() {
+		t.Error("modification time for missing directory")
+	}
+	modifiedOn := time.Now().Add(-time.Hour).Truncate(time.Second)
+	directory := image.Directory{
+		Name:     "dir",
+		Metadata: image.DirectoryMetadata{OwnerGroup: "group"},
+	}
+	if err := imdb.UpdateDirectory(directory, modifiedOn); err != nil {
+		t.Fatal(err)
+	}
+	if got := imdb.GetDirectoryModifiedOn("dir"); !got.Equal(modifiedOn) {
+		t.Errorf("modified on: %s, expected: %s", got, modifiedOn)
+	}
+	// The modification time is persistent.
+	imdb = loadTestImdb(t, baseDir)
+	if got := imdb.GetDirectoryModifiedOn("dir"); !got.Equal(modifiedOn) {
+		t.Errorf("reloaded modified on: %s, expected: %s", got, modifiedOn)
+	}
+}
@@ -29,6 +29,10 @@ func (imdb *ImageDataBase) expireImage(img *image.Image, name string) {
 	pathname := path.Join(imdb.BaseDirectory, name)
 	// Only rename file while lock is held, because removing can be slow.
 	imdb.Lock()
+	if imgType := imdb.imageMap[name]; imgType != nil && imgType.image != img {
+		imdb.Unlock() // The image was deleted and added again.
+		return
+	}
 	if err := os.Rename(pathname, pathname+"~"); err != nil {
 		imdb.Logger.Println(err)
 	}
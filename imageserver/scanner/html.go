@@ -3,6 +3,9 @@ package scanner
 import (
 	"fmt"
 	"io"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/format"
 )
 
 func (imdb *ImageDataBase) writeHtml(writer io.Writer) {
@@ -21,4 +24,27 @@ func (imdb *ImageDataBase) writeHtml(writer io.Writer) {
 			imdb.ReplicationMaster,
 			imdb.ReplicationMaster)
 	}
+	if imdb.RetentionCheckInterval > 0 && imdb.ReplicationMaster == "" &&
+		imdb.Params.ListMdbImages != nil {
+		imdb.writeRetentionHtml(writer)
+	}
+}
+
+func (imdb *ImageDataBase) writeRetentionHtml(writer io.Writer) {
+	imdb.retentionLock.Lock()
+	status := imdb.retentionStatus
+	imdb.retentionLock.Unlock()
+	fmt.Fprintf(writer, "Retention policies: checked every %s",
+		format.Duration(imdb.RetentionCheckInterval))
+	if !status.lastCheck.IsZero() {
+		fmt.Fprintf(writer, ", last check %s ago",
+			format.Duration(time.Since(status.lastCheck)))
+	}
+	fmt.Fprintf(writer, ", %d images deleted, %d kept for MDB",
+		status.numDeleted, status.numKeptForMdb)
+	if status.lastError != "" {
+		fmt.Fprintf(writer, ", <font color=\"red\">%s</font>",
+			status.lastError)
+	}
+	fmt.Fprintln(writer, "<br>")
 }
@@ -26,8 +26,10 @@ import (
 )
 
 var (
-	errNoAccess   = errors.New("no access to image")
-	errNoAuthInfo = errors.New("no authentication information")
+	errNoAccess    = errors.New("no access to image")
+	errNoAuthInfo  = errors.New("no authentication information")
+	errNoMdb       = errors.New("no MDB: not enforcing retention policies")
+	errNoMdbImages = errors.New("MDB lists no images: not enforcing retention")
 )
 
 // writeImage will write an image to the specified filename, ensuring that a
@@ -234,6 +236,16 @@ func (imdb *ImageDataBase) checkExpiration(expiresAt time.Time,
 	return nil
 }
 
+func (imdb *ImageDataBase) checkDeletedImage(name string) bool {
+	imdb.RLock()
+	defer imdb.RUnlock()
+	deletedOn, ok := imdb.deletedImages[name]
+	if !ok {
+		return false
+	}
+	return time.Since(deletedOn) < imdb.DeletedImageRetention
+}
+
 // checkImage returns true if the image exists.
 func (imdb *ImageDataBase) checkImage(name string) bool {
 	imdb.RLock()
@@ -325,7 +337,7 @@ func (imdb *ImageDataBase) updateDirectoryMetadataFile(
 		}
 		return os.Remove(filename)
 	}
-	file, err := os.OpenFile(filename, os.O_CREATE|os.O_RDWR,
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_TRUNC|os.O_RDWR,
 		fsutil.PublicFilePerms)
 	if err != nil {
 		return err
@@ -374,14 +386,37 @@ func (imdb *ImageDataBase) deleteImage(name string,
 		if err := imdb.checkPermissions(name, img, authInfo); err != nil {
 			return err
 		}
-		filename := filepath.Join(imdb.BaseDirectory, name)
-		if err := os.Truncate(filename, 0); err != nil {
-			return err
+		return imdb.deleteImageWithLock(name)
+	}
+}
+
+func (imdb *ImageDataBase) deleteReplicatedImage(name string) error {
+	imdb.Lock()
+	defer imdb.Unlock()
+	if img, ok := imdb.getImageWithLock(name); ok {
+		if img == nil {
+			return errors.New("image: " + name + " is being written")
 		}
-		imdb.deleteImageAndUpdateUnreferencedObjectsList(name)
-		imdb.deleteNotifiers.sendPlain(name, "delete", imdb.Logger)
+		return imdb.deleteImageWithLock(name)
+	}
+	if _, ok := imdb.deletedImages[name]; ok {
 		return nil
 	}
+	return imdb.recordDeletedImage(name)
+}
+
+// This must be called with the main lock held.
+func (imdb *ImageDataBase) deleteImageWithLock(name string) error {
+	filename := filepath.Join(imdb.BaseDirectory, name)
+	if err := os.Truncate(filename, 0); err != nil {
+		return err
+	}
+	imdb.deleteImageAndUpdateUnreferencedObjectsList(name)
+	if err := imdb.recordDeletedImage(name); err != nil {
+		imdb.Logger.Printf("error recording deletion of: %s: %s\n", name, err)
+	}
+	imdb.deleteNotifiers.sendPlain(name, "delete", imdb.Logger)
+	return nil
 }
 
 // This must be called with the main lock held.
@@ -439,6 +474,27 @@ func (imdb *ImageDataBase) findLatestImage(
 	return imageName, nil
 }
 
+func (imdb *ImageDataBase) getDirectoryMetadata(name string) (
+	image.DirectoryMetadata, bool) {
+	imdb.RLock()
+	defer imdb.RUnlock()
+	metadata, ok := imdb.directoryMap[name]
+	return metadata, ok
+}
+
+// getDirectoryModifiedOn returns the time of the last change to the directory
+// metadata, or the zero time if unknown.
+func (imdb *ImageDataBase) getDirectoryModifiedOn(name string) time.Time {
+	imdb.RLock()
+	defer imdb.RUnlock()
+	fi, err := os.Stat(filepath.Join(imdb.BaseDirectory, filepath.Clean(name),
+		metadataFile))
+	if err != nil {
+		return time.Time{}
+	}
+	return fi.ModTime()
+}
+
 func (imdb *ImageDataBase) getImage(name string) *image.Image {
 	imdb.RLock()
 	defer imdb.RUnlock()
@@ -747,6 +803,27 @@ func (imdb *ImageDataBase) unregisterMakeDirectoryNotifier(
 	delete(imdb.mkdirNotifiers, channel)
 }
 
+// updateDirectory applies a directory change from another imageserver. The
+// modification time of the metadata file records when the change was made.
+func (imdb *ImageDataBase) updateDirectory(directory image.Directory,
+	modifiedOn time.Time) error {
+	imdb.Lock()
+	defer imdb.Unlock()
+	if err := imdb.makeDirectoryWithLock(directory, nil, false); err != nil {
+		return err
+	}
+	if modifiedOn.IsZero() {
+		return nil
+	}
+	filename := filepath.Join(imdb.BaseDirectory, filepath.Clean(directory.Name),
+		metadataFile)
+	err := os.Chtimes(filename, modifiedOn, modifiedOn)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
 // Write the specified image, assuming other writers are blocked and validation
 // checks have been performed.
 func (imdb *ImageDataBase) writeImage(name string, img *image.Image,
@@ -759,6 +836,7 @@ func (imdb *ImageDataBase) writeImage(name string, img *image.Image,
 	}
 	imdb.scheduleExpiration(img, name)
 	imdb.Lock()
+	delete(imdb.deletedImages, name)
 	imdb.imageMap[name] = &imageType{
 		computedFiles: computedFiles,
 		fileChecksum:  fileChecksum,
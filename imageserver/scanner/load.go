@@ -33,6 +33,13 @@ func loadImageDataBase(config Config, params Params) (*ImageDataBase, error) {
 		config.MaximumExpirationDurationPrivileged =
 			config.MaximumExpirationDuration
 	}
+	if config.DeletedImageRetention < 1 {
+		config.DeletedImageRetention = 30 * 24 * time.Hour
+	}
+	if config.RetentionAuditLog == "" {
+		config.RetentionAuditLog = path.Join(config.BaseDirectory,
+			retentionAuditLogFile)
+	}
 	fi, err := os.Stat(config.BaseDirectory)
 	if err != nil {
 		return nil, fmt.Errorf("cannot stat: %s: %s\n",
@@ -44,6 +51,7 @@ func loadImageDataBase(config Config, params Params) (*ImageDataBase, error) {
 	imdb := &ImageDataBase{
 		Config:          config,
 		Params:          params,
+		deletedImages:   make(map[string]time.Time),
 		directoryMap:    make(map[string]image.DirectoryMetadata),
 		imageMap:        make(map[string]*imageType),
 		addNotifiers:    make(notifiers),
@@ -66,6 +74,9 @@ func loadImageDataBase(config Config, params Params) (*ImageDataBase, error) {
 	if err := state.Reap(); err != nil {
 		return nil, err
 	}
+	if err := imdb.loadDeletedImages(); err != nil {
+		return nil, err
+	}
 	if params.Logger != nil {
 		plural := ""
 		if imdb.CountImages() != 1 {
@@ -80,6 +91,16 @@ func loadImageDataBase(config Config, params Params) (*ImageDataBase, error) {
 			imdb.CountImages(), plural, time.Since(startTime), userTime)
 		logutil.LogMemory(params.Logger, 0, "after loading")
 	}
+	// Replicas receive deletions from their master.
+	if config.RetentionCheckInterval > 0 && config.ReplicationMaster == "" {
+		if params.ListMdbImages == nil {
+			if params.Logger != nil {
+				params.Logger.Println(errNoMdb)
+			}
+		} else {
+			go imdb.retentionLoop()
+		}
+	}
 	return imdb, nil
 }
 
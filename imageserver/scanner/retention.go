@@ -0,0 +1,263 @@
+package scanner
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/errors"
+	"github.com/Cloud-Foundations/Dominator/lib/fsutil"
+	"github.com/Cloud-Foundations/Dominator/lib/image"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	"github.com/Cloud-Foundations/Dominator/lib/verstr"
+	proto "github.com/Cloud-Foundations/Dominator/proto/imageserver"
+)
+
+const retentionAuditLogFile = ".retention-audit.log"
+
+type retainedImage struct {
+	name      string
+	createdOn time.Time
+}
+
+type retentionDeletion struct {
+	name      string
+	createdOn time.Time
+	policy    image.RetentionPolicy
+}
+
+// applyRetentionPolicy returns the names of the images which are not kept by
+// the policy and the names of the images which are only kept because they are
+// in use. The images must all be in the same directory.
+func applyRetentionPolicy(policy image.RetentionPolicy,
+	images []retainedImage, inUse map[string]struct{}, now time.Time) (
+	[]string, []string) {
+	if policy.IsEmpty() {
+		return nil, nil
+	}
+	sort.Slice(images, func(left, right int) bool {
+		if images[left].createdOn.Equal(images[right].createdOn) {
+			return verstr.Less(images[right].name, images[left].name)
+		}
+		return images[left].createdOn.After(images[right].createdOn)
+	})
+	var toDelete, keptForMdb []string
+	for index, img := range images {
+		if index < 1 || uint(index) < policy.KeepNewest {
+			continue
+		}
+		if policy.KeepNewerThan > 0 &&
+			now.Sub(img.createdOn) < policy.KeepNewerThan {
+			continue
+		}
+		if _, ok := inUse[img.name]; ok {
+			keptForMdb = append(keptForMdb, img.name)
+			continue
+		}
+		toDelete = append(toDelete, img.name)
+	}
+	return toDelete, keptForMdb
+}
+
+// This must be called with the lock held.
+func (imdb *ImageDataBase) checkDirectoryOwner(dirname string,
+	authInfo *srpc.AuthInformation) error {
+	if authInfo == nil {
+		return errNoAuthInfo
+	}
+	if authInfo.HaveMethodAccess {
+		return nil
+	}
+	// Owners of the directory or of the parent have access.
+	for _, name := range []string{dirname, filepath.Dir(dirname)} {
+		directoryMetadata, ok := imdb.directoryMap[name]
+		if !ok || directoryMetadata.OwnerGroup == "" {
+			continue
+		}
+		if _, ok := authInfo.GroupList[directoryMetadata.OwnerGroup]; ok {
+			return nil
+		}
+	}
+	return errNoAccess
+}
+
+// This must be called with the lock held.
+func (imdb *ImageDataBase) computeRetention(dirname string,
+	inUse map[string]struct{}, now time.Time) (
+	map[string]image.RetentionPolicy, []string, []string) {
+	policies := make(map[string]image.RetentionPolicy)
+	for name, directoryMetadata := range imdb.directoryMap {
+		if directoryMetadata.RetentionPolicy.IsEmpty() {
+			continue
+		}
+		if dirname == "" || dirname == name {
+			policies[name] = directoryMetadata.RetentionPolicy
+		}
+	}
+	if len(policies) < 1 {
+		return nil, nil, nil
+	}
+	imagesPerDirectory := make(map[string][]retainedImage)
+	for name, imgType := range imdb.imageMap {
+		if imgType == nil || !imgType.image.ExpiresAt.IsZero() {
+			continue
+		}
+		directory := filepath.Dir(name)
+		if _, ok := policies[directory]; !ok {
+			continue
+		}
+		imagesPerDirectory[directory] = append(imagesPerDirectory[directory],
+			retainedImage{name: name, createdOn: imgType.image.CreatedOn})
+	}
+	var toDelete, keptForMdb []string
+	for directory, images := range imagesPerDirectory {
+		deletions, kept := applyRetentionPolicy(policies[directory], images,
+			inUse, now)
+		toDelete = append(toDelete, deletions...)
+		keptForMdb = append(keptForMdb, kept...)
+	}
+	verstr.Sort(toDelete)
+	verstr.Sort(keptForMdb)
+	return policies, toDelete, keptForMdb
+}
+
+// enforceRetentionPolicies deletes the images which are not kept by the
+// retention policies. Images are only deleted if the images in use can be
+// listed from the MDB, otherwise images the MDB needs could be deleted.
+func (imdb *ImageDataBase) enforceRetentionPolicies() error {
+	if imdb.Params.ListMdbImages == nil {
+		return errNoMdb
+	}
+	inUse, err := imdb.listMdbImages()
+	if err != nil {
+		return fmt.Errorf("error listing MDB images: %s", err)
+	}
+	if len(inUse) < 1 {
+		return errNoMdbImages
+	}
+	var deletions []retentionDeletion
+	var firstError error
+	imdb.Lock()
+	policies, toDelete, keptForMdb := imdb.computeRetention("", inUse,
+		time.Now())
+	for _, name := range toDelete {
+		img, _ := imdb.getImageWithLock(name)
+		if err := imdb.deleteImageWithLock(name); err != nil {
+			if firstError == nil {
+				firstError = err
+			}
+			continue
+		}
+		deletions = append(deletions, retentionDeletion{
+			name:      name,
+			createdOn: img.CreatedOn,
+			policy:    policies[filepath.Dir(name)],
+		})
+	}
+	imdb.Unlock()
+	for _, deletion := range deletions {
+		imdb.Logger.Printf("Retention policy (%s) deleted image: %s\n",
+			deletion.policy, deletion.name)
+	}
+	if err := imdb.writeRetentionAuditLog(deletions); err != nil {
+		imdb.Logger.Printf("Error writing retention audit log: %s\n", err)
+	}
+	imdb.retentionLock.Lock()
+	defer imdb.retentionLock.Unlock()
+	imdb.retentionStatus.numDeleted += uint64(len(deletions))
+	imdb.retentionStatus.numKeptForMdb = uint(len(keptForMdb))
+	return firstError
+}
+
+func (imdb *ImageDataBase) getRetentionReport(dirname string) (
+	proto.RetentionReport, error) {
+	inUse, err := imdb.listMdbImages()
+	if err != nil {
+		return proto.RetentionReport{},
+			fmt.Errorf("error listing MDB images: %s", err)
+	}
+	if dirname != "" {
+		dirname = filepath.Clean(dirname)
+	}
+	imdb.RLock()
+	defer imdb.RUnlock()
+	if dirname != "" {
+		if _, ok := imdb.directoryMap[dirname]; !ok {
+			return proto.RetentionReport{},
+				fmt.Errorf("no metadata for: \"%s\"", dirname)
+		}
+	}
+	_, toDelete, keptForMdb := imdb.computeRetention(dirname, inUse,
+		time.Now())
+	return proto.RetentionReport{
+		ImagesToDelete:   toDelete,
+		ImagesKeptForMdb: keptForMdb,
+	}, nil
+}
+
+func (imdb *ImageDataBase) listMdbImages() (map[string]struct{}, error) {
+	if imdb.Params.ListMdbImages == nil {
+		return nil, nil
+	}
+	return imdb.Params.ListMdbImages()
+}
+
+func (imdb *ImageDataBase) retentionLoop() {
+	for range time.Tick(imdb.RetentionCheckInterval) {
+		err := imdb.enforceRetentionPolicies()
+		if err != nil {
+			imdb.Logger.Printf("Error enforcing retention policies: %s\n",
+				err)
+		}
+		imdb.retentionLock.Lock()
+		imdb.retentionStatus.lastCheck = time.Now()
+		imdb.retentionStatus.lastError = errors.ErrorToString(err)
+		imdb.retentionLock.Unlock()
+	}
+}
+
+func (imdb *ImageDataBase) setRetentionPolicy(dirname string,
+	policy image.RetentionPolicy, authInfo *srpc.AuthInformation) error {
+	dirname = filepath.Clean(dirname)
+	imdb.Lock()
+	defer imdb.Unlock()
+	directoryMetadata, ok := imdb.directoryMap[dirname]
+	if !ok {
+		return fmt.Errorf("no metadata for: \"%s\"", dirname)
+	}
+	if err := imdb.checkDirectoryOwner(dirname, authInfo); err != nil {
+		return err
+	}
+	directoryMetadata.RetentionPolicy = policy
+	return imdb.updateDirectoryMetadata(
+		image.Directory{Name: dirname, Metadata: directoryMetadata})
+}
+
+// writeRetentionAuditLog appends a line for each deleted image to the audit
+// log.
+func (imdb *ImageDataBase) writeRetentionAuditLog(
+	deletions []retentionDeletion) error {
+	if len(deletions) < 1 {
+		return nil
+	}
+	file, err := os.OpenFile(imdb.RetentionAuditLog,
+		os.O_APPEND|os.O_CREATE|os.O_WRONLY, fsutil.PrivateFilePerms)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	deletedOn := time.Now().UTC().Format(time.RFC3339)
+	for _, deletion := range deletions {
+		fmt.Fprintf(writer, "%s deleted: %s created: %s policy: %s\n",
+			deletedOn, deletion.name,
+			deletion.createdOn.UTC().Format(time.RFC3339), deletion.policy)
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,158 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/filesystem"
+	"github.com/Cloud-Foundations/Dominator/lib/image"
+	"github.com/Cloud-Foundations/Dominator/lib/log/testlogger"
+	objectserver "github.com/Cloud-Foundations/Dominator/lib/objectserver/filesystem"
+	"github.com/Cloud-Foundations/Dominator/lib/verstr"
+)
+
+// makeRetentionImdb makes an image database with five images in a directory
+// with a retention policy which keeps the two newest images.
+func makeRetentionImdb(t *testing.T, now time.Time,
+	listMdbImages func() (map[string]struct{}, error)) *ImageDataBase {
+	logger := testlogger.New(t)
+	objSrv, err := objectserver.NewObjectServer(t.TempDir(), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	baseDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(baseDir, "s"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	imdb, err := loadImageDataBase(
+		Config{BaseDirectory: baseDir},
+		Params{
+			Logger:        logger,
+			ObjectServer:  objSrv,
+			ListMdbImages: listMdbImages,
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	imdb.directoryMap["s"] = image.DirectoryMetadata{
+		RetentionPolicy: image.RetentionPolicy{KeepNewest: 2},
+	}
+	for index, name := range []string{"s/1", "s/2", "s/3", "s/4", "s/5"} {
+		err := os.WriteFile(filepath.Join(baseDir, name), []byte("image"),
+			0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		imdb.imageMap[name] = &imageType{image: &image.Image{
+			CreatedOn:  now.Add(time.Duration(index-5) * time.Hour),
+			FileSystem: &filesystem.FileSystem{},
+		}}
+	}
+	return imdb
+}
+
+func TestComputeRetention(t *testing.T) {
+	now := time.Now()
+	imdb := makeRetentionImdb(t, now, nil)
+	inUse := map[string]struct{}{"s/1": {}}
+	policies, toDelete, keptForMdb := imdb.computeRetention("", inUse, now)
+	if len(policies) != 1 {
+		t.Errorf("expected 1 policy, got: %v", policies)
+	}
+	expected := []string{"s/2", "s/3"}
+	if !reflect.DeepEqual(toDelete, expected) {
+		t.Errorf("deleted: %v, expected: %v", toDelete, expected)
+	}
+	expected = []string{"s/1"}
+	if !reflect.DeepEqual(keptForMdb, expected) {
+		t.Errorf("kept for MDB: %v, expected: %v", keptForMdb, expected)
+	}
+	policies, _, _ = imdb.computeRetention("t", inUse, now)
+	if len(policies) > 0 {
+		t.Errorf("policy for other directory included: %v", policies)
+	}
+}
+
+func TestEnforceRetentionPolicies(t *testing.T) {
+	now := time.Now()
+	// Without an MDB, nothing may be deleted.
+	imdb := makeRetentionImdb(t, now, nil)
+	if err := imdb.enforceRetentionPolicies(); err != errNoMdb {
+		t.Errorf("expected: %s, got: %v", errNoMdb, err)
+	}
+	if numImages := imdb.CountImages(); numImages != 5 {
+		t.Errorf("images deleted without an MDB: %d remain", numImages)
+	}
+	// An MDB which lists no images may be broken.
+	imdb = makeRetentionImdb(t, now,
+		func() (map[string]struct{}, error) { return nil, nil })
+	if err := imdb.enforceRetentionPolicies(); err != errNoMdbImages {
+		t.Errorf("expected: %s, got: %v", errNoMdbImages, err)
+	}
+	if numImages := imdb.CountImages(); numImages != 5 {
+		t.Errorf("images deleted with an empty MDB: %d remain", numImages)
+	}
+	// Images in the MDB are kept.
+	imdb = makeRetentionImdb(t, now,
+		func() (map[string]struct{}, error) {
+			return map[string]struct{}{"s/2": {}}, nil
+		})
+	if err := imdb.enforceRetentionPolicies(); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"s/2", "s/4", "s/5"}
+	images := imdb.ListImages()
+	verstr.Sort(images)
+	if !reflect.DeepEqual(images, expected) {
+		t.Errorf("remaining images: %v, expected: %v", images, expected)
+	}
+	if !imdb.CheckDeletedImage("s/1") || !imdb.CheckDeletedImage("s/3") {
+		t.Error("deleted images not recorded")
+	}
+	if imdb.retentionStatus.numDeleted != 2 ||
+		imdb.retentionStatus.numKeptForMdb != 1 {
+		t.Errorf("bad status: %+v", imdb.retentionStatus)
+	}
+}
+
+func TestApplyRetentionPolicy(t *testing.T) {
+	now := time.Now()
+	day := 24 * time.Hour
+	var images []retainedImage
+	for _, name := range []string{"s/1", "s/2", "s/3", "s/4", "s/5"} {
+		age := time.Duration(5-len(images)) * 10 * day
+		images = append(images,
+			retainedImage{name: name, createdOn: now.Add(-age)})
+	}
+	inUse := map[string]struct{}{"s/2": {}}
+	tests := []struct {
+		policy     image.RetentionPolicy
+		toDelete   []string
+		keptForMdb []string
+	}{
+		{image.RetentionPolicy{}, nil, nil},
+		{image.RetentionPolicy{KeepNewest: 2},
+			[]string{"s/3", "s/1"}, []string{"s/2"}},
+		{image.RetentionPolicy{KeepNewerThan: 25 * day},
+			[]string{"s/3", "s/1"}, []string{"s/2"}},
+		{image.RetentionPolicy{KeepNewest: 4, KeepNewerThan: 25 * day},
+			[]string{"s/1"}, nil},
+		{image.RetentionPolicy{KeepNewerThan: day}, // Newest is always kept.
+			[]string{"s/4", "s/3", "s/1"}, []string{"s/2"}},
+	}
+	for _, test := range tests {
+		toDelete, keptForMdb := applyRetentionPolicy(test.policy, images,
+			inUse, now)
+		if !reflect.DeepEqual(toDelete, test.toDelete) {
+			t.Errorf("%s: deleted: %v, expected: %v",
+				test.policy, toDelete, test.toDelete)
+		}
+		if !reflect.DeepEqual(keptForMdb, test.keptForMdb) {
+			t.Errorf("%s: kept for MDB: %v, expected: %v",
+				test.policy, keptForMdb, test.keptForMdb)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package cgroup
+
+import (
+	"os"
+	"os/exec"
+)
+
+// Group represents a control group in the cgroup v2 (unified) hierarchy.
+type Group struct {
+	name      string   // Relative to the hierarchy root, e.g. "/a/b".
+	directory *os.File // Kept open for starting commands in the group.
+}
+
+// Available returns true if the cgroup v2 hierarchy is mounted.
+func Available() bool {
+	return available()
+}
+
+// Open returns the existing group with the specified name, relative to the
+// root of the hierarchy.
+func Open(name string) (*Group, error) {
+	return openGroup(name)
+}
+
+// Self returns the group containing the calling process.
+func Self() (*Group, error) {
+	return self()
+}
+
+// Command returns a Cmd which will run the named program with the given
+// arguments in the group.
+func (g *Group) Command(name string, args ...string) *exec.Cmd {
+	return g.command(name, args...)
+}
+
+// CreateChild returns the named child group, creating it if needed. If
+// threaded is true the child is made a threaded group, which may contain
+// individual threads of processes in the threaded subtree. Only threaded
+// controllers (such as cpu) may be enabled for threaded groups.
+func (g *Group) CreateChild(name string, threaded bool) (*Group, error) {
+	return g.createChild(name, threaded)
+}
+
+// DisableControllers disables the specified controllers for the children of
+// the group.
+func (g *Group) DisableControllers(controllers ...string) error {
+	return g.disableControllers(controllers)
+}
+
+// EnableControllers enables the specified controllers (such as "cpu", "io" and
+// "memory") for the children of the group.
+func (g *Group) EnableControllers(controllers ...string) error {
+	return g.enableControllers(controllers)
+}
+
+// MoveMyThread moves the calling OS thread into the group. The caller should
+// be locked to its thread with runtime.LockOSThread.
+func (g *Group) MoveMyThread() error {
+	return g.moveMyThread()
+}
+
+// MoveProcess moves all the threads of the specified process into the group.
+func (g *Group) MoveProcess(pid int) error {
+	return g.moveProcess(pid)
+}
+
+// Name returns the name of the group, relative to the root of the hierarchy.
+func (g *Group) Name() string {
+	return g.name
+}
+
+// Processes returns the process IDs of the processes in the group.
+func (g *Group) Processes() ([]int, error) {
+	return g.processes()
+}
+
+// SetCpuPercent sets the maximum CPU time which may be used by the group, as a
+// percentage of all the CPUs. A value of 0 or 100 removes the limit.
+func (g *Group) SetCpuPercent(cpuPercent uint) error {
+	return g.setCpuPercent(cpuPercent)
+}
+
+// SetIoMax sets the maximum read and write speeds (in bytes per second) for the
+// specified block device. A partition is mapped to the disk containing it. A
+// speed of 0 removes the limit.
+func (g *Group) SetIoMax(devnum uint64, readBytesPerSecond uint64,
+	writeBytesPerSecond uint64) error {
+	return g.setIoMax(devnum, readBytesPerSecond, writeBytesPerSecond)
+}
+
+// SetMemoryMax sets the maximum memory which may be used by the group. A limit
+// of 0 removes the limit.
+func (g *Group) SetMemoryMax(limit uint64) error {
+	return g.setMemoryMax(limit)
+}
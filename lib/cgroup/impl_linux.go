@@ -0,0 +1,190 @@
+package cgroup
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+const cpuPeriod = 100000 // Microseconds.
+
+var (
+	mountPoint  = "/sys/fs/cgroup"
+	procCgroup  = "/proc/self/cgroup"
+	sysDevBlock = "/sys/dev/block"
+)
+
+func available() bool {
+	_, err := os.Stat(path.Join(mountPoint, "cgroup.controllers"))
+	return err == nil
+}
+
+func openGroup(name string) (*Group, error) {
+	name = path.Clean("/" + name)
+	directory, err := os.Open(path.Join(mountPoint, name))
+	if err != nil {
+		return nil, err
+	}
+	return &Group{name: name, directory: directory}, nil
+}
+
+func self() (*Group, error) {
+	file, err := os.Open(procCgroup)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// The entry for the unified hierarchy is of the form: "0::/name".
+		if line := scanner.Text(); strings.HasPrefix(line, "0::") {
+			return openGroup(line[3:])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, errors.New("not in a cgroup v2 hierarchy")
+}
+
+func (g *Group) command(name string, args ...string) *exec.Cmd {
+	cmd := exec.Command(name, args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		UseCgroupFD: true,
+		CgroupFD:    int(g.directory.Fd()),
+	}
+	return cmd
+}
+
+func (g *Group) createChild(name string, threaded bool) (*Group, error) {
+	childName := path.Join(g.name, name)
+	err := os.Mkdir(path.Join(mountPoint, childName), 0755)
+	if err != nil && !os.IsExist(err) {
+		return nil, err
+	}
+	child, err := openGroup(childName)
+	if err != nil {
+		return nil, err
+	}
+	if threaded {
+		if err := child.write("cgroup.type", "threaded"); err != nil {
+			child.directory.Close()
+			return nil, err
+		}
+	}
+	return child, nil
+}
+
+func (g *Group) disableControllers(controllers []string) error {
+	return g.writeControllers("-", controllers)
+}
+
+func (g *Group) enableControllers(controllers []string) error {
+	return g.writeControllers("+", controllers)
+}
+
+func (g *Group) moveMyThread() error {
+	return g.write("cgroup.threads", strconv.Itoa(unix.Gettid()))
+}
+
+func (g *Group) moveProcess(pid int) error {
+	return g.write("cgroup.procs", strconv.Itoa(pid))
+}
+
+func (g *Group) processes() ([]int, error) {
+	data, err := os.ReadFile(path.Join(mountPoint, g.name, "cgroup.procs"))
+	if err != nil {
+		return nil, err
+	}
+	var pids []int
+	for _, field := range strings.Fields(string(data)) {
+		pid, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, err
+		}
+		pids = append(pids, pid)
+	}
+	return pids, nil
+}
+
+func (g *Group) setCpuPercent(cpuPercent uint) error {
+	if cpuPercent < 1 || cpuPercent >= 100 {
+		return g.write("cpu.max", "max")
+	}
+	quota := uint64(cpuPeriod) * uint64(runtime.NumCPU()) *
+		uint64(cpuPercent) / 100
+	return g.write("cpu.max", fmt.Sprintf("%d %d", quota, cpuPeriod))
+}
+
+func (g *Group) setIoMax(devnum uint64, readBytesPerSecond uint64,
+	writeBytesPerSecond uint64) error {
+	device, err := getDisk(devnum)
+	if err != nil {
+		return err
+	}
+	return g.write("io.max", fmt.Sprintf("%s rbps=%s wbps=%s", device,
+		formatLimit(readBytesPerSecond), formatLimit(writeBytesPerSecond)))
+}
+
+func (g *Group) setMemoryMax(limit uint64) error {
+	return g.write("memory.max", formatLimit(limit))
+}
+
+func (g *Group) write(filename, value string) error {
+	pathname := path.Join(mountPoint, g.name, filename)
+	file, err := os.OpenFile(pathname, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := file.WriteString(value); err != nil {
+		return fmt.Errorf("error writing: %s to: %s: %s", value, pathname, err)
+	}
+	return nil
+}
+
+func (g *Group) writeControllers(prefix string, controllers []string) error {
+	var changes []string
+	for _, controller := range controllers {
+		changes = append(changes, prefix+controller)
+	}
+	return g.write("cgroup.subtree_control", strings.Join(changes, " "))
+}
+
+func formatLimit(value uint64) string {
+	if value < 1 {
+		return "max"
+	}
+	return strconv.FormatUint(value, 10)
+}
+
+// getDisk returns the "major:minor" device number of the disk containing the
+// block device, since I/O limits may only be set for disks.
+func getDisk(devnum uint64) (string, error) {
+	device := fmt.Sprintf("%d:%d", unix.Major(devnum), unix.Minor(devnum))
+	dirname, err := filepath.EvalSymlinks(path.Join(sysDevBlock, device))
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(path.Join(dirname, "partition")); err != nil {
+		if os.IsNotExist(err) {
+			return device, nil
+		}
+		return "", err
+	}
+	disk, err := os.ReadFile(path.Join(path.Dir(dirname), "dev"))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(disk)), nil
+}
@@ -0,0 +1,218 @@
+package cgroup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// setupFakeHierarchy points the package at a fake hierarchy in a temporary
+// directory. The control files for the named groups are created, since they
+// are not created automatically as in a real hierarchy.
+func setupFakeHierarchy(t *testing.T, groups ...string) {
+	oldMountPoint, oldProcCgroup, oldSysDevBlock :=
+		mountPoint, procCgroup, sysDevBlock
+	t.Cleanup(func() {
+		mountPoint, procCgroup, sysDevBlock =
+			oldMountPoint, oldProcCgroup, oldSysDevBlock
+	})
+	mountPoint = t.TempDir()
+	procCgroup = filepath.Join(t.TempDir(), "cgroup")
+	sysDevBlock = t.TempDir()
+	for _, group := range groups {
+		dirname := filepath.Join(mountPoint, group)
+		if err := os.MkdirAll(dirname, 0755); err != nil {
+			t.Fatal(err)
+		}
+		for _, filename := range []string{"cgroup.procs", "cgroup.type",
+			"cgroup.subtree_control", "cpu.max", "io.max", "memory.max"} {
+			writeTestFile(t, filepath.Join(dirname, filename), "")
+		}
+	}
+}
+
+func readTestFile(t *testing.T, filename string) string {
+	data, err := os.ReadFile(filepath.Join(mountPoint, filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func writeTestFile(t *testing.T, filename, data string) {
+	if err := os.WriteFile(filename, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAvailable(t *testing.T) {
+	setupFakeHierarchy(t)
+	if Available() {
+		t.Error("available without cgroup.controllers")
+	}
+	writeTestFile(t, filepath.Join(mountPoint, "cgroup.controllers"), "cpu")
+	if !Available() {
+		t.Error("not available with cgroup.controllers")
+	}
+}
+
+func TestSelf(t *testing.T) {
+	setupFakeHierarchy(t, "a/b")
+	writeTestFile(t, procCgroup, "1:name=systemd:/x\n0::/a/b\n")
+	group, err := Self()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if group.Name() != "/a/b" {
+		t.Errorf("expected: /a/b, got: %s", group.Name())
+	}
+	writeTestFile(t, procCgroup, "1:name=systemd:/x\n")
+	if _, err := Self(); err == nil {
+		t.Error("cgroup v1 only process did not fail")
+	}
+}
+
+func TestChildAndControllers(t *testing.T) {
+	setupFakeHierarchy(t, "a", "a/threaded")
+	parent, err := Open("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	child, err := parent.CreateChild("child", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if child.Name() != "/a/child" {
+		t.Errorf("expected: /a/child, got: %s", child.Name())
+	}
+	if _, err := parent.CreateChild("threaded", true); err != nil {
+		t.Fatal(err)
+	}
+	if value := readTestFile(t, "a/threaded/cgroup.type"); value != "threaded" {
+		t.Errorf("cgroup.type: %s", value)
+	}
+	if err := parent.EnableControllers("cpu", "io"); err != nil {
+		t.Fatal(err)
+	}
+	if value := readTestFile(t, "a/cgroup.subtree_control"); value !=
+		"+cpu +io" {
+		t.Errorf("enabled controllers: %s", value)
+	}
+	writeTestFile(t, filepath.Join(mountPoint, "a/cgroup.subtree_control"),
+		"")
+	if err := parent.DisableControllers("memory"); err != nil {
+		t.Fatal(err)
+	}
+	if value := readTestFile(t, "a/cgroup.subtree_control"); value !=
+		"-memory" {
+		t.Errorf("disabled controllers: %s", value)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	setupFakeHierarchy(t, "a", "b")
+	group, err := Open("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := group.SetCpuPercent(50); err != nil {
+		t.Fatal(err)
+	}
+	expected := fmt.Sprintf("%d %d", cpuPeriod*runtime.NumCPU()/2, cpuPeriod)
+	if value := readTestFile(t, "a/cpu.max"); value != expected {
+		t.Errorf("cpu.max: %s, expected: %s", value, expected)
+	}
+	other, err := Open("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := other.SetCpuPercent(100); err != nil {
+		t.Fatal(err)
+	}
+	if value := readTestFile(t, "b/cpu.max"); value != "max" {
+		t.Errorf("unlimited cpu.max: %s", value)
+	}
+	if err := group.SetMemoryMax(1 << 20); err != nil {
+		t.Fatal(err)
+	}
+	if value := readTestFile(t, "a/memory.max"); value != "1048576" {
+		t.Errorf("memory.max: %s", value)
+	}
+	if err := other.SetMemoryMax(0); err != nil {
+		t.Fatal(err)
+	}
+	if value := readTestFile(t, "b/memory.max"); value != "max" {
+		t.Errorf("unlimited memory.max: %s", value)
+	}
+}
+
+func TestSetIoMaxForPartition(t *testing.T) {
+	setupFakeHierarchy(t, "a")
+	// Make /sys/dev/block entries for the sda disk and its sda1 partition.
+	diskDir := filepath.Join(sysDevBlock, "devices", "sda")
+	if err := os.MkdirAll(filepath.Join(diskDir, "sda1"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(diskDir, "dev"), "8:0\n")
+	writeTestFile(t, filepath.Join(diskDir, "sda1", "partition"), "1\n")
+	err := os.Symlink(filepath.Join(diskDir, "sda1"),
+		filepath.Join(sysDevBlock, "8:1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Symlink(diskDir, filepath.Join(sysDevBlock, "8:0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	group, err := Open("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := group.SetIoMax(unix.Mkdev(8, 1), 100, 0); err != nil {
+		t.Fatal(err)
+	}
+	expected := "8:0 rbps=100 wbps=max"
+	if value := readTestFile(t, "a/io.max"); value != expected {
+		t.Errorf("io.max: %s, expected: %s", value, expected)
+	}
+	if disk, err := getDisk(unix.Mkdev(8, 0)); err != nil {
+		t.Fatal(err)
+	} else if disk != "8:0" {
+		t.Errorf("disk: %s, expected: 8:0", disk)
+	}
+	if _, err := getDisk(unix.Mkdev(8, 2)); err == nil {
+		t.Error("unknown device did not fail")
+	}
+}
+
+func TestProcesses(t *testing.T) {
+	setupFakeHierarchy(t, "a", "b")
+	writeTestFile(t, filepath.Join(mountPoint, "a", "cgroup.procs"),
+		"12\n34\n")
+	group, err := Open("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pids, err := group.Processes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []int{12, 34}; !reflect.DeepEqual(pids, expected) {
+		t.Errorf("processes: %v, expected: %v", pids, expected)
+	}
+	other, err := Open("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := other.MoveProcess(56); err != nil {
+		t.Fatal(err)
+	}
+	if value := readTestFile(t, "b/cgroup.procs"); value != "56" {
+		t.Errorf("cgroup.procs: %s", value)
+	}
+}
@@ -0,0 +1,61 @@
+// +build !linux
+
+package cgroup
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+func available() bool {
+	return false
+}
+
+func openGroup(name string) (*Group, error) {
+	return nil, syscall.ENOTSUP
+}
+
+func self() (*Group, error) {
+	return nil, syscall.ENOTSUP
+}
+
+func (g *Group) command(name string, args ...string) *exec.Cmd {
+	return exec.Command(name, args...)
+}
+
+func (g *Group) createChild(name string, threaded bool) (*Group, error) {
+	return nil, syscall.ENOTSUP
+}
+
+func (g *Group) disableControllers(controllers []string) error {
+	return syscall.ENOTSUP
+}
+
+func (g *Group) enableControllers(controllers []string) error {
+	return syscall.ENOTSUP
+}
+
+func (g *Group) moveMyThread() error {
+	return syscall.ENOTSUP
+}
+
+func (g *Group) moveProcess(pid int) error {
+	return syscall.ENOTSUP
+}
+
+func (g *Group) processes() ([]int, error) {
+	return nil, syscall.ENOTSUP
+}
+
+func (g *Group) setCpuPercent(cpuPercent uint) error {
+	return syscall.ENOTSUP
+}
+
+func (g *Group) setIoMax(devnum uint64, readBytesPerSecond uint64,
+	writeBytesPerSecond uint64) error {
+	return syscall.ENOTSUP
+}
+
+func (g *Group) setMemoryMax(limit uint64) error {
+	return syscall.ENOTSUP
+}
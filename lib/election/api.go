@@ -0,0 +1,83 @@
+/*
+Package election implements leader election using leases.
+
+A lease is held by at most one holder at a time and must be renewed before
+it expires. The holder of an unexpired lease is the leader. The lease is kept
+in a LeaseStore, which may be a file on shared storage or a lease service.
+*/
+package election
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/log"
+)
+
+type Lease struct {
+	Holder    string // Identity of the holder, usually an address.
+	ExpiresAt time.Time
+}
+
+// LeaseStore is the interface to the storage for a lease.
+type LeaseStore interface {
+	// Acquire will acquire or renew the lease for holder with the specified
+	// duration, unless the lease is held by another holder and has not
+	// expired. The current lease is returned.
+	Acquire(holder string, duration time.Duration) (Lease, error)
+	// Release will release the lease if it is held by holder.
+	Release(holder string) error
+}
+
+type Elector struct {
+	params   Params
+	stopChan chan struct{}
+	mutex    sync.RWMutex // Protect everything below.
+	lease    Lease
+	leader   bool
+	stopped  bool
+}
+
+type Params struct {
+	Identity      string // Identity of this participant, usually an address.
+	LeaseDuration time.Duration
+	Logger        log.DebugLogger
+	Store         LeaseStore
+}
+
+// NewFileStore returns a LeaseStore which keeps the lease in a file. The file
+// may be on shared storage which supports file locking.
+func NewFileStore(filename string) LeaseStore {
+	return newFileStore(filename)
+}
+
+// New creates an Elector and starts a goroutine which will attempt to acquire
+// and then renew the lease. If params.LeaseDuration is zero, a default of 30
+// seconds is used.
+func New(params Params) *Elector {
+	return newElector(params)
+}
+
+// GetLeader returns the identity of the current leader, or an empty string if
+// there is no known leader.
+func (e *Elector) GetLeader() string {
+	return e.getLeader()
+}
+
+// IsLeader returns true if this participant is the leader.
+func (e *Elector) IsLeader() bool {
+	return e.isLeader()
+}
+
+// Release will stop renewing the lease and release it if held. This should be
+// called before a planned shutdown, so that another participant may take over
+// quickly.
+func (e *Elector) Release() error {
+	return e.release()
+}
+
+// WriteHtml will write status information about the election.
+func (e *Elector) WriteHtml(writer io.Writer) {
+	e.writeHtml(writer)
+}
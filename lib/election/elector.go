@@ -0,0 +1,120 @@
+package election
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/format"
+)
+
+const defaultLeaseDuration = 30 * time.Second
+
+func newElector(params Params) *Elector {
+	if params.LeaseDuration <= 0 {
+		params.LeaseDuration = defaultLeaseDuration
+	}
+	e := &Elector{
+		params:   params,
+		stopChan: make(chan struct{}),
+	}
+	go e.loop()
+	return e
+}
+
+func (e *Elector) getLeader() string {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	if time.Now().After(e.lease.ExpiresAt) {
+		return ""
+	}
+	return e.lease.Holder
+}
+
+func (e *Elector) isLeader() bool {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.leader && time.Now().Before(e.lease.ExpiresAt)
+}
+
+func (e *Elector) loop() {
+	interval := e.params.LeaseDuration / 3
+	timer := time.NewTimer(0)
+	for {
+		select {
+		case <-e.stopChan:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		e.tryAcquire()
+		timer.Reset(interval)
+	}
+}
+
+func (e *Elector) release() error {
+	e.mutex.Lock()
+	if e.stopped {
+		e.mutex.Unlock()
+		return nil
+	}
+	e.stopped = true
+	wasLeader := e.leader
+	e.leader = false
+	e.mutex.Unlock()
+	close(e.stopChan)
+	if !wasLeader {
+		return nil
+	}
+	e.params.Logger.Println("Releasing leadership")
+	return e.params.Store.Release(e.params.Identity)
+}
+
+func (e *Elector) tryAcquire() {
+	// Compute the expiration time before acquiring, so that the leader never
+	// believes the lease is valid for longer than the other participants do.
+	expiresAt := time.Now().Add(e.params.LeaseDuration)
+	lease, err := e.params.Store.Acquire(e.params.Identity,
+		e.params.LeaseDuration)
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.stopped {
+		return
+	}
+	if err != nil {
+		e.params.Logger.Printf("Error acquiring lease: %s\n", err)
+		if e.leader && time.Now().After(e.lease.ExpiresAt) {
+			e.leader = false
+			e.params.Logger.Println("Lost leadership: lease expired")
+		}
+		return
+	}
+	isLeader := lease.Holder == e.params.Identity
+	if isLeader && lease.ExpiresAt.After(expiresAt) {
+		lease.ExpiresAt = expiresAt
+	}
+	e.lease = lease
+	if isLeader != e.leader {
+		e.leader = isLeader
+		if isLeader {
+			e.params.Logger.Println("Acquired leadership")
+		} else {
+			e.params.Logger.Printf("Lost leadership to: %s\n", lease.Holder)
+		}
+	}
+}
+
+func (e *Elector) writeHtml(writer io.Writer) {
+	e.mutex.RLock()
+	lease := e.lease
+	leader := e.leader
+	e.mutex.RUnlock()
+	if leader {
+		fmt.Fprintf(writer, "Leader, lease expires in %s<br>\n",
+			format.Duration(time.Until(lease.ExpiresAt)))
+	} else if lease.Holder == "" || time.Now().After(lease.ExpiresAt) {
+		fmt.Fprintln(writer, "Follower, no leader<br>")
+	} else {
+		fmt.Fprintf(writer, "Follower, leader: %s<br>\n", lease.Holder)
+	}
+}
@@ -0,0 +1,178 @@
+package election
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/log/testlogger"
+)
+
+const testLeaseDuration = 60 * time.Millisecond
+
+type memoryStore struct {
+	mutex        sync.Mutex
+	err          error
+	lease        Lease
+	numAcquires  uint
+	releasedFrom string
+}
+
+func (ms *memoryStore) Acquire(holder string, duration time.Duration) (
+	Lease, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	if ms.err != nil {
+		return Lease{}, ms.err
+	}
+	ms.numAcquires++
+	if ms.lease.Holder == holder || time.Now().After(ms.lease.ExpiresAt) {
+		ms.lease = Lease{Holder: holder, ExpiresAt: time.Now().Add(duration)}
+	}
+	return ms.lease, nil
+}
+
+func (ms *memoryStore) Release(holder string) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	if ms.lease.Holder == holder {
+		ms.lease = Lease{}
+		ms.releasedFrom = holder
+	}
+	return nil
+}
+
+func (ms *memoryStore) getNumAcquires() uint {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	return ms.numAcquires
+}
+
+func (ms *memoryStore) setError(err error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	ms.err = err
+}
+
+func (ms *memoryStore) setLease(lease Lease) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	ms.lease = lease
+}
+
+func newTestElector(t *testing.T, identity string,
+	store LeaseStore) *Elector {
+	e := New(Params{
+		Identity:      identity,
+		LeaseDuration: testLeaseDuration,
+		Logger:        testlogger.New(t),
+		Store:         store,
+	})
+	t.Cleanup(func() { e.Release() })
+	return e
+}
+
+func waitFor(t *testing.T, description string, condition func() bool) {
+	stopTime := time.Now().Add(time.Second)
+	for ; time.Now().Before(stopTime); time.Sleep(time.Millisecond) {
+		if condition() {
+			return
+		}
+	}
+	t.Fatalf("timed out waiting for: %s", description)
+}
+
+func TestElectorAcquireAndRenew(t *testing.T) {
+	store := &memoryStore{}
+	e := newTestElector(t, "a", store)
+	waitFor(t, "leadership", e.IsLeader)
+	if leader := e.GetLeader(); leader != "a" {
+		t.Errorf("leader: %s, expected: a", leader)
+	}
+	numAcquires := store.getNumAcquires()
+	waitFor(t, "renewal", func() bool {
+		return store.getNumAcquires() > numAcquires+2
+	})
+	// Renewals keep the lease valid past the original expiration.
+	time.Sleep(testLeaseDuration)
+	if !e.IsLeader() {
+		t.Error("leadership not renewed")
+	}
+}
+
+func TestElectorFollower(t *testing.T) {
+	store := &memoryStore{}
+	store.setLease(Lease{Holder: "b", ExpiresAt: time.Now().Add(time.Hour)})
+	e := newTestElector(t, "a", store)
+	waitFor(t, "leader to be known", func() bool {
+		return e.GetLeader() == "b"
+	})
+	if e.IsLeader() {
+		t.Error("acquired a lease held by another holder")
+	}
+	if err := e.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if store.releasedFrom != "" {
+		t.Error("follower released the lease")
+	}
+}
+
+func TestElectorLoss(t *testing.T) {
+	store := &memoryStore{}
+	e := newTestElector(t, "a", store)
+	waitFor(t, "leadership", e.IsLeader)
+	// Another holder takes the lease (for example, after a network partition).
+	store.setLease(Lease{Holder: "b", ExpiresAt: time.Now().Add(time.Hour)})
+	waitFor(t, "loss of leadership", func() bool { return !e.IsLeader() })
+	if leader := e.GetLeader(); leader != "b" {
+		t.Errorf("leader: %s, expected: b", leader)
+	}
+	// The lease is available again.
+	store.setLease(Lease{})
+	waitFor(t, "leadership", e.IsLeader)
+}
+
+func TestElectorLossWhenStoreFails(t *testing.T) {
+	store := &memoryStore{}
+	e := newTestElector(t, "a", store)
+	waitFor(t, "leadership", e.IsLeader)
+	store.setError(errors.New("store unavailable"))
+	waitFor(t, "loss of leadership", func() bool { return !e.IsLeader() })
+	if leader := e.GetLeader(); leader != "" {
+		t.Errorf("leader: %s, expected none", leader)
+	}
+	store.setError(nil)
+	waitFor(t, "leadership", e.IsLeader)
+}
+
+func TestElectorRelease(t *testing.T) {
+	store := &memoryStore{}
+	e := newTestElector(t, "a", store)
+	waitFor(t, "leadership", e.IsLeader)
+	if err := e.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if e.IsLeader() {
+		t.Error("still leader after release")
+	}
+	if store.releasedFrom != "a" {
+		t.Error("lease not released")
+	}
+	numAcquires := store.getNumAcquires()
+	time.Sleep(testLeaseDuration)
+	if store.getNumAcquires() != numAcquires {
+		t.Error("lease renewed after release")
+	}
+	if err := e.Release(); err != nil {
+		t.Errorf("second release failed: %s", err)
+	}
+	lease, err := store.Acquire("b", testLeaseDuration)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lease.Holder != "b" {
+		t.Errorf("released lease held by: %s", lease.Holder)
+	}
+}
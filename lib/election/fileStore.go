@@ -0,0 +1,99 @@
+package election
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/fsutil"
+)
+
+type fileStore struct {
+	filename string
+}
+
+func newFileStore(filename string) *fileStore {
+	return &fileStore{filename: filename}
+}
+
+// lock opens and locks the lease file. The lock is released when the file is
+// closed.
+func (fs *fileStore) lock() (*os.File, error) {
+	file, err := os.OpenFile(fs.filename, os.O_RDWR|os.O_CREATE,
+		fsutil.PrivateFilePerms)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+func readLease(file *os.File) (Lease, error) {
+	var lease Lease
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return lease, err
+	}
+	if err := json.NewDecoder(file).Decode(&lease); err != nil {
+		if err == io.EOF {
+			return Lease{}, nil // New file.
+		}
+		return lease, err
+	}
+	return lease, nil
+}
+
+func writeLease(file *os.File, lease Lease) error {
+	data, err := json.Marshal(lease)
+	if err != nil {
+		return err
+	}
+	if err := file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := file.WriteAt(append(data, '\n'), 0); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+func (fs *fileStore) Acquire(holder string, duration time.Duration) (
+	Lease, error) {
+	file, err := fs.lock()
+	if err != nil {
+		return Lease{}, err
+	}
+	defer file.Close()
+	lease, err := readLease(file)
+	if err != nil {
+		return Lease{}, err
+	}
+	if lease.Holder != holder && time.Now().Before(lease.ExpiresAt) {
+		return lease, nil // Held by another.
+	}
+	lease = Lease{Holder: holder, ExpiresAt: time.Now().Add(duration)}
+	if err := writeLease(file, lease); err != nil {
+		return Lease{}, err
+	}
+	return lease, nil
+}
+
+func (fs *fileStore) Release(holder string) error {
+	file, err := fs.lock()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	lease, err := readLease(file)
+	if err != nil {
+		return err
+	}
+	if lease.Holder != holder {
+		return nil
+	}
+	return writeLease(file, Lease{})
+}
@@ -0,0 +1,43 @@
+package election
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileStore(t *testing.T) {
+	store := NewFileStore(filepath.Join(t.TempDir(), "lease"))
+	lease, err := store.Acquire("a", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lease.Holder != "a" {
+		t.Fatalf("expected a to acquire the lease, holder: %s", lease.Holder)
+	}
+	lease, err = store.Acquire("b", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lease.Holder != "a" {
+		t.Fatalf("b acquired a held lease")
+	}
+	if err := store.Release("b"); err != nil {
+		t.Fatal(err)
+	}
+	if lease, _ := store.Acquire("a", time.Minute); lease.Holder != "a" {
+		t.Fatalf("a could not renew the lease, holder: %s", lease.Holder)
+	}
+	if err := store.Release("a"); err != nil {
+		t.Fatal(err)
+	}
+	if lease, _ := store.Acquire("b", time.Minute); lease.Holder != "b" {
+		t.Fatalf("b could not acquire a released lease")
+	}
+	if lease, _ := store.Acquire("b", -time.Second); lease.Holder != "b" {
+		t.Fatalf("b could not renew the lease")
+	}
+	if lease, _ := store.Acquire("a", time.Minute); lease.Holder != "a" {
+		t.Fatalf("a could not acquire an expired lease")
+	}
+}
@@ -11,7 +11,7 @@ import (
 
 type NumLinksTable map[uint64]int
 
-type ListSelector uint8
+type ListSelector uint16
 
 const (
 	ListSelectSkipMode = 1 << iota
@@ -22,6 +22,7 @@ const (
 	ListSelectSkipMtime
 	ListSelectSkipName
 	ListSelectSkipData
+	ListSelectSkipXattrs
 
 	ListSelectAll = 0
 )
@@ -145,6 +146,7 @@ type DirectoryInode struct {
 	Mode          FileMode
 	Uid           uint32
 	Gid           uint32
+	Xattrs        map[string][]byte
 }
 
 func (directory *DirectoryInode) BuildEntryMap() {
@@ -215,6 +217,7 @@ type RegularInode struct {
 	MtimeSeconds     int64
 	Size             uint64
 	Hash             hash.Hash
+	Xattrs           map[string][]byte
 }
 
 func (inode *RegularInode) GetGid() uint32 {
@@ -280,6 +283,7 @@ type SymlinkInode struct {
 	Uid     uint32
 	Gid     uint32
 	Symlink string
+	Xattrs  map[string][]byte
 }
 
 func (inode *SymlinkInode) GetGid() uint32 {
@@ -319,6 +323,7 @@ type SpecialInode struct {
 	MtimeNanoSeconds int32
 	MtimeSeconds     int64
 	Rdev             uint64
+	Xattrs           map[string][]byte
 }
 
 func (inode *SpecialInode) GetGid() uint32 {
@@ -423,6 +428,20 @@ func CompareSpecialInodesData(left, right *SpecialInode,
 	return compareSpecialInodesData(left, right, logWriter)
 }
 
+// CompareXattrs compares extended attributes. A nil map and an empty map are
+// considered the same.
+func CompareXattrs(left, right map[string][]byte, logWriter io.Writer) bool {
+	return compareXattrs(left, right, logWriter)
+}
+
 func ForceWriteMetadata(inode GenericInode, name string) error {
 	return forceWriteMetadata(inode, name)
 }
+
+// SelectXattrs returns the extended attributes in xattrs which have a name in
+// managed, such as the extended attributes of an inode in an image. Only those
+// extended attributes are managed by the image. If there are none, nil is
+// returned.
+func SelectXattrs(xattrs, managed map[string][]byte) map[string][]byte {
+	return selectXattrs(xattrs, managed)
+}
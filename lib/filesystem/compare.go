@@ -71,7 +71,7 @@ func compareDirectoriesMetadata(left, right *DirectoryInode,
 		}
 		return false
 	}
-	return true
+	return compareXattrs(left.Xattrs, right.Xattrs, logWriter)
 }
 
 func compareDirectoryEntries(left, right *DirectoryEntry,
@@ -205,7 +205,7 @@ func compareRegularInodesMetadata(left, right *RegularInode,
 		}
 		return false
 	}
-	return true
+	return compareXattrs(left.Xattrs, right.Xattrs, logWriter)
 }
 
 func compareRegularInodesData(left, right *RegularInode,
@@ -311,7 +311,7 @@ func compareSymlinkInodesMetadata(left, right *SymlinkInode,
 		}
 		return false
 	}
-	return true
+	return compareXattrs(left.Xattrs, right.Xattrs, logWriter)
 }
 
 func compareSymlinkInodesData(left, right *SymlinkInode,
@@ -374,7 +374,7 @@ func compareSpecialInodesMetadata(left, right *SpecialInode,
 		}
 		return false
 	}
-	return true
+	return compareXattrs(left.Xattrs, right.Xattrs, logWriter)
 }
 
 func compareSpecialInodesData(left, right *SpecialInode,
@@ -391,3 +391,41 @@ func compareSpecialInodesData(left, right *SpecialInode,
 	}
 	return true
 }
+
+func compareXattrs(left, right map[string][]byte, logWriter io.Writer) bool {
+	if len(left) != len(right) {
+		if logWriter != nil {
+			fmt.Fprintf(logWriter, "Xattrs: left vs. right: %d vs. %d\n",
+				len(left), len(right))
+		}
+		return false
+	}
+	for name, leftValue := range left {
+		if rightValue, ok := right[name]; !ok {
+			if logWriter != nil {
+				fmt.Fprintf(logWriter, "Xattr: %s: missing on right\n", name)
+			}
+			return false
+		} else if !bytes.Equal(leftValue, rightValue) {
+			if logWriter != nil {
+				fmt.Fprintf(logWriter, "Xattr: %s: left vs. right: %x vs. %x\n",
+					name, leftValue, rightValue)
+			}
+			return false
+		}
+	}
+	return true
+}
+
+func selectXattrs(xattrs, managed map[string][]byte) map[string][]byte {
+	var selected map[string][]byte
+	for name := range managed {
+		if value, ok := xattrs[name]; ok {
+			if selected == nil {
+				selected = make(map[string][]byte, len(managed))
+			}
+			selected[name] = value
+		}
+	}
+	return selected
+}
@@ -4,7 +4,11 @@ import (
 	"fmt"
 	"io"
 	"path"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
+	"unicode"
 
 	"github.com/Cloud-Foundations/Dominator/lib/filter"
 	"github.com/Cloud-Foundations/Dominator/lib/wsyscall"
@@ -46,6 +50,9 @@ func (inode *DirectoryInode) list(w io.Writer, name string,
 		0, -1, -1, name, true, listSelector); err != nil {
 		return err
 	}
+	if err := listXattrs(w, inode.Xattrs, listSelector); err != nil {
+		return err
+	}
 	for _, dirent := range inode.EntryList {
 		pathname := path.Join(name, dirent.Name)
 		if filter != nil && filter.Match(pathname) {
@@ -74,7 +81,10 @@ func (inode *RegularInode) list(w io.Writer, name string,
 	} else {
 		_, err = io.WriteString(w, "\n")
 	}
-	return err
+	if err != nil {
+		return err
+	}
+	return listXattrs(w, inode.Xattrs, listSelector)
 }
 
 func (inode *ComputedRegularInode) list(w io.Writer, name string,
@@ -106,15 +116,21 @@ func (inode *SymlinkInode) list(w io.Writer, name string,
 	} else {
 		_, err = io.WriteString(w, "\n")
 	}
-	return err
+	if err != nil {
+		return err
+	}
+	return listXattrs(w, inode.Xattrs, listSelector)
 }
 
 func (inode *SpecialInode) list(w io.Writer, name string,
 	numLinksTable NumLinksTable, numLinks int,
 	listSelector ListSelector) error {
-	return listUntilName(w, inode.Mode, numLinks, inode.Uid, inode.Gid,
+	if err := listUntilName(w, inode.Mode, numLinks, inode.Uid, inode.Gid,
 		inode.Rdev, inode.MtimeSeconds, inode.MtimeNanoSeconds, name, true,
-		listSelector)
+		listSelector); err != nil {
+		return err
+	}
+	return listXattrs(w, inode.Xattrs, listSelector)
 }
 
 func listUntilName(w io.Writer, mode FileMode, numLinks int, uid uint32,
@@ -182,6 +198,39 @@ func listUntilName(w io.Writer, mode FileMode, numLinks int, uid uint32,
 	return nil
 }
 
+// listXattrs writes the extended attributes, one per line, sorted by name.
+func listXattrs(w io.Writer, xattrs map[string][]byte,
+	listSelector ListSelector) error {
+	if len(xattrs) < 1 || listSelector&ListSelectSkipXattrs != 0 {
+		return nil
+	}
+	names := make([]string, 0, len(xattrs))
+	for name := range xattrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		_, err := fmt.Fprintf(w, "    xattr: %s=%s\n",
+			name, formatXattrValue(xattrs[name]))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatXattrValue returns a quoted string if the value is printable text
+// (optionally NUL-terminated, as used for SELinux labels), else hexadecimal.
+func formatXattrValue(value []byte) string {
+	text := strings.TrimSuffix(string(value), "\x00")
+	for _, char := range text {
+		if !unicode.IsPrint(char) {
+			return fmt.Sprintf("0x%x", value)
+		}
+	}
+	return strconv.Quote(string(value))
+}
+
 func (mode FileMode) string() string {
 	var buf [10]byte
 	w := 1
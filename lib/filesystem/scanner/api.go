@@ -38,7 +38,9 @@ type FileSystem struct {
 	inodeNumber uint64
 	fsLock      sync.Locker // Protect everything below.
 	filesystem.FileSystem
-	hashWaiters map[uint64]<-chan struct{} // Key: inode number.
+	hashWaiters      map[uint64]<-chan struct{} // Key: inode number.
+	dirtyDirectories map[string]struct{}        // Ancestors of ChangedPaths.
+	reusedInodes     filesystem.InodeTable      // Taken from OldFS.
 }
 
 type Params struct {
@@ -49,6 +51,11 @@ type Params struct {
 	CheckScanDisableRequest func() bool
 	Hasher                  Hasher
 	OldFS                   *FileSystem
+	// If ChangedPaths is not nil, only the paths listed and their ancestor
+	// directories are scanned. Everything else is taken from OldFS. The
+	// entries of a changed directory are all scanned. A Runner may not be
+	// specified, since a full scan may be needed.
+	ChangedPaths map[string]struct{}
 }
 
 func MakeRegularInode(stat *wsyscall.Stat_t) *filesystem.RegularInode {
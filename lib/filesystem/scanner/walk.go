@@ -13,6 +13,7 @@ import (
 
 	"github.com/Cloud-Foundations/Dominator/lib/concurrent"
 	"github.com/Cloud-Foundations/Dominator/lib/filesystem"
+	"github.com/Cloud-Foundations/Dominator/lib/fsutil"
 	"github.com/Cloud-Foundations/Dominator/lib/hash"
 	"github.com/Cloud-Foundations/Dominator/lib/wsyscall"
 )
@@ -49,6 +50,7 @@ func makeSpecialInode(stat *wsyscall.Stat_t) *filesystem.SpecialInode {
 }
 
 func scanFileSystem(params Params) (*FileSystem, error) {
+	runner := params.Runner
 	if params.CheckScanDisableRequest != nil &&
 		params.CheckScanDisableRequest() {
 		return nil, errors.New("DisableScan")
@@ -85,8 +87,20 @@ func scanFileSystem(params Params) (*FileSystem, error) {
 	if params.OldFS != nil && params.OldFS.InodeTable != nil {
 		oldDirectory = &params.OldFS.DirectoryInode
 	}
+	incremental := params.ChangedPaths != nil && oldDirectory != nil
+	if incremental {
+		fileSystem.dirtyDirectories = make(map[string]struct{})
+		fileSystem.reusedInodes = make(filesystem.InodeTable)
+		for pathname := range params.ChangedPaths {
+			for pathname != "/" {
+				pathname = path.Dir(pathname)
+				fileSystem.dirtyDirectories[pathname] = struct{}{}
+			}
+		}
+	}
 	err, _ := fileSystem.scanDirectory(&fileSystem.FileSystem.DirectoryInode,
-		oldDirectory, "/")
+		oldDirectory, "/", incremental)
+	oldFS := params.OldFS
 	params.OldFS = nil // Indicate early garbage collection.
 	if err != nil {
 		return nil, err
@@ -94,6 +108,23 @@ func scanFileSystem(params Params) (*FileSystem, error) {
 	if err := params.Runner.Reap(); err != nil {
 		return nil, err
 	}
+	fileSystem.Xattrs, err = fileSystem.getXattrs("/")
+	if err != nil {
+		return nil, err
+	}
+	for inodeNumber, inode := range fileSystem.reusedInodes {
+		if tableInode, ok := fileSystem.InodeTable[inodeNumber]; !ok {
+			fileSystem.InodeTable[inodeNumber] = inode
+		} else if tableInode != inode {
+			// A hardlink to a changed inode was not scanned: scan everything.
+			params.ChangedPaths = nil
+			params.OldFS = oldFS
+			params.Runner = runner
+			return scanFileSystem(params)
+		}
+	}
+	fileSystem.dirtyDirectories = nil
+	fileSystem.reusedInodes = nil
 	fileSystem.ComputeTotalDataBytes()
 	if err = fileSystem.RebuildInodePointers(); err != nil {
 		return nil, err
@@ -102,7 +133,8 @@ func scanFileSystem(params Params) (*FileSystem, error) {
 }
 
 func (fs *FileSystem) scanDirectory(directory *filesystem.DirectoryInode,
-	oldDirectory *filesystem.DirectoryInode, myPathName string) (error, bool) {
+	oldDirectory *filesystem.DirectoryInode, myPathName string,
+	incremental bool) (error, bool) {
 	file, err := os.Open(path.Join(fs.params.RootDirectoryName,
 		myPathName))
 	if err != nil {
@@ -116,6 +148,16 @@ func (fs *FileSystem) scanDirectory(directory *filesystem.DirectoryInode,
 	sort.Strings(names)
 	entryList := make([]*filesystem.DirectoryEntry, 0, len(names))
 	var copiedDirents int
+	var oldEntries map[string]*filesystem.DirectoryEntry
+	var rescanEntries bool
+	if incremental {
+		oldEntries = make(map[string]*filesystem.DirectoryEntry,
+			len(oldDirectory.EntryList))
+		for _, dirent := range oldDirectory.EntryList {
+			oldEntries[dirent.Name] = dirent
+		}
+		_, rescanEntries = fs.params.ChangedPaths[myPathName]
+	}
 	for _, name := range names {
 		if directory == &fs.DirectoryInode && name == ".subd" {
 			continue
@@ -125,6 +167,14 @@ func (fs *FileSystem) scanDirectory(directory *filesystem.DirectoryInode,
 			fs.params.ScanFilter.Match(filename) {
 			continue
 		}
+		if incremental && !rescanEntries && !fs.isDirty(filename) {
+			if oldDirent := oldEntries[name]; oldDirent != nil {
+				fs.reuseDirent(oldDirent)
+				entryList = append(entryList, oldDirent)
+				copiedDirents++
+				continue
+			}
+		}
 		var stat wsyscall.Stat_t
 		err := wsyscall.Lstat(path.Join(fs.params.RootDirectoryName,
 			filename), &stat)
@@ -145,7 +195,9 @@ func (fs *FileSystem) scanDirectory(directory *filesystem.DirectoryInode,
 		dirent.Name = name
 		dirent.InodeNumber = stat.Ino
 		var oldDirent *filesystem.DirectoryEntry
-		if oldDirectory != nil {
+		if incremental {
+			oldDirent = oldEntries[name]
+		} else if oldDirectory != nil {
 			index := len(entryList)
 			if len(oldDirectory.EntryList) > index &&
 				oldDirectory.EntryList[index].Name == name {
@@ -153,7 +205,8 @@ func (fs *FileSystem) scanDirectory(directory *filesystem.DirectoryInode,
 			}
 		}
 		if stat.Mode&syscall.S_IFMT == syscall.S_IFDIR {
-			err = fs.addDirectory(dirent, oldDirent, myPathName, &stat)
+			err = fs.addDirectory(dirent, oldDirent, myPathName, &stat,
+				incremental)
 		} else if stat.Mode&syscall.S_IFMT == syscall.S_IFREG {
 			err = fs.addRegularFile(dirent, myPathName, &stat)
 		} else if stat.Mode&syscall.S_IFMT == syscall.S_IFLNK {
@@ -161,7 +214,7 @@ func (fs *FileSystem) scanDirectory(directory *filesystem.DirectoryInode,
 		} else if stat.Mode&syscall.S_IFMT == syscall.S_IFSOCK {
 			continue
 		} else {
-			err = fs.addSpecialFile(dirent, &stat)
+			err = fs.addSpecialFile(dirent, myPathName, &stat)
 		}
 		if err != nil {
 			if err == syscall.ENOENT {
@@ -185,9 +238,30 @@ func (fs *FileSystem) scanDirectory(directory *filesystem.DirectoryInode,
 	}
 }
 
+func (fs *FileSystem) isDirty(pathname string) bool {
+	if _, ok := fs.params.ChangedPaths[pathname]; ok {
+		return true
+	}
+	_, ok := fs.dirtyDirectories[pathname]
+	return ok
+}
+
+// reuseDirent records the inodes from the old directory entry (recursively)
+// for inclusion in the new inode table.
+func (fs *FileSystem) reuseDirent(dirent *filesystem.DirectoryEntry) {
+	inode := dirent.Inode()
+	fs.reusedInodes[dirent.InodeNumber] = inode
+	if inode, ok := inode.(*filesystem.DirectoryInode); ok {
+		fs.DirectoryCount++
+		for _, dirent := range inode.EntryList {
+			fs.reuseDirent(dirent)
+		}
+	}
+}
+
 func (fs *FileSystem) addDirectory(dirent *filesystem.DirectoryEntry,
 	oldDirent *filesystem.DirectoryEntry, directoryPathName string,
-	stat *wsyscall.Stat_t) error {
+	stat *wsyscall.Stat_t, incremental bool) error {
 	myPathName := path.Join(directoryPathName, dirent.Name)
 	if stat.Ino == fs.inodeNumber {
 		return errors.New("recursive directory: " + myPathName)
@@ -204,13 +278,21 @@ func (fs *FileSystem) addDirectory(dirent *filesystem.DirectoryEntry,
 	inode.Mode = filesystem.FileMode(stat.Mode)
 	inode.Uid = stat.Uid
 	inode.Gid = stat.Gid
+	xattrs, err := fs.getXattrs(myPathName)
+	if err != nil {
+		return err
+	}
+	inode.Xattrs = xattrs
 	var oldInode *filesystem.DirectoryInode
 	if oldDirent != nil {
 		if oi, ok := oldDirent.Inode().(*filesystem.DirectoryInode); ok {
 			oldInode = oi
 		}
 	}
-	err, copied := fs.scanDirectory(inode, oldInode, myPathName)
+	// A replaced directory is scanned in full.
+	incremental = incremental && oldInode != nil &&
+		oldDirent.InodeNumber == stat.Ino
+	err, copied := fs.scanDirectory(inode, oldInode, myPathName, incremental)
 	if err != nil {
 		return err
 	}
@@ -252,6 +334,13 @@ func (fs *FileSystem) addRegularFile(dirent *filesystem.DirectoryEntry,
 		return err
 	}
 	inode := makeRegularInode(stat)
+	inode.Xattrs, err = fs.getXattrs(path.Join(directoryPathName,
+		dirent.Name))
+	if err != nil {
+		file.Close()
+		close(channel)
+		return err
+	}
 	err = fs.params.Runner.GoRun(func() (uint64, error) {
 		defer close(channel)
 		defer file.Close()
@@ -297,6 +386,10 @@ func (fs *FileSystem) addSymlink(dirent *filesystem.DirectoryEntry,
 	if err != nil {
 		return err
 	}
+	inode.Xattrs, err = fs.getXattrs(path.Join(directoryPathName, dirent.Name))
+	if err != nil {
+		return err
+	}
 	if fs.params.OldFS != nil && fs.params.OldFS.InodeTable != nil {
 		if oldInode, found := fs.params.OldFS.InodeTable[stat.Ino]; found {
 			if oldInode, ok := oldInode.(*filesystem.SymlinkInode); ok {
@@ -314,7 +407,7 @@ func (fs *FileSystem) addSymlink(dirent *filesystem.DirectoryEntry,
 }
 
 func (fs *FileSystem) addSpecialFile(dirent *filesystem.DirectoryEntry,
-	stat *wsyscall.Stat_t) error {
+	directoryPathName string, stat *wsyscall.Stat_t) error {
 	fs.fsLock.Lock()
 	if inode, ok := fs.InodeTable[stat.Ino]; ok {
 		if inode, ok := inode.(*filesystem.SpecialInode); ok {
@@ -327,6 +420,11 @@ func (fs *FileSystem) addSpecialFile(dirent *filesystem.DirectoryEntry,
 	}
 	fs.fsLock.Unlock()
 	inode := makeSpecialInode(stat)
+	xattrs, err := fs.getXattrs(path.Join(directoryPathName, dirent.Name))
+	if err != nil {
+		return err
+	}
+	inode.Xattrs = xattrs
 	if fs.params.OldFS != nil && fs.params.OldFS.InodeTable != nil {
 		if oldInode, found := fs.params.OldFS.InodeTable[stat.Ino]; found {
 			if oldInode, ok := oldInode.(*filesystem.SpecialInode); ok {
@@ -402,6 +500,11 @@ func (fs *FileSystem) scanSymlinkInode(inode *filesystem.SymlinkInode,
 	return nil
 }
 
+func (fs *FileSystem) getXattrs(myPathName string) (map[string][]byte, error) {
+	return fsutil.GetXattrs(path.Join(fs.params.RootDirectoryName,
+		myPathName))
+}
+
 func (l nilLocker) Lock() {}
 
 func (l nilLocker) Unlock() {}
@@ -0,0 +1,191 @@
+package scanner
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Cloud-Foundations/Dominator/lib/filesystem"
+)
+
+func describeDirectory(directory *filesystem.DirectoryInode,
+	dirname string, description map[string]string) {
+	for _, dirent := range directory.EntryList {
+		pathname := path.Join(dirname, dirent.Name)
+		switch inode := dirent.Inode().(type) {
+		case *filesystem.DirectoryInode:
+			description[pathname] = fmt.Sprintf("dir %s", inode.Mode)
+			describeDirectory(inode, pathname, description)
+		case *filesystem.RegularInode:
+			description[pathname] = fmt.Sprintf("file %s %d %x",
+				inode.Mode, inode.Size, inode.Hash)
+		case *filesystem.SymlinkInode:
+			description[pathname] = "symlink " + inode.Symlink
+		default:
+			description[pathname] = fmt.Sprintf("%T", inode)
+		}
+	}
+}
+
+// describeFileSystem returns a description of each path, for comparing scans.
+func describeFileSystem(fs *FileSystem) map[string]string {
+	description := make(map[string]string)
+	describeDirectory(&fs.DirectoryInode, "/", description)
+	return description
+}
+
+func findDirent(t *testing.T, fs *FileSystem,
+	pathname string) *filesystem.DirectoryEntry {
+	directory := &fs.DirectoryInode
+	var found *filesystem.DirectoryEntry
+	for _, name := range strings.Split(pathname[1:], "/") {
+		found = nil
+		for _, dirent := range directory.EntryList {
+			if dirent.Name == name {
+				found = dirent
+				break
+			}
+		}
+		if found == nil {
+			t.Fatalf("%s not found", pathname)
+		}
+		directory, _ = found.Inode().(*filesystem.DirectoryInode)
+	}
+	return found
+}
+
+func scanTestFileSystem(t *testing.T, rootDir string, oldFS *FileSystem,
+	changedPaths map[string]struct{}) *FileSystem {
+	fs, err := ScanFileSystemWithParams(Params{
+		ChangedPaths:      changedPaths,
+		OldFS:             oldFS,
+		RootDirectoryName: rootDir,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fs
+}
+
+func writeTestFile(t *testing.T, pathname, data string) {
+	if err := os.MkdirAll(filepath.Dir(pathname), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(pathname, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// checkIncrementalScan makes an incremental scan and checks that it matches
+// a full scan.
+func checkIncrementalScan(t *testing.T, rootDir string, oldFS *FileSystem,
+	changedPaths ...string) *FileSystem {
+	changedPathsMap := make(map[string]struct{}, len(changedPaths))
+	for _, pathname := range changedPaths {
+		changedPathsMap[pathname] = struct{}{}
+	}
+	fs := scanTestFileSystem(t, rootDir, oldFS, changedPathsMap)
+	fullFS := scanTestFileSystem(t, rootDir, nil, nil)
+	description := describeFileSystem(fs)
+	expected := describeFileSystem(fullFS)
+	if !reflect.DeepEqual(description, expected) {
+		t.Errorf("incremental scan: %v, expected: %v", description, expected)
+	}
+	if len(fs.InodeTable) != len(fullFS.InodeTable) {
+		t.Errorf("inode table size: %d, expected: %d",
+			len(fs.InodeTable), len(fullFS.InodeTable))
+	}
+	for inodeNumber := range fullFS.InodeTable {
+		if _, ok := fs.InodeTable[inodeNumber]; !ok {
+			t.Errorf("inode: %d missing", inodeNumber)
+		}
+	}
+	if fs.DirectoryCount != fullFS.DirectoryCount {
+		t.Errorf("directory count: %d, expected: %d",
+			fs.DirectoryCount, fullFS.DirectoryCount)
+	}
+	return fs
+}
+
+func TestIncrementalScan(t *testing.T) {
+	rootDir := t.TempDir()
+	writeTestFile(t, filepath.Join(rootDir, "changed", "file"), "old data")
+	writeTestFile(t, filepath.Join(rootDir, "removed", "gone"), "gone")
+	writeTestFile(t, filepath.Join(rootDir, "removed", "kept"), "kept")
+	writeTestFile(t, filepath.Join(rootDir, "renamed", "old"), "renamed")
+	writeTestFile(t, filepath.Join(rootDir, "renamed", "dir", "file"), "file")
+	writeTestFile(t, filepath.Join(rootDir, "unchanged", "file"), "same")
+	oldFS := scanTestFileSystem(t, rootDir, nil, nil)
+	oldDirent := findDirent(t, oldFS, "/unchanged")
+	writeTestFile(t, filepath.Join(rootDir, "changed", "file"), "new data")
+	writeTestFile(t, filepath.Join(rootDir, "changed", "added"), "added")
+	if err := os.Remove(filepath.Join(rootDir, "removed", "gone")); err != nil {
+		t.Fatal(err)
+	}
+	err := os.Rename(filepath.Join(rootDir, "renamed", "old"),
+		filepath.Join(rootDir, "renamed", "new"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Rename(filepath.Join(rootDir, "renamed", "dir"),
+		filepath.Join(rootDir, "renamed", "moved"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := checkIncrementalScan(t, rootDir, oldFS,
+		"/changed/file", "/changed/added", "/removed/gone",
+		"/renamed/old", "/renamed/new", "/renamed/dir", "/renamed/moved")
+	if findDirent(t, fs, "/unchanged") != oldDirent {
+		t.Error("unchanged directory was scanned")
+	}
+}
+
+func TestIncrementalScanChangedDirectory(t *testing.T) {
+	rootDir := t.TempDir()
+	writeTestFile(t, filepath.Join(rootDir, "dir", "a"), "a")
+	writeTestFile(t, filepath.Join(rootDir, "dir", "b"), "b")
+	oldFS := scanTestFileSystem(t, rootDir, nil, nil)
+	// A changed directory has all its entries scanned.
+	writeTestFile(t, filepath.Join(rootDir, "dir", "a"), "new a")
+	writeTestFile(t, filepath.Join(rootDir, "dir", "b"), "new b")
+	checkIncrementalScan(t, rootDir, oldFS, "/dir")
+}
+
+func TestIncrementalScanHardlinkFallback(t *testing.T) {
+	rootDir := t.TempDir()
+	writeTestFile(t, filepath.Join(rootDir, "x", "link1"), "old data")
+	if err := os.Mkdir(filepath.Join(rootDir, "y"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	err := os.Link(filepath.Join(rootDir, "x", "link1"),
+		filepath.Join(rootDir, "y", "link2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldFS := scanTestFileSystem(t, rootDir, nil, nil)
+	oldDirent := findDirent(t, oldFS, "/y")
+	// Change the data of the inode in place, so only one link is reported.
+	file, err := os.OpenFile(filepath.Join(rootDir, "x", "link1"),
+		os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.Write([]byte("new data")); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+	fs := checkIncrementalScan(t, rootDir, oldFS, "/x/link1")
+	if findDirent(t, fs, "/y") == oldDirent {
+		t.Error("no full scan after hardlinked inode changed")
+	}
+	link1 := findDirent(t, fs, "/x/link1").Inode()
+	if link2 := findDirent(t, fs, "/y/link2").Inode(); link1 != link2 {
+		t.Error("hardlinks do not share an inode")
+	}
+}
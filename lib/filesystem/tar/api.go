@@ -8,11 +8,23 @@ import (
 	"github.com/Cloud-Foundations/Dominator/lib/objectserver"
 )
 
+// DecodeXattrs returns the extended attributes in the PAX records of a tar
+// header, which are in the same format as GNU tar.
+func DecodeXattrs(header *tar.Header) map[string][]byte {
+	return decodeXattrs(header)
+}
+
 func Encode(tarWriter *tar.Writer, fileSystem *filesystem.FileSystem,
 	objectsGetter objectserver.ObjectsGetter) error {
 	return encode(tarWriter, fileSystem, objectsGetter)
 }
 
+// EncodeXattrs returns the PAX records for the extended attributes, using the
+// same format as GNU tar.
+func EncodeXattrs(xattrs map[string][]byte) map[string]string {
+	return encodeXattrs(xattrs)
+}
+
 func Write(writer io.Writer, fileSystem *filesystem.FileSystem,
 	objectsGetter objectserver.ObjectsGetter) error {
 	return write(writer, fileSystem, objectsGetter)
@@ -52,11 +52,12 @@ func writeDirectory(tarWriter *tar.Writer, fileSystem *filesystem.FileSystem,
 	objectsReader objectserver.ObjectsReader,
 	inodeTable map[uint64]struct{}) error {
 	header := tar.Header{
-		Name:     dirname + "/",
-		Mode:     int64(inode.Mode),
-		Uid:      int(inode.Uid),
-		Gid:      int(inode.Gid),
-		Typeflag: tar.TypeDir,
+		Name:       dirname + "/",
+		Mode:       int64(inode.Mode),
+		Uid:        int(inode.Uid),
+		Gid:        int(inode.Gid),
+		Typeflag:   tar.TypeDir,
+		PAXRecords: encodeXattrs(inode.Xattrs),
 	}
 	if err := tarWriter.WriteHeader(&header); err != nil {
 		return err
@@ -77,13 +78,14 @@ func writeRegularFile(tarWriter *tar.Writer, fileSystem *filesystem.FileSystem,
 	objectsReader objectserver.ObjectsReader,
 	inodeTable map[uint64]struct{}) error {
 	header := tar.Header{
-		Name:     name,
-		Mode:     int64(inode.Mode),
-		Uid:      int(inode.Uid),
-		Gid:      int(inode.Gid),
-		Size:     int64(inode.Size),
-		ModTime:  time.Unix(inode.MtimeSeconds, int64(inode.MtimeNanoSeconds)),
-		Typeflag: tar.TypeReg,
+		Name:       name,
+		Mode:       int64(inode.Mode),
+		Uid:        int(inode.Uid),
+		Gid:        int(inode.Gid),
+		Size:       int64(inode.Size),
+		ModTime:    time.Unix(inode.MtimeSeconds, int64(inode.MtimeNanoSeconds)),
+		Typeflag:   tar.TypeReg,
+		PAXRecords: encodeXattrs(inode.Xattrs),
 	}
 	err := writeHeader(tarWriter, fileSystem, &header, inodeNumber,
 		inodeTable)
@@ -154,13 +156,14 @@ func writeSpecial(tarWriter *tar.Writer, fileSystem *filesystem.FileSystem,
 	inode *filesystem.SpecialInode, name string, inodeNumber uint64,
 	inodeTable map[uint64]struct{}) error {
 	header := tar.Header{
-		Name:     name,
-		Mode:     int64(inode.Mode),
-		Uid:      int(inode.Uid),
-		Gid:      int(inode.Gid),
-		ModTime:  time.Unix(inode.MtimeSeconds, int64(inode.MtimeNanoSeconds)),
-		Devmajor: int64(inode.Rdev >> 8),
-		Devminor: int64(inode.Rdev & 0xff),
+		Name:       name,
+		Mode:       int64(inode.Mode),
+		Uid:        int(inode.Uid),
+		Gid:        int(inode.Gid),
+		ModTime:    time.Unix(inode.MtimeSeconds, int64(inode.MtimeNanoSeconds)),
+		Devmajor:   int64(inode.Rdev >> 8),
+		Devminor:   int64(inode.Rdev & 0xff),
+		PAXRecords: encodeXattrs(inode.Xattrs),
 	}
 	if inode.Mode&syscall.S_IFMT == syscall.S_IFCHR {
 		header.Typeflag = tar.TypeChar
@@ -178,12 +181,13 @@ func writeSymlink(tarWriter *tar.Writer, fileSystem *filesystem.FileSystem,
 	inode *filesystem.SymlinkInode, name string, inodeNumber uint64,
 	inodeTable map[uint64]struct{}) error {
 	header := tar.Header{
-		Name:     name,
-		Mode:     0777,
-		Uid:      int(inode.Uid),
-		Gid:      int(inode.Gid),
-		Typeflag: tar.TypeSymlink,
-		Linkname: inode.Symlink,
+		Name:       name,
+		Mode:       0777,
+		Uid:        int(inode.Uid),
+		Gid:        int(inode.Gid),
+		Typeflag:   tar.TypeSymlink,
+		Linkname:   inode.Symlink,
+		PAXRecords: encodeXattrs(inode.Xattrs),
 	}
 	return writeHeader(tarWriter, fileSystem, &header, inodeNumber, inodeTable)
 }
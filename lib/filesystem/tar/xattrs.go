@@ -0,0 +1,32 @@
+package tar
+
+import (
+	"archive/tar"
+	"strings"
+)
+
+const paxXattrPrefix = "SCHILY.xattr."
+
+func decodeXattrs(header *tar.Header) map[string][]byte {
+	var xattrs map[string][]byte
+	for key, value := range header.PAXRecords {
+		if name := strings.TrimPrefix(key, paxXattrPrefix); name != key {
+			if xattrs == nil {
+				xattrs = make(map[string][]byte)
+			}
+			xattrs[name] = []byte(value)
+		}
+	}
+	return xattrs
+}
+
+func encodeXattrs(xattrs map[string][]byte) map[string]string {
+	if len(xattrs) < 1 {
+		return nil
+	}
+	records := make(map[string]string, len(xattrs))
+	for name, value := range xattrs {
+		records[paxXattrPrefix+name] = string(value)
+	}
+	return records
+}
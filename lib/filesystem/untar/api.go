@@ -13,7 +13,31 @@ type Hasher interface {
 	Hash(reader io.Reader, length uint64) (hash.Hash, error)
 }
 
+// LayerDecoder builds a file-system from a sequence of image layers, such as
+// the layers of an OCI or Docker image. Whiteout files in a layer remove paths
+// from the lower layers.
+type LayerDecoder struct {
+	data       *decoderData
+	filter     *filter.Filter
+	hasher     Hasher
+	layerNames map[string]struct{} // Paths in the current layer.
+}
+
 func Decode(tarReader *tar.Reader, hasher Hasher, filter *filter.Filter) (
 	*filesystem.FileSystem, error) {
 	return decode(tarReader, hasher, filter)
 }
+
+func NewLayerDecoder(hasher Hasher, filter *filter.Filter) *LayerDecoder {
+	return newLayerDecoder(hasher, filter)
+}
+
+// DecodeLayer applies the next layer on top of the previous layers.
+func (d *LayerDecoder) DecodeLayer(tarReader *tar.Reader) error {
+	return d.decodeLayer(tarReader)
+}
+
+// FileSystem returns the file-system. No more layers may be decoded.
+func (d *LayerDecoder) FileSystem() *filesystem.FileSystem {
+	return d.fileSystem()
+}
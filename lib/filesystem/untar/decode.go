@@ -9,10 +9,14 @@ import (
 	"syscall"
 
 	"github.com/Cloud-Foundations/Dominator/lib/filesystem"
+	fstar "github.com/Cloud-Foundations/Dominator/lib/filesystem/tar"
 	"github.com/Cloud-Foundations/Dominator/lib/filter"
 	"github.com/Cloud-Foundations/Dominator/lib/wsyscall"
 )
 
+const directoryMode = wsyscall.S_IFDIR | wsyscall.S_IRWXU | wsyscall.S_IRGRP |
+	wsyscall.S_IXGRP | wsyscall.S_IROTH | wsyscall.S_IXOTH
+
 type decoderData struct {
 	nextInodeNumber uint64
 	fileSystem      filesystem.FileSystem
@@ -22,17 +26,7 @@ type decoderData struct {
 
 func decode(tarReader *tar.Reader, hasher Hasher, filter *filter.Filter) (
 	*filesystem.FileSystem, error) {
-	var decoderData decoderData
-	decoderData.inodeTable = make(map[string]uint64)
-	decoderData.directoryTable = make(map[string]*filesystem.DirectoryInode)
-	fileSystem := &decoderData.fileSystem
-	fileSystem.InodeTable = make(filesystem.InodeTable)
-	// Create a default top-level directory which may be updated.
-	decoderData.addInode("/", &fileSystem.DirectoryInode)
-	fileSystem.DirectoryInode.Mode = wsyscall.S_IFDIR | wsyscall.S_IRWXU |
-		wsyscall.S_IRGRP | wsyscall.S_IXGRP | wsyscall.S_IROTH |
-		wsyscall.S_IXOTH
-	decoderData.directoryTable["/"] = &fileSystem.DirectoryInode
+	decoderData := newDecoderData()
 	for {
 		header, err := tarReader.Next()
 		if err == io.EOF {
@@ -54,11 +48,30 @@ func decode(tarReader *tar.Reader, hasher Hasher, filter *filter.Filter) (
 			return nil, err
 		}
 	}
+	return decoderData.finish(), nil
+}
+
+func newDecoderData() *decoderData {
+	decoderData := &decoderData{
+		inodeTable:     make(map[string]uint64),
+		directoryTable: make(map[string]*filesystem.DirectoryInode),
+	}
+	fileSystem := &decoderData.fileSystem
+	fileSystem.InodeTable = make(filesystem.InodeTable)
+	// Create a default top-level directory which may be updated.
+	decoderData.addInode("/", &fileSystem.DirectoryInode)
+	fileSystem.DirectoryInode.Mode = directoryMode
+	decoderData.directoryTable["/"] = &fileSystem.DirectoryInode
+	return decoderData
+}
+
+func (decoderData *decoderData) finish() *filesystem.FileSystem {
+	fileSystem := &decoderData.fileSystem
 	delete(fileSystem.InodeTable, 0)
 	fileSystem.DirectoryCount = uint64(len(decoderData.directoryTable))
 	fileSystem.ComputeTotalDataBytes()
 	sortDirectory(&fileSystem.DirectoryInode)
-	return fileSystem, nil
+	return fileSystem
 }
 
 func normaliseFilename(filename string) string {
@@ -112,6 +125,7 @@ func (decoderData *decoderData) addRegularFile(tarReader *tar.Reader,
 	newInode.MtimeNanoSeconds = int32(header.ModTime.Nanosecond())
 	newInode.MtimeSeconds = header.ModTime.Unix()
 	newInode.Size = uint64(header.Size)
+	newInode.Xattrs = fstar.DecodeXattrs(header)
 	if header.Size > 0 {
 		var err error
 		newInode.Hash, err = hasher.Hash(tarReader, uint64(header.Size))
@@ -130,6 +144,7 @@ func (decoderData *decoderData) addDirectory(header *tar.Header,
 		syscall.S_IFDIR)
 	newInode.Uid = uint32(header.Uid)
 	newInode.Gid = uint32(header.Gid)
+	newInode.Xattrs = fstar.DecodeXattrs(header)
 	if header.Name == "/" {
 		*decoderData.directoryTable[header.Name] = newInode
 		return nil
@@ -159,6 +174,7 @@ func (decoderData *decoderData) addSymlink(header *tar.Header,
 	newInode.Uid = uint32(header.Uid)
 	newInode.Gid = uint32(header.Gid)
 	newInode.Symlink = header.Linkname
+	newInode.Xattrs = fstar.DecodeXattrs(header)
 	decoderData.addEntry(parent, header.Name, name, &newInode)
 	return nil
 }
@@ -187,6 +203,7 @@ func (decoderData *decoderData) addSpecialFile(header *tar.Header,
 			header.Devminor)
 	}
 	newInode.Rdev = uint64(header.Devmajor<<8 | header.Devminor)
+	newInode.Xattrs = fstar.DecodeXattrs(header)
 	decoderData.addEntry(parent, header.Name, name, &newInode)
 	return nil
 }
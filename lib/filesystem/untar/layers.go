@@ -0,0 +1,175 @@
+package untar
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"syscall"
+
+	"github.com/Cloud-Foundations/Dominator/lib/filesystem"
+	fstar "github.com/Cloud-Foundations/Dominator/lib/filesystem/tar"
+	"github.com/Cloud-Foundations/Dominator/lib/filter"
+)
+
+const (
+	whiteoutOpaque = ".wh..wh..opq"
+	whiteoutPrefix = ".wh."
+)
+
+func newLayerDecoder(hasher Hasher, filter *filter.Filter) *LayerDecoder {
+	return &LayerDecoder{
+		data:   newDecoderData(),
+		filter: filter,
+		hasher: hasher,
+	}
+}
+
+func (d *LayerDecoder) decodeLayer(tarReader *tar.Reader) error {
+	d.layerNames = make(map[string]struct{})
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+		header.Name = normaliseFilename(header.Name)
+		if header.Name == "/.subd" ||
+			strings.HasPrefix(header.Name, "/.subd/") {
+			continue
+		}
+		dirname := path.Dir(header.Name)
+		leafName := path.Base(header.Name)
+		// Whiteouts only apply to the lower layers.
+		if leafName == whiteoutOpaque {
+			d.removeLowerEntries(dirname)
+			continue
+		}
+		if strings.HasPrefix(leafName, whiteoutPrefix) {
+			name := path.Join(dirname, leafName[len(whiteoutPrefix):])
+			if _, ok := d.layerNames[name]; !ok {
+				d.data.removeEntry(name)
+			}
+			continue
+		}
+		if d.filter != nil && d.filter.Match(header.Name) {
+			continue
+		}
+		d.data.makeParents(dirname)
+		d.layerNames[header.Name] = struct{}{}
+		if header.Typeflag == tar.TypeDir {
+			// Existing directories keep their contents.
+			if inode, ok := d.data.directoryTable[header.Name]; ok {
+				updateDirectory(inode, header)
+				continue
+			}
+		}
+		d.data.removeEntry(header.Name)
+		if err := d.data.addHeader(tarReader, d.hasher, header); err != nil {
+			return fmt.Errorf("%s: %s", header.Name, err)
+		}
+	}
+}
+
+func (d *LayerDecoder) fileSystem() *filesystem.FileSystem {
+	d.data.removeUnreferencedInodes()
+	return d.data.finish()
+}
+
+// removeLowerEntries removes the entries in the directory which were not added
+// by the current layer.
+func (d *LayerDecoder) removeLowerEntries(dirname string) {
+	directory, ok := d.data.directoryTable[dirname]
+	if !ok {
+		return
+	}
+	var names []string
+	for _, entry := range directory.EntryList {
+		name := path.Join(dirname, entry.Name)
+		if _, ok := d.layerNames[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	for _, name := range names {
+		d.data.removeEntry(name)
+	}
+}
+
+// forgetNames removes the entry and everything below it from the name tables.
+func (decoderData *decoderData) forgetNames(fullName string,
+	entry *filesystem.DirectoryEntry) {
+	delete(decoderData.inodeTable, fullName)
+	if inode, ok := entry.Inode().(*filesystem.DirectoryInode); ok {
+		delete(decoderData.directoryTable, fullName)
+		for _, child := range inode.EntryList {
+			decoderData.forgetNames(path.Join(fullName, child.Name), child)
+		}
+	}
+}
+
+// makeParents makes the directory and any missing parents, replacing anything
+// which is not a directory.
+func (decoderData *decoderData) makeParents(dirname string) {
+	if _, ok := decoderData.directoryTable[dirname]; ok {
+		return
+	}
+	decoderData.makeParents(path.Dir(dirname))
+	decoderData.removeEntry(dirname)
+	newInode := &filesystem.DirectoryInode{Mode: directoryMode}
+	decoderData.addEntry(decoderData.directoryTable[path.Dir(dirname)],
+		dirname, path.Base(dirname), newInode)
+	decoderData.directoryTable[dirname] = newInode
+}
+
+func (decoderData *decoderData) removeEntry(fullName string) {
+	parent, ok := decoderData.directoryTable[path.Dir(fullName)]
+	if !ok {
+		return
+	}
+	name := path.Base(fullName)
+	for index, entry := range parent.EntryList {
+		if entry.Name == name {
+			parent.EntryList = append(parent.EntryList[:index],
+				parent.EntryList[index+1:]...)
+			decoderData.forgetNames(fullName, entry)
+			return
+		}
+	}
+}
+
+// removeUnreferencedInodes removes inodes which were replaced or removed by
+// later layers.
+func (decoderData *decoderData) removeUnreferencedInodes() {
+	inodeTable := decoderData.fileSystem.InodeTable
+	referenced := make(map[uint64]struct{}, len(inodeTable))
+	markReferencedInodes(&decoderData.fileSystem.DirectoryInode, referenced)
+	for inodeNumber := range inodeTable {
+		if _, ok := referenced[inodeNumber]; !ok {
+			delete(inodeTable, inodeNumber)
+		}
+	}
+}
+
+func markReferencedInodes(directory *filesystem.DirectoryInode,
+	referenced map[uint64]struct{}) {
+	for _, entry := range directory.EntryList {
+		referenced[entry.InodeNumber] = struct{}{}
+		if inode, ok := entry.Inode().(*filesystem.DirectoryInode); ok {
+			markReferencedInodes(inode, referenced)
+		}
+	}
+}
+
+func updateDirectory(inode *filesystem.DirectoryInode, header *tar.Header) {
+	inode.Mode = filesystem.FileMode((header.Mode & ^syscall.S_IFMT) |
+		syscall.S_IFDIR)
+	inode.Uid = uint32(header.Uid)
+	inode.Gid = uint32(header.Gid)
+	inode.Xattrs = fstar.DecodeXattrs(header)
+}
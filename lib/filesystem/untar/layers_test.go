@@ -0,0 +1,111 @@
+package untar
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha512"
+	"io"
+	"testing"
+
+	"github.com/Cloud-Foundations/Dominator/lib/filesystem"
+	"github.com/Cloud-Foundations/Dominator/lib/hash"
+)
+
+type testHasher struct{}
+
+type testEntry struct {
+	name     string
+	typeflag byte
+	data     string
+}
+
+func (testHasher) Hash(reader io.Reader, length uint64) (hash.Hash, error) {
+	hasher := sha512.New()
+	if _, err := io.CopyN(hasher, reader, int64(length)); err != nil {
+		return hash.Hash{}, err
+	}
+	var hashVal hash.Hash
+	copy(hashVal[:], hasher.Sum(nil))
+	return hashVal, nil
+}
+
+func makeLayer(t *testing.T, entries []testEntry) *tar.Reader {
+	buffer := &bytes.Buffer{}
+	writer := tar.NewWriter(buffer)
+	for _, entry := range entries {
+		header := &tar.Header{
+			Name:     entry.name,
+			Typeflag: entry.typeflag,
+			Mode:     0755,
+			Size:     int64(len(entry.data)),
+		}
+		if entry.typeflag != tar.TypeReg {
+			header.Size = 0
+		}
+		if err := writer.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(writer, entry.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return tar.NewReader(buffer)
+}
+
+func listNames(fs *filesystem.FileSystem) map[string]struct{} {
+	names := make(map[string]struct{})
+	fs.ForEachFile(func(name string, inodeNumber uint64,
+		inode filesystem.GenericInode) error {
+		names[name] = struct{}{}
+		return nil
+	})
+	return names
+}
+
+func TestDecodeLayers(t *testing.T) {
+	decoder := NewLayerDecoder(testHasher{}, nil)
+	layers := [][]testEntry{
+		{
+			{"etc/", tar.TypeDir, ""},
+			{"etc/passwd", tar.TypeReg, "root"},
+			{"etc/group", tar.TypeReg, "root"},
+			{"opt/app/", tar.TypeDir, ""},
+			{"opt/app/old", tar.TypeReg, "old"},
+			{"usr/bin/tool", tar.TypeReg, "tool"},
+		},
+		{
+			{"etc/.wh.group", tar.TypeReg, ""},
+			{"etc/passwd", tar.TypeReg, "root user"},
+			{"opt/app/new", tar.TypeReg, "new"},
+			{"opt/app/.wh..wh..opq", tar.TypeReg, ""},
+			{"usr/bin/", tar.TypeDir, ""},
+		},
+	}
+	for _, layer := range layers {
+		if err := decoder.DecodeLayer(makeLayer(t, layer)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fs := decoder.FileSystem()
+	names := listNames(fs)
+	for _, name := range []string{"/etc/passwd", "/opt/app/new",
+		"/usr/bin/tool"} {
+		if _, ok := names[name]; !ok {
+			t.Errorf("missing: %s", name)
+		}
+	}
+	for _, name := range []string{"/etc/group", "/opt/app/old"} {
+		if _, ok := names[name]; ok {
+			t.Errorf("not removed: %s", name)
+		}
+	}
+	if len(fs.InodeTable) != len(names)-1 { // The root is not in the table.
+		t.Errorf("%d inodes for %d names", len(fs.InodeTable), len(names)-1)
+	}
+	if fs.TotalDataBytes != uint64(len("root user")+len("new")+len("tool")) {
+		t.Errorf("bad total data bytes: %d", fs.TotalDataBytes)
+	}
+}
@@ -0,0 +1,87 @@
+package untar
+
+import (
+	"archive/tar"
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/Cloud-Foundations/Dominator/lib/filesystem"
+	fstar "github.com/Cloud-Foundations/Dominator/lib/filesystem/tar"
+	"github.com/Cloud-Foundations/Dominator/lib/objectserver/memory"
+)
+
+func TestXattrsRoundTrip(t *testing.T) {
+	objSrv := memory.NewObjectServer()
+	data := []byte("data")
+	hashVal, _, err := objSrv.AddObject(bytes.NewReader(data),
+		uint64(len(data)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dirXattrs := map[string][]byte{"user.dir": []byte("dir")}
+	fileXattrs := map[string][]byte{
+		"security.capability": {1, 0, 0, 2},
+		"user.empty":          {},
+	}
+	symlinkXattrs := map[string][]byte{"user.symlink": []byte("link")}
+	fs := &filesystem.FileSystem{
+		InodeTable: filesystem.InodeTable{
+			1: &filesystem.DirectoryInode{Mode: 040755, Xattrs: dirXattrs},
+			2: &filesystem.RegularInode{
+				Mode:   0100644,
+				Size:   uint64(len(data)),
+				Hash:   hashVal,
+				Xattrs: fileXattrs,
+			},
+			3: &filesystem.SymlinkInode{Symlink: "file",
+				Xattrs: symlinkXattrs},
+			4: &filesystem.RegularInode{Mode: 0100644},
+		},
+		DirectoryInode: filesystem.DirectoryInode{Mode: 040755},
+	}
+	fs.DirectoryInode.EntryList = []*filesystem.DirectoryEntry{
+		{Name: "dir", InodeNumber: 1},
+		{Name: "file", InodeNumber: 2},
+		{Name: "plain", InodeNumber: 4},
+		{Name: "symlink", InodeNumber: 3},
+	}
+	if err := fs.RebuildInodePointers(); err != nil {
+		t.Fatal(err)
+	}
+	buffer := &bytes.Buffer{}
+	if err := fstar.Write(buffer, fs, objSrv); err != nil {
+		t.Fatal(err)
+	}
+	decodedFS, err := Decode(tar.NewReader(buffer), testHasher{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decodedFS.BuildEntryMap()
+	entries := decodedFS.DirectoryInode.EntriesByName
+	tests := map[string]map[string][]byte{
+		"dir":     dirXattrs,
+		"file":    fileXattrs,
+		"plain":   nil,
+		"symlink": symlinkXattrs,
+	}
+	for name, expected := range tests {
+		entry, ok := entries[name]
+		if !ok {
+			t.Errorf("%s: missing", name)
+			continue
+		}
+		var xattrs map[string][]byte
+		switch inode := entry.Inode().(type) {
+		case *filesystem.DirectoryInode:
+			xattrs = inode.Xattrs
+		case *filesystem.RegularInode:
+			xattrs = inode.Xattrs
+		case *filesystem.SymlinkInode:
+			xattrs = inode.Xattrs
+		}
+		if !reflect.DeepEqual(xattrs, expected) {
+			t.Errorf("%s: xattrs: %v, expected: %v", name, xattrs, expected)
+		}
+	}
+}
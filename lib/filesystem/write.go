@@ -48,7 +48,10 @@ func (inode *DirectoryInode) writeMetadata(name string) error {
 	if err := os.Lchown(name, int(inode.Uid), int(inode.Gid)); err != nil {
 		return err
 	}
-	return syscall.Chmod(name, uint32(inode.Mode))
+	if err := syscall.Chmod(name, uint32(inode.Mode)); err != nil {
+		return err
+	}
+	return writeXattrs(name, inode.Xattrs)
 }
 
 func (inode *RegularInode) writeMetadata(name string) error {
@@ -58,6 +61,9 @@ func (inode *RegularInode) writeMetadata(name string) error {
 	if err := syscall.Chmod(name, uint32(inode.Mode)); err != nil {
 		return err
 	}
+	if err := writeXattrs(name, inode.Xattrs); err != nil {
+		return err
+	}
 	t := time.Unix(inode.MtimeSeconds, int64(inode.MtimeNanoSeconds))
 	return os.Chtimes(name, t, t)
 }
@@ -77,7 +83,10 @@ func (inode *SymlinkInode) make(name string) error {
 }
 
 func (inode *SymlinkInode) writeMetadata(name string) error {
-	return os.Lchown(name, int(inode.Uid), int(inode.Gid))
+	if err := os.Lchown(name, int(inode.Uid), int(inode.Gid)); err != nil {
+		return err
+	}
+	return writeXattrs(name, inode.Xattrs)
 }
 
 func (inode *SpecialInode) write(name string) error {
@@ -107,6 +116,20 @@ func (inode *SpecialInode) writeMetadata(name string) error {
 	if err := syscall.Chmod(name, uint32(inode.Mode)); err != nil {
 		return err
 	}
+	if err := writeXattrs(name, inode.Xattrs); err != nil {
+		return err
+	}
 	t := time.Unix(inode.MtimeSeconds, int64(inode.MtimeNanoSeconds))
 	return os.Chtimes(name, t, t)
 }
+
+// writeXattrs sets the extended attributes. This must be done after changing
+// the ownership, which clears file capabilities. Other extended attributes of
+// the file (such as SELinux labels) are left alone, since they are not managed
+// by the image.
+func writeXattrs(name string, xattrs map[string][]byte) error {
+	if len(xattrs) < 1 {
+		return nil
+	}
+	return fsutil.SetXattrs(name, xattrs)
+}
@@ -148,6 +148,13 @@ func GetTreeSize(dirname string) (uint64, error) {
 	return getTreeSize(dirname)
 }
 
+// GetXattrs will read the extended attributes of the file at pathname, without
+// following symbolic links. If the file has no extended attributes or the
+// file-system does not support them, nil is returned.
+func GetXattrs(pathname string) (map[string][]byte, error) {
+	return getXattrs(pathname)
+}
+
 // LoadLines will open a file and read lines from it. Comment lines (i.e. lines
 // beginning with '#') are skipped.
 func LoadLines(filename string) ([]string, error) {
@@ -213,6 +220,20 @@ func ReadLines(reader io.Reader) ([]string, error) {
 	return readLines(reader)
 }
 
+// ReplaceXattrs will set the extended attributes of the file at pathname to
+// xattrs, without following symbolic links. Extended attributes not in xattrs
+// are removed.
+func ReplaceXattrs(pathname string, xattrs map[string][]byte) error {
+	return replaceXattrs(pathname, xattrs)
+}
+
+// SetXattrs will set the extended attributes in xattrs for the file at
+// pathname, without following symbolic links. Other extended attributes (such
+// as SELinux labels) are left alone.
+func SetXattrs(pathname string, xattrs map[string][]byte) error {
+	return setXattrs(pathname, xattrs)
+}
+
 // UpdateFile will read and compare the contents of a file and buffer and will
 // update the file if different. It returns true if the contents were updated.
 func UpdateFile(buffer []byte, filename string) (bool, error) {
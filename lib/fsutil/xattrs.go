@@ -0,0 +1,115 @@
+package fsutil
+
+import (
+	"bytes"
+	"os"
+	"syscall"
+
+	"github.com/Cloud-Foundations/Dominator/lib/wsyscall"
+)
+
+func getXattrs(pathname string) (map[string][]byte, error) {
+	names, err := listXattrs(pathname)
+	if err != nil || len(names) < 1 {
+		return nil, err
+	}
+	xattrs := make(map[string][]byte, len(names))
+	for _, name := range names {
+		value, err := getXattr(pathname, name)
+		if err != nil {
+			if err == syscall.ENODATA {
+				continue // Removed since listing.
+			}
+			return nil, err
+		}
+		xattrs[name] = value
+	}
+	if len(xattrs) < 1 {
+		return nil, nil
+	}
+	return xattrs, nil
+}
+
+func getXattr(pathname, name string) ([]byte, error) {
+	for {
+		size, err := wsyscall.Lgetxattr(pathname, name, nil)
+		if err != nil {
+			return nil, err
+		}
+		if size < 1 {
+			return []byte{}, nil
+		}
+		buffer := make([]byte, size)
+		size, err = wsyscall.Lgetxattr(pathname, name, buffer)
+		if err == syscall.ERANGE {
+			continue // Grew since getting the size.
+		}
+		if err != nil {
+			return nil, err
+		}
+		return buffer[:size], nil
+	}
+}
+
+func listXattrs(pathname string) ([]string, error) {
+	for {
+		size, err := wsyscall.Llistxattr(pathname, nil)
+		if err != nil {
+			if err == syscall.ENOTSUP {
+				return nil, nil
+			}
+			return nil, err
+		}
+		if size < 1 {
+			return nil, nil
+		}
+		buffer := make([]byte, size)
+		size, err = wsyscall.Llistxattr(pathname, buffer)
+		if err == syscall.ERANGE {
+			continue // Grew since getting the size.
+		}
+		if err != nil {
+			return nil, err
+		}
+		var names []string
+		for _, name := range bytes.Split(buffer[:size], []byte{0}) {
+			if len(name) > 0 {
+				names = append(names, string(name))
+			}
+		}
+		return names, nil
+	}
+}
+
+func replaceXattrs(pathname string, xattrs map[string][]byte) error {
+	names, err := listXattrs(pathname)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if _, ok := xattrs[name]; ok {
+			continue
+		}
+		if err := wsyscall.Lremovexattr(pathname, name); err != nil {
+			if err != syscall.ENODATA {
+				return &os.PathError{Op: "lremovexattr " + name, Path: pathname,
+					Err: err}
+			}
+		}
+	}
+	return setXattrs(pathname, xattrs)
+}
+
+func setXattrs(pathname string, xattrs map[string][]byte) error {
+	for name, value := range xattrs {
+		if oldValue, err := getXattr(pathname, name); err == nil &&
+			bytes.Equal(value, oldValue) {
+			continue
+		}
+		if err := wsyscall.Lsetxattr(pathname, name, value, 0); err != nil {
+			return &os.PathError{Op: "lsetxattr " + name, Path: pathname,
+				Err: err}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package fsutil
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+
+	"github.com/Cloud-Foundations/Dominator/lib/wsyscall"
+)
+
+func makeXattrsTestFile(t *testing.T) string {
+	filename := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(filename, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	err := wsyscall.Lsetxattr(filename, "user.unmanaged", []byte("keep"), 0)
+	if err == syscall.ENOTSUP || err == syscall.EPERM {
+		t.Skipf("extended attributes not supported: %s", err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestSetXattrs(t *testing.T) {
+	filename := makeXattrsTestFile(t)
+	err := SetXattrs(filename, map[string][]byte{
+		"user.managed": []byte("one"),
+		"user.empty":   {},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SetXattrs(filename, map[string][]byte{
+		"user.managed": []byte("two"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	xattrs, err := GetXattrs(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string][]byte{
+		"user.empty":     {},
+		"user.managed":   []byte("two"),
+		"user.unmanaged": []byte("keep"),
+	}
+	if !reflect.DeepEqual(xattrs, expected) {
+		t.Errorf("xattrs: %v, expected: %v", xattrs, expected)
+	}
+}
+
+func TestReplaceXattrs(t *testing.T) {
+	filename := makeXattrsTestFile(t)
+	expected := map[string][]byte{"user.managed": []byte("value")}
+	if err := ReplaceXattrs(filename, expected); err != nil {
+		t.Fatal(err)
+	}
+	xattrs, err := GetXattrs(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(xattrs, expected) {
+		t.Errorf("xattrs: %v, expected: %v", xattrs, expected)
+	}
+	if err := ReplaceXattrs(filename, nil); err != nil {
+		t.Fatal(err)
+	}
+	if xattrs, err := GetXattrs(filename); err != nil {
+		t.Fatal(err)
+	} else if xattrs != nil {
+		t.Errorf("xattrs not removed: %v", xattrs)
+	}
+}
@@ -1,6 +1,7 @@
 package image
 
 import (
+	"crypto/ed25519"
 	"time"
 
 	"github.com/Cloud-Foundations/Dominator/lib/filesystem"
@@ -12,13 +13,20 @@ import (
 	"github.com/Cloud-Foundations/Dominator/lib/triggers"
 )
 
+const (
+	SignaturePolicyIgnore        = SignaturePolicy(iota) // Do not check.
+	SignaturePolicyAllowUnsigned                         // Reject if invalid.
+	SignaturePolicyRequire                               // Reject if unsigned.
+)
+
 type Annotation struct {
 	Object *hash.Hash // These are mutually exclusive.
 	URL    string
 }
 
 type DirectoryMetadata struct {
-	OwnerGroup string
+	OwnerGroup      string
+	RetentionPolicy RetentionPolicy
 }
 
 type Directory struct {
@@ -41,6 +49,7 @@ type Image struct {
 	ExpiresAt     time.Time
 	Packages      []Package
 	SourceImage   string // Name of source image.
+	Signature     *Signature
 	Tags          tags.Tags
 }
 
@@ -50,6 +59,49 @@ type Package struct {
 	Version string
 }
 
+// RetentionPolicy specifies which images in a directory are kept. Only images
+// which do not expire are subject to the policy. An image is kept if it is one
+// of the KeepNewest newest images or if it is younger than KeepNewerThan. The
+// newest image is always kept. The zero value keeps all images.
+type RetentionPolicy struct {
+	KeepNewest    uint
+	KeepNewerThan time.Duration
+}
+
+// Signature is an ed25519 signature over the digest of the file-system, filter,
+// triggers and tags of an image, together with the signer name and time.
+type Signature struct {
+	Digest    []byte // SHA-512 of the canonical encoding.
+	PublicKey []byte
+	Signature []byte
+	SignedBy  string // Username or name of the build system.
+	SignedOn  time.Time
+}
+
+// SignatureChecker checks image signatures against a set of trusted keys,
+// according to a SignaturePolicy. A nil *SignatureChecker accepts all images.
+type SignatureChecker struct {
+	policy      SignaturePolicy
+	trustedKeys map[string]string // Key: public key, value: name.
+}
+
+type SignaturePolicy uint
+
+// LoadSigningKey loads an ed25519 private key from a PEM encoded PKCS #8 file,
+// as generated by "openssl genpkey -algorithm ed25519".
+func LoadSigningKey(filename string) (ed25519.PrivateKey, error) {
+	return loadSigningKey(filename)
+}
+
+// NewSignatureChecker returns a SignatureChecker for the policy. The
+// trustedKeysFile contains one line per trusted key, with the base64 encoded
+// public key followed by the name of the key owner. If the policy is
+// SignaturePolicyIgnore, nil is returned.
+func NewSignatureChecker(policy SignaturePolicy,
+	trustedKeysFile string) (*SignatureChecker, error) {
+	return newSignatureChecker(policy, trustedKeysFile)
+}
+
 // ForEachObject will call objectFunc for all objects (including those for
 // annotations) for the image. If objectFunc returns a non-nil error, processing
 // stops and the error is returned.
@@ -65,6 +117,12 @@ func (image *Image) GetMissingObjects(objectServer objectserver.ObjectServer,
 	return image.getMissingObjects(objectServer, objectsGetter, logger)
 }
 
+// ComputeDigest returns the SHA-512 digest of the canonical encoding of the
+// file-system, filter, triggers and tags of the image.
+func (image *Image) ComputeDigest() ([]byte, error) {
+	return image.computeDigest()
+}
+
 func (image *Image) ListMissingObjects(
 	objectsChecker objectserver.ObjectsChecker) ([]hash.Hash, error) {
 	return image.listMissingObjects(objectsChecker)
@@ -84,6 +142,11 @@ func (image *Image) ReplaceStrings(replaceFunc func(string) string) {
 	image.replaceStrings(replaceFunc)
 }
 
+// Sign signs the image with the private key, replacing any existing signature.
+func (image *Image) Sign(key ed25519.PrivateKey, signedBy string) error {
+	return image.sign(key, signedBy)
+}
+
 // Verify will perform some self-consistency checks on the image. If a problem
 // is found, an error is returned.
 func (image *Image) Verify() error {
@@ -94,6 +157,12 @@ func (image *Image) VerifyObjects(checker objectserver.ObjectsChecker) error {
 	return image.verifyObjects(checker)
 }
 
+// VerifySignature checks that the image matches its signature and that the
+// signature is valid. It does not check whether the key is trusted.
+func (image *Image) VerifySignature() error {
+	return image.verifySignature()
+}
+
 // VerifyRequiredPaths will verify if required paths are present in the image.
 // The table of required paths is given by requiredPaths. If the image is a
 // sparse image (has no filter), then this check is skipped. If a problem is
@@ -104,6 +173,47 @@ func (image *Image) VerifyRequiredPaths(requiredPaths map[string]rune) error {
 	return image.verifyRequiredPaths(requiredPaths)
 }
 
+// IsEmpty returns true if the policy keeps all images.
+func (policy RetentionPolicy) IsEmpty() bool {
+	return policy == RetentionPolicy{}
+}
+
+func (policy RetentionPolicy) String() string {
+	return policy.string()
+}
+
 func SortDirectories(directories []Directory) {
 	sortDirectories(directories)
 }
+
+// CheckImage checks the signature of the image according to the policy.
+func (sc *SignatureChecker) CheckImage(image *Image) error {
+	return sc.checkImage(image)
+}
+
+// CheckSignature checks the signature according to the policy. Since the image
+// is not available, the signature is not checked against the image contents.
+// A nil signature is treated as an unsigned image.
+func (sc *SignatureChecker) CheckSignature(signature *Signature) error {
+	return sc.checkSignature(signature)
+}
+
+// GetTrustedName returns the name of the owner of the key used to create the
+// signature if the key is trusted, else the empty string.
+func (sc *SignatureChecker) GetTrustedName(signature *Signature) string {
+	return sc.getTrustedName(signature)
+}
+
+func (policy *SignaturePolicy) Set(value string) error {
+	return policy.set(value)
+}
+
+func (policy SignaturePolicy) String() string {
+	return policy.string()
+}
+
+// Verify checks that the signature is valid for its digest. It does not check
+// whether the digest matches an image or whether the key is trusted.
+func (signature *Signature) Verify() error {
+	return signature.verify()
+}
@@ -0,0 +1,57 @@
+package bundle
+
+import (
+	"bufio"
+	"encoding/gob"
+	"io"
+
+	"github.com/Cloud-Foundations/Dominator/lib/hash"
+	proto "github.com/Cloud-Foundations/Dominator/proto/imageserver"
+)
+
+// A bundle is a GOB-encoded []byte containing the image archive data (as
+// returned by the GetImageArchive RPC) followed by multiple Object values, each
+// followed by the object data.
+type Object struct {
+	Hash   hash.Hash
+	Length uint64
+}
+
+type Reader struct {
+	Archive     proto.ImageArchive
+	ArchiveData []byte // GOB encoding of Archive followed by HMAC.
+	decoder     *gob.Decoder
+	reader      *bufio.Reader
+}
+
+type Writer struct {
+	encoder *gob.Encoder
+	writer  *bufio.Writer
+}
+
+// NewReader reads the image archive from the bundle.
+func NewReader(reader io.Reader) (*Reader, error) {
+	return newReader(reader)
+}
+
+// NextObject returns the next object in the bundle and a reader for its data,
+// which must be fully read before NextObject is called again. It returns io.EOF
+// after the last object.
+func (r *Reader) NextObject() (Object, io.Reader, error) {
+	return r.nextObject()
+}
+
+// NewWriter writes the image archive data to the bundle.
+func NewWriter(writer io.Writer, archiveData []byte) (*Writer, error) {
+	return newWriter(writer, archiveData)
+}
+
+func (w *Writer) Flush() error {
+	return w.writer.Flush()
+}
+
+// WriteObject writes an object to the bundle.
+func (w *Writer) WriteObject(hashVal hash.Hash, length uint64,
+	reader io.Reader) error {
+	return w.writeObject(hashVal, length, reader)
+}
@@ -0,0 +1,182 @@
+package bundle
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/Cloud-Foundations/Dominator/lib/filesystem"
+	"github.com/Cloud-Foundations/Dominator/lib/hash"
+	"github.com/Cloud-Foundations/Dominator/lib/image"
+	"github.com/Cloud-Foundations/Dominator/lib/objectserver/memory"
+	proto "github.com/Cloud-Foundations/Dominator/proto/imageserver"
+)
+
+var testObjects = [][]byte{
+	[]byte("file0 contents"),
+	[]byte("file1 contents, which are longer"),
+}
+
+func hashObject(data []byte) hash.Hash {
+	return hash.Hash(sha512.Sum512(data))
+}
+
+func makeArchiveData(t *testing.T) []byte {
+	fs := &filesystem.FileSystem{
+		InodeTable: filesystem.InodeTable{},
+	}
+	for index, data := range testObjects {
+		inum := uint64(index + 1)
+		fs.InodeTable[inum] = &filesystem.RegularInode{
+			Size: uint64(len(data)),
+			Hash: hashObject(data),
+		}
+		fs.EntryList = append(fs.EntryList, &filesystem.DirectoryEntry{
+			Name:        fmt.Sprintf("file%d", index),
+			InodeNumber: inum,
+		})
+	}
+	buffer := &bytes.Buffer{}
+	archive := proto.ImageArchive{
+		ImageName: "stream/image",
+		Image:     image.Image{FileSystem: fs},
+	}
+	if err := gob.NewEncoder(buffer).Encode(archive); err != nil {
+		t.Fatal(err)
+	}
+	buffer.Write(make([]byte, sha512.Size)) // Stand-in for the HMAC.
+	return buffer.Bytes()
+}
+
+// writeBundle writes a bundle with the test objects. If corruptIndex is
+// non-negative, the data for that object do not match its hash.
+func writeBundle(t *testing.T, archiveData []byte, corruptIndex int) []byte {
+	buffer := &bytes.Buffer{}
+	writer, err := NewWriter(buffer, archiveData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for index, data := range testObjects {
+		hashVal := hashObject(data)
+		if index == corruptIndex {
+			data = append([]byte{}, data...)
+			data[0] ^= 0xff
+		}
+		err := writer.WriteObject(hashVal, uint64(len(data)),
+			bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+// readObjects reads the objects in the bundle into objSrv, checking their
+// hashes as the subtool apply-bundle subcommand does.
+func readObjects(reader *Reader, objSrv *memory.ObjectServer) error {
+	for {
+		object, objectReader, err := reader.NextObject()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		_, _, err = objSrv.AddObject(objectReader, object.Length, &object.Hash)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	archiveData := makeArchiveData(t)
+	reader, err := NewReader(bytes.NewReader(writeBundle(t, archiveData, -1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(reader.ArchiveData, archiveData) {
+		t.Error("archive data changed")
+	}
+	if reader.Archive.ImageName != "stream/image" {
+		t.Errorf("image name: %s", reader.Archive.ImageName)
+	}
+	fs := reader.Archive.Image.FileSystem
+	if fs == nil {
+		t.Fatal("no file-system")
+	}
+	if err := fs.RebuildInodePointers(); err != nil {
+		t.Fatal(err)
+	}
+	if len(fs.EntryList) != len(testObjects) {
+		t.Fatalf("%d entries, expected %d",
+			len(fs.EntryList), len(testObjects))
+	}
+	objSrv := memory.NewObjectServer()
+	if err := readObjects(reader, objSrv); err != nil {
+		t.Fatal(err)
+	}
+	if numObjects := objSrv.NumObjects(); numObjects != 2 {
+		t.Fatalf("read %d objects, expected 2", numObjects)
+	}
+	for index, entry := range fs.EntryList {
+		inode := entry.Inode().(*filesystem.RegularInode)
+		_, objectReader, err := objSrv.GetObject(inode.Hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(objectReader)
+		objectReader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, testObjects[index]) {
+			t.Errorf("%s: data mismatch", entry.Name)
+		}
+	}
+}
+
+func TestCorruptedObject(t *testing.T) {
+	reader, err := NewReader(bytes.NewReader(
+		writeBundle(t, makeArchiveData(t), 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	objSrv := memory.NewObjectServer()
+	if err := readObjects(reader, objSrv); err == nil {
+		t.Error("corrupted object not detected")
+	}
+	if numObjects := objSrv.NumObjects(); numObjects != 1 {
+		t.Errorf("added %d objects, expected 1", numObjects)
+	}
+}
+
+func TestTruncatedBundle(t *testing.T) {
+	data := writeBundle(t, makeArchiveData(t), -1)
+	reader, err := NewReader(bytes.NewReader(data[:len(data)-4]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := readObjects(reader, memory.NewObjectServer()); err == nil {
+		t.Error("truncated object not detected")
+	}
+}
+
+func TestWriteObjectShort(t *testing.T) {
+	writer, err := NewWriter(io.Discard, makeArchiveData(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := testObjects[0]
+	err = writer.WriteObject(hashObject(data), uint64(len(data))+1,
+		bytes.NewReader(data))
+	if err == nil {
+		t.Error("short object not detected")
+	}
+}
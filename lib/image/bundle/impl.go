@@ -0,0 +1,56 @@
+package bundle
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"io"
+
+	"github.com/Cloud-Foundations/Dominator/lib/hash"
+)
+
+func newReader(reader io.Reader) (*Reader, error) {
+	r := &Reader{reader: bufio.NewReader(reader)}
+	r.decoder = gob.NewDecoder(r.reader)
+	if err := r.decoder.Decode(&r.ArchiveData); err != nil {
+		return nil, err
+	}
+	err := gob.NewDecoder(bytes.NewReader(r.ArchiveData)).Decode(&r.Archive)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Reader) nextObject() (Object, io.Reader, error) {
+	var object Object
+	if err := r.decoder.Decode(&object); err != nil {
+		return Object{}, nil, err
+	}
+	return object, io.LimitReader(r.reader, int64(object.Length)), nil
+}
+
+func newWriter(writer io.Writer, archiveData []byte) (*Writer, error) {
+	w := &Writer{writer: bufio.NewWriter(writer)}
+	w.encoder = gob.NewEncoder(w.writer)
+	if err := w.encoder.Encode(archiveData); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *Writer) writeObject(hashVal hash.Hash, length uint64,
+	reader io.Reader) error {
+	err := w.encoder.Encode(Object{Hash: hashVal, Length: length})
+	if err != nil {
+		return err
+	}
+	if nCopied, err := io.Copy(w.writer, reader); err != nil {
+		return err
+	} else if uint64(nCopied) != length {
+		return fmt.Errorf("object: %x length: %d, copied: %d",
+			hashVal, length, nCopied)
+	}
+	return nil
+}
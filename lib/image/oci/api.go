@@ -0,0 +1,35 @@
+// Package oci converts between Dominator images and OCI/Docker images.
+package oci
+
+import (
+	"github.com/Cloud-Foundations/Dominator/lib/filesystem"
+	"github.com/Cloud-Foundations/Dominator/lib/filesystem/untar"
+	"github.com/Cloud-Foundations/Dominator/lib/filter"
+	"github.com/Cloud-Foundations/Dominator/lib/image"
+	"github.com/Cloud-Foundations/Dominator/lib/objectserver"
+	"github.com/Cloud-Foundations/Dominator/lib/tags"
+)
+
+// Decode reads the image in an OCI image layout directory or in a tarfile
+// written by "docker save" and flattens the layers into a file-system. The
+// hasher is called for each regular file. The labels in the image
+// configuration are returned as tags.
+func Decode(pathname string, hasher untar.Hasher, filter *filter.Filter) (
+	*filesystem.FileSystem, tags.Tags, error) {
+	return decode(pathname, hasher, filter)
+}
+
+// IsLayoutDirectory returns true if pathname is an OCI image layout directory.
+// Tarfiles are not detected, since every header in the archive would need to
+// be read.
+func IsLayoutDirectory(pathname string) bool {
+	return isLayoutDirectory(pathname)
+}
+
+// WriteLayout writes the image as a single-layer OCI image for the running
+// architecture into the OCI image layout directory, which is created if needed.
+// Any image in the layout with the same reference name is replaced.
+func WriteLayout(dirname string, img *image.Image, refName string,
+	objectsGetter objectserver.ObjectsGetter) error {
+	return writeLayout(dirname, img, refName, objectsGetter)
+}
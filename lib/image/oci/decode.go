@@ -0,0 +1,349 @@
+package oci
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/Cloud-Foundations/Dominator/lib/filesystem"
+	"github.com/Cloud-Foundations/Dominator/lib/filesystem/untar"
+	"github.com/Cloud-Foundations/Dominator/lib/filter"
+	"github.com/Cloud-Foundations/Dominator/lib/tags"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// blobSource provides access to the files in an image layout directory or in
+// a tarfile.
+type blobSource interface {
+	Close() error
+	exists(name string) bool
+	open(name string) (io.ReadCloser, error)
+}
+
+type directorySource string
+
+type tarEntry struct {
+	offset int64
+	size   int64
+}
+
+type tarSource struct {
+	file    *os.File
+	entries map[string]tarEntry
+}
+
+// verifyingReader computes the digest of the data read, which may be checked
+// with verify after all the data have been read.
+type verifyingReader struct {
+	io.ReadCloser
+	digest string
+	hasher hash.Hash
+}
+
+func decode(pathname string, hasher untar.Hasher, filter *filter.Filter) (
+	*filesystem.FileSystem, tags.Tags, error) {
+	source, err := openSource(pathname)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer source.Close()
+	var configName string
+	var layerNames []string
+	if source.exists(manifestFile) {
+		configName, layerNames, err = readDockerManifest(source)
+	} else {
+		configName, layerNames, err = readIndex(source)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	var config imageConfig
+	if err := readJson(source, configName, &config); err != nil {
+		return nil, nil, err
+	}
+	decoder := untar.NewLayerDecoder(hasher, filter)
+	for _, name := range layerNames {
+		if err := decodeLayer(source, name, decoder); err != nil {
+			return nil, nil, fmt.Errorf("error decoding layer: %s: %s",
+				name, err)
+		}
+	}
+	var labels tags.Tags
+	if len(config.Config.Labels) > 0 {
+		labels = tags.Tags(config.Config.Labels)
+	}
+	return decoder.FileSystem(), labels, nil
+}
+
+func decodeLayer(source blobSource, name string,
+	decoder *untar.LayerDecoder) error {
+	reader, err := source.open(name)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	bufferedReader := bufio.NewReader(reader)
+	magic, _ := bufferedReader.Peek(len(zstdMagic))
+	var layerReader io.Reader = bufferedReader
+	if bytes.HasPrefix(magic, gzipMagic) {
+		gzipReader, err := gzip.NewReader(bufferedReader)
+		if err != nil {
+			return err
+		}
+		defer gzipReader.Close()
+		layerReader = gzipReader
+	} else if bytes.HasPrefix(magic, zstdMagic) {
+		return errors.New("zstd compressed layers are not supported")
+	}
+	if err := decoder.DecodeLayer(tar.NewReader(layerReader)); err != nil {
+		return err
+	}
+	// Read any padding so that the digest may be checked.
+	if _, err := io.Copy(io.Discard, bufferedReader); err != nil {
+		return err
+	}
+	if reader, ok := reader.(*verifyingReader); ok {
+		return reader.verify()
+	}
+	return nil
+}
+
+// blobName returns the name of the blob for the digest in an image layout.
+func blobName(digest string) (string, error) {
+	algorithm, encoded, ok := strings.Cut(digest, ":")
+	if !ok || algorithm == "" || encoded == "" ||
+		strings.ContainsAny(encoded, "/.") {
+		return "", fmt.Errorf("bad digest: \"%s\"", digest)
+	}
+	return path.Join("blobs", algorithm, encoded), nil
+}
+
+func isLayoutDirectory(pathname string) bool {
+	fi, err := os.Stat(pathname)
+	if err != nil || !fi.IsDir() {
+		return false
+	}
+	return directorySource(pathname).exists(layoutFile)
+}
+
+func openSource(pathname string) (blobSource, error) {
+	fi, err := os.Stat(pathname)
+	if err != nil {
+		return nil, err
+	}
+	if fi.IsDir() {
+		return directorySource(pathname), nil
+	}
+	return openTarSource(pathname)
+}
+
+func openTarSource(filename string) (*tarSource, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	source := &tarSource{file: file, entries: make(map[string]tarEntry)}
+	tarReader := tar.NewReader(file)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		// The tar reader does not read ahead, so the file offset is the start
+		// of the data.
+		offset, err := file.Seek(0, io.SeekCurrent)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		source.entries[path.Clean(header.Name)] = tarEntry{
+			offset: offset,
+			size:   header.Size,
+		}
+	}
+	return source, nil
+}
+
+func readDockerManifest(source blobSource) (string, []string, error) {
+	var manifests []dockerManifest
+	if err := readJson(source, manifestFile, &manifests); err != nil {
+		return "", nil, err
+	}
+	if len(manifests) != 1 {
+		return "", nil, fmt.Errorf("%d images in %s, only one supported",
+			len(manifests), manifestFile)
+	}
+	return manifests[0].Config, manifests[0].Layers, nil
+}
+
+// readIndex reads the index of an image layout and returns the names of the
+// configuration and the layers of the image for the running architecture.
+func readIndex(source blobSource) (string, []string, error) {
+	if !source.exists(layoutFile) {
+		return "", nil, errors.New("not an OCI image layout")
+	}
+	var index imageIndex
+	if err := readJson(source, indexFile, &index); err != nil {
+		return "", nil, err
+	}
+	for {
+		desc, err := selectManifest(index.Manifests)
+		if err != nil {
+			return "", nil, err
+		}
+		name, err := blobName(desc.Digest)
+		if err != nil {
+			return "", nil, err
+		}
+		switch desc.MediaType {
+		case mediaTypeIndex, mediaTypeDockerList:
+			index = imageIndex{}
+			if err := readJson(source, name, &index); err != nil {
+				return "", nil, err
+			}
+			continue
+		}
+		var manifest imageManifest
+		if err := readJson(source, name, &manifest); err != nil {
+			return "", nil, err
+		}
+		configName, err := blobName(manifest.Config.Digest)
+		if err != nil {
+			return "", nil, err
+		}
+		layerNames := make([]string, 0, len(manifest.Layers))
+		for _, layer := range manifest.Layers {
+			name, err := blobName(layer.Digest)
+			if err != nil {
+				return "", nil, err
+			}
+			layerNames = append(layerNames, name)
+		}
+		return configName, layerNames, nil
+	}
+}
+
+func readJson(source blobSource, name string, value interface{}) error {
+	reader, err := source.open(name)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	if reader, ok := reader.(*verifyingReader); ok {
+		if err := reader.verify(); err != nil {
+			return err
+		}
+	}
+	if err := json.Unmarshal(data, value); err != nil {
+		return fmt.Errorf("error decoding: %s: %s", name, err)
+	}
+	return nil
+}
+
+// selectManifest selects the only manifest, or else the manifest for the
+// running architecture.
+func selectManifest(manifests []descriptor) (descriptor, error) {
+	if len(manifests) == 1 {
+		return manifests[0], nil
+	}
+	for _, desc := range manifests {
+		if desc.Platform != nil && desc.Platform.OS == "linux" &&
+			desc.Platform.Architecture == runtime.GOARCH {
+			return desc, nil
+		}
+	}
+	if len(manifests) < 1 {
+		return descriptor{}, errors.New("no image manifests")
+	}
+	return descriptor{}, fmt.Errorf("no image manifest for linux/%s",
+		runtime.GOARCH)
+}
+
+// wrapReader returns a reader which verifies the data if the name is a blob
+// with a SHA-256 digest.
+func wrapReader(name string, reader io.ReadCloser) io.ReadCloser {
+	if path.Dir(name) != "blobs/sha256" {
+		return reader
+	}
+	return &verifyingReader{
+		ReadCloser: reader,
+		digest:     path.Base(name),
+		hasher:     sha256.New(),
+	}
+}
+
+func (source directorySource) Close() error {
+	return nil
+}
+
+func (source directorySource) exists(name string) bool {
+	_, err := os.Stat(filepath.Join(string(source), name))
+	return err == nil
+}
+
+func (source directorySource) open(name string) (io.ReadCloser, error) {
+	file, err := os.Open(filepath.Join(string(source), name))
+	if err != nil {
+		return nil, err
+	}
+	return wrapReader(name, file), nil
+}
+
+func (source *tarSource) Close() error {
+	return source.file.Close()
+}
+
+func (source *tarSource) exists(name string) bool {
+	_, ok := source.entries[name]
+	return ok
+}
+
+func (source *tarSource) open(name string) (io.ReadCloser, error) {
+	entry, ok := source.entries[path.Clean(name)]
+	if !ok {
+		return nil, fmt.Errorf("%s: not found in tarfile", name)
+	}
+	reader := io.NewSectionReader(source.file, entry.offset, entry.size)
+	return wrapReader(path.Clean(name), io.NopCloser(reader)), nil
+}
+
+func (r *verifyingReader) Read(p []byte) (int, error) {
+	nRead, err := r.ReadCloser.Read(p)
+	r.hasher.Write(p[:nRead])
+	return nRead, err
+}
+
+func (r *verifyingReader) verify() error {
+	if digest := hex.EncodeToString(r.hasher.Sum(nil)); digest != r.digest {
+		return fmt.Errorf("digest mismatch: %s != %s", digest, r.digest)
+	}
+	return nil
+}
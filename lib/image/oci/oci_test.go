@@ -0,0 +1,163 @@
+package oci
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/filesystem"
+	"github.com/Cloud-Foundations/Dominator/lib/filesystem/untar"
+	"github.com/Cloud-Foundations/Dominator/lib/hash"
+	"github.com/Cloud-Foundations/Dominator/lib/image"
+	"github.com/Cloud-Foundations/Dominator/lib/objectserver/memory"
+	"github.com/Cloud-Foundations/Dominator/lib/tags"
+)
+
+type testHasher struct {
+	objSrv *memory.ObjectServer
+}
+
+func (h testHasher) Hash(reader io.Reader, length uint64) (hash.Hash, error) {
+	hashVal, _, err := h.objSrv.AddObject(reader, length, nil)
+	return hashVal, err
+}
+
+func makeImage(t *testing.T, hasher testHasher) *image.Image {
+	buffer := &bytes.Buffer{}
+	writer := tar.NewWriter(buffer)
+	files := []struct {
+		name string
+		data string
+	}{{"etc/", ""}, {"etc/hostname", "test"}, {"bin/", ""}, {"bin/app", "app"}}
+	for _, file := range files {
+		header := &tar.Header{Name: file.name, Mode: 0755}
+		if file.data == "" {
+			header.Typeflag = tar.TypeDir
+		} else {
+			header.Typeflag = tar.TypeReg
+			header.Size = int64(len(file.data))
+		}
+		if err := writer.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(writer, file.data)
+	}
+	writer.Close()
+	fs, err := untar.Decode(tar.NewReader(buffer), hasher, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &image.Image{
+		CreatedOn:  time.Now(),
+		FileSystem: fs,
+		Tags:       tags.Tags{"version": "1.0"},
+	}
+}
+
+func checkImage(t *testing.T, pathname string, hasher testHasher) {
+	fs, labels, err := Decode(pathname, hasher, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if labels["version"] != "1.0" {
+		t.Errorf("%s: bad labels: %v", pathname, labels)
+	}
+	names := make(map[string]struct{})
+	fs.ForEachFile(func(name string, inodeNumber uint64,
+		inode filesystem.GenericInode) error {
+		names[name] = struct{}{}
+		return nil
+	})
+	for _, name := range []string{"/etc/hostname", "/bin/app"} {
+		if _, ok := names[name]; !ok {
+			t.Errorf("%s: missing: %s", pathname, name)
+		}
+	}
+}
+
+// writeDockerSave writes a tarfile like "docker save" from an image layout.
+func writeDockerSave(t *testing.T, layoutDirname, filename string) {
+	var index imageIndex
+	source := directorySource(layoutDirname)
+	if err := readJson(source, indexFile, &index); err != nil {
+		t.Fatal(err)
+	}
+	manifestName, _ := blobName(index.Manifests[0].Digest)
+	var manifest imageManifest
+	if err := readJson(source, manifestName, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	dockerManifests := []dockerManifest{{RepoTags: []string{"test:latest"}}}
+	dockerManifests[0].Config, _ = blobName(manifest.Config.Digest)
+	files := []string{dockerManifests[0].Config}
+	for _, layer := range manifest.Layers {
+		name, _ := blobName(layer.Digest)
+		dockerManifests[0].Layers = append(dockerManifests[0].Layers, name)
+		files = append(files, name)
+	}
+	file, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	writer := tar.NewWriter(file)
+	for _, name := range files {
+		data, err := os.ReadFile(filepath.Join(layoutDirname, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		writer.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(data)),
+			Typeflag: tar.TypeReg,
+		})
+		writer.Write(data)
+	}
+	data, _ := json.Marshal(dockerManifests)
+	writer.WriteHeader(&tar.Header{
+		Name:     manifestFile,
+		Mode:     0644,
+		Size:     int64(len(data)),
+		Typeflag: tar.TypeReg,
+	})
+	writer.Write(data)
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWriteAndDecode(t *testing.T) {
+	hasher := testHasher{memory.NewObjectServer()}
+	img := makeImage(t, hasher)
+	dirname := t.TempDir()
+	layoutDirname := filepath.Join(dirname, "layout")
+	for count := 0; count < 2; count++ {
+		err := WriteLayout(layoutDirname, img, "latest", hasher.objSrv)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	index, err := readLayoutIndex(layoutDirname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(index.Manifests) != 1 {
+		t.Errorf("%d manifests after replacing image", len(index.Manifests))
+	}
+	if !IsLayoutDirectory(layoutDirname) {
+		t.Fatalf("%s: not detected as a layout directory", layoutDirname)
+	}
+	checkImage(t, layoutDirname, hasher)
+	tarFilename := filepath.Join(dirname, "image.tar")
+	writeDockerSave(t, layoutDirname, tarFilename)
+	if IsLayoutDirectory(tarFilename) || IsLayoutDirectory(dirname) {
+		t.Error("tarfile or plain directory detected as a layout directory")
+	}
+	checkImage(t, tarFilename, hasher)
+}
@@ -0,0 +1,75 @@
+package oci
+
+import (
+	"time"
+)
+
+const (
+	layoutFile    = "oci-layout"
+	layoutVersion = "1.0.0"
+	indexFile     = "index.json"
+	manifestFile  = "manifest.json" // Written by "docker save".
+
+	annotationRefName = "org.opencontainers.image.ref.name"
+
+	mediaTypeConfig     = "application/vnd.oci.image.config.v1+json"
+	mediaTypeDockerList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	mediaTypeIndex      = "application/vnd.oci.image.index.v1+json"
+	mediaTypeLayerGzip  = "application/vnd.oci.image.layer.v1.tar+gzip"
+	mediaTypeManifest   = "application/vnd.oci.image.manifest.v1+json"
+)
+
+type descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Platform    *platform         `json:"platform,omitempty"`
+}
+
+// dockerManifest is an entry in the manifest.json file written by
+// "docker save".
+type dockerManifest struct {
+	Config   string
+	RepoTags []string
+	Layers   []string
+}
+
+type imageConfig struct {
+	Architecture string          `json:"architecture"`
+	Config       containerConfig `json:"config"`
+	Created      *time.Time      `json:"created,omitempty"`
+	OS           string          `json:"os"`
+	RootFS       rootFS          `json:"rootfs"`
+}
+
+type containerConfig struct {
+	Labels map[string]string `json:"Labels,omitempty"`
+}
+
+type imageIndex struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType,omitempty"`
+	Manifests     []descriptor `json:"manifests"`
+}
+
+type imageLayout struct {
+	ImageLayoutVersion string `json:"imageLayoutVersion"`
+}
+
+type imageManifest struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType,omitempty"`
+	Config        descriptor   `json:"config"`
+	Layers        []descriptor `json:"layers"`
+}
+
+type platform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+}
+
+type rootFS struct {
+	Type    string   `json:"type"`
+	DiffIDs []string `json:"diff_ids"`
+}
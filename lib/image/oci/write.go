@@ -0,0 +1,182 @@
+package oci
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/Cloud-Foundations/Dominator/lib/filesystem/tar"
+	"github.com/Cloud-Foundations/Dominator/lib/fsutil"
+	"github.com/Cloud-Foundations/Dominator/lib/image"
+	"github.com/Cloud-Foundations/Dominator/lib/objectserver"
+)
+
+type countingWriter struct {
+	count  int64
+	writer io.Writer
+}
+
+func writeLayout(dirname string, img *image.Image, refName string,
+	objectsGetter objectserver.ObjectsGetter) error {
+	blobDirname := filepath.Join(dirname, "blobs", "sha256")
+	if err := os.MkdirAll(blobDirname, fsutil.DirPerms); err != nil {
+		return err
+	}
+	layer, diffId, err := writeLayer(blobDirname, img, objectsGetter)
+	if err != nil {
+		return err
+	}
+	config := imageConfig{
+		Architecture: runtime.GOARCH,
+		Config:       containerConfig{Labels: img.Tags},
+		OS:           "linux",
+		RootFS:       rootFS{Type: "layers", DiffIDs: []string{diffId}},
+	}
+	if !img.CreatedOn.IsZero() {
+		config.Created = &img.CreatedOn
+	}
+	configDesc, err := writeJsonBlob(blobDirname, mediaTypeConfig, config)
+	if err != nil {
+		return err
+	}
+	manifest := imageManifest{
+		SchemaVersion: 2,
+		MediaType:     mediaTypeManifest,
+		Config:        configDesc,
+		Layers:        []descriptor{layer},
+	}
+	manifestDesc, err := writeJsonBlob(blobDirname, mediaTypeManifest,
+		manifest)
+	if err != nil {
+		return err
+	}
+	if refName != "" {
+		manifestDesc.Annotations = map[string]string{
+			annotationRefName: refName,
+		}
+	}
+	index, err := readLayoutIndex(dirname)
+	if err != nil {
+		return err
+	}
+	var manifests []descriptor
+	for _, desc := range index.Manifests {
+		if refName == "" || desc.Annotations[annotationRefName] != refName {
+			manifests = append(manifests, desc)
+		}
+	}
+	index.Manifests = append(manifests, manifestDesc)
+	err = writeJsonFile(filepath.Join(dirname, layoutFile),
+		imageLayout{ImageLayoutVersion: layoutVersion})
+	if err != nil {
+		return err
+	}
+	return writeJsonFile(filepath.Join(dirname, indexFile), index)
+}
+
+// readLayoutIndex reads the index of an existing image layout, or returns an
+// empty index if there is no layout.
+func readLayoutIndex(dirname string) (imageIndex, error) {
+	index := imageIndex{SchemaVersion: 2, MediaType: mediaTypeIndex}
+	err := readJson(directorySource(dirname), indexFile, &index)
+	if err != nil && !os.IsNotExist(err) {
+		return imageIndex{}, err
+	}
+	return index, nil
+}
+
+// writeBlob writes a blob, calling writeFunc to write the data. The descriptor
+// of the blob is returned.
+func writeBlob(blobDirname, mediaType string,
+	writeFunc func(writer io.Writer) error) (descriptor, error) {
+	file, err := os.CreateTemp(blobDirname, ".tmp")
+	if err != nil {
+		return descriptor{}, err
+	}
+	defer os.Remove(file.Name())
+	bufferedWriter := bufio.NewWriter(file)
+	hasher := sha256.New()
+	writer := &countingWriter{writer: io.MultiWriter(bufferedWriter, hasher)}
+	if err := writeFunc(writer); err != nil {
+		file.Close()
+		return descriptor{}, err
+	}
+	if err := bufferedWriter.Flush(); err != nil {
+		file.Close()
+		return descriptor{}, err
+	}
+	if err := file.Chmod(fsutil.PublicFilePerms); err != nil {
+		file.Close()
+		return descriptor{}, err
+	}
+	if err := file.Close(); err != nil {
+		return descriptor{}, err
+	}
+	digest := hex.EncodeToString(hasher.Sum(nil))
+	err = os.Rename(file.Name(), filepath.Join(blobDirname, digest))
+	if err != nil {
+		return descriptor{}, err
+	}
+	return descriptor{
+		MediaType: mediaType,
+		Digest:    "sha256:" + digest,
+		Size:      writer.count,
+	}, nil
+}
+
+func writeJsonBlob(blobDirname, mediaType string,
+	value interface{}) (descriptor, error) {
+	return writeBlob(blobDirname, mediaType, func(writer io.Writer) error {
+		return json.NewEncoder(writer).Encode(value)
+	})
+}
+
+func writeJsonFile(filename string, value interface{}) error {
+	writer, err := fsutil.CreateRenamingWriter(filename,
+		fsutil.PublicFilePerms)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "    ")
+	if err := encoder.Encode(value); err != nil {
+		writer.Abort()
+		writer.Close()
+		return err
+	}
+	return writer.Close()
+}
+
+// writeLayer writes the file-system as a compressed layer and returns the
+// descriptor and the digest of the uncompressed layer.
+func writeLayer(blobDirname string, img *image.Image,
+	objectsGetter objectserver.ObjectsGetter) (descriptor, string, error) {
+	diffIdHasher := sha256.New()
+	desc, err := writeBlob(blobDirname, mediaTypeLayerGzip,
+		func(writer io.Writer) error {
+			gzipWriter := gzip.NewWriter(writer)
+			err := tar.Write(io.MultiWriter(gzipWriter, diffIdHasher),
+				img.FileSystem, objectsGetter)
+			if err != nil {
+				gzipWriter.Close()
+				return err
+			}
+			return gzipWriter.Close()
+		})
+	if err != nil {
+		return descriptor{}, "", err
+	}
+	return desc, "sha256:" + hex.EncodeToString(diffIdHasher.Sum(nil)), nil
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	nWritten, err := w.writer.Write(p)
+	w.count += int64(nWritten)
+	return nWritten, err
+}
@@ -0,0 +1,23 @@
+package image
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Cloud-Foundations/Dominator/lib/format"
+)
+
+func (policy RetentionPolicy) string() string {
+	if policy.IsEmpty() {
+		return "keep all"
+	}
+	var rules []string
+	if policy.KeepNewest > 0 {
+		rules = append(rules, fmt.Sprintf("keep newest %d", policy.KeepNewest))
+	}
+	if policy.KeepNewerThan > 0 {
+		rules = append(rules,
+			"keep newer than "+format.Duration(policy.KeepNewerThan))
+	}
+	return strings.Join(rules, ", ")
+}
@@ -0,0 +1,321 @@
+package image
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/filesystem"
+	"github.com/Cloud-Foundations/Dominator/lib/fsutil"
+)
+
+const digestVersion = "Dominator image digest v1\n"
+
+var (
+	errorInvalidSignature = errors.New("invalid image signature")
+	errorNotSigned        = errors.New("image is not signed")
+
+	signaturePolicyToString = map[SignaturePolicy]string{
+		SignaturePolicyIgnore:        "ignore",
+		SignaturePolicyAllowUnsigned: "allow-unsigned",
+		SignaturePolicyRequire:       "require",
+	}
+)
+
+func loadSigningKey(filename string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("%s: no PEM private key found", filename)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	if privateKey, ok := key.(ed25519.PrivateKey); ok {
+		return privateKey, nil
+	}
+	return nil, fmt.Errorf("%s: not an ed25519 key", filename)
+}
+
+func newSignatureChecker(policy SignaturePolicy,
+	trustedKeysFile string) (*SignatureChecker, error) {
+	if policy == SignaturePolicyIgnore {
+		return nil, nil
+	}
+	if trustedKeysFile == "" {
+		return nil, errors.New("no trusted signing keys file specified")
+	}
+	lines, err := fsutil.LoadLines(trustedKeysFile)
+	if err != nil {
+		return nil, err
+	}
+	sc := &SignatureChecker{
+		policy:      policy,
+		trustedKeys: make(map[string]string, len(lines)),
+	}
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("%s: bad line: \"%s\"", trustedKeysFile, line)
+		}
+		key, err := base64.StdEncoding.DecodeString(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", trustedKeysFile, err)
+		}
+		if len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%s: bad key length: %d for: %s",
+				trustedKeysFile, len(key), fields[1])
+		}
+		sc.trustedKeys[string(key)] = strings.Join(fields[1:], " ")
+	}
+	return sc, nil
+}
+
+// signedMessage returns the message which is signed: the digest followed by
+// the signer name and time, so that these cannot be altered.
+func (signature *Signature) signedMessage() []byte {
+	buffer := &bytes.Buffer{}
+	buffer.Write(signature.Digest)
+	fmt.Fprintf(buffer, "\n%s\n%s\n", signature.SignedBy,
+		signature.SignedOn.UTC().Format(time.RFC3339Nano))
+	return buffer.Bytes()
+}
+
+func (signature *Signature) verify() error {
+	if len(signature.PublicKey) != ed25519.PublicKeySize {
+		return errorInvalidSignature
+	}
+	if !ed25519.Verify(signature.PublicKey, signature.signedMessage(),
+		signature.Signature) {
+		return errorInvalidSignature
+	}
+	return nil
+}
+
+func (image *Image) computeDigest() ([]byte, error) {
+	hasher := sha512.New()
+	if err := image.writeCanonical(hasher); err != nil {
+		return nil, err
+	}
+	return hasher.Sum(nil), nil
+}
+
+func (image *Image) sign(key ed25519.PrivateKey, signedBy string) error {
+	digest, err := image.computeDigest()
+	if err != nil {
+		return err
+	}
+	signature := &Signature{
+		Digest:    digest,
+		PublicKey: key.Public().(ed25519.PublicKey),
+		SignedBy:  signedBy,
+		SignedOn:  time.Now(),
+	}
+	signature.Signature = ed25519.Sign(key, signature.signedMessage())
+	image.Signature = signature
+	return nil
+}
+
+func (image *Image) verifySignature() error {
+	if image.Signature == nil {
+		return errorNotSigned
+	}
+	if err := image.Signature.verify(); err != nil {
+		return err
+	}
+	digest, err := image.computeDigest()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(digest, image.Signature.Digest) {
+		return errors.New("image does not match signature")
+	}
+	return nil
+}
+
+// writeCanonical writes an encoding of the file-system, filter, triggers and
+// tags which does not depend on the order of maps or on cached data. Inode
+// numbers are not encoded, only which pathnames are hardlinked.
+func (image *Image) writeCanonical(writer io.Writer) error {
+	if image.FileSystem == nil {
+		return errors.New("no file-system")
+	}
+	io.WriteString(writer, digestVersion)
+	fs := image.FileSystem
+	writeDirectoryInode(writer, "/", &fs.DirectoryInode)
+	err := writeDirectoryEntries(writer, fs, "/", &fs.DirectoryInode,
+		make(map[uint64]string))
+	if err != nil {
+		return err
+	}
+	if image.Filter == nil {
+		io.WriteString(writer, "filter sparse\n")
+	} else {
+		fmt.Fprintf(writer, "filter %d\n", len(image.Filter.FilterLines))
+		for _, line := range image.Filter.FilterLines {
+			fmt.Fprintf(writer, "%q\n", line)
+		}
+	}
+	if image.Triggers == nil {
+		io.WriteString(writer, "triggers none\n")
+	} else {
+		data, err := json.Marshal(image.Triggers)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(writer, "triggers %d\n", len(data))
+		writer.Write(data)
+	}
+	keys := make([]string, 0, len(image.Tags))
+	for key := range image.Tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	fmt.Fprintf(writer, "tags %d\n", len(keys))
+	for _, key := range keys {
+		fmt.Fprintf(writer, "%q %q\n", key, image.Tags[key])
+	}
+	return nil
+}
+
+func writeDirectoryEntries(writer io.Writer, fs *filesystem.FileSystem,
+	dirname string, directory *filesystem.DirectoryInode,
+	linkedInodes map[uint64]string) error {
+	entries := make([]*filesystem.DirectoryEntry, len(directory.EntryList))
+	copy(entries, directory.EntryList)
+	sort.Slice(entries, func(left, right int) bool {
+		return entries[left].Name < entries[right].Name
+	})
+	for _, dirent := range entries {
+		name := path.Join(dirname, dirent.Name)
+		if linkedName, ok := linkedInodes[dirent.InodeNumber]; ok {
+			fmt.Fprintf(writer, "link %q %q\n", name, linkedName)
+			continue
+		}
+		linkedInodes[dirent.InodeNumber] = name
+		switch inode := fs.InodeTable[dirent.InodeNumber].(type) {
+		case *filesystem.DirectoryInode:
+			writeDirectoryInode(writer, name, inode)
+			err := writeDirectoryEntries(writer, fs, name, inode, linkedInodes)
+			if err != nil {
+				return err
+			}
+		case *filesystem.RegularInode:
+			fmt.Fprintf(writer, "regular %q %o %d %d %d.%09d %d %x\n",
+				name, inode.Mode, inode.Uid, inode.Gid, inode.MtimeSeconds,
+				inode.MtimeNanoSeconds, inode.Size, inode.Hash)
+			writeXattrs(writer, inode.Xattrs)
+		case *filesystem.ComputedRegularInode:
+			fmt.Fprintf(writer, "computed %q %o %d %d %q\n",
+				name, inode.Mode, inode.Uid, inode.Gid, inode.Source)
+		case *filesystem.SymlinkInode:
+			fmt.Fprintf(writer, "symlink %q %d %d %q\n",
+				name, inode.Uid, inode.Gid, inode.Symlink)
+			writeXattrs(writer, inode.Xattrs)
+		case *filesystem.SpecialInode:
+			fmt.Fprintf(writer, "special %q %o %d %d %d.%09d %d\n",
+				name, inode.Mode, inode.Uid, inode.Gid, inode.MtimeSeconds,
+				inode.MtimeNanoSeconds, inode.Rdev)
+			writeXattrs(writer, inode.Xattrs)
+		default:
+			return fmt.Errorf("%s: unsupported inode type: %T", name, inode)
+		}
+	}
+	return nil
+}
+
+func writeDirectoryInode(writer io.Writer, name string,
+	inode *filesystem.DirectoryInode) {
+	fmt.Fprintf(writer, "directory %q %o %d %d\n",
+		name, inode.Mode, inode.Uid, inode.Gid)
+	writeXattrs(writer, inode.Xattrs)
+}
+
+func writeXattrs(writer io.Writer, xattrs map[string][]byte) {
+	names := make([]string, 0, len(xattrs))
+	for name := range xattrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(writer, "xattr %q %x\n", name, xattrs[name])
+	}
+}
+
+func (sc *SignatureChecker) checkImage(image *Image) error {
+	if sc == nil || sc.policy == SignaturePolicyIgnore {
+		return nil
+	}
+	if image.Signature == nil {
+		return sc.checkSignature(nil)
+	}
+	if err := image.verifySignature(); err != nil {
+		return err
+	}
+	return sc.checkTrusted(image.Signature)
+}
+
+func (sc *SignatureChecker) checkSignature(signature *Signature) error {
+	if sc == nil || sc.policy == SignaturePolicyIgnore {
+		return nil
+	}
+	if signature == nil {
+		if sc.policy == SignaturePolicyRequire {
+			return errorNotSigned
+		}
+		return nil
+	}
+	if err := signature.verify(); err != nil {
+		return err
+	}
+	return sc.checkTrusted(signature)
+}
+
+func (sc *SignatureChecker) checkTrusted(signature *Signature) error {
+	if _, ok := sc.trustedKeys[string(signature.PublicKey)]; !ok {
+		return fmt.Errorf("image signed by: %s with untrusted key: %s",
+			signature.SignedBy,
+			base64.StdEncoding.EncodeToString(signature.PublicKey))
+	}
+	return nil
+}
+
+func (sc *SignatureChecker) getTrustedName(signature *Signature) string {
+	if sc == nil || signature == nil {
+		return ""
+	}
+	return sc.trustedKeys[string(signature.PublicKey)]
+}
+
+func (policy *SignaturePolicy) set(value string) error {
+	for key, name := range signaturePolicyToString {
+		if value == name {
+			*policy = key
+			return nil
+		}
+	}
+	return errors.New("unknown signature policy: " + value)
+}
+
+func (policy SignaturePolicy) string() string {
+	if name, ok := signaturePolicyToString[policy]; ok {
+		return name
+	}
+	return fmt.Sprintf("SignaturePolicy(%d)", policy)
+}
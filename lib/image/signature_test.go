@@ -0,0 +1,83 @@
+package image
+
+import (
+	"crypto/ed25519"
+	"testing"
+
+	"github.com/Cloud-Foundations/Dominator/lib/filesystem"
+	"github.com/Cloud-Foundations/Dominator/lib/tags"
+)
+
+func makeTestImage() *Image {
+	fs := &filesystem.FileSystem{InodeTable: filesystem.InodeTable{
+		1: &filesystem.RegularInode{Mode: 0100644, Size: 3},
+		2: &filesystem.SymlinkInode{Symlink: "target"},
+	}}
+	fs.EntryList = []*filesystem.DirectoryEntry{
+		{Name: "file", InodeNumber: 1},
+		{Name: "link", InodeNumber: 2},
+		{Name: "hardlink", InodeNumber: 1},
+	}
+	return &Image{FileSystem: fs, Tags: tags.Tags{"Key": "Value"}}
+}
+
+func TestSignAndVerify(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := makeTestImage()
+	if err := img.Sign(key, "tester"); err != nil {
+		t.Fatal(err)
+	}
+	if err := img.VerifySignature(); err != nil {
+		t.Fatalf("signature not valid: %s", err)
+	}
+	// The order of directory entries does not matter.
+	entries := img.FileSystem.EntryList
+	entries[0], entries[2] = entries[2], entries[0]
+	if err := img.VerifySignature(); err != nil {
+		t.Fatalf("signature not valid after reordering: %s", err)
+	}
+	img.Tags["Key"] = "Changed"
+	if err := img.VerifySignature(); err == nil {
+		t.Fatal("signature valid after changing tags")
+	}
+	img.Tags["Key"] = "Value"
+	img.Signature.SignedBy = "impostor"
+	if err := img.VerifySignature(); err == nil {
+		t.Fatal("signature valid after changing signer")
+	}
+}
+
+func TestSignatureChecker(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := makeTestImage()
+	checker := &SignatureChecker{
+		policy:      SignaturePolicyRequire,
+		trustedKeys: make(map[string]string),
+	}
+	if err := checker.CheckImage(img); err == nil {
+		t.Fatal("unsigned image accepted")
+	}
+	if err := img.Sign(key, "tester"); err != nil {
+		t.Fatal(err)
+	}
+	if err := checker.CheckImage(img); err == nil {
+		t.Fatal("image with untrusted key accepted")
+	}
+	checker.trustedKeys[string(key.Public().(ed25519.PublicKey))] = "testers"
+	if err := checker.CheckImage(img); err != nil {
+		t.Fatal(err)
+	}
+	if name := checker.GetTrustedName(img.Signature); name != "testers" {
+		t.Fatalf("wrong trusted name: %s", name)
+	}
+	var nilChecker *SignatureChecker
+	if err := nilChecker.CheckSignature(nil); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,55 @@
+/*
+Package maintenancewindow determines when disruptive updates are permitted
+for a machine, from the maintenance window in its MDB tags or a default.
+
+The window is specified with the DisruptionManagerMaintenanceWindow tag, using
+the syntax of the timewindow package. A value of "any" (or an empty value)
+permits disruption at any time.
+*/
+package maintenancewindow
+
+import (
+	"sync"
+
+	"github.com/Cloud-Foundations/Dominator/lib/tags"
+	"github.com/Cloud-Foundations/Dominator/lib/timewindow"
+)
+
+// TagName is the MDB tag which specifies the maintenance window.
+const TagName = "DisruptionManagerMaintenanceWindow"
+
+// Cache keeps the parsed maintenance windows, so that each distinct tag value
+// is only parsed once.
+type Cache struct {
+	defaultWindow *timewindow.Windows
+	mutex         sync.Mutex             // Protect everything below.
+	windows       map[string]parseResult // Key: tag value.
+}
+
+type parseResult struct {
+	window *timewindow.Windows
+	err    error
+}
+
+// NewCache returns a Cache which will return defaultWindow for machines
+// without the tag or with an invalid tag. If defaultWindow is nil, disruption
+// is permitted at any time for those machines. A nil *Cache behaves like a
+// Cache with a nil defaultWindow.
+func NewCache(defaultWindow *timewindow.Windows) *Cache {
+	return newCache(defaultWindow)
+}
+
+// Default returns the default window.
+func (c *Cache) Default() *timewindow.Windows {
+	if c == nil {
+		return nil
+	}
+	return c.defaultWindow
+}
+
+// Get returns the maintenance window for a machine with the specified tags, or
+// nil if disruption is permitted at any time. If the tag cannot be parsed, the
+// default window is returned with the error.
+func (c *Cache) Get(machineTags tags.Tags) (*timewindow.Windows, error) {
+	return c.get(machineTags)
+}
@@ -0,0 +1,54 @@
+package maintenancewindow
+
+import (
+	"strings"
+
+	"github.com/Cloud-Foundations/Dominator/lib/tags"
+	"github.com/Cloud-Foundations/Dominator/lib/timewindow"
+)
+
+// Tag values are few, but clear the cache if there are too many, rather than
+// growing without bound.
+const maxCacheSize = 1024
+
+var nilCache = newCache(nil) // Used by a nil *Cache.
+
+func newCache(defaultWindow *timewindow.Windows) *Cache {
+	return &Cache{
+		defaultWindow: defaultWindow,
+		windows:       make(map[string]parseResult),
+	}
+}
+
+func (c *Cache) get(machineTags tags.Tags) (*timewindow.Windows, error) {
+	if c == nil {
+		c = nilCache
+	}
+	value, ok := machineTags[TagName]
+	if !ok {
+		return c.defaultWindow, nil
+	}
+	if value == "" || strings.EqualFold(value, "any") {
+		return nil, nil
+	}
+	result := c.parse(value)
+	if result.err != nil {
+		return c.defaultWindow, result.err
+	}
+	return result.window, nil
+}
+
+func (c *Cache) parse(value string) parseResult {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if result, ok := c.windows[value]; ok {
+		return result
+	}
+	if len(c.windows) >= maxCacheSize {
+		c.windows = make(map[string]parseResult)
+	}
+	window, err := timewindow.Parse(value)
+	result := parseResult{window: window, err: err}
+	c.windows[value] = result
+	return result
+}
@@ -0,0 +1,48 @@
+package maintenancewindow
+
+import (
+	"testing"
+
+	"github.com/Cloud-Foundations/Dominator/lib/tags"
+	"github.com/Cloud-Foundations/Dominator/lib/timewindow"
+)
+
+func TestGet(t *testing.T) {
+	defaultWindow, err := timewindow.Parse("Sat 22:00-02:00 UTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := NewCache(defaultWindow)
+	tests := []struct {
+		tags     tags.Tags
+		expected string // Empty: any time.
+		invalid  bool
+	}{
+		{nil, "Sat 22:00-02:00 UTC", false},
+		{tags.Tags{TagName: ""}, "", false},
+		{tags.Tags{TagName: "Any"}, "", false},
+		{tags.Tags{TagName: "Tue 02:00-05:00 UTC"}, "Tue 02:00-05:00 UTC",
+			false},
+		{tags.Tags{TagName: "Tue 25:00-05:00"}, "Sat 22:00-02:00 UTC", true},
+	}
+	for _, test := range tests {
+		window, err := cache.Get(test.tags)
+		if test.invalid != (err != nil) {
+			t.Errorf("%v: error: %v", test.tags, err)
+		}
+		var specification string
+		if window != nil {
+			specification = window.String()
+		}
+		if specification != test.expected {
+			t.Errorf("%v: window: %q, expected: %q",
+				test.tags, specification, test.expected)
+		}
+	}
+	// The parsed window is cached.
+	machineTags := tags.Tags{TagName: "Tue 02:00-05:00 UTC"}
+	first, _ := cache.Get(machineTags)
+	if second, _ := cache.Get(machineTags); first != second {
+		t.Error("window not cached")
+	}
+}
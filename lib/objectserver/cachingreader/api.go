@@ -30,7 +30,8 @@ type ObjectServer struct {
 	lruUpdateNotifier   chan<- struct{}
 	maxCachedBytes      uint64
 	objectServerAddress string
-	rwLock              sync.RWMutex // Protect the following fields.
+	upstream            objectserver.ObjectsGetter // Used if no address.
+	rwLock              sync.RWMutex               // Protect the following fields.
 	data                Stats
 	newest              *objectType // For unused objects only.
 	objects             map[hash.Hash]*objectType
@@ -46,7 +47,17 @@ type Stats struct {
 
 func NewObjectServer(baseDir string, maxCachedBytes uint64,
 	objectServerAddress string, logger log.DebugLogger) (*ObjectServer, error) {
-	return newObjectServer(baseDir, maxCachedBytes, objectServerAddress, logger)
+	return newObjectServer(baseDir, maxCachedBytes, objectServerAddress, nil,
+		logger)
+}
+
+// NewObjectServerWithUpstream is like NewObjectServer, except that objects are
+// fetched from upstream rather than from a remote object server. The objects
+// readers returned by upstream must implement objectserver.FullObjectsReader.
+func NewObjectServerWithUpstream(baseDir string, maxCachedBytes uint64,
+	upstream objectserver.ObjectsGetter,
+	logger log.DebugLogger) (*ObjectServer, error) {
+	return newObjectServer(baseDir, maxCachedBytes, "", upstream, logger)
 }
 
 func (objSrv *ObjectServer) FetchObjects(hashes []hash.Hash) error {
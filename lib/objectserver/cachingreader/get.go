@@ -109,13 +109,24 @@ func (objSrv *ObjectServer) getObjects(hashes []hash.Hash) (
 	if len(hashesToFetch) < 1 {
 		return &or, nil
 	}
-	or.objectClient = client.NewObjectClient(objSrv.objectServerAddress)
-	if realOR, err := or.objectClient.GetObjects(hashesToFetch); err != nil {
-		or.Close()
-		return nil, err
+	var realOR objectserver.ObjectsReader
+	var err error
+	if objSrv.upstream != nil {
+		realOR, err = objSrv.upstream.GetObjects(hashesToFetch)
 	} else {
-		or.objectsReader = realOR.(objectserver.FullObjectsReader)
+		or.objectClient = client.NewObjectClient(objSrv.objectServerAddress)
+		realOR, err = or.objectClient.GetObjects(hashesToFetch)
 	}
+	if err != nil {
+		for _, object := range or.objectsToRead {
+			if object != nil {
+				objSrv.putObjectWithLock(object)
+			}
+		}
+		or.closeUpstream()
+		return nil, err
+	}
+	or.objectsReader = realOR.(objectserver.FullObjectsReader)
 	sizes := or.objectsReader.ObjectSizes()
 	for index, hashVal := range hashesToFetch {
 		size := sizes[index]
@@ -164,20 +175,22 @@ func (or *objectsReader) Close() error {
 		}
 	}
 	or.objSrv.rwLock.Unlock()
-	if or.objectClient == nil {
-		return nil
-	}
+	return or.closeUpstream()
+}
+
+func (or *objectsReader) closeUpstream() error {
 	var err error
-	if e := or.objectsReader.Close(); err == nil && e != nil {
-		err = e
-	}
-	if e := or.objectClient.Close(); err == nil && e != nil {
-		err = e
+	if or.objectsReader != nil {
+		if e := or.objectsReader.Close(); err == nil && e != nil {
+			err = e
+		}
 	}
-	if err != nil {
-		return err
+	if or.objectClient != nil {
+		if e := or.objectClient.Close(); err == nil && e != nil {
+			err = e
+		}
 	}
-	return nil
+	return err
 }
 
 func (or *objectsReader) NextObject() (uint64, io.ReadCloser, error) {
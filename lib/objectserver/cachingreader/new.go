@@ -7,11 +7,13 @@ import (
 
 	"github.com/Cloud-Foundations/Dominator/lib/hash"
 	"github.com/Cloud-Foundations/Dominator/lib/log"
+	"github.com/Cloud-Foundations/Dominator/lib/objectserver"
 	"github.com/Cloud-Foundations/Dominator/lib/objectserver/filesystem/scan"
 )
 
 func newObjectServer(baseDir string, maxCachedBytes uint64,
-	objectServerAddress string, logger log.DebugLogger) (*ObjectServer, error) {
+	objectServerAddress string, upstream objectserver.ObjectsGetter,
+	logger log.DebugLogger) (*ObjectServer, error) {
 	startTime := time.Now()
 	var rusageStart, rusageStop syscall.Rusage
 	syscall.Getrusage(syscall.RUSAGE_SELF, &rusageStart)
@@ -46,6 +48,7 @@ func newObjectServer(baseDir string, maxCachedBytes uint64,
 		lruUpdateNotifier:   lruUpdateNotifier,
 		maxCachedBytes:      maxCachedBytes,
 		objectServerAddress: objectServerAddress,
+		upstream:            upstream,
 		data:                Stats{CachedBytes: cachedBytes},
 		objects:             objects,
 	}
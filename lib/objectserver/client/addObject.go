@@ -6,6 +6,8 @@ import (
 	"io"
 
 	"github.com/Cloud-Foundations/Dominator/lib/hash"
+	"github.com/Cloud-Foundations/Dominator/lib/objectserver/compression"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
 	"github.com/Cloud-Foundations/Dominator/proto/objectserver"
 )
 
@@ -21,6 +23,7 @@ func (objClient *ObjectClient) addObject(reader io.Reader, length uint64,
 	if err != nil {
 		return reply.Hash, false, err
 	}
+	compressions := objClient.getCompressions(srpcClient)
 	conn, err := srpcClient.Call("ObjectServer.AddObjects")
 	if err != nil {
 		return reply.Hash, false, err
@@ -28,14 +31,29 @@ func (objClient *ObjectClient) addObject(reader io.Reader, length uint64,
 	defer conn.Close()
 	request.Length = length
 	request.ExpectedHash = expectedHash
-	conn.Encode(request)
-	nCopied, err := io.Copy(conn, reader)
-	if err != nil {
-		return reply.Hash, false, err
-	}
-	if uint64(nCopied) != length {
-		return reply.Hash, false, fmt.Errorf(
-			"failed to copy, wanted: %d, got: %d bytes", length, nCopied)
+	if len(compressions) > 0 && length <= compression.MaxObjectSize {
+		data := make([]byte, length)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return reply.Hash, false, err
+		}
+		request.Compression, data = compression.Compress(data, compressions)
+		if request.Compression != objectserver.CompressionNone {
+			request.CompressedLength = uint64(len(data))
+		}
+		conn.Encode(request)
+		if _, err := conn.Write(data); err != nil {
+			return reply.Hash, false, err
+		}
+	} else {
+		conn.Encode(request)
+		nCopied, err := io.Copy(conn, reader)
+		if err != nil {
+			return reply.Hash, false, err
+		}
+		if uint64(nCopied) != length {
+			return reply.Hash, false, fmt.Errorf(
+				"failed to copy, wanted: %d, got: %d bytes", length, nCopied)
+		}
 	}
 	// Send end-of-stream marker.
 	request = objectserver.AddObjectRequest{}
@@ -54,3 +72,12 @@ func (objClient *ObjectClient) addObject(reader io.Reader, length uint64,
 	}
 	return reply.Hash, reply.Added, nil
 }
+
+func (objClient *ObjectClient) getCompressions(
+	client srpc.ClientI) []objectserver.Compression {
+	if !objClient.haveCompressions {
+		objClient.compressions = getCompressions(client)
+		objClient.haveCompressions = true
+	}
+	return objClient.compressions
+}
@@ -6,12 +6,15 @@ import (
 	"github.com/Cloud-Foundations/Dominator/lib/hash"
 	"github.com/Cloud-Foundations/Dominator/lib/objectserver"
 	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	proto "github.com/Cloud-Foundations/Dominator/proto/objectserver"
 )
 
 type ObjectClient struct {
-	address      string
-	client       srpc.ClientI
-	exclusiveGet bool
+	address          string
+	client           srpc.ClientI
+	exclusiveGet     bool
+	compressions     []proto.Compression // Accepted by AddObjects.
+	haveCompressions bool
 }
 
 func NewObjectClient(address string) *ObjectClient {
@@ -51,10 +54,12 @@ func (objClient *ObjectClient) SetExclusiveGetObjects(exclusive bool) {
 }
 
 type ObjectsReader struct {
-	sizes     []uint64
-	client    *ObjectClient
-	reader    *srpc.Conn
-	nextIndex int64
+	sizes         []uint64
+	client        *ObjectClient
+	reader        *srpc.Conn
+	nextIndex     int64
+	objectHeaders bool
+	remaining     *io.LimitedReader // Compressed data for the last object.
 }
 
 func (or *ObjectsReader) Close() error {
@@ -70,6 +75,7 @@ func (or *ObjectsReader) ObjectSizes() []uint64 {
 }
 
 type ObjectAdderQueue struct {
+	compressions    []proto.Compression // Accepted by AddObjects.
 	conn            *srpc.Conn
 	getResponseChan chan<- struct{}
 	errorChan       <-chan error
@@ -2,6 +2,7 @@ package client
 
 import (
 	"github.com/Cloud-Foundations/Dominator/lib/hash"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
 	"github.com/Cloud-Foundations/Dominator/proto/objectserver"
 )
 
@@ -20,3 +21,16 @@ func (objClient *ObjectClient) checkObjects(hashes []hash.Hash) (
 	}
 	return reply.ObjectSizes, nil
 }
+
+// getCompressions returns the compressions accepted by the server for
+// AddObjects. Old servers and servers which do not support CheckObjects accept
+// none.
+func getCompressions(client srpc.ClientI) []objectserver.Compression {
+	var reply objectserver.CheckObjectsResponse
+	err := client.RequestReply("ObjectServer.CheckObjects",
+		objectserver.CheckObjectsRequest{}, &reply)
+	if err != nil {
+		return nil
+	}
+	return reply.Compressions
+}
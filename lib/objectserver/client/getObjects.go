@@ -7,6 +7,7 @@ import (
 	"io/ioutil"
 
 	"github.com/Cloud-Foundations/Dominator/lib/hash"
+	"github.com/Cloud-Foundations/Dominator/lib/objectserver/compression"
 	"github.com/Cloud-Foundations/Dominator/proto/objectserver"
 )
 
@@ -23,6 +24,9 @@ func (objClient *ObjectClient) getObjects(hashes []hash.Hash) (
 	var request objectserver.GetObjectsRequest
 	var reply objectserver.GetObjectsResponse
 	request.Exclusive = objClient.exclusiveGet
+	if !objClient.exclusiveGet { // Compression would skew the benchmark.
+		request.Compressions = compression.Supported()
+	}
 	request.Hashes = hashes
 	conn.Encode(request)
 	conn.Flush()
@@ -37,6 +41,7 @@ func (objClient *ObjectClient) getObjects(hashes []hash.Hash) (
 		return nil, errors.New(reply.ResponseString)
 	}
 	objectsReader.nextIndex = -1
+	objectsReader.objectHeaders = reply.ObjectHeaders
 	objectsReader.sizes = reply.ObjectSizes
 	return &objectsReader, nil
 }
@@ -51,6 +56,26 @@ func (or *ObjectsReader) nextObject() (uint64, io.ReadCloser, error) {
 		return 0, nil, errors.New("all objects have been consumed")
 	}
 	size := or.sizes[or.nextIndex]
-	return size,
-		ioutil.NopCloser(&io.LimitedReader{R: or.reader, N: int64(size)}), nil
+	if !or.objectHeaders {
+		return size,
+			ioutil.NopCloser(&io.LimitedReader{R: or.reader, N: int64(size)}),
+			nil
+	}
+	// Skip any data the decompressor did not consume for the last object.
+	if or.remaining != nil {
+		if _, err := io.Copy(io.Discard, or.remaining); err != nil {
+			return 0, nil, err
+		}
+	}
+	var header objectserver.ObjectHeader
+	if err := or.reader.Decode(&header); err != nil {
+		return 0, nil, err
+	}
+	or.remaining = &io.LimitedReader{R: or.reader, N: int64(header.Length)}
+	reader, err := compression.NewReader(or.remaining, header.Compression,
+		header.Length, size)
+	if err != nil {
+		return 0, nil, err
+	}
+	return size, ioutil.NopCloser(reader), nil
 }
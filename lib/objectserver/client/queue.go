@@ -7,6 +7,7 @@ import (
 
 	"github.com/Cloud-Foundations/Dominator/lib/errors"
 	"github.com/Cloud-Foundations/Dominator/lib/hash"
+	"github.com/Cloud-Foundations/Dominator/lib/objectserver/compression"
 	"github.com/Cloud-Foundations/Dominator/lib/queue"
 	"github.com/Cloud-Foundations/Dominator/lib/srpc"
 	"github.com/Cloud-Foundations/Dominator/proto/objectserver"
@@ -15,6 +16,7 @@ import (
 func newObjectAdderQueue(client srpc.ClientI) (*ObjectAdderQueue, error) {
 	var objQ ObjectAdderQueue
 	var err error
+	objQ.compressions = getCompressions(client)
 	objQ.conn, err = client.Call("ObjectServer.AddObjects")
 	if err != nil {
 		return nil, err
@@ -63,6 +65,11 @@ func (objQ *ObjectAdderQueue) addData(data []byte, hashVal hash.Hash) error {
 		var request objectserver.AddObjectRequest
 		request.Length = uint64(len(data))
 		request.ExpectedHash = &hashVal
+		request.Compression, data = compression.Compress(data,
+			objQ.compressions)
+		if request.Compression != objectserver.CompressionNone {
+			request.CompressedLength = uint64(len(data))
+		}
 		objQ.conn.Encode(request)
 		objQ.conn.Write(data)
 		objQ.getResponseChan <- struct{}{}
@@ -0,0 +1,53 @@
+package compression
+
+import (
+	"io"
+
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	proto "github.com/Cloud-Foundations/Dominator/proto/objectserver"
+)
+
+// MaxObjectSize is the size of the largest object which is compressed. The
+// ObjectHeader which precedes the data contains the compressed length, so an
+// object and its compressed form are held in memory while it is compressed.
+// Larger objects are streamed uncompressed, which bounds the memory used by
+// each transfer.
+const MaxObjectSize = 4 << 20
+
+// Compress returns the data compressed with the first of the accepted
+// compressions which is supported. If there is none, or if the data do not
+// compress well, the data are returned uncompressed with CompressionNone.
+func Compress(data []byte, accepted []proto.Compression) (
+	proto.Compression, []byte) {
+	return compress(data, accepted)
+}
+
+// NewReader returns a reader which yields the decompressed data read from
+// reader, which should be limited to the compressedLength bytes of compressed
+// data. The caller must consume any data remaining in reader afterwards.
+func NewReader(reader io.Reader, compression proto.Compression,
+	compressedLength, length uint64) (io.Reader, error) {
+	return newReader(reader, compression, compressedLength, length)
+}
+
+// Select returns the first of the accepted compressions which is supported,
+// or CompressionNone.
+func Select(accepted []proto.Compression) proto.Compression {
+	return selectCompression(accepted)
+}
+
+// Supported returns the supported compressions, in order of preference.
+func Supported() []proto.Compression {
+	return []proto.Compression{proto.CompressionGzip}
+}
+
+// WriteObject writes an ObjectHeader to encoder followed by length bytes of
+// object data read from reader to writer. The data are compressed with
+// compression if that saves enough space. Whether the data compress well is
+// decided from a sample, so that incompressible objects are streamed without
+// being read into memory. Objects larger than MaxObjectSize are not
+// compressed.
+func WriteObject(encoder srpc.Encoder, writer io.Writer, reader io.Reader,
+	length uint64, compression proto.Compression) error {
+	return writeObject(encoder, writer, reader, length, compression)
+}
@@ -0,0 +1,160 @@
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	proto "github.com/Cloud-Foundations/Dominator/proto/objectserver"
+	"github.com/Cloud-Foundations/tricorder/go/tricorder"
+	"github.com/Cloud-Foundations/tricorder/go/tricorder/units"
+)
+
+const (
+	minObjectSize = 512
+	sampleSize    = 64 << 10
+)
+
+var (
+	bytesSavedReceiving   uint64
+	bytesSavedSending     uint64
+	incompressibleObjects uint64
+)
+
+func init() {
+	tricorder.RegisterMetric("/objectserver/compression/bytes-saved-receiving",
+		&bytesSavedReceiving, units.Byte,
+		"bytes saved receiving compressed objects")
+	tricorder.RegisterMetric("/objectserver/compression/bytes-saved-sending",
+		&bytesSavedSending, units.Byte,
+		"bytes saved sending compressed objects")
+	tricorder.RegisterMetric(
+		"/objectserver/compression/incompressible-objects",
+		&incompressibleObjects, units.None,
+		"objects sent uncompressed because they did not compress well")
+}
+
+func compress(data []byte, accepted []proto.Compression) (
+	proto.Compression, []byte) {
+	compression := selectCompression(accepted)
+	if compression == proto.CompressionNone ||
+		len(data) < minObjectSize || len(data) > MaxObjectSize {
+		return proto.CompressionNone, data
+	}
+	compressedData, ok := gzipData(data)
+	if !ok {
+		atomic.AddUint64(&incompressibleObjects, 1)
+		return proto.CompressionNone, data
+	}
+	atomic.AddUint64(&bytesSavedSending, uint64(len(data)-len(compressedData)))
+	return compression, compressedData
+}
+
+// gzipData returns the compressed data and true if the data compress well
+// enough to be worth the decompression effort.
+func gzipData(data []byte) ([]byte, bool) {
+	buffer := &bytes.Buffer{}
+	writer, err := gzip.NewWriterLevel(buffer, gzip.BestSpeed)
+	if err != nil {
+		return nil, false
+	}
+	if _, err := writer.Write(data); err != nil {
+		return nil, false
+	}
+	if err := writer.Close(); err != nil {
+		return nil, false
+	}
+	if buffer.Len() > len(data)-len(data)>>3 {
+		return nil, false
+	}
+	return buffer.Bytes(), true
+}
+
+func newReader(reader io.Reader, compression proto.Compression,
+	compressedLength, length uint64) (io.Reader, error) {
+	switch compression {
+	case proto.CompressionNone:
+		return reader, nil
+	case proto.CompressionGzip:
+		gzipReader, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		if length > compressedLength {
+			atomic.AddUint64(&bytesSavedReceiving, length-compressedLength)
+		}
+		return gzipReader, nil
+	}
+	return nil, fmt.Errorf("unsupported compression: %d", compression)
+}
+
+func selectCompression(accepted []proto.Compression) proto.Compression {
+	for _, compression := range accepted {
+		if compression == proto.CompressionGzip {
+			return compression
+		}
+	}
+	return proto.CompressionNone
+}
+
+func writeObject(encoder srpc.Encoder, writer io.Writer, reader io.Reader,
+	length uint64, compression proto.Compression) error {
+	if compression == proto.CompressionNone ||
+		length < minObjectSize || length > MaxObjectSize {
+		return writeUncompressed(encoder, writer, reader, nil, length)
+	}
+	// Compress a sample first, so that incompressible objects are streamed
+	// without reading them all into memory.
+	sample := make([]byte, sampleSize)
+	if length < sampleSize {
+		sample = sample[:length]
+	}
+	if _, err := io.ReadFull(reader, sample); err != nil {
+		return err
+	}
+	if uint64(len(sample)) < length {
+		if _, ok := gzipData(sample); !ok {
+			atomic.AddUint64(&incompressibleObjects, 1)
+			return writeUncompressed(encoder, writer, reader, sample, length)
+		}
+	}
+	data := make([]byte, length)
+	copy(data, sample)
+	if _, err := io.ReadFull(reader, data[len(sample):]); err != nil {
+		return err
+	}
+	header := proto.ObjectHeader{}
+	header.Compression, data = compress(data, []proto.Compression{compression})
+	header.Length = uint64(len(data))
+	if err := encoder.Encode(header); err != nil {
+		return err
+	}
+	_, err := writer.Write(data)
+	return err
+}
+
+// writeUncompressed writes an ObjectHeader followed by the data already read
+// and the remainder of the object data.
+func writeUncompressed(encoder srpc.Encoder, writer io.Writer,
+	reader io.Reader, dataRead []byte, length uint64) error {
+	header := proto.ObjectHeader{Length: length}
+	if err := encoder.Encode(header); err != nil {
+		return err
+	}
+	if _, err := writer.Write(dataRead); err != nil {
+		return err
+	}
+	remaining := int64(length) - int64(len(dataRead))
+	nCopied, err := io.CopyN(writer, reader, remaining)
+	if err != nil {
+		return err
+	}
+	if nCopied != remaining {
+		return fmt.Errorf("expected length: %d, got: %d",
+			length, int64(len(dataRead))+nCopied)
+	}
+	return nil
+}
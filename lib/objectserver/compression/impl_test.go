@@ -0,0 +1,139 @@
+package compression
+
+import (
+	"bytes"
+	"encoding/gob"
+	"io"
+	"math/rand"
+	"testing"
+
+	proto "github.com/Cloud-Foundations/Dominator/proto/objectserver"
+)
+
+func makeCompressibleData(length int) []byte {
+	data := make([]byte, length)
+	for index := range data {
+		data[index] = byte(index % 7)
+	}
+	return data
+}
+
+func makeRandomData(length int) []byte {
+	data := make([]byte, length)
+	rand.New(rand.NewSource(1)).Read(data)
+	return data
+}
+
+// writeAndRead writes the object with WriteObject and reads it back the way
+// a client does, returning the header and the decompressed data.
+func writeAndRead(t *testing.T, data []byte,
+	compression proto.Compression) (proto.ObjectHeader, []byte) {
+	buffer := &bytes.Buffer{}
+	err := WriteObject(gob.NewEncoder(buffer), buffer, bytes.NewReader(data),
+		uint64(len(data)), compression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var header proto.ObjectHeader
+	if err := gob.NewDecoder(buffer).Decode(&header); err != nil {
+		t.Fatal(err)
+	}
+	if uint64(buffer.Len()) != header.Length {
+		t.Fatalf("remaining data: %d, header length: %d",
+			buffer.Len(), header.Length)
+	}
+	reader, err := NewReader(buffer, header.Compression, header.Length,
+		uint64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	readData, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return header, readData
+}
+
+func TestCompress(t *testing.T) {
+	data := makeCompressibleData(sampleSize)
+	compression, compressedData := Compress(data,
+		[]proto.Compression{proto.CompressionGzip})
+	if compression != proto.CompressionGzip {
+		t.Fatalf("compression: %d, expected gzip", compression)
+	}
+	if len(compressedData) >= len(data) {
+		t.Errorf("compressed length: %d, not less than: %d",
+			len(compressedData), len(data))
+	}
+	tests := []struct {
+		name     string
+		data     []byte
+		accepted []proto.Compression
+	}{
+		{"not accepted", data, nil},
+		{"small", data[:minObjectSize-1],
+			[]proto.Compression{proto.CompressionGzip}},
+		{"incompressible", makeRandomData(sampleSize),
+			[]proto.Compression{proto.CompressionGzip}},
+	}
+	for _, test := range tests {
+		compression, returnedData := Compress(test.data, test.accepted)
+		if compression != proto.CompressionNone {
+			t.Errorf("%s: compression: %d, expected none",
+				test.name, compression)
+		}
+		if !bytes.Equal(returnedData, test.data) {
+			t.Errorf("%s: data changed", test.name)
+		}
+	}
+}
+
+func TestWriteObject(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        []byte
+		compression proto.Compression
+		expected    proto.Compression
+	}{
+		{"compressible", makeCompressibleData(sampleSize * 3),
+			proto.CompressionGzip, proto.CompressionGzip},
+		{"compressible sample", makeCompressibleData(sampleSize / 2),
+			proto.CompressionGzip, proto.CompressionGzip},
+		{"incompressible", makeRandomData(sampleSize * 3),
+			proto.CompressionGzip, proto.CompressionNone},
+		{"incompressible sample", makeRandomData(sampleSize / 2),
+			proto.CompressionGzip, proto.CompressionNone},
+		{"small", makeCompressibleData(minObjectSize - 1),
+			proto.CompressionGzip, proto.CompressionNone},
+		{"not requested", makeCompressibleData(sampleSize),
+			proto.CompressionNone, proto.CompressionNone},
+		{"large", makeCompressibleData(MaxObjectSize + 1),
+			proto.CompressionGzip, proto.CompressionNone},
+	}
+	for _, test := range tests {
+		header, readData := writeAndRead(t, test.data, test.compression)
+		if header.Compression != test.expected {
+			t.Errorf("%s: compression: %d, expected: %d",
+				test.name, header.Compression, test.expected)
+		}
+		if header.Compression == proto.CompressionNone &&
+			header.Length != uint64(len(test.data)) {
+			t.Errorf("%s: length: %d, expected: %d",
+				test.name, header.Length, len(test.data))
+		}
+		if !bytes.Equal(readData, test.data) {
+			t.Errorf("%s: data corrupted", test.name)
+		}
+	}
+}
+
+func TestWriteObjectShortRead(t *testing.T) {
+	data := makeRandomData(sampleSize * 2)
+	buffer := &bytes.Buffer{}
+	err := WriteObject(gob.NewEncoder(buffer), buffer,
+		bytes.NewReader(data[:sampleSize+1]), uint64(len(data)),
+		proto.CompressionGzip)
+	if err == nil {
+		t.Error("short object did not fail")
+	}
+}
@@ -0,0 +1,122 @@
+package s3
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/hash"
+	"github.com/Cloud-Foundations/Dominator/lib/objectcache"
+)
+
+// This must be called with the lock held. The object must not already exist.
+func (objSrv *ObjectServer) add(object *objectType) {
+	objSrv.objects[object.hash] = object
+	objSrv.addUnreferenced(object)
+	objSrv.lastMutationTime = time.Now()
+	objSrv.totalBytes += object.size
+}
+
+// addData uploads the object to the bucket if it is not already present and
+// records it in the index. It returns true if the object is new.
+func (objSrv *ObjectServer) addData(hashVal hash.Hash, reader io.ReadSeeker,
+	length uint64) (bool, error) {
+	objSrv.rwLock.Lock()
+	objSrv.lockObject(hashVal)
+	objSrv.rwLock.Unlock()
+	defer func() {
+		objSrv.rwLock.Lock()
+		objSrv.unlockObject(hashVal)
+		objSrv.rwLock.Unlock()
+	}()
+	if exists, err := objSrv.checkExisting(hashVal, length); err != nil {
+		return false, err
+	} else if exists {
+		return false, nil
+	}
+	if err := objSrv.putToBucket(hashVal, reader, length); err != nil {
+		return false, err
+	}
+	object := &objectType{hash: hashVal, size: length}
+	objSrv.rwLock.Lock()
+	defer objSrv.rwLock.Unlock()
+	objSrv.add(object)
+	// An object in the bucket which is not in the index is harmless.
+	if err := objSrv.recordIndex(indexOpAdd, object); err != nil {
+		objSrv.remove(object)
+		return false, fmt.Errorf("error recording object: %x in index: %s",
+			hashVal, err)
+	}
+	return true, nil
+}
+
+func (objSrv *ObjectServer) addObject(reader io.Reader, length uint64,
+	expectedHash *hash.Hash) (hash.Hash, bool, error) {
+	hashVal, data, err := objectcache.ReadObject(reader, length, expectedHash)
+	if err != nil {
+		return hashVal, false, err
+	}
+	isNew, err := objSrv.addData(hashVal, bytes.NewReader(data),
+		uint64(len(data)))
+	if err != nil {
+		return hashVal, false, err
+	}
+	if objSrv.addCallback != nil {
+		objSrv.addCallback(hashVal, uint64(len(data)), isNew)
+	}
+	return hashVal, isNew, nil
+}
+
+// checkExisting returns true if the object is in the index. An error is
+// returned if the size of the existing object is different.
+func (objSrv *ObjectServer) checkExisting(hashVal hash.Hash,
+	length uint64) (bool, error) {
+	size, err := objSrv.checkObject(hashVal)
+	if err != nil || size < 1 {
+		return false, err
+	}
+	if size != length {
+		return false, fmt.Errorf(
+			"collision detected: length mismatch. Data=%d, existing object=%d",
+			length, size)
+	}
+	return true, nil
+}
+
+// lockObject waits until no other add or delete of the object is in progress
+// and then marks the object as being added or deleted, so that the bucket and
+// the index are changed together. This must be called with the lock held,
+// which is released while waiting.
+func (objSrv *ObjectServer) lockObject(hashVal hash.Hash) {
+	for {
+		channel, ok := objSrv.objectLocks[hashVal]
+		if !ok {
+			break
+		}
+		objSrv.rwLock.Unlock()
+		<-channel
+		objSrv.rwLock.Lock()
+	}
+	objSrv.objectLocks[hashVal] = make(chan struct{})
+}
+
+// This must be called with the lock held.
+func (objSrv *ObjectServer) remove(object *objectType) {
+	delete(objSrv.objects, object.hash)
+	objSrv.duplicatedBytes -= object.size * object.refcount
+	objSrv.lastMutationTime = time.Now()
+	objSrv.numDuplicated -= object.refcount
+	if object.refcount > 0 {
+		objSrv.numReferenced--
+		objSrv.referencedBytes -= object.size
+	}
+	objSrv.removeUnreferenced(object)
+	objSrv.totalBytes -= object.size
+}
+
+// This must be called with the lock held.
+func (objSrv *ObjectServer) unlockObject(hashVal hash.Hash) {
+	close(objSrv.objectLocks[hashVal])
+	delete(objSrv.objectLocks, hashVal)
+}
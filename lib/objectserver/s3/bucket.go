@@ -0,0 +1,118 @@
+package s3
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Cloud-Foundations/Dominator/lib/hash"
+	"github.com/Cloud-Foundations/Dominator/lib/objectcache"
+	"github.com/Cloud-Foundations/Dominator/lib/objectserver"
+	"github.com/aws/aws-sdk-go/aws"
+	awss3 "github.com/aws/aws-sdk-go/service/s3"
+)
+
+// bucketGetter reads objects directly from the bucket. It is the upstream for
+// the cache.
+type bucketGetter struct {
+	objSrv *ObjectServer
+}
+
+func (objSrv *ObjectServer) deleteFromBucket(hashVal hash.Hash) error {
+	_, err := objSrv.client.DeleteObject(&awss3.DeleteObjectInput{
+		Bucket: aws.String(objSrv.Bucket),
+		Key:    aws.String(objSrv.makeKey(hashVal)),
+	})
+	return err
+}
+
+// getObjectsFromBucket returns an ObjectsReader which reads directly from the
+// bucket. The objects must be in the index.
+func (objSrv *ObjectServer) getObjectsFromBucket(hashes []hash.Hash) (
+	*ObjectsReader, error) {
+	objectsReader := ObjectsReader{
+		objectServer: objSrv,
+		hashes:       hashes,
+		nextIndex:    -1,
+	}
+	var err error
+	if objectsReader.sizes, err = objSrv.getSizes(hashes); err != nil {
+		return nil, err
+	}
+	return &objectsReader, nil
+}
+
+// listBucket calls registerFunc for each object in the bucket.
+func (objSrv *ObjectServer) listBucket(
+	registerFunc func(hashVal hash.Hash, size uint64)) error {
+	var numIgnored uint
+	err := objSrv.client.ListObjectsV2Pages(&awss3.ListObjectsV2Input{
+		Bucket: aws.String(objSrv.Bucket),
+		Prefix: aws.String(objSrv.KeyPrefix),
+	},
+		func(page *awss3.ListObjectsV2Output, lastPage bool) bool {
+			for _, object := range page.Contents {
+				key := strings.TrimPrefix(aws.StringValue(object.Key),
+					objSrv.KeyPrefix)
+				hashVal, err := objectcache.FilenameToHash(key)
+				if err != nil || objectcache.HashToFilename(hashVal) != key {
+					numIgnored++
+					continue
+				}
+				registerFunc(hashVal, uint64(aws.Int64Value(object.Size)))
+			}
+			return true
+		})
+	if err != nil {
+		return err
+	}
+	if numIgnored > 0 {
+		objSrv.Logger.Printf("Ignored %d keys which are not objects\n",
+			numIgnored)
+	}
+	return nil
+}
+
+func (objSrv *ObjectServer) makeKey(hashVal hash.Hash) string {
+	return objSrv.KeyPrefix + objectcache.HashToFilename(hashVal)
+}
+
+func (objSrv *ObjectServer) putToBucket(hashVal hash.Hash,
+	reader io.ReadSeeker, length uint64) error {
+	_, err := objSrv.client.PutObject(&awss3.PutObjectInput{
+		Body:          reader,
+		Bucket:        aws.String(objSrv.Bucket),
+		ContentLength: aws.Int64(int64(length)),
+		Key:           aws.String(objSrv.makeKey(hashVal)),
+	})
+	return err
+}
+
+func (bg bucketGetter) GetObjects(hashes []hash.Hash) (
+	objectserver.ObjectsReader, error) {
+	return bg.objSrv.getObjectsFromBucket(hashes)
+}
+
+func (or *ObjectsReader) nextObject() (uint64, io.ReadCloser, error) {
+	or.nextIndex++
+	if or.nextIndex >= int64(len(or.hashes)) {
+		return 0, nil, errors.New("all objects have been consumed")
+	}
+	objSrv := or.objectServer
+	hashVal := or.hashes[or.nextIndex]
+	output, err := objSrv.client.GetObject(&awss3.GetObjectInput{
+		Bucket: aws.String(objSrv.Bucket),
+		Key:    aws.String(objSrv.makeKey(hashVal)),
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	size := or.sizes[or.nextIndex]
+	if length := aws.Int64Value(output.ContentLength); length != int64(size) {
+		output.Body.Close()
+		return 0, nil, fmt.Errorf("object: %x length: %d != expected: %d",
+			hashVal, length, size)
+	}
+	return size, output.Body, nil
+}
@@ -0,0 +1,62 @@
+package s3
+
+import (
+	"errors"
+
+	"github.com/Cloud-Foundations/Dominator/lib/hash"
+	"github.com/Cloud-Foundations/Dominator/lib/objectserver"
+)
+
+func (objSrv *ObjectServer) checkObjects(hashes []hash.Hash) ([]uint64, error) {
+	sizesList := make([]uint64, len(hashes))
+	objSrv.rwLock.RLock()
+	defer objSrv.rwLock.RUnlock()
+	for index, hashVal := range hashes {
+		if object, ok := objSrv.objects[hashVal]; ok {
+			sizesList[index] = object.size
+		}
+	}
+	return sizesList, nil
+}
+
+func (objSrv *ObjectServer) checkObject(hashVal hash.Hash) (uint64, error) {
+	objSrv.rwLock.RLock()
+	defer objSrv.rwLock.RUnlock()
+	if object, ok := objSrv.objects[hashVal]; ok {
+		return object.size, nil
+	}
+	return 0, nil
+}
+
+func (objSrv *ObjectServer) getObjects(hashes []hash.Hash) (
+	objectserver.ObjectsReader, error) {
+	if objSrv.cache == nil {
+		objectsReader, err := objSrv.getObjectsFromBucket(hashes)
+		if err != nil {
+			return nil, err
+		}
+		return objectsReader, nil
+	}
+	// The cache may contain objects which were deleted: check them first.
+	if _, err := objSrv.getSizes(hashes); err != nil {
+		return nil, err
+	}
+	return objSrv.cache.GetObjects(hashes)
+}
+
+// getSizes returns the sizes of the objects. An error is returned if any object
+// is missing.
+func (objSrv *ObjectServer) getSizes(hashes []hash.Hash) ([]uint64, error) {
+	sizes := make([]uint64, 0, len(hashes))
+	objSrv.rwLock.RLock()
+	defer objSrv.rwLock.RUnlock()
+	for _, hashVal := range hashes {
+		object, ok := objSrv.objects[hashVal]
+		if !ok {
+			hashStr, _ := hashVal.MarshalText()
+			return nil, errors.New("missing object: " + string(hashStr))
+		}
+		sizes = append(sizes, object.size)
+	}
+	return sizes, nil
+}
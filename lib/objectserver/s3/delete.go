@@ -0,0 +1,51 @@
+package s3
+
+import (
+	"fmt"
+
+	"github.com/Cloud-Foundations/Dominator/lib/hash"
+)
+
+// deleteObject will delete the specified object. If haveLock is false, the
+// lock is grabbed. In either case, the lock will be released. The lock is not
+// held while the object is deleted from the bucket: other adds and deletes of
+// the object wait until it has been deleted. If onlyUnreferenced is true and
+// the object was referenced while waiting, it is not deleted.
+func (objSrv *ObjectServer) deleteObject(hashVal hash.Hash,
+	haveLock, onlyUnreferenced bool) error {
+	if !haveLock {
+		objSrv.rwLock.Lock()
+	}
+	objSrv.lockObject(hashVal)
+	object := objSrv.objects[hashVal]
+	if object == nil {
+		objSrv.unlockObject(hashVal)
+		objSrv.rwLock.Unlock()
+		return fmt.Errorf("deleteObject(%x): object unknown", hashVal)
+	}
+	refcount := object.refcount
+	if onlyUnreferenced && refcount > 0 {
+		objSrv.unlockObject(hashVal)
+		objSrv.rwLock.Unlock()
+		return nil
+	}
+	// Record the deletion first: an object in the bucket which is not in the
+	// index is harmless.
+	if err := objSrv.recordIndex(indexOpDelete, object); err != nil {
+		objSrv.unlockObject(hashVal)
+		objSrv.rwLock.Unlock()
+		return fmt.Errorf("error recording deletion of: %x in index: %s",
+			hashVal, err)
+	}
+	objSrv.remove(object)
+	objSrv.rwLock.Unlock()
+	if refcount > 0 {
+		objSrv.Logger.Printf("deleteObject(%x): refcount: %d\n",
+			hashVal, refcount)
+	}
+	err := objSrv.deleteFromBucket(hashVal)
+	objSrv.rwLock.Lock()
+	objSrv.unlockObject(hashVal)
+	objSrv.rwLock.Unlock()
+	return err
+}
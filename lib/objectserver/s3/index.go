@@ -0,0 +1,178 @@
+package s3
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/fsutil"
+	"github.com/Cloud-Foundations/Dominator/lib/hash"
+)
+
+// The index is a journal of fixed-size records. Each record has an operation
+// byte, the object hash and the object size (little-endian). The journal is
+// compacted when it is loaded.
+
+const (
+	indexFilename = ".index"
+	indexOpAdd    = '+'
+	indexOpDelete = '-'
+	recordLength  = 1 + len(hash.Hash{}) + 8
+
+	reconcileInterval = time.Minute
+)
+
+func encodeRecord(op byte, hashVal hash.Hash, size uint64) []byte {
+	record := make([]byte, recordLength)
+	record[0] = op
+	copy(record[1:], hashVal[:])
+	binary.LittleEndian.PutUint64(record[1+len(hashVal):], size)
+	return record
+}
+
+// loadIndex reads the index, or lists the bucket if there is no index, and
+// then writes a compacted index.
+func (objSrv *ObjectServer) loadIndex() error {
+	filename := filepath.Join(objSrv.BaseDirectory, indexFilename)
+	if file, err := os.Open(filename); err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+		objSrv.Logger.Printf("No index: listing bucket: %s\n", objSrv.Bucket)
+		err := objSrv.listBucket(func(hashVal hash.Hash, size uint64) {
+			if _, ok := objSrv.objects[hashVal]; !ok {
+				objSrv.add(&objectType{hash: hashVal, size: size})
+			}
+		})
+		if err != nil {
+			return err
+		}
+	} else {
+		err := objSrv.readIndex(bufio.NewReader(file))
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("error reading index: %s: %s", filename, err)
+		}
+	}
+	return objSrv.writeIndex(filename)
+}
+
+func (objSrv *ObjectServer) readIndex(reader io.Reader) error {
+	record := make([]byte, recordLength)
+	for {
+		if _, err := io.ReadFull(reader, record); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			if err == io.ErrUnexpectedEOF { // Partially written record.
+				objSrv.Logger.Println("Ignoring truncated index record")
+				return nil
+			}
+			return err
+		}
+		var hashVal hash.Hash
+		copy(hashVal[:], record[1:])
+		size := binary.LittleEndian.Uint64(record[1+len(hashVal):])
+		switch record[0] {
+		case indexOpAdd:
+			if _, ok := objSrv.objects[hashVal]; !ok {
+				objSrv.add(&objectType{hash: hashVal, size: size})
+			}
+		case indexOpDelete:
+			if object := objSrv.objects[hashVal]; object != nil {
+				objSrv.remove(object)
+			}
+		default:
+			return fmt.Errorf("bad index record operation: %d", record[0])
+		}
+	}
+}
+
+// reconcileIndex lists the bucket and adds the objects which are missing from
+// the index, such as objects which were added while the index could not be
+// written. The bucket is listed at most once per reconcileInterval. The number
+// of objects added is returned.
+func (objSrv *ObjectServer) reconcileIndex() (uint, error) {
+	objSrv.rwLock.Lock()
+	if time.Since(objSrv.lastReconcileTime) < reconcileInterval {
+		objSrv.rwLock.Unlock()
+		return 0, nil
+	}
+	objSrv.lastReconcileTime = time.Now()
+	objSrv.rwLock.Unlock()
+	objSrv.Logger.Printf("Reconciling index with bucket: %s\n", objSrv.Bucket)
+	bucketObjects := make(map[hash.Hash]uint64)
+	err := objSrv.listBucket(func(hashVal hash.Hash, size uint64) {
+		bucketObjects[hashVal] = size
+	})
+	if err != nil {
+		return 0, err
+	}
+	var numAdded uint
+	objSrv.rwLock.Lock()
+	defer objSrv.rwLock.Unlock()
+	for hashVal, size := range bucketObjects {
+		if _, ok := objSrv.objects[hashVal]; ok {
+			continue
+		}
+		if _, ok := objSrv.objectLocks[hashVal]; ok {
+			continue // Being added or deleted.
+		}
+		object := &objectType{hash: hashVal, size: size}
+		if err := objSrv.recordIndex(indexOpAdd, object); err != nil {
+			return numAdded, err
+		}
+		objSrv.add(object)
+		numAdded++
+	}
+	if numAdded > 0 {
+		objSrv.Logger.Printf("Added %d objects missing from the index\n",
+			numAdded)
+	}
+	return numAdded, nil
+}
+
+// This must be called with the lock held.
+func (objSrv *ObjectServer) recordIndex(op byte, object *objectType) error {
+	_, err := objSrv.indexFile.Write(encodeRecord(op, object.hash,
+		object.size))
+	if err != nil {
+		return err
+	}
+	return fsutil.FsyncFile(objSrv.indexFile)
+}
+
+// writeIndex writes the objects in order of when they were added and opens the
+// index for appending.
+func (objSrv *ObjectServer) writeIndex(filename string) error {
+	writer, err := fsutil.CreateRenamingWriter(filename,
+		fsutil.PrivateFilePerms)
+	if err != nil {
+		return err
+	}
+	bufferedWriter := bufio.NewWriter(writer)
+	// All objects are unreferenced at this point.
+	for ob := objSrv.oldestUnreferenced; ob != nil; ob = ob.newerUnreferenced {
+		_, err := bufferedWriter.Write(encodeRecord(indexOpAdd, ob.hash,
+			ob.size))
+		if err != nil {
+			writer.Abort()
+			writer.Close()
+			return err
+		}
+	}
+	if err := bufferedWriter.Flush(); err != nil {
+		writer.Abort()
+		writer.Close()
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	objSrv.indexFile, err = os.OpenFile(filename, os.O_APPEND|os.O_WRONLY, 0)
+	return err
+}
@@ -0,0 +1,85 @@
+package s3
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/format"
+	"github.com/Cloud-Foundations/Dominator/lib/fsutil"
+	"github.com/Cloud-Foundations/Dominator/lib/hash"
+	"github.com/Cloud-Foundations/Dominator/lib/lockwatcher"
+	"github.com/Cloud-Foundations/Dominator/lib/log/prefixlogger"
+	"github.com/Cloud-Foundations/Dominator/lib/objectserver/cachingreader"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	awss3 "github.com/aws/aws-sdk-go/service/s3"
+)
+
+const (
+	cacheDirectory = "cache"
+	defaultRegion  = "us-east-1"
+)
+
+func newObjectServer(config Config, params Params) (*ObjectServer, error) {
+	if config.Bucket == "" {
+		return nil, errors.New("no bucket specified")
+	}
+	if config.Region == "" {
+		config.Region = defaultRegion
+	}
+	awsConfig := aws.Config{Region: aws.String(config.Region)}
+	if config.Endpoint != "" {
+		// S3-compatible servers generally do not support virtual hosting.
+		awsConfig.Endpoint = aws.String(config.Endpoint)
+		awsConfig.S3ForcePathStyle = aws.Bool(true)
+	}
+	awsSession, err := session.NewSessionWithOptions(session.Options{
+		Config:            awsConfig,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = os.MkdirAll(filepath.Join(config.BaseDirectory, cacheDirectory),
+		fsutil.PrivateDirPerms)
+	if err != nil {
+		return nil, err
+	}
+	objSrv := &ObjectServer{
+		client:      awss3.New(awsSession),
+		Config:      config,
+		Params:      params,
+		objectLocks: make(map[hash.Hash]chan struct{}),
+		objects:     make(map[hash.Hash]*objectType),
+	}
+	startTime := time.Now()
+	if err := objSrv.loadIndex(); err != nil {
+		return nil, err
+	}
+	plural := ""
+	if len(objSrv.objects) != 1 {
+		plural = "s"
+	}
+	params.Logger.Printf("Loaded index of %d object%s (%s) in %s\n",
+		len(objSrv.objects), plural, format.FormatBytes(objSrv.totalBytes),
+		format.Duration(time.Since(startTime)))
+	if config.MaxCachedBytes > 0 {
+		objSrv.cache, err = cachingreader.NewObjectServerWithUpstream(
+			filepath.Join(config.BaseDirectory, cacheDirectory),
+			config.MaxCachedBytes, bucketGetter{objSrv},
+			prefixlogger.New("Cache: ", params.Logger))
+		if err != nil {
+			objSrv.indexFile.Close()
+			return nil, err
+		}
+	}
+	objSrv.lockWatcher = lockwatcher.New(&objSrv.rwLock,
+		lockwatcher.LockWatcherOptions{
+			CheckInterval: config.LockCheckInterval,
+			Logger:        prefixlogger.New("ObjectServer: ", params.Logger),
+			LogTimeout:    config.LockLogTimeout,
+		})
+	return objSrv, nil
+}
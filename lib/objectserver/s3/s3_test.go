@@ -0,0 +1,302 @@
+package s3
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Cloud-Foundations/Dominator/lib/hash"
+	"github.com/Cloud-Foundations/Dominator/lib/log/testlogger"
+)
+
+// fakeBucket is a minimal S3-compatible server with a single bucket, which
+// supports the requests made by the ObjectServer using path-style addressing.
+type fakeBucket struct {
+	name    string
+	mutex   sync.Mutex
+	objects map[string][]byte
+}
+
+type hashList []hash.Hash
+
+type listBucketResult struct {
+	XMLName     xml.Name `xml:"ListBucketResult"`
+	Name        string
+	Prefix      string
+	KeyCount    int
+	IsTruncated bool
+	Contents    []listBucketObject
+}
+
+type listBucketObject struct {
+	Key  string
+	Size int
+}
+
+func (b *fakeBucket) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(req.URL.Path, "/"), "/")
+	if bucket != b.name {
+		http.Error(w, "NoSuchBucket", http.StatusNotFound)
+		return
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	switch {
+	case req.Method == http.MethodGet && key == "":
+		prefix := req.URL.Query().Get("prefix")
+		result := listBucketResult{Name: bucket, Prefix: prefix}
+		for key, data := range b.objects {
+			if strings.HasPrefix(key, prefix) {
+				result.Contents = append(result.Contents,
+					listBucketObject{Key: key, Size: len(data)})
+			}
+		}
+		sort.Slice(result.Contents, func(left, right int) bool {
+			return result.Contents[left].Key < result.Contents[right].Key
+		})
+		result.KeyCount = len(result.Contents)
+		xml.NewEncoder(w).Encode(result)
+	case req.Method == http.MethodGet:
+		data, ok := b.objects[key]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	case req.Method == http.MethodPut:
+		data, err := io.ReadAll(req.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		b.objects[key] = data
+	case req.Method == http.MethodDelete:
+		delete(b.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "NotImplemented", http.StatusNotImplemented)
+	}
+}
+
+func (b *fakeBucket) numObjects() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return len(b.objects)
+}
+
+func (list hashList) ForEachObject(objectFunc func(hash.Hash) error) error {
+	for _, hashVal := range list {
+		if err := objectFunc(hashVal); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func addObject(t *testing.T, objSrv *ObjectServer, data string) hash.Hash {
+	hashVal, isNew, err := objSrv.AddObject(strings.NewReader(data),
+		uint64(len(data)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isNew {
+		t.Fatalf("object: \"%s\" is not new", data)
+	}
+	return hashVal
+}
+
+func checkObject(t *testing.T, objSrv *ObjectServer, hashVal hash.Hash,
+	data string) {
+	for count := 0; count < 2; count++ { // Second read may be from the cache.
+		size, reader, err := objSrv.GetObject(hashVal)
+		if err != nil {
+			t.Fatal(err)
+		}
+		readData, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size != uint64(len(data)) || string(readData) != data {
+			t.Fatalf("read: \"%s\", expected: \"%s\"", readData, data)
+		}
+	}
+}
+
+func newTestObjectServer(t *testing.T, endpoint, baseDir string) *ObjectServer {
+	objSrv, err := NewObjectServer(
+		Config{
+			BaseDirectory:  baseDir,
+			Bucket:         "test",
+			Endpoint:       endpoint,
+			KeyPrefix:      "objects/",
+			MaxCachedBytes: 1 << 20,
+		},
+		Params{Logger: testlogger.New(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return objSrv
+}
+
+// startFakeBucket starts a fake S3-compatible server with a bucket containing
+// a key which is not an object.
+func startFakeBucket(t *testing.T) (*fakeBucket, *httptest.Server) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE",
+		filepath.Join(t.TempDir(), "credentials"))
+	bucket := &fakeBucket{name: "test", objects: make(map[string][]byte)}
+	bucket.objects["README"] = []byte("not an object")
+	server := httptest.NewServer(bucket)
+	t.Cleanup(server.Close)
+	return bucket, server
+}
+
+func TestObjectServer(t *testing.T) {
+	bucket, server := startFakeBucket(t)
+	baseDir := t.TempDir()
+	objSrv := newTestObjectServer(t, server.URL, baseDir)
+	hash0 := addObject(t, objSrv, "object zero")
+	hash1 := addObject(t, objSrv, "object one")
+	_, data, err := objSrv.StashOrVerifyObject(
+		bytes.NewReader([]byte("object two")), 10, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash2, _, _ := objSrv.StashOrVerifyObject(bytes.NewReader(data), 10, nil)
+	if err := objSrv.CommitObject(hash2); err != nil {
+		t.Fatal(err)
+	}
+	if num := bucket.numObjects(); num != 4 {
+		t.Fatalf("%d objects in bucket, expected 4", num)
+	}
+	checkObject(t, objSrv, hash0, "object zero")
+	checkObject(t, objSrv, hash2, "object two")
+	err = objSrv.AdjustRefcounts(true, hashList{hash0, hash2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unreferenced := objSrv.ListUnreferenced(); len(unreferenced) != 1 {
+		t.Fatalf("%d unreferenced objects, expected 1", len(unreferenced))
+	} else if _, ok := unreferenced[hash1]; !ok {
+		t.Fatal("object one is not unreferenced")
+	}
+	_, numDeleted, err := objSrv.DeleteUnreferenced(100, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if numDeleted != 1 {
+		t.Fatalf("deleted %d objects, expected 1", numDeleted)
+	}
+	if _, _, err := objSrv.GetObject(hash1); err == nil {
+		t.Fatal("no error getting deleted object")
+	}
+	if num := bucket.numObjects(); num != 3 {
+		t.Fatalf("%d objects in bucket, expected 3", num)
+	}
+	objSrv.indexFile.Close()
+	// Load from the index.
+	objSrv = newTestObjectServer(t, server.URL, baseDir)
+	if num := objSrv.NumObjects(); num != 2 {
+		t.Fatalf("%d objects after loading index, expected 2", num)
+	}
+	checkObject(t, objSrv, hash2, "object two")
+	objSrv.indexFile.Close()
+	// Rebuild the index by listing the bucket.
+	if err := os.Remove(filepath.Join(baseDir, indexFilename)); err != nil {
+		t.Fatal(err)
+	}
+	objSrv = newTestObjectServer(t, server.URL, baseDir)
+	if num := objSrv.NumObjects(); num != 2 {
+		t.Fatalf("%d objects after listing bucket, expected 2", num)
+	}
+	checkObject(t, objSrv, hash0, "object zero")
+	objSrv.indexFile.Close()
+}
+
+func TestIndexReload(t *testing.T) {
+	_, server := startFakeBucket(t)
+	baseDir := t.TempDir()
+	objSrv := newTestObjectServer(t, server.URL, baseDir)
+	hash0 := addObject(t, objSrv, "object zero")
+	hash1 := addObject(t, objSrv, "object one")
+	if err := objSrv.DeleteObject(hash0); err != nil {
+		t.Fatal(err)
+	}
+	// A partially written record is ignored.
+	if _, err := objSrv.indexFile.Write([]byte{indexOpAdd, 1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	objSrv.indexFile.Close()
+	objSrv = newTestObjectServer(t, server.URL, baseDir)
+	defer objSrv.indexFile.Close()
+	if num := objSrv.NumObjects(); num != 1 {
+		t.Fatalf("%d objects after loading index, expected 1", num)
+	}
+	if sizes, _ := objSrv.CheckObjects([]hash.Hash{hash0}); sizes[0] != 0 {
+		t.Fatal("deleted object loaded from index")
+	}
+	checkObject(t, objSrv, hash1, "object one")
+	fi, err := os.Stat(filepath.Join(baseDir, indexFilename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() != int64(recordLength) {
+		t.Fatalf("index size: %d, expected compacted size: %d",
+			fi.Size(), recordLength)
+	}
+}
+
+func TestLostIndexRecord(t *testing.T) {
+	_, server := startFakeBucket(t)
+	objSrv := newTestObjectServer(t, server.URL, t.TempDir())
+	defer objSrv.indexFile.Close()
+	hash0 := addObject(t, objSrv, "object zero")
+	// Simulate an object which was uploaded but not recorded in the index.
+	hash1 := addObject(t, objSrv, "object one")
+	objSrv.rwLock.Lock()
+	objSrv.remove(objSrv.objects[hash1])
+	objSrv.rwLock.Unlock()
+	err := objSrv.AdjustRefcounts(true, hashList{hash0, hash1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unreferenced := objSrv.ListUnreferenced(); len(unreferenced) != 0 {
+		t.Fatalf("%d unreferenced objects, expected 0", len(unreferenced))
+	}
+	checkObject(t, objSrv, hash1, "object one")
+	// Objects missing from the bucket are still reported.
+	missing := hash.Hash{1}
+	err = objSrv.AdjustRefcounts(true, hashList{hash0, missing})
+	if err == nil {
+		t.Fatal("no error for missing object")
+	}
+	if unreferenced := objSrv.ListUnreferenced(); len(unreferenced) != 0 {
+		t.Fatalf("%d unreferenced objects, expected 0", len(unreferenced))
+	}
+}
+
+func TestIndexWriteFailure(t *testing.T) {
+	_, server := startFakeBucket(t)
+	objSrv := newTestObjectServer(t, server.URL, t.TempDir())
+	objSrv.indexFile.Close()
+	data := "object zero"
+	hashVal, _, err := objSrv.AddObject(strings.NewReader(data),
+		uint64(len(data)), nil)
+	if err == nil {
+		t.Fatal("no error when the index cannot be written")
+	}
+	if sizes, _ := objSrv.CheckObjects([]hash.Hash{hashVal}); sizes[0] != 0 {
+		t.Fatal("object which is not in the index is available")
+	}
+}
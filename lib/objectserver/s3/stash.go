@@ -0,0 +1,75 @@
+package s3
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/Cloud-Foundations/Dominator/lib/fsutil"
+	"github.com/Cloud-Foundations/Dominator/lib/hash"
+	"github.com/Cloud-Foundations/Dominator/lib/objectcache"
+)
+
+const stashDirectory = ".stash"
+
+func (objSrv *ObjectServer) commitObject(hashVal hash.Hash) error {
+	stashFilename := objSrv.getStashFilename(hashVal)
+	file, err := os.Open(stashFilename)
+	if err != nil {
+		if length, _ := objSrv.checkObject(hashVal); length > 0 {
+			return nil // Previously committed: return success.
+		}
+		return err
+	}
+	defer file.Close()
+	fi, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	isNew, err := objSrv.addData(hashVal, file, uint64(fi.Size()))
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(stashFilename); err != nil {
+		return err
+	}
+	if objSrv.addCallback != nil {
+		objSrv.addCallback(hashVal, uint64(fi.Size()), isNew)
+	}
+	return nil
+}
+
+func (objSrv *ObjectServer) deleteStashedObject(hashVal hash.Hash) error {
+	return os.Remove(objSrv.getStashFilename(hashVal))
+}
+
+func (objSrv *ObjectServer) getStashFilename(hashVal hash.Hash) string {
+	return filepath.Join(objSrv.BaseDirectory, stashDirectory,
+		objectcache.HashToFilename(hashVal))
+}
+
+func (objSrv *ObjectServer) stashOrVerifyObject(reader io.Reader,
+	length uint64, expectedHash *hash.Hash) (hash.Hash, []byte, error) {
+	hashVal, data, err := objectcache.ReadObject(reader, length, expectedHash)
+	if err != nil {
+		return hashVal, nil, err
+	}
+	if exists, err := objSrv.checkExisting(hashVal,
+		uint64(len(data))); err != nil {
+		return hashVal, nil, err
+	} else if exists {
+		return hashVal, nil, nil
+	}
+	stashFilename := objSrv.getStashFilename(hashVal)
+	err = os.MkdirAll(filepath.Dir(stashFilename), fsutil.PrivateDirPerms)
+	if err != nil {
+		return hashVal, nil, err
+	}
+	err = fsutil.CopyToFile(stashFilename, fsutil.PrivateFilePerms,
+		bytes.NewReader(data), uint64(len(data)))
+	if err != nil {
+		return hashVal, nil, err
+	}
+	return hashVal, data, nil
+}
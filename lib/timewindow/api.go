@@ -0,0 +1,57 @@
+/*
+Package timewindow supports recurring weekly time windows, such as
+maintenance windows.
+
+A window specification has the form:
+
+	[days] HH:MM-HH:MM [zone]
+
+where days is an optional comma-separated list of day names or ranges of
+day names (e.g. "Tue,Thu" or "Mon-Fri"), and zone is an optional time zone
+name (e.g. "UTC" or "America/New_York"). If days are not specified the
+window recurs every day. If the zone is not specified, UTC is used. If the
+end time is not after the start time, the window ends on the following
+day. Multiple windows may be specified, separated by semicolons. For
+example:
+
+	Tue,Thu 02:00-05:00 UTC; Sat 22:00-02:00 UTC
+*/
+package timewindow
+
+import (
+	"time"
+)
+
+type Windows struct {
+	specification string
+	windows       []window
+}
+
+type window struct {
+	days     [7]bool // Index: time.Weekday.
+	start    time.Duration
+	duration time.Duration
+	location *time.Location
+}
+
+// Parse parses a specification for one or more windows.
+func Parse(specification string) (*Windows, error) {
+	return parse(specification)
+}
+
+// Contains returns true if t is inside one of the windows.
+func (w *Windows) Contains(t time.Time) bool {
+	start, _ := w.next(t)
+	return !start.After(t)
+}
+
+// Next returns the start and end times of the window which contains t, or if
+// t is not inside a window, of the next window.
+func (w *Windows) Next(t time.Time) (time.Time, time.Time) {
+	return w.next(t)
+}
+
+// String returns the specification for the windows.
+func (w *Windows) String() string {
+	return w.specification
+}
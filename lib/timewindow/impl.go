@@ -0,0 +1,160 @@
+package timewindow
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var dayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+func parse(specification string) (*Windows, error) {
+	w := &Windows{specification: strings.TrimSpace(specification)}
+	for _, spec := range strings.Split(specification, ";") {
+		win, err := parseWindow(strings.Fields(spec))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", strings.TrimSpace(spec), err)
+		}
+		w.windows = append(w.windows, win)
+	}
+	return w, nil
+}
+
+func parseDay(name string) (time.Weekday, error) {
+	if len(name) < 3 {
+		return 0, fmt.Errorf("bad day: %s", name)
+	}
+	if day, ok := dayNames[strings.ToLower(name[:3])]; ok {
+		return day, nil
+	}
+	return 0, fmt.Errorf("bad day: %s", name)
+}
+
+func parseDays(value string) ([7]bool, error) {
+	var days [7]bool
+	for _, field := range strings.Split(value, ",") {
+		first, last, isRange := strings.Cut(field, "-")
+		firstDay, err := parseDay(first)
+		if err != nil {
+			return days, err
+		}
+		if !isRange {
+			days[firstDay] = true
+			continue
+		}
+		lastDay, err := parseDay(last)
+		if err != nil {
+			return days, err
+		}
+		for day := firstDay; ; day = (day + 1) % 7 {
+			days[day] = true
+			if day == lastDay {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+func parseTimeOfDay(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("bad time: %s", value)
+	}
+	return time.Duration(t.Hour())*time.Hour +
+		time.Duration(t.Minute())*time.Minute, nil
+}
+
+func parseWindow(fields []string) (window, error) {
+	var win window
+	if len(fields) < 1 {
+		return win, errors.New("empty window")
+	}
+	if strings.Contains(fields[0], ":") {
+		for index := range win.days {
+			win.days[index] = true
+		}
+	} else {
+		days, err := parseDays(fields[0])
+		if err != nil {
+			return win, err
+		}
+		win.days = days
+		fields = fields[1:]
+		if len(fields) < 1 {
+			return win, errors.New("missing times")
+		}
+	}
+	startString, endString, ok := strings.Cut(fields[0], "-")
+	if !ok {
+		return win, fmt.Errorf("bad time range: %s", fields[0])
+	}
+	start, err := parseTimeOfDay(startString)
+	if err != nil {
+		return win, err
+	}
+	end, err := parseTimeOfDay(endString)
+	if err != nil {
+		return win, err
+	}
+	win.start = start
+	win.duration = end - start
+	if win.duration <= 0 {
+		win.duration += 24 * time.Hour
+	}
+	win.location = time.UTC
+	switch len(fields) {
+	case 1:
+	case 2:
+		location, err := time.LoadLocation(fields[1])
+		if err != nil {
+			return win, err
+		}
+		win.location = location
+	default:
+		return win, errors.New("too many fields")
+	}
+	return win, nil
+}
+
+func (w *Windows) next(t time.Time) (time.Time, time.Time) {
+	var nextStart, nextEnd time.Time
+	for _, win := range w.windows {
+		start, end := win.next(t)
+		if nextStart.IsZero() || start.Before(nextStart) {
+			nextStart = start
+			nextEnd = end
+		}
+	}
+	return nextStart, nextEnd
+}
+
+func (win window) next(t time.Time) (time.Time, time.Time) {
+	local := t.In(win.location)
+	// Start from the previous day, since a window may span midnight.
+	year, month, day := local.AddDate(0, 0, -1).Date()
+	for offset := 0; offset < 9; offset++ {
+		date := time.Date(year, month, day+offset, 0, 0, 0, 0, win.location)
+		if !win.days[date.Weekday()] {
+			continue
+		}
+		hours := int(win.start / time.Hour)
+		minutes := int((win.start % time.Hour) / time.Minute)
+		start := time.Date(year, month, day+offset, hours, minutes, 0, 0,
+			win.location)
+		end := start.Add(win.duration)
+		if end.After(t) {
+			return start, end
+		}
+	}
+	return time.Time{}, time.Time{}
+}
@@ -0,0 +1,64 @@
+package timewindow
+
+import (
+	"testing"
+	"time"
+)
+
+func mustParseTime(t *testing.T, value string) time.Time {
+	result, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestNext(t *testing.T) {
+	w, err := Parse("Tue,Thu 02:00-05:00 UTC; Sat 22:00-02:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		now, start, end string
+		contains        bool
+	}{
+		// 2024-01-01 is a Monday.
+		{"2024-01-01T12:00:00Z", "2024-01-02T02:00:00Z",
+			"2024-01-02T05:00:00Z", false},
+		{"2024-01-02T03:00:00Z", "2024-01-02T02:00:00Z",
+			"2024-01-02T05:00:00Z", true},
+		{"2024-01-02T05:00:00Z", "2024-01-04T02:00:00Z",
+			"2024-01-04T05:00:00Z", false},
+		{"2024-01-05T00:00:00Z", "2024-01-06T22:00:00Z",
+			"2024-01-07T02:00:00Z", false},
+		{"2024-01-07T01:00:00Z", "2024-01-06T22:00:00Z",
+			"2024-01-07T02:00:00Z", true},
+	}
+	for _, test := range tests {
+		now := mustParseTime(t, test.now)
+		start, end := w.Next(now)
+		if !start.Equal(mustParseTime(t, test.start)) ||
+			!end.Equal(mustParseTime(t, test.end)) {
+			t.Errorf("%s: expected %s-%s, got %s-%s",
+				test.now, test.start, test.end, start, end)
+		}
+		if w.Contains(now) != test.contains {
+			t.Errorf("%s: expected contains=%v", test.now, test.contains)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, spec := range []string{"02:00-05:00", "Mon-Fri 22:00-06:00",
+		"Sat,Sun 00:00-00:00 America/New_York"} {
+		if _, err := Parse(spec); err != nil {
+			t.Errorf("%s: %s", spec, err)
+		}
+	}
+	for _, spec := range []string{"", "Tue", "Tue 02:00", "Foo 02:00-03:00",
+		"Tue 25:00-26:00", "Tue 02:00-03:00 UTC extra", "Tue 02:00-03:00 Mars"} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("%s did not fail", spec)
+		}
+	}
+}
@@ -2,27 +2,75 @@ package triggers
 
 import (
 	"io"
+	"time"
 
 	"github.com/Cloud-Foundations/Dominator/lib/pathregexp"
 )
 
+// Actions which may be specified for a trigger. By default, the service is
+// stopped before files are changed and started afterwards. For other actions,
+// the service is not stopped and the action is performed afterwards. The
+// try-restart action only restarts the service if it is running.
+const (
+	ActionDefault         = ""
+	ActionReload          = "reload"
+	ActionReloadOrRestart = "reload-or-restart"
+	ActionRestart         = "restart"
+	ActionTryRestart      = "try-restart"
+)
+
+// Types of health probes.
+const (
+	HealthProbeCommand = "command"
+	HealthProbeHTTP    = "http"
+	HealthProbeTCP     = "tcp"
+)
+
+// DefaultHealthProbeTimeout is the time allowed for a health probe to succeed
+// if TimeoutSeconds is not specified.
+const DefaultHealthProbeTimeout = 30 * time.Second
+
+// HealthProbe checks whether a service is healthy after it was started. The
+// probe is retried until it succeeds or the timeout expires.
+type HealthProbe struct {
+	Type           string
+	Address        string `json:",omitempty"` // tcp: host:port to connect to.
+	URL            string `json:",omitempty"` // http: URL to GET.
+	ExpectedStatus int    `json:",omitempty"` // http: default 200.
+	Command        string `json:",omitempty"` // command: run with /bin/sh -c.
+	TimeoutSeconds uint   `json:",omitempty"`
+}
+
+func (probe *HealthProbe) String() string {
+	return probe.string()
+}
+
+// Timeout returns the time allowed for the probe to succeed.
+func (probe *HealthProbe) Timeout() time.Duration {
+	return probe.timeout()
+}
+
 type MergeableTriggers struct {
 	triggers map[string]*mergeableTrigger // Key: service name.
 }
 
 type mergeableTrigger struct {
-	matchLines map[string]struct{}
-	doReboot   bool
-	highImpact bool
+	matchLines   map[string]struct{}
+	action       string
+	doReboot     bool
+	healthProbes []HealthProbe
+	highImpact   bool
 }
 
 type Trigger struct {
 	MatchLines   []string
 	matchRegexes []pathregexp.Regexp
 	Service      string
-	SortName     string `json:",omitempty"`
-	DoReboot     bool   `json:",omitempty"`
-	HighImpact   bool   `json:",omitempty"`
+	SortName     string        `json:",omitempty"`
+	Action       string        `json:",omitempty"`
+	DoReboot     bool          `json:",omitempty"`
+	HealthProbes []HealthProbe `json:",omitempty"`
+	HighImpact   bool          `json:",omitempty"`
 }
 
 func (trigger *Trigger) RegisterStrings(registerFunc func(string)) {
@@ -0,0 +1,46 @@
+package triggers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+func (probe *HealthProbe) check() error {
+	switch probe.Type {
+	case HealthProbeCommand:
+		if probe.Command == "" {
+			return errors.New("command health probe: missing Command")
+		}
+	case HealthProbeHTTP:
+		if probe.URL == "" {
+			return errors.New("http health probe: missing URL")
+		}
+	case HealthProbeTCP:
+		if probe.Address == "" {
+			return errors.New("tcp health probe: missing Address")
+		}
+	default:
+		return fmt.Errorf("unknown health probe type: %s", probe.Type)
+	}
+	return nil
+}
+
+func (probe *HealthProbe) string() string {
+	switch probe.Type {
+	case HealthProbeCommand:
+		return "command: " + probe.Command
+	case HealthProbeHTTP:
+		return "http: " + probe.URL
+	case HealthProbeTCP:
+		return "tcp: " + probe.Address
+	}
+	return probe.Type
+}
+
+func (probe *HealthProbe) timeout() time.Duration {
+	if probe.TimeoutSeconds < 1 {
+		return DefaultHealthProbeTimeout
+	}
+	return time.Duration(probe.TimeoutSeconds) * time.Second
+}
@@ -3,6 +3,7 @@ package triggers
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 
@@ -23,6 +24,9 @@ func decode(jsonData []byte) (*Triggers, error) {
 	if err := json.Unmarshal(jsonData, &trig.Triggers); err != nil {
 		return nil, errors.New("error decoding triggers " + err.Error())
 	}
+	if err := trig.check(); err != nil {
+		return nil, err
+	}
 	return &trig, nil
 }
 
@@ -31,5 +35,26 @@ func read(reader io.Reader) (*Triggers, error) {
 	if err := libjson.Read(reader, &trig.Triggers); err != nil {
 		return nil, errors.New("error decoding triggers " + err.Error())
 	}
+	if err := trig.check(); err != nil {
+		return nil, err
+	}
 	return &trig, nil
 }
+
+func (triggers *Triggers) check() error {
+	for _, trigger := range triggers.Triggers {
+		switch trigger.Action {
+		case ActionDefault, ActionReload, ActionReloadOrRestart, ActionRestart,
+			ActionTryRestart:
+		default:
+			return fmt.Errorf("service: %s: unknown action: %s",
+				trigger.Service, trigger.Action)
+		}
+		for _, probe := range trigger.HealthProbes {
+			if err := probe.check(); err != nil {
+				return fmt.Errorf("service: %s: %s", trigger.Service, err)
+			}
+		}
+	}
+	return nil
+}
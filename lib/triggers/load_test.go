@@ -0,0 +1,28 @@
+package triggers
+
+import (
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		json  string
+		valid bool
+	}{
+		{`[{"MatchLines": ["/etc/x"], "Service": "x"}]`, true},
+		{`[{"Service": "x", "Action": "reload"}]`, true},
+		{`[{"Service": "x", "Action": "reload-or-restart"}]`, true},
+		{`[{"Service": "x", "Action": "restart"}]`, true},
+		{`[{"Service": "x", "Action": "try-restart"}]`, true},
+		{`[{"Service": "x", "Action": "bounce"}]`, false},
+		{`[{"Service": "x", "HealthProbes": [{"Type": "bogus"}]}]`, false},
+	}
+	for _, test := range tests {
+		_, err := Decode([]byte(test.json))
+		if test.valid && err != nil {
+			t.Errorf("%s: %s", test.json, err)
+		} else if !test.valid && err == nil {
+			t.Errorf("%s: no error", test.json)
+		}
+	}
+}
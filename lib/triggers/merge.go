@@ -20,10 +20,12 @@ func (mt *MergeableTriggers) exportTriggers() *Triggers {
 		trigger := mt.triggers[service]
 		matchLines := stringutil.ConvertMapKeysToList(trigger.matchLines, true)
 		triggerList = append(triggerList, &Trigger{
-			MatchLines: matchLines,
-			Service:    service,
-			DoReboot:   trigger.doReboot,
-			HighImpact: trigger.highImpact,
+			MatchLines:   matchLines,
+			Service:      service,
+			Action:       trigger.action,
+			DoReboot:     trigger.doReboot,
+			HealthProbes: trigger.healthProbes,
+			HighImpact:   trigger.highImpact,
 		})
 	}
 	triggers := New()
@@ -42,8 +44,11 @@ func (mt *MergeableTriggers) merge(triggers *Triggers) {
 		trig := mt.triggers[trigger.Service]
 		if trig == nil {
 			trig = new(mergeableTrigger)
+			trig.action = trigger.Action
 			trig.matchLines = make(map[string]struct{})
 			mt.triggers[trigger.Service] = trig
+		} else {
+			trig.action = mergeActions(trig.action, trigger.Action)
 		}
 		for _, matchLine := range trigger.MatchLines {
 			trig.matchLines[matchLine] = struct{}{}
@@ -51,8 +56,37 @@ func (mt *MergeableTriggers) merge(triggers *Triggers) {
 		if trigger.DoReboot {
 			trig.doReboot = true
 		}
+		for _, probe := range trigger.HealthProbes {
+			trig.addHealthProbe(probe)
+		}
 		if trigger.HighImpact {
 			trig.highImpact = true
 		}
 	}
 }
+
+// mergeActions returns an action which does at least what both actions do.
+// Stopping and starting the service (the default) satisfies any action and
+// restarting satisfies any action other than the default.
+func mergeActions(left, right string) string {
+	switch {
+	case left == right:
+		return left
+	case left == ActionDefault || right == ActionDefault:
+		return ActionDefault
+	case left == ActionReload:
+		return right
+	case right == ActionReload:
+		return left
+	}
+	return ActionRestart
+}
+
+func (trig *mergeableTrigger) addHealthProbe(probe HealthProbe) {
+	for _, oldProbe := range trig.healthProbes {
+		if oldProbe == probe {
+			return
+		}
+	}
+	trig.healthProbes = append(trig.healthProbes, probe)
+}
@@ -0,0 +1,59 @@
+package triggers
+
+import (
+	"testing"
+)
+
+func TestMergeActions(t *testing.T) {
+	tests := []struct {
+		left, right, expected string
+	}{
+		{ActionDefault, ActionDefault, ActionDefault},
+		{ActionReload, ActionReload, ActionReload},
+		{ActionDefault, ActionReload, ActionDefault},
+		{ActionRestart, ActionDefault, ActionDefault},
+		{ActionReload, ActionTryRestart, ActionTryRestart},
+		{ActionReloadOrRestart, ActionReload, ActionReloadOrRestart},
+		{ActionReloadOrRestart, ActionTryRestart, ActionRestart},
+		{ActionTryRestart, ActionRestart, ActionRestart},
+	}
+	for _, test := range tests {
+		if action := mergeActions(test.left, test.right); action !=
+			test.expected {
+			t.Errorf("merge(%q, %q): %q, expected: %q",
+				test.left, test.right, action, test.expected)
+		}
+		if action := mergeActions(test.right, test.left); action !=
+			test.expected {
+			t.Errorf("merge(%q, %q): %q, expected: %q",
+				test.right, test.left, action, test.expected)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	var mt MergeableTriggers
+	mt.Merge(&Triggers{Triggers: []*Trigger{
+		{MatchLines: []string{"/etc/a"}, Service: "a", Action: ActionReload},
+		{MatchLines: []string{"/etc/b"}, Service: "b"},
+	}})
+	mt.Merge(&Triggers{Triggers: []*Trigger{
+		{MatchLines: []string{"/etc/a2"}, Service: "a"},
+		{MatchLines: []string{"/etc/b2"}, Service: "b", Action: ActionReload},
+	}})
+	triggers := mt.ExportTriggers()
+	if len(triggers.Triggers) != 2 {
+		t.Fatalf("%d triggers, expected 2", len(triggers.Triggers))
+	}
+	// A default trigger in either image means the service must be stopped.
+	for _, trigger := range triggers.Triggers {
+		if trigger.Action != ActionDefault {
+			t.Errorf("service: %s: action: %s, expected default",
+				trigger.Service, trigger.Action)
+		}
+		if len(trigger.MatchLines) != 2 {
+			t.Errorf("service: %s: match lines: %v",
+				trigger.Service, trigger.MatchLines)
+		}
+	}
+}
@@ -109,6 +109,32 @@ func Ioctl(fd int, request, argp uintptr) error {
 	return ioctl(fd, request, argp)
 }
 
+// Lgetxattr gets the value of the extended attribute attr of the file at path,
+// without following symbolic links. If dest is empty, the size of the value is
+// returned.
+func Lgetxattr(path string, attr string, dest []byte) (int, error) {
+	return lgetxattr(path, attr, dest)
+}
+
+// Llistxattr lists the names of the extended attributes of the file at path,
+// without following symbolic links. The names are NUL-terminated. If dest is
+// empty, the size of the list is returned.
+func Llistxattr(path string, dest []byte) (int, error) {
+	return llistxattr(path, dest)
+}
+
+// Lremovexattr removes the extended attribute attr of the file at path,
+// without following symbolic links.
+func Lremovexattr(path string, attr string) error {
+	return lremovexattr(path, attr)
+}
+
+// Lsetxattr sets the value of the extended attribute attr of the file at path,
+// without following symbolic links.
+func Lsetxattr(path string, attr string, data []byte, flags int) error {
+	return lsetxattr(path, attr, data, flags)
+}
+
 func Lstat(path string, statbuf *Stat_t) error {
 	return lstat(path, statbuf)
 }
@@ -151,6 +151,22 @@ func ioctl(fd int, request, argp uintptr) error {
 	return nil
 }
 
+func lgetxattr(path string, attr string, dest []byte) (int, error) {
+	return 0, syscall.ENOTSUP
+}
+
+func llistxattr(path string, dest []byte) (int, error) {
+	return 0, syscall.ENOTSUP
+}
+
+func lremovexattr(path string, attr string) error {
+	return syscall.ENOTSUP
+}
+
+func lsetxattr(path string, attr string, data []byte, flags int) error {
+	return syscall.ENOTSUP
+}
+
 func lstat(path string, statbuf *Stat_t) error {
 	var rawStatbuf syscall.Stat_t
 	if err := syscall.Lstat(path, &rawStatbuf); err != nil {
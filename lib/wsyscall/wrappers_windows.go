@@ -70,6 +70,22 @@ func ioctl(fd int, request, argp uintptr) error {
 	return syscall.ENOTSUP
 }
 
+func lgetxattr(path string, attr string, dest []byte) (int, error) {
+	return 0, syscall.ENOTSUP
+}
+
+func llistxattr(path string, dest []byte) (int, error) {
+	return 0, syscall.ENOTSUP
+}
+
+func lremovexattr(path string, attr string) error {
+	return syscall.ENOTSUP
+}
+
+func lsetxattr(path string, attr string, data []byte, flags int) error {
+	return syscall.ENOTSUP
+}
+
 func lstat(path string, statbuf *Stat_t) error {
 	return syscall.ENOTSUP
 }
@@ -14,6 +14,7 @@ type Config struct {
 	AllowPublicAddObjects   bool
 	AllowPublicCheckObjects bool
 	AllowPublicGetObjects   bool
+	CompressObjects         bool // Compress objects sent and accept compressed.
 	ReplicationMaster       string
 }
 
@@ -23,6 +24,7 @@ type Params struct {
 }
 
 type srpcType struct {
+	compressObjects   bool
 	objectServer      objectserver.StashingObjectServer
 	replicationMaster string
 	getSemaphore      chan bool
@@ -40,6 +42,7 @@ func (hw *htmlWriter) WriteHtml(writer io.Writer) {
 func Setup(config Config, params Params) *htmlWriter {
 	getSemaphore := make(chan bool, 100)
 	srpcObj := &srpcType{
+		compressObjects:   config.CompressObjects,
 		objectServer:      params.ObjectServer,
 		replicationMaster: config.ReplicationMaster,
 		getSemaphore:      getSemaphore,
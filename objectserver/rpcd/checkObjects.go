@@ -1,6 +1,7 @@
 package rpcd
 
 import (
+	"github.com/Cloud-Foundations/Dominator/lib/objectserver/compression"
 	"github.com/Cloud-Foundations/Dominator/lib/srpc"
 	"github.com/Cloud-Foundations/Dominator/proto/objectserver"
 )
@@ -14,5 +15,8 @@ func (t *srpcType) CheckObjects(conn *srpc.Conn,
 		return err
 	}
 	reply.ObjectSizes = sizes
+	if t.compressObjects {
+		reply.Compressions = compression.Supported()
+	}
 	return nil
 }
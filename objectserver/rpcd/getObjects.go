@@ -7,6 +7,7 @@ import (
 	"sync"
 
 	"github.com/Cloud-Foundations/Dominator/lib/hash"
+	"github.com/Cloud-Foundations/Dominator/lib/objectserver/compression"
 	"github.com/Cloud-Foundations/Dominator/lib/srpc"
 	"github.com/Cloud-Foundations/Dominator/proto/objectserver"
 )
@@ -62,6 +63,11 @@ func (objSrv *srpcType) GetObjects(conn *srpc.Conn) error {
 		return conn.Encode(response)
 	}
 	defer objectsReader.Close()
+	compressionType := objectserver.CompressionNone
+	if objSrv.compressObjects {
+		compressionType = compression.Select(request.Compressions)
+	}
+	response.ObjectHeaders = compressionType != objectserver.CompressionNone
 	if err := conn.Encode(response); err != nil {
 		return err
 	}
@@ -73,6 +79,16 @@ func (objSrv *srpcType) GetObjects(conn *srpc.Conn) error {
 			objSrv.logger.Println(err)
 			return err
 		}
+		if response.ObjectHeaders {
+			err := compression.WriteObject(conn, conn, reader, length,
+				compressionType)
+			reader.Close()
+			if err != nil {
+				objSrv.logger.Printf("Error sending: %x: %s\n", hashVal, err)
+				return err
+			}
+			continue
+		}
 		nCopied, err := io.CopyBuffer(conn, reader, buffer)
 		reader.Close()
 		if err != nil {
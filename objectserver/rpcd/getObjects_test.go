@@ -0,0 +1,169 @@
+package rpcd
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/hash"
+	"github.com/Cloud-Foundations/Dominator/lib/log/testlogger"
+	oclient "github.com/Cloud-Foundations/Dominator/lib/objectserver/client"
+	"github.com/Cloud-Foundations/Dominator/lib/objectserver/memory"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	"github.com/Cloud-Foundations/Dominator/proto/objectserver"
+)
+
+type stashingObjectServer struct {
+	*memory.ObjectServer
+}
+
+func (objSrv stashingObjectServer) CommitObject(hash.Hash) error {
+	return nil
+}
+
+func (objSrv stashingObjectServer) DeleteStashedObject(hash.Hash) error {
+	return nil
+}
+
+func (objSrv stashingObjectServer) StashOrVerifyObject(io.Reader, uint64,
+	*hash.Hash) (hash.Hash, []byte, error) {
+	return hash.Hash{}, nil, nil
+}
+
+func makeGetObjectsServer(t *testing.T, objects ...[]byte) (
+	*srpcType, string, []hash.Hash) {
+	objSrv := memory.NewObjectServer()
+	var hashes []hash.Hash
+	for _, object := range objects {
+		hashVal, _, err := objSrv.AddObject(bytes.NewReader(object),
+			uint64(len(object)), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hashes = append(hashes, hashVal)
+	}
+	srpcObj := &srpcType{
+		objectServer: stashingObjectServer{objSrv},
+		getSemaphore: make(chan bool, 1),
+		logger:       testlogger.New(t),
+	}
+	srpc.RegisterName("ObjectServer", srpcObj)
+	listener, err := net.Listen("tcp", "localhost:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go http.Serve(listener, nil)
+	return srpcObj, listener.Addr().String(), hashes
+}
+
+// getObjectsRaw requests the objects with the specified compressions. If the
+// server does not send object headers, the raw object data are returned.
+func getObjectsRaw(t *testing.T, address string, hashes []hash.Hash,
+	compressions []objectserver.Compression) (bool, [][]byte) {
+	client, err := srpc.DialHTTP("tcp", address, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	conn, err := client.Call("ObjectServer.GetObjects")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	request := objectserver.GetObjectsRequest{
+		Compressions: compressions,
+		Hashes:       hashes,
+	}
+	if err := conn.Encode(request); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	var reply objectserver.GetObjectsResponse
+	if err := conn.Decode(&reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.ResponseString != "" {
+		t.Fatal(reply.ResponseString)
+	}
+	if reply.ObjectHeaders {
+		return true, nil
+	}
+	var objects [][]byte
+	for _, size := range reply.ObjectSizes {
+		object := make([]byte, size)
+		if _, err := io.ReadFull(conn, object); err != nil {
+			t.Fatal(err)
+		}
+		objects = append(objects, object)
+	}
+	return false, objects
+}
+
+func getObjectsWithClient(t *testing.T, address string,
+	hashes []hash.Hash) [][]byte {
+	client := oclient.NewObjectClient(address)
+	defer client.Close()
+	objectsReader, err := client.GetObjects(hashes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer objectsReader.Close()
+	var objects [][]byte
+	for range hashes {
+		_, reader, err := objectsReader.NextObject()
+		if err != nil {
+			t.Fatal(err)
+		}
+		object, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		objects = append(objects, object)
+	}
+	return objects
+}
+
+func checkObjects(t *testing.T, name string, objects, expected [][]byte) {
+	if len(objects) != len(expected) {
+		t.Fatalf("%s: %d objects, expected: %d",
+			name, len(objects), len(expected))
+	}
+	for index, object := range objects {
+		if !bytes.Equal(object, expected[index]) {
+			t.Errorf("%s: object %d corrupted", name, index)
+		}
+	}
+}
+
+func TestGetObjectsCompatibility(t *testing.T) {
+	objects := [][]byte{
+		bytes.Repeat([]byte("compressible "), 1000),
+		[]byte("small"),
+		bytes.Repeat([]byte("another compressible object "), 500),
+	}
+	srpcObj, address, hashes := makeGetObjectsServer(t, objects...)
+	gzip := []objectserver.Compression{objectserver.CompressionGzip}
+	for _, compressObjects := range []bool{false, true} {
+		srpcObj.compressObjects = compressObjects
+		// Old clients do not send compressions and expect raw data.
+		headers, readObjects := getObjectsRaw(t, address, hashes, nil)
+		if headers {
+			t.Fatalf("compress: %v: object headers sent to old client",
+				compressObjects)
+		}
+		checkObjects(t, "old client", readObjects, objects)
+		headers, _ = getObjectsRaw(t, address, hashes, gzip)
+		if headers != compressObjects {
+			t.Errorf("compress: %v: object headers: %v",
+				compressObjects, headers)
+		}
+		checkObjects(t, "new client",
+			getObjectsWithClient(t, address, hashes), objects)
+	}
+}
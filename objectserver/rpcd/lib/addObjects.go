@@ -56,9 +56,14 @@ func addObjects(conn *srpc.Conn, decoder srpc.Decoder, encoder srpc.Encoder,
 		if request.Length < 1 {
 			break
 		}
-		var err error
-		response.Hash, response.Added, err =
-			adder.AddObject(conn, request.Length, request.ExpectedHash)
+		reader, skip, err := newObjectReader(conn, request)
+		if err == nil {
+			response.Hash, response.Added, err =
+				adder.AddObject(reader, request.Length, request.ExpectedHash)
+		}
+		if e := skip(); e != nil {
+			return fmt.Errorf("error reading after %d objects: %s", numObj, e)
+		}
 		if err == nil && haveRefcounter {
 			err = refcountObject(refcounter, refcountedObjects, response.Hash)
 		}
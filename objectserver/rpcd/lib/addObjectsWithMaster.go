@@ -57,8 +57,14 @@ func addObjectsWithMaster(conn *srpc.Conn, decoder srpc.Decoder,
 			break
 		}
 		var data []byte
-		response.Hash, data, err = objSrv.StashOrVerifyObject(conn,
-			request.Length, request.ExpectedHash)
+		reader, skip, err := newObjectReader(conn, request)
+		if err == nil {
+			response.Hash, data, err = objSrv.StashOrVerifyObject(reader,
+				request.Length, request.ExpectedHash)
+		}
+		if e := skip(); e != nil && err == nil {
+			err = e
+		}
 		if err != nil {
 			sendError(outgoingQueueSendChan, err)
 			break
@@ -0,0 +1,29 @@
+package lib
+
+import (
+	"io"
+
+	"github.com/Cloud-Foundations/Dominator/lib/objectserver/compression"
+	proto "github.com/Cloud-Foundations/Dominator/proto/objectserver"
+)
+
+// newObjectReader returns a reader for the uncompressed object data which
+// follow the request and a function which skips any data not consumed. The
+// skip function must be called even if an error is returned.
+func newObjectReader(reader io.Reader, request proto.AddObjectRequest) (
+	io.Reader, func() error, error) {
+	if request.Compression == proto.CompressionNone {
+		return reader, func() error { return nil }, nil
+	}
+	limitedReader := &io.LimitedReader{
+		R: reader,
+		N: int64(request.CompressedLength),
+	}
+	skip := func() error {
+		_, err := io.Copy(io.Discard, limitedReader)
+		return err
+	}
+	objectReader, err := compression.NewReader(limitedReader,
+		request.Compression, request.CompressedLength, request.Length)
+	return objectReader, skip, err
+}
@@ -24,6 +24,7 @@ type DisruptionCheckRequest struct {
 // DisruptionCheck RPC response.
 type DisruptionCheckResponse struct {
 	Error    string
+	Message  string // Reason for denial, if any.
 	Response sub.DisruptionState
 }
 
@@ -35,6 +36,7 @@ type DisruptionRequest struct {
 
 // REST endpoint response.
 type DisruptionResponse struct {
+	Message  string `json:",omitempty"` // Reason for denial, if any.
 	Response sub.DisruptionState
 }
 
@@ -46,6 +48,7 @@ type DisruptionRequestRequest struct {
 // DisruptionRequest RPC response.
 type DisruptionRequestResponse struct {
 	Error    string
+	Message  string // Reason for denial, if any.
 	Response sub.DisruptionState
 }
 
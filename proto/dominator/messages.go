@@ -3,11 +3,29 @@ package dominator
 import (
 	"time"
 
+	"github.com/Cloud-Foundations/Dominator/lib/filesystem"
 	"github.com/Cloud-Foundations/Dominator/lib/mdb"
 	"github.com/Cloud-Foundations/Dominator/lib/tags"
+	"github.com/Cloud-Foundations/Dominator/lib/triggers"
 	"github.com/Cloud-Foundations/Dominator/proto/sub"
 )
 
+const (
+	UpdateOperationFetch      = "Fetch"
+	UpdateOperationFastUpdate = "FastUpdate"
+	UpdateOperationUpdate     = "Update"
+
+	UpdateResultDisruptionDenied  = "disruption denied"
+	UpdateResultDisruptionPending = "disruption pending"
+	UpdateResultFailed            = "failed"
+	UpdateResultFailedToStart     = "failed to start"
+	UpdateResultSucceeded         = "succeeded"
+)
+
+// NotLeaderErrorPrefix starts the error returned by a standby dominator for
+// requests other than GetLeader. It is followed by the address of the leader.
+const NotLeaderErrorPrefix = "not leader, leader is: "
+
 type ClearSafetyShutoffRequest struct {
 	Hostname string
 }
@@ -18,14 +36,22 @@ type ConfigureSubsRequest sub.Configuration
 
 type ConfigureSubsResponse struct{}
 
+// If none of the selectors are specified, updates are disabled for all subs.
 type DisableUpdatesRequest struct {
-	Reason string
+	ExpiresAt        time.Time // Zero: never expires.
+	Hostnames        []string  // Empty: match all hostnames.
+	LocationsToMatch []string  // Empty: match all locations.
+	Reason           string
+	TagsToMatch      tags.MatchTags // Empty: match all tags.
 }
 
-type DisableUpdatesResponse struct{}
+type DisableUpdatesResponse struct {
+	FreezeId uint64
+}
 
 type EnableUpdatesRequest struct {
-	Reason string
+	FreezeId uint64 // Zero: remove all freezes which cover all subs.
+	Reason   string
 }
 
 type EnableUpdatesResponse struct{}
@@ -57,10 +83,35 @@ type GetDefaultImageResponse struct {
 	ImageName string
 }
 
+type GetLeaderRequest struct{}
+
+type GetLeaderResponse struct {
+	Address  string // Empty if there is no known leader.
+	IsLeader bool   // True if the responding dominator is the leader.
+}
+
+type GetRolloutsRequest struct{}
+
+type GetRolloutsResponse struct {
+	Error    string
+	Rollouts []RolloutInfo
+}
+
 type GetSubsConfigurationRequest struct{}
 
 type GetSubsConfigurationResponse sub.Configuration
 
+type GetUpdateHistoryRequest struct {
+	Hostname   string    // Empty: match all subs.
+	MaxRecords uint      // Zero: default of 1000. The newest records are sent.
+	Since      time.Time // Zero: no limit. Compared with CompletionTime.
+}
+
+type GetUpdateHistoryResponse struct {
+	Error   string
+	Records []UpdateRecord // Oldest first.
+}
+
 type GetInfoForSubsRequest struct {
 	Hostnames        []string       // Empty: match all hostnames.
 	LocationsToMatch []string       // Empty: match all locations.
@@ -85,6 +136,53 @@ type ListSubsResponse struct {
 	Hostnames []string
 }
 
+type ListUpdateFreezesRequest struct{}
+
+type ListUpdateFreezesResponse struct {
+	Error   string
+	Freezes []UpdateFreeze
+}
+
+type PreviewUpdateRequest struct {
+	Hostname        string
+	Timeout         time.Duration // Default: 1 minute.
+	UsePlannedImage bool
+}
+
+type PreviewUpdateResponse struct {
+	BlockedBy         string                              `json:",omitempty"`
+	ComputedFiles     map[string]*filesystem.RegularInode `json:",omitempty"`
+	Error             string
+	ImageName         string
+	MatchedTriggers   []*triggers.Trigger `json:",omitempty"`
+	NumObjectsToFetch uint                `json:",omitempty"`
+	UpdateNeeded      bool
+	UpdateRequest     sub.UpdateRequest
+}
+
+type RolloutInfo struct {
+	Aborted               bool `json:",omitempty"`
+	CurrentWave           uint
+	Halted                bool `json:",omitempty"`
+	ImageName             string
+	MinimumSuccessPercent uint
+	NumFailed             uint
+	NumSubs               uint
+	NumSynced             uint
+	StartTime             time.Time
+	Waves                 []RolloutWaveInfo
+}
+
+type RolloutWaveInfo struct {
+	NumFailed uint
+	NumSubs   uint
+	NumSynced uint
+	Percent   uint          // Cumulative percentage of subs.
+	ReadyTime time.Time     `json:",omitempty"` // Success threshold reached.
+	SoakTime  time.Duration `json:",omitempty"`
+	StartTime time.Time     `json:",omitempty"`
+}
+
 type SetDefaultImageRequest struct {
 	ImageName string
 }
@@ -93,14 +191,72 @@ type SetDefaultImageResponse struct{}
 
 type SubInfo struct {
 	mdb.Machine
-	LastAddress         string              `json:",omitempty"`
-	LastNote            string              `json:",omitempty"`
-	LastDisruptionState sub.DisruptionState `json:",omitempty"`
-	LastScanDuration    time.Duration       `json:",omitempty"`
-	LastSuccessfulImage string              `json:",omitempty"`
-	LastSyncTime        time.Time           `json:",omitempty"`
-	LastUpdateTime      time.Time           `json:",omitempty"`
-	StartTime           time.Time           `json:",omitempty"`
-	Status              string
-	SystemUptime        *time.Duration `json:",omitempty"`
+	CorrectedFileCount    uint64              `json:",omitempty"`
+	NewFileCount          uint64              `json:",omitempty"`
+	LastAddress           string              `json:",omitempty"`
+	LastNote              string              `json:",omitempty"`
+	LastDisruptionState   sub.DisruptionState `json:",omitempty"`
+	LastScanDuration      time.Duration       `json:",omitempty"`
+	LastSuccessfulImage   string              `json:",omitempty"`
+	LastSyncTime          time.Time           `json:",omitempty"`
+	LastUpdateTime        time.Time           `json:",omitempty"`
+	MaintenanceWindow     string              `json:",omitempty"`
+	NextMaintenanceWindow time.Time           `json:",omitempty"` // Or now.
+	RollbackReason        string              `json:",omitempty"`
+	RolledBackFromImage   string              `json:",omitempty"`
+	StartTime             time.Time           `json:",omitempty"`
+	Status                string
+	SystemUptime          *time.Duration `json:",omitempty"`
+	UnsafeUpdateReason    string         `json:",omitempty"`
+	UpdatesDisabledReason string         `json:",omitempty"`
+}
+
+type UpdateFreeze struct {
+	CreatedAt        time.Time
+	CreatedBy        string    `json:",omitempty"`
+	ExpiresAt        time.Time `json:",omitempty"`
+	Hostnames        []string  `json:",omitempty"`
+	Id               uint64
+	LocationsToMatch []string `json:",omitempty"`
+	Reason           string
+	TagsToMatch      tags.MatchTags `json:",omitempty"`
+}
+
+type UpdateRecord struct {
+	CompletionTime      time.Time // Time the record was written.
+	Error               string    `json:",omitempty"`
+	ForcedDisruption    bool      `json:",omitempty"`
+	HealthProbeFailures bool      `json:",omitempty"`
+	Hostname            string
+	ImageName           string
+	NumChanged          uint `json:",omitempty"` // Paths made or changed.
+	NumDeleted          uint `json:",omitempty"` // Paths deleted.
+	NumObjects          uint `json:",omitempty"` // Objects to fetch.
+	Operation           string
+	Result              string
+	StartTime           time.Time
+	TriggerFailures     bool     `json:",omitempty"`
+	Triggers            []string `json:",omitempty"` // Services to restart.
+	Username            string   `json:",omitempty"`
+}
+
+// The WatchSubs() RPC is fully streamed.
+// The client sends a WatchSubsRequest message to the server.
+// The server sends a snapshot of the matching subs, followed by a message with
+// no Sub to signify the snapshot is complete, followed by a message each time
+// the Status, LastSuccessfulImage, LastUpdateTime, LastDisruptionState or MDB
+// data of a matching sub changes. A message is also sent when a sub starts or
+// stops matching.
+
+type WatchSubsRequest struct {
+	Hostnames        []string       // Empty: match all hostnames.
+	LocationsToMatch []string       // Empty: match all locations.
+	StatusesToMatch  []string       // Empty: match all statuses.
+	TagsToMatch      tags.MatchTags // Empty: match all tags.
+}
+
+type WatchSubsResponse struct { // Multiple responses are sent.
+	Deleted bool     // If true, the sub was removed from the MDB.
+	Error   string   // If non-empty, this is the final response.
+	Sub     *SubInfo // nil signifies initial snapshot is sent, changes follow.
 }
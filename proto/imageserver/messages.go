@@ -124,16 +124,25 @@ const (
 
 // The GetFilteredImageUpdates() RPC is fully streamed.
 // The client sends a GetFilteredImageUpdatesRequest message to the server.
-// The server sends a stream of ImageUpdate messages.
+// The server sends a stream of ImageUpdate messages. If Peer is true the client
+// is a peer which also accepts changes: it is not blocked while the server is
+// replicating and the initial list includes the images deleted after
+// DeletedSince. The SentAt field of the end of the initial list should be used
+// as DeletedSince when reconnecting.
 
 type GetFilteredImageUpdatesRequest struct {
+	DeletedSince   time.Time
 	IgnoreExpiring bool
+	Peer           bool
 }
 
 type ImageUpdate struct {
-	Name      string // "" signifies initial list is sent, changes to follow.
-	Directory *image.Directory
-	Operation uint
+	Name       string    // "" signifies initial list is sent, changes to follow.
+	CreatedOn  time.Time // OperationAddImage: when the image was created.
+	Directory  *image.Directory
+	ModifiedOn time.Time // OperationMakeDirectory: zero if unknown.
+	Operation  uint
+	SentAt     time.Time
 }
 
 type GetReplicationMasterRequest struct{}
@@ -143,6 +152,15 @@ type GetReplicationMasterResponse struct {
 	ReplicationMaster string
 }
 
+type GetRetentionReportRequest struct {
+	DirectoryName string // Empty: all directories with a retention policy.
+}
+
+type GetRetentionReportResponse struct {
+	Error string
+	RetentionReport
+}
+
 type ImageArchive struct {
 	ImageName string
 	image.Image
@@ -183,6 +201,13 @@ type MakeDirectoryRequest struct {
 
 type MakeDirectoryResponse struct{}
 
+// RetentionReport lists the images which would be deleted by the retention
+// policies if they were enforced now.
+type RetentionReport struct {
+	ImagesToDelete   []string
+	ImagesKeptForMdb []string // Only kept because they are in the MDB.
+}
+
 type RestoreImageFromArchiveRequest struct {
 	ExpiresAt   time.Time
 	ArchiveData []byte // GOB encoding of ImageArchive followed by HMAC.
@@ -192,3 +217,12 @@ type RestoreImageFromArchiveResponse struct {
 	Error             string
 	ReplicationMaster string // If not empty, go here instead.
 }
+
+type SetRetentionPolicyRequest struct {
+	DirectoryName   string
+	RetentionPolicy image.RetentionPolicy
+}
+
+type SetRetentionPolicyResponse struct {
+	Error string
+}
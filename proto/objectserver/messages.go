@@ -5,14 +5,27 @@ import (
 	"time"
 )
 
+const (
+	CompressionNone = Compression(0)
+	CompressionGzip = Compression(1)
+)
+
+// Compression is the compression applied to object data in transit. Hashes
+// are always computed over the uncompressed data.
+type Compression uint
+
 // The AddObjects() RPC requires the client to send a stream of AddObjectRequest
 // objects in Gob format. To signify the end of the stream, the client should
 // send an AddObjectRequest object with .Length == 0.
 // The server will send one AddObjectResponse for each AddObjectRequest, but it
 // will not flush the connection until the client signals the end of the stream.
+// Clients must only send compressed objects to servers which list the
+// compression in CheckObjectsResponse.Compressions.
 type AddObjectRequest struct {
-	Length       uint64
-	ExpectedHash *hash.Hash
+	Length           uint64 // Uncompressed length.
+	ExpectedHash     *hash.Hash
+	Compression      Compression
+	CompressedLength uint64 // Length of data sent if compressed.
 } // Object data are streamed afterwards.
 
 type AddObjectResponse struct {
@@ -26,20 +39,30 @@ type CheckObjectsRequest struct {
 }
 
 type CheckObjectsResponse struct {
-	ObjectSizes []uint64 // size == 0: object not found.
+	ObjectSizes  []uint64      // size == 0: object not found.
+	Compressions []Compression // Accepted by AddObjects.
 }
 
 // This is used in the special GetObjects streaming HTTP/RPC protocol.
 type GetObjectsRequest struct {
-	Exclusive bool // For initial performance benchmarking only.
-	Hashes    []hash.Hash
+	Exclusive    bool          // For initial performance benchmarking only.
+	Compressions []Compression // Accepted by the client, in order of preference.
+	Hashes       []hash.Hash
 }
 
 type GetObjectsResponse struct {
 	ResponseString string
-	ObjectSizes    []uint64
+	ObjectSizes    []uint64 // Uncompressed sizes.
+	ObjectHeaders  bool     // If true, each object is preceded by ObjectHeader.
 } // Object datas are streamed afterwards.
 
+// ObjectHeader is sent before each object in the GetObjects streaming protocol
+// if the server supports any of the compressions accepted by the client.
+type ObjectHeader struct {
+	Compression Compression
+	Length      uint64 // Length of data which follow.
+}
+
 type TestBandwidthRequest struct {
 	Duration     time.Duration // Ignored when sending to server.
 	ChunkSize    uint          // Maximum permitted: 65535.
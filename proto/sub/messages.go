@@ -21,6 +21,7 @@ const (
 
 	ErrorDisruptionPending = "disruption pending"
 	ErrorDisruptionDenied  = "disruption denied"
+	ErrorUpdateRolledBack  = "start triggers failed: update rolled back"
 )
 
 type BoostCpuLimitRequest struct{}
@@ -37,12 +38,20 @@ type CleanupRequest struct {
 
 type CleanupResponse struct{}
 
+type CorrectedFile struct {
+	Action    string // "changed", "deleted" or "made".
+	ImageName string
+	Pathname  string
+	Time      time.Time
+}
+
 type DisruptionRequest uint
 
 type DisruptionState uint
 
 type FetchRequest struct {
 	LockFor       time.Duration // Duration to lock other clients from mutating.
+	PeerAddress   string        // If set, try this sub first.
 	ServerAddress string
 	SpeedPercent  byte
 	Wait          bool
@@ -55,6 +64,15 @@ type FetchResponse struct {
 
 type GetConfigurationRequest struct{}
 
+type GetDriftReportRequest struct{}
+
+type GetDriftReportResponse struct {
+	CorrectedFiles []CorrectedFile // Oldest first.
+	NewFiles       []string        // Appeared since the last update.
+	NewFilesSince  time.Time       // Time of the baseline scan.
+	NumNewFiles    uint64          // May exceed the length of NewFiles.
+}
+
 type GetConfigurationResponse Configuration
 
 // The GetFiles() RPC is fully streamed.
@@ -68,6 +86,12 @@ type GetFileResponse struct {
 	Size  uint64
 } // File data are streamed afterwards.
 
+type HealthProbeResult struct {
+	Error   string // Empty if the service is healthy.
+	Probe   string
+	Service string
+}
+
 type PollRequest struct {
 	HaveGeneration uint64
 	LockFor        time.Duration
@@ -79,16 +103,21 @@ type PollResponse struct {
 	CurrentConfiguration         Configuration
 	FetchInProgress              bool // Fetch() and Update() mutually exclusive
 	UpdateInProgress             bool
+	HealthProbesInProgress       bool // Run after Update() has completed.
 	InitialImageName             string
 	LastFetchError               string
 	LastNote                     string // Updated after successful Update().
 	LastSuccessfulImageName      string
 	LastUpdateError              string
 	LastUpdateHadTriggerFailures bool
+	LastUpdateHealthProbeResults []HealthProbeResult
 	LastWriteError               string
 	LockedByAnotherClient        bool // Fetch() and Update() restricted.
 	LockedUntil                  time.Time
 	FreeSpace                    *uint64
+	ServesObjects                bool   // Peers may fetch objects from this sub.
+	CorrectedFileCount           uint64 // Drift corrected by updates.
+	NewFileCount                 uint64 // Appeared since the last update.
 	StartTime                    time.Time
 	PollTime                     time.Time
 	ScanCount                    uint64
@@ -101,6 +130,12 @@ type PollResponse struct {
 	ObjectCache                  objectcache.ObjectCache // Streamed separately.
 } // FileSystem is encoded afterwards, followed by ObjectCache.
 
+type RollbackRequest struct{}
+
+type RollbackResponse struct {
+	ImageName string // The image which was restored.
+}
+
 type SetConfigurationRequest Configuration
 
 type SetConfigurationResponse struct{}
@@ -119,6 +154,8 @@ type UpdateRequest struct {
 	InodesToChange      []Inode
 	MultiplyUsedObjects map[hash.Hash]uint64
 	Triggers            *triggers.Triggers
+	ComputedFiles       []string // Changes to these do not correct drift.
+	ImageFilterLines    []string // Unmanaged paths, which may not drift.
 }
 
 type UpdateResponse struct{}
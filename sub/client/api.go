@@ -44,11 +44,19 @@ func GetConfiguration(client *srpc.Client) (sub.Configuration, error) {
 	return getConfiguration(client)
 }
 
+func GetDriftReport(client *srpc.Client) (sub.GetDriftReportResponse, error) {
+	return getDriftReport(client)
+}
+
 func GetFiles(client *srpc.Client, filenames []string,
 	readerFunc func(reader io.Reader, size uint64) error) error {
 	return getFiles(client, filenames, readerFunc)
 }
 
+func Rollback(client *srpc.Client) (string, error) {
+	return rollback(client)
+}
+
 func SetConfiguration(client *srpc.Client, config sub.Configuration) error {
 	return setConfiguration(client, config)
 }
@@ -0,0 +1,13 @@
+package client
+
+import (
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	"github.com/Cloud-Foundations/Dominator/proto/sub"
+)
+
+func getDriftReport(client *srpc.Client) (sub.GetDriftReportResponse, error) {
+	var reply sub.GetDriftReportResponse
+	err := client.RequestReply("Subd.GetDriftReport",
+		sub.GetDriftReportRequest{}, &reply)
+	return reply, err
+}
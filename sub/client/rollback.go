@@ -0,0 +1,12 @@
+package client
+
+import (
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	"github.com/Cloud-Foundations/Dominator/proto/sub"
+)
+
+func rollback(client *srpc.Client) (string, error) {
+	var reply sub.RollbackResponse
+	err := client.RequestReply("Subd.Rollback", sub.RollbackRequest{}, &reply)
+	return reply.ImageName, err
+}
@@ -21,6 +21,7 @@ type UpdateOptions struct {
 	Logger            log.Logger
 	ObjectsDir        string
 	OldTriggers       *triggers.Triggers
+	RollbackDir       string // If set, save state so the update can be undone.
 	RootDirectoryName string
 	RunTriggers       TriggersRunner
 	SkipFilter        *filter.Filter
@@ -32,6 +33,15 @@ type uType struct {
 	lastError          error
 	hadTriggerFailures bool
 	fsChangeDuration   time.Duration
+	rollbackState      *rollbackState
+	savedPaths         map[string]struct{}
+}
+
+// GetRollbackAreaSize returns the disk space used by the rollback area in
+// rollbackDir. Files which are also linked outside the rollback area are not
+// counted, since removing the rollback area would not free their space.
+func GetRollbackAreaSize(rollbackDir string) (uint64, error) {
+	return getRollbackAreaSize(rollbackDir)
 }
 
 // MatchTriggersInUpdate will return a list of triggers in an update request
@@ -41,6 +51,16 @@ func MatchTriggersInUpdate(request sub.UpdateRequest) []*triggers.Trigger {
 	return matchTriggersInUpdate(request)
 }
 
+// Rollback will undo the last update which was made with the RollbackDir
+// option, restoring the saved state and running the triggers for the affected
+// services. It returns the name of the image prior to that update, and true if
+// there were trigger failures.
+func Rollback(options UpdateOptions) (string, bool, error) {
+	updateObj := &uType{UpdateOptions: options}
+	imageName, err := updateObj.rollback()
+	return imageName, updateObj.hadTriggerFailures, err
+}
+
 // Update is deprecated. Use UpdateWithOptions instead.
 func Update(request sub.UpdateRequest, rootDirectoryName string,
 	objectsDir string, oldTriggers *triggers.Triggers,
@@ -59,7 +79,9 @@ func Update(request sub.UpdateRequest, rootDirectoryName string,
 }
 
 // UpdateWithOptions will process an update request, modifying the local
-// file-system and running triggers.
+// file-system and running triggers. If options.RollbackDir is set, the inodes
+// which are replaced or deleted are saved first and if the start triggers fail,
+// the update is rolled back.
 func UpdateWithOptions(request sub.UpdateRequest, options UpdateOptions) (
 	bool, time.Duration, error) {
 	updateObj := &uType{UpdateOptions: options}
@@ -0,0 +1,451 @@
+package lib
+
+import (
+	"bufio"
+	"encoding/gob"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Cloud-Foundations/Dominator/lib/constants"
+	"github.com/Cloud-Foundations/Dominator/lib/filesystem"
+	"github.com/Cloud-Foundations/Dominator/lib/filesystem/scanner"
+	"github.com/Cloud-Foundations/Dominator/lib/fsutil"
+	"github.com/Cloud-Foundations/Dominator/lib/triggers"
+	"github.com/Cloud-Foundations/Dominator/lib/wsyscall"
+	"github.com/Cloud-Foundations/Dominator/proto/sub"
+)
+
+const (
+	rollbackDataDirectory = "data"
+	rollbackStateFile     = "state"
+)
+
+// The data for each saved entry are stored in the data directory, using the
+// index of the entry as the name.
+type rollbackEntry struct {
+	Name        string
+	LinkedFiles []linkedFile            // Saved files which are hardlinked.
+	Metadata    filesystem.GenericInode // If set, only the metadata changed.
+	Saved       bool                    // If false, the path was made.
+	Xattrs      map[string][]byte       // Only used with Metadata.
+}
+
+// linkedFile records the metadata of a saved regular file which is hardlinked
+// to the original, since the update may change the metadata of the original.
+type linkedFile struct {
+	Name     string // Relative to the saved entry, empty for the entry itself.
+	Metadata *filesystem.RegularInode
+	Xattrs   map[string][]byte
+}
+
+type rollbackState struct {
+	ImageName string // The patched image name prior to the update.
+	Entries   []rollbackEntry
+	Triggers  []*triggers.Trigger
+}
+
+// saveForRollback saves the inodes which the update will replace or delete,
+// and the metadata of the inodes which the update will change.
+func (t *uType) saveForRollback(request sub.UpdateRequest) error {
+	if err := fsutil.ForceRemoveAll(t.RollbackDir); err != nil {
+		return err
+	}
+	err := os.MkdirAll(filepath.Join(t.RollbackDir, rollbackDataDirectory),
+		fsutil.PrivateDirPerms)
+	if err != nil {
+		return err
+	}
+	t.rollbackState = &rollbackState{ImageName: t.readPatchedImageName()}
+	t.savedPaths = make(map[string]struct{})
+	for _, inode := range request.DirectoriesToMake {
+		if err := t.saveForRollbackPath(inode.Name, true); err != nil {
+			return err
+		}
+	}
+	for _, inode := range request.InodesToMake {
+		if err := t.saveForRollbackPath(inode.Name, false); err != nil {
+			return err
+		}
+	}
+	for _, hardlink := range request.HardlinksToMake {
+		if err := t.saveForRollbackPath(hardlink.NewLink, false); err != nil {
+			return err
+		}
+	}
+	for _, pathname := range request.PathsToDelete {
+		if err := t.saveForRollbackPath(pathname, false); err != nil {
+			return err
+		}
+	}
+	for _, inode := range request.InodesToChange {
+		if err := t.saveMetadataForRollback(inode.Name); err != nil {
+			return err
+		}
+	}
+	t.Logger.Printf("Saved: %d entries for rollback\n",
+		len(t.rollbackState.Entries))
+	return t.writeRollbackState()
+}
+
+// saveForRollbackPath saves the inode at pathname, or records that it does not
+// exist. If keepDirectory is true and the inode is a directory, only the
+// metadata are saved, since the directory will not be replaced. Sockets are
+// not saved, since they are made by the processes which listen on them.
+func (t *uType) saveForRollbackPath(pathname string, keepDirectory bool) error {
+	if _, ok := t.savedPaths[pathname]; ok {
+		return nil
+	}
+	if t.skipPath(pathname) {
+		return nil
+	}
+	t.savedPaths[pathname] = struct{}{}
+	fullPathname := filepath.Join(t.RootDirectoryName, pathname)
+	entry := rollbackEntry{Name: pathname}
+	var stat wsyscall.Stat_t
+	if err := wsyscall.Lstat(fullPathname, &stat); err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+	} else if stat.Mode&wsyscall.S_IFMT == wsyscall.S_IFSOCK {
+		t.Logger.Printf("Not saving socket: %s\n", fullPathname)
+	} else if keepDirectory && stat.Mode&wsyscall.S_IFMT == wsyscall.S_IFDIR {
+		if err := entry.saveMetadata(fullPathname, &stat); err != nil {
+			return err
+		}
+	} else {
+		err := saveTree(filepath.Join(t.RollbackDir, rollbackDataDirectory,
+			strconv.Itoa(len(t.rollbackState.Entries))), fullPathname, "",
+			&entry.LinkedFiles)
+		if err != nil {
+			return err
+		}
+		entry.Saved = true
+	}
+	t.rollbackState.Entries = append(t.rollbackState.Entries, entry)
+	return nil
+}
+
+// saveMetadataForRollback saves the metadata of the inode at pathname.
+func (t *uType) saveMetadataForRollback(pathname string) error {
+	if _, ok := t.savedPaths[pathname]; ok {
+		return nil
+	}
+	if t.skipPath(pathname) {
+		return nil
+	}
+	t.savedPaths[pathname] = struct{}{}
+	fullPathname := filepath.Join(t.RootDirectoryName, pathname)
+	var stat wsyscall.Stat_t
+	if err := wsyscall.Lstat(fullPathname, &stat); err != nil {
+		if os.IsNotExist(err) {
+			return nil // Nothing to restore.
+		}
+		return err
+	}
+	if stat.Mode&wsyscall.S_IFMT == wsyscall.S_IFSOCK {
+		return nil
+	}
+	entry := rollbackEntry{Name: pathname}
+	if err := entry.saveMetadata(fullPathname, &stat); err != nil {
+		return err
+	}
+	t.rollbackState.Entries = append(t.rollbackState.Entries, entry)
+	return nil
+}
+
+func (entry *rollbackEntry) saveMetadata(pathname string,
+	stat *wsyscall.Stat_t) error {
+	inode, err := readMetadata(pathname, stat)
+	if err != nil {
+		return err
+	}
+	entry.Metadata = inode
+	entry.Xattrs, err = fsutil.GetXattrs(pathname)
+	return err
+}
+
+// saveTree saves a copy of the tree at sourcePathname, which is at name within
+// the saved entry. Regular files are hardlinked where possible, since updates
+// replace rather than modify files. The metadata of hardlinked files are
+// appended to linkedFiles. Sockets are skipped.
+func saveTree(destPathname, sourcePathname, name string,
+	linkedFiles *[]linkedFile) error {
+	var stat wsyscall.Stat_t
+	if err := wsyscall.Lstat(sourcePathname, &stat); err != nil {
+		return err
+	}
+	if stat.Mode&wsyscall.S_IFMT == wsyscall.S_IFSOCK {
+		return nil
+	}
+	if stat.Mode&wsyscall.S_IFMT == wsyscall.S_IFREG {
+		xattrs, err := fsutil.GetXattrs(sourcePathname)
+		if err != nil {
+			return err
+		}
+		if err := os.Link(sourcePathname, destPathname); err == nil {
+			*linkedFiles = append(*linkedFiles, linkedFile{
+				Name:     name,
+				Metadata: scanner.MakeRegularInode(&stat),
+				Xattrs:   xattrs,
+			})
+			return nil
+		}
+		err = fsutil.CopyFile(destPathname, sourcePathname,
+			os.FileMode(stat.Mode)&os.ModePerm)
+		if err != nil {
+			return err
+		}
+	}
+	inode, err := readMetadata(sourcePathname, &stat)
+	if err != nil {
+		return err
+	}
+	xattrs, err := fsutil.GetXattrs(sourcePathname)
+	if err != nil {
+		return err
+	}
+	switch inode := inode.(type) {
+	case *filesystem.DirectoryInode:
+		if err := os.Mkdir(destPathname, fsutil.PrivateDirPerms); err != nil {
+			return err
+		}
+		names, err := fsutil.ReadDirnames(sourcePathname, false)
+		if err != nil {
+			return err
+		}
+		for _, entryName := range names {
+			err := saveTree(filepath.Join(destPathname, entryName),
+				filepath.Join(sourcePathname, entryName),
+				filepath.Join(name, entryName), linkedFiles)
+			if err != nil {
+				return err
+			}
+		}
+		// Write the metadata last, in case the directory is not writable.
+		inode.Xattrs = xattrs
+		return inode.WriteMetadata(destPathname)
+	case *filesystem.RegularInode:
+		inode.Xattrs = xattrs
+		return inode.WriteMetadata(destPathname)
+	case *filesystem.SymlinkInode:
+		if inode.Symlink, err = os.Readlink(sourcePathname); err != nil {
+			return err
+		}
+		inode.Xattrs = xattrs
+		return inode.Write(destPathname)
+	case *filesystem.SpecialInode:
+		inode.Xattrs = xattrs
+		return inode.Write(destPathname)
+	}
+	return errors.New("unsupported inode type: " + sourcePathname)
+}
+
+// readMetadata returns an inode with the metadata (excluding extended
+// attributes) of the inode at pathname.
+func readMetadata(pathname string,
+	stat *wsyscall.Stat_t) (filesystem.GenericInode, error) {
+	switch stat.Mode & wsyscall.S_IFMT {
+	case wsyscall.S_IFDIR:
+		return &filesystem.DirectoryInode{
+			Mode: filesystem.FileMode(stat.Mode),
+			Uid:  stat.Uid,
+			Gid:  stat.Gid,
+		}, nil
+	case wsyscall.S_IFREG:
+		return scanner.MakeRegularInode(stat), nil
+	case wsyscall.S_IFLNK:
+		return scanner.MakeSymlinkInode(stat), nil
+	case wsyscall.S_IFBLK, wsyscall.S_IFCHR, wsyscall.S_IFIFO:
+		return scanner.MakeSpecialInode(stat), nil
+	}
+	return nil, errors.New("unsupported inode type: " + pathname)
+}
+
+func getRollbackAreaSize(rollbackDir string) (uint64, error) {
+	type inodeType struct {
+		numLinks uint64 // Links within the rollback area.
+		stat     wsyscall.Stat_t
+	}
+	inodes := make(map[uint64]*inodeType)
+	err := filepath.Walk(rollbackDir,
+		func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			var stat wsyscall.Stat_t
+			if err := wsyscall.Lstat(path, &stat); err != nil {
+				return err
+			}
+			if inode, ok := inodes[stat.Ino]; ok {
+				inode.numLinks++
+			} else {
+				inodes[stat.Ino] = &inodeType{numLinks: 1, stat: stat}
+			}
+			return nil
+		})
+	if err != nil {
+		return 0, err
+	}
+	var size uint64
+	for _, inode := range inodes {
+		if inode.stat.Mode&wsyscall.S_IFMT == wsyscall.S_IFDIR ||
+			inode.numLinks >= inode.stat.Nlink {
+			size += uint64(inode.stat.Blocks) * 512
+		}
+	}
+	return size, nil
+}
+
+func (t *uType) readPatchedImageName() string {
+	lines, err := fsutil.LoadLines(filepath.Join(t.RootDirectoryName,
+		constants.PatchedImageNameFile))
+	if err != nil || len(lines) < 1 {
+		return ""
+	}
+	return strings.TrimSpace(lines[0])
+}
+
+// setRollbackTriggers records the triggers to run if the update is rolled
+// back.
+func (t *uType) setRollbackTriggers(triggerLists ...[]*triggers.Trigger) {
+	services := make(map[string]struct{})
+	for _, triggerList := range triggerLists {
+		for _, trigger := range triggerList {
+			if _, ok := services[trigger.Service]; ok {
+				continue
+			}
+			services[trigger.Service] = struct{}{}
+			t.rollbackState.Triggers = append(t.rollbackState.Triggers,
+				trigger)
+		}
+	}
+	if err := t.writeRollbackState(); err != nil {
+		t.Logger.Println(err)
+	}
+}
+
+func (t *uType) writeRollbackState() error {
+	writer, err := fsutil.CreateRenamingWriter(
+		filepath.Join(t.RollbackDir, rollbackStateFile),
+		fsutil.PrivateFilePerms)
+	if err != nil {
+		return err
+	}
+	defer writer.Close()
+	bufferedWriter := bufio.NewWriter(writer)
+	err = gob.NewEncoder(bufferedWriter).Encode(t.rollbackState)
+	if err != nil {
+		writer.Abort()
+		return err
+	}
+	if err := bufferedWriter.Flush(); err != nil {
+		writer.Abort()
+		return err
+	}
+	return writer.Close()
+}
+
+func readRollbackState(rollbackDir string) (*rollbackState, error) {
+	file, err := os.Open(filepath.Join(rollbackDir, rollbackStateFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.New("no saved state to roll back to")
+		}
+		return nil, err
+	}
+	defer file.Close()
+	var state rollbackState
+	if err := gob.NewDecoder(bufio.NewReader(file)).Decode(&state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+func (t *uType) rollback() (string, error) {
+	state, err := readRollbackState(t.RollbackDir)
+	if err != nil {
+		return "", err
+	}
+	if err := t.restore(state); err != nil {
+		return "", err
+	}
+	return state.ImageName, nil
+}
+
+// restore stops the services, restores the saved state and starts the
+// services. The saved state is removed once it has been restored.
+func (t *uType) restore(state *rollbackState) error {
+	if t.RunTriggers != nil && t.RunTriggers(state.Triggers, "stop", t.Logger) {
+		t.hadTriggerFailures = true
+	}
+	err := t.restoreEntries(state)
+	if t.RunTriggers != nil &&
+		t.RunTriggers(state.Triggers, "start", t.Logger) {
+		t.hadTriggerFailures = true
+	}
+	if err != nil {
+		return err
+	}
+	return fsutil.ForceRemoveAll(t.RollbackDir)
+}
+
+func (t *uType) restoreEntries(state *rollbackState) error {
+	dataDir := filepath.Join(t.RollbackDir, rollbackDataDirectory)
+	var firstError error
+	for index := len(state.Entries) - 1; index >= 0; index-- {
+		entry := state.Entries[index]
+		fullPathname := filepath.Join(t.RootDirectoryName, entry.Name)
+		savedPathname := filepath.Join(dataDir, strconv.Itoa(index))
+		var err error
+		switch {
+		case entry.Metadata != nil:
+			err = restoreMetadata(fullPathname, entry.Metadata, entry.Xattrs)
+		case entry.Saved:
+			err = fsutil.ForceRemoveAll(fullPathname)
+			if err == nil {
+				err = os.Rename(savedPathname, fullPathname)
+			}
+			if err == nil {
+				err = restoreLinkedFiles(fullPathname, entry.LinkedFiles)
+			}
+		default:
+			err = fsutil.ForceRemoveAll(fullPathname)
+		}
+		if err != nil {
+			t.Logger.Println(err)
+			if firstError == nil {
+				firstError = err
+			}
+		} else {
+			t.Logger.Printf("Restored: %s\n", fullPathname)
+		}
+	}
+	if err := t.writePatchedImageName(state.ImageName); err != nil {
+		t.Logger.Println(err)
+	}
+	return firstError
+}
+
+// restoreLinkedFiles restores the metadata of the hardlinked files in the tree
+// at pathname.
+func restoreLinkedFiles(pathname string, linkedFiles []linkedFile) error {
+	for _, file := range linkedFiles {
+		err := restoreMetadata(filepath.Join(pathname, file.Name),
+			file.Metadata, file.Xattrs)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func restoreMetadata(pathname string, inode filesystem.GenericInode,
+	xattrs map[string][]byte) error {
+	if err := filesystem.ForceWriteMetadata(inode, pathname); err != nil {
+		return err
+	}
+	return fsutil.ReplaceXattrs(pathname, xattrs)
+}
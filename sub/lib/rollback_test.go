@@ -0,0 +1,147 @@
+package lib
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Cloud-Foundations/Dominator/lib/filter"
+	"github.com/Cloud-Foundations/Dominator/lib/log/testlogger"
+	"github.com/Cloud-Foundations/Dominator/proto/sub"
+)
+
+func checkFile(t *testing.T, pathname, data string, mode os.FileMode) {
+	readData, err := os.ReadFile(pathname)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if string(readData) != data {
+		t.Errorf("%s: data: \"%s\", expected: \"%s\"", pathname, readData, data)
+	}
+	if fi, err := os.Lstat(pathname); err != nil {
+		t.Error(err)
+	} else if fi.Mode().Perm() != mode {
+		t.Errorf("%s: mode: %s, expected: %s", pathname, fi.Mode().Perm(), mode)
+	}
+}
+
+func writeFile(t *testing.T, pathname, data string, mode os.FileMode) {
+	if err := os.MkdirAll(filepath.Dir(pathname), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(pathname, []byte(data), mode); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(pathname, mode); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRollback(t *testing.T) {
+	rootDir := t.TempDir()
+	writeFile(t, filepath.Join(rootDir, "file"), "old file", 0644)
+	writeFile(t, filepath.Join(rootDir, "dir", "a"), "old a", 0644)
+	writeFile(t, filepath.Join(rootDir, "meta"), "meta", 0644)
+	writeFile(t, filepath.Join(rootDir, ".subd", "state"), "state", 0644)
+	// Unix socket names are limited in length, so use a relative name.
+	if cwd, err := os.Getwd(); err != nil {
+		t.Fatal(err)
+	} else {
+		defer os.Chdir(cwd)
+	}
+	if err := os.Chdir(rootDir); err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("unix", "socket")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	updateObj := &uType{UpdateOptions: UpdateOptions{
+		Logger:            testlogger.New(t),
+		RollbackDir:       filepath.Join(t.TempDir(), "rollback"),
+		RootDirectoryName: rootDir,
+		SkipFilter:        &filter.Filter{},
+	}}
+	request := sub.UpdateRequest{
+		DirectoriesToMake: []sub.Inode{{Name: "/newdir"}},
+		InodesToChange:    []sub.Inode{{Name: "/meta"}},
+		InodesToMake:      []sub.Inode{{Name: "/file"}, {Name: "/socket"}},
+		PathsToDelete:     []string{"/dir", "/.subd/state"},
+	}
+	if err := updateObj.saveForRollback(request); err != nil {
+		t.Fatal(err)
+	}
+	if num := len(updateObj.rollbackState.Entries); num != 5 {
+		t.Fatalf("saved: %d entries, expected 5", num)
+	}
+	// Make the changes. The metadata of the hardlinked original are changed
+	// before it is replaced.
+	if err := os.Chmod(filepath.Join(rootDir, "file"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(rootDir, "file")); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(rootDir, "file"), "new file", 0755)
+	if err := os.Chmod(filepath.Join(rootDir, "meta"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(filepath.Join(rootDir, "dir")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(rootDir, "newdir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	listener.Close()
+	writeFile(t, filepath.Join(rootDir, "socket"), "not a socket", 0644)
+	if _, err := updateObj.rollback(); err != nil {
+		t.Fatal(err)
+	}
+	checkFile(t, filepath.Join(rootDir, "file"), "old file", 0644)
+	checkFile(t, filepath.Join(rootDir, "dir", "a"), "old a", 0644)
+	checkFile(t, filepath.Join(rootDir, "meta"), "meta", 0644)
+	checkFile(t, filepath.Join(rootDir, ".subd", "state"), "state", 0644)
+	for _, name := range []string{"newdir", "socket"} {
+		if _, err := os.Lstat(filepath.Join(rootDir, name)); err == nil {
+			t.Errorf("%s was not removed", name)
+		}
+	}
+	if _, err := os.Stat(updateObj.RollbackDir); err == nil {
+		t.Error("rollback directory was not removed")
+	}
+}
+
+func TestGetRollbackAreaSize(t *testing.T) {
+	rootDir := t.TempDir()
+	rollbackDir := filepath.Join(rootDir, "rollback")
+	writeFile(t, filepath.Join(rootDir, "linked"), string(make([]byte, 8192)),
+		0644)
+	writeFile(t, filepath.Join(rollbackDir, "unique"),
+		string(make([]byte, 8192)), 0644)
+	err := os.Link(filepath.Join(rootDir, "linked"),
+		filepath.Join(rollbackDir, "linked"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	linkedSize, err := GetRollbackAreaSize(rollbackDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(rootDir, "linked")); err != nil {
+		t.Fatal(err)
+	}
+	size, err := GetRollbackAreaSize(rollbackDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if linkedSize < 8192 {
+		t.Errorf("size: %d is less than the unique file", linkedSize)
+	}
+	if size-linkedSize < 8192 {
+		t.Errorf("size: %d when unlinked, expected at least %d",
+			size, linkedSize+8192)
+	}
+}
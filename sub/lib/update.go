@@ -32,6 +32,12 @@ func (t *uType) update(request sub.UpdateRequest) error {
 	}
 	t.copyFilesToCache(request.FilesToCopyToCache)
 	t.makeObjectCopies(request.MultiplyUsedObjects)
+	if t.RollbackDir != "" {
+		if err := t.saveForRollback(request); err != nil {
+			return fmt.Errorf("error saving state for rollback: %s", err)
+		}
+	}
+	var matchedOldTriggers []*triggers.Trigger
 	if t.RunTriggers != nil &&
 		t.OldTriggers != nil && len(t.OldTriggers.Triggers) > 0 {
 		t.makeDirectories(request.DirectoriesToMake,
@@ -41,7 +47,7 @@ func (t *uType) update(request sub.UpdateRequest) error {
 		t.makeHardlinks(request.HardlinksToMake, t.OldTriggers, false)
 		t.doDeletes(request.PathsToDelete, t.OldTriggers, false)
 		t.changeInodes(request.InodesToChange, t.OldTriggers, false)
-		matchedOldTriggers := t.OldTriggers.GetMatchedTriggers()
+		matchedOldTriggers = t.OldTriggers.GetMatchedTriggers()
 		err := t.checkDisruption(matchedOldTriggers, request.ForceDisruption)
 		if err != nil {
 			return err
@@ -64,9 +70,19 @@ func (t *uType) update(request sub.UpdateRequest) error {
 	}
 	t.fsChangeDuration = time.Since(fsChangeStartTime)
 	matchedNewTriggers := request.Triggers.GetMatchedTriggers()
+	if t.rollbackState != nil {
+		t.setRollbackTriggers(matchedOldTriggers, matchedNewTriggers)
+	}
 	if t.RunTriggers != nil &&
 		t.RunTriggers(matchedNewTriggers, "start", t.Logger) {
 		t.hadTriggerFailures = true
+		if t.rollbackState != nil {
+			t.Logger.Println("Start triggers failed, rolling back")
+			if err := t.restore(t.rollbackState); err != nil {
+				return fmt.Errorf("error rolling back: %s", err)
+			}
+			return errors.New(sub.ErrorUpdateRolledBack)
+		}
 	}
 	return t.lastError
 }
@@ -365,7 +381,11 @@ func checkNonMtimeChange(filename string, inode filesystem.GenericInode) bool {
 			oldInode.Hash = inode.Hash
 			oldInode.MtimeNanoSeconds = inode.MtimeNanoSeconds
 			oldInode.MtimeSeconds = inode.MtimeSeconds
-			if *oldInode == *inode {
+			if len(inode.Xattrs) > 0 { // Only managed if in the image.
+				xattrs, _ := fsutil.GetXattrs(filename)
+				oldInode.Xattrs = filesystem.SelectXattrs(xattrs, inode.Xattrs)
+			}
+			if filesystem.CompareRegularInodes(oldInode, inode, nil) {
 				return false
 			}
 		}
@@ -379,7 +399,11 @@ func checkNonMtimeChange(filename string, inode filesystem.GenericInode) bool {
 			oldInode := scanner.MakeSpecialInode(&stat)
 			oldInode.MtimeNanoSeconds = inode.MtimeNanoSeconds
 			oldInode.MtimeSeconds = inode.MtimeSeconds
-			if *oldInode == *inode {
+			if len(inode.Xattrs) > 0 { // Only managed if in the image.
+				xattrs, _ := fsutil.GetXattrs(filename)
+				oldInode.Xattrs = filesystem.SelectXattrs(xattrs, inode.Xattrs)
+			}
+			if filesystem.CompareSpecialInodes(oldInode, inode, nil) {
 				return false
 			}
 		}
@@ -6,11 +6,13 @@ import (
 	"time"
 
 	"github.com/Cloud-Foundations/Dominator/lib/goroutine"
+	"github.com/Cloud-Foundations/Dominator/lib/hash"
 	"github.com/Cloud-Foundations/Dominator/lib/log"
 	"github.com/Cloud-Foundations/Dominator/lib/rateio"
 	"github.com/Cloud-Foundations/Dominator/lib/srpc"
 	"github.com/Cloud-Foundations/Dominator/lib/srpc/serverutil"
 	"github.com/Cloud-Foundations/Dominator/lib/stringutil"
+	"github.com/Cloud-Foundations/Dominator/lib/triggers"
 	proto "github.com/Cloud-Foundations/Dominator/proto/sub"
 	"github.com/Cloud-Foundations/Dominator/sub/scanner"
 	"github.com/Cloud-Foundations/tricorder/go/tricorder"
@@ -24,12 +26,17 @@ const (
 )
 
 type Config struct {
+	CompressObjects          bool // If true, compress objects sent to peers.
 	DisruptionManager        string
+	DriftReportFilename      string
 	NetworkBenchmarkFilename string
 	NoteGeneratorCommand     string
 	ObjectsDirectoryName     string
 	OldTriggersFilename      string
+	RollbackDirectoryName    string // If set, updates are transactional.
 	RootDirectoryName        string
+	ServableObjectsFilename  string
+	ServeObjects             bool // If true, peers may fetch objects.
 	SubConfiguration         proto.Configuration
 }
 
@@ -50,9 +57,13 @@ type rpcType struct {
 	systemGoroutine *goroutine.Goroutine
 	*serverutil.PerUserMethodLimiter
 	disruptionManagerControl     chan<- bool // True: request; false: cancel.
+	driftReport                  driftReport
 	ownerUsers                   map[string]struct{}
+	servableObjects              *servableObjects
 	rwLock                       sync.RWMutex // Protect everything below.
 	disruptionState              proto.DisruptionState
+	healthProbeGeneration        uint64
+	healthProbesInProgress       bool
 	getFilesLock                 sync.Mutex
 	fetchInProgress              bool // Fetch() & Update() mutually exclusive.
 	updateInProgress             bool
@@ -64,9 +75,13 @@ type rpcType struct {
 	lastSuccessfulImageName      string
 	lastUpdateError              error
 	lastUpdateHadTriggerFailures bool
+	lastUpdateHealthProbeResults []proto.HealthProbeResult
 	lastWriteError               string
 	lockedBy                     *srpc.Conn
 	lockedUntil                  time.Time
+	rollbackAreaSize             uint64
+	probedTriggers               []*triggers.Trigger // Only Update().
+	triggerFailures              []string            // Only Update().
 }
 
 type addObjectsHandlerType struct {
@@ -74,6 +89,9 @@ type addObjectsHandlerType struct {
 	scannerConfiguration *scanner.Configuration
 	logger               log.Logger
 	rpcObj               *rpcType
+	getObjectsLock       sync.Mutex // Serialise serving objects to peers.
+	fileSystem           *scanner.FileSystem
+	objectFiles          map[hash.Hash]string // Key: hash, value: pathname.
 }
 
 type HtmlWriter struct {
@@ -93,7 +111,20 @@ func Setup(config Config, params Params) *HtmlWriter {
 				"Poll": 1,
 			}),
 	}
+	if cgroups := params.ScannerConfiguration.Cgroups; cgroups != nil {
+		triggerCommand = cgroups.TriggerCommand
+		releaseTriggerProcesses = cgroups.ReleaseTriggerProcesses
+	}
+	if config.ServeObjects {
+		rpcObj.servableObjects = loadServableObjects(
+			config.ServableObjectsFilename, params.Logger)
+	}
 	rpcObj.startDisruptionManager()
+	rpcObj.loadDriftReport()
+	scanNotifier := make(chan struct{}, 1)
+	params.FileSystemHistory.RegisterScanNotifier(scanNotifier)
+	go rpcObj.driftReportLoop(scanNotifier)
+	rpcObj.updateRollbackAreaSize()
 	rpcObj.ownerUsers = stringutil.ConvertListToMap(
 		config.SubConfiguration.OwnerUsers, false)
 	srpc.RegisterNameWithOptions("Subd", rpcObj,
@@ -0,0 +1,288 @@
+package rpcd
+
+import (
+	"hash/fnv"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/filesystem"
+	"github.com/Cloud-Foundations/Dominator/lib/filter"
+	"github.com/Cloud-Foundations/Dominator/lib/fsutil"
+	"github.com/Cloud-Foundations/Dominator/lib/json"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	"github.com/Cloud-Foundations/Dominator/proto/sub"
+	"github.com/Cloud-Foundations/Dominator/sub/scanner"
+)
+
+const (
+	maxCorrectedFiles = 10000
+	maxNewFiles       = 10000
+)
+
+type driftReport struct {
+	mutex              sync.Mutex // Protect everything below.
+	correctedFiles     []sub.CorrectedFile
+	baseline           []uint64 // Sorted pathname hashes. nil: no scan yet.
+	baselineGeneration uint64   // Incremented when the baseline is reset.
+	baselineScanCount  uint64
+	baselineTime       time.Time
+	fileSystem         *scanner.FileSystem // Last checked for new files.
+	imageFilter        *filter.Filter      // nil: no filter for last image.
+	newFiles           []string            // The first maxNewFiles.
+	numNewFiles        uint64
+}
+
+// driftReportState is the persistent state of the drift report.
+type driftReportState struct {
+	CorrectedFiles   []sub.CorrectedFile
+	ImageFilterLines []string `json:",omitempty"`
+}
+
+func (t *rpcType) GetDriftReport(conn *srpc.Conn,
+	request sub.GetDriftReportRequest,
+	reply *sub.GetDriftReportResponse) error {
+	t.driftReport.mutex.Lock()
+	defer t.driftReport.mutex.Unlock()
+	reply.CorrectedFiles = make([]sub.CorrectedFile,
+		len(t.driftReport.correctedFiles))
+	copy(reply.CorrectedFiles, t.driftReport.correctedFiles)
+	reply.NewFiles = make([]string, len(t.driftReport.newFiles))
+	copy(reply.NewFiles, t.driftReport.newFiles)
+	reply.NewFilesSince = t.driftReport.baselineTime
+	reply.NumNewFiles = t.driftReport.numNewFiles
+	return nil
+}
+
+// driftReportLoop updates the new files after each scan.
+func (t *rpcType) driftReportLoop(scanNotifier <-chan struct{}) {
+	for range scanNotifier {
+		t.updateNewFiles()
+	}
+}
+
+// getDriftCounts returns the number of corrected files and new files.
+func (t *rpcType) getDriftCounts() (uint64, uint64) {
+	t.driftReport.mutex.Lock()
+	defer t.driftReport.mutex.Unlock()
+	return uint64(len(t.driftReport.correctedFiles)),
+		t.driftReport.numNewFiles
+}
+
+func (t *rpcType) loadDriftReport() {
+	if t.config.DriftReportFilename == "" {
+		return
+	}
+	var state driftReportState
+	err := json.ReadFromFile(t.config.DriftReportFilename, &state)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			t.params.Logger.Printf("Error reading drift report: %s\n", err)
+		}
+		return
+	}
+	t.driftReport.correctedFiles = state.CorrectedFiles
+	if state.ImageFilterLines != nil {
+		imageFilter, err := filter.New(state.ImageFilterLines)
+		if err != nil {
+			t.params.Logger.Printf("Error loading image filter: %s\n", err)
+		} else {
+			t.driftReport.imageFilter = imageFilter
+		}
+	}
+}
+
+// recordCorrections records the changes made by an update which re-applied
+// the image the sub was already updated to. Such changes correct drift, except
+// for changes to computed files, which may change between updates.
+func (t *rpcType) recordCorrections(request sub.UpdateRequest) {
+	computedFiles := make(map[string]struct{}, len(request.ComputedFiles))
+	for _, pathname := range request.ComputedFiles {
+		computedFiles[pathname] = struct{}{}
+	}
+	now := time.Now()
+	var correctedFiles []sub.CorrectedFile
+	addFile := func(action, pathname string) {
+		if _, ok := computedFiles[pathname]; ok {
+			return
+		}
+		correctedFiles = append(correctedFiles, sub.CorrectedFile{
+			Action:    action,
+			ImageName: request.ImageName,
+			Pathname:  pathname,
+			Time:      now,
+		})
+	}
+	for _, inode := range request.DirectoriesToMake {
+		addFile("made", inode.Name)
+	}
+	for _, inode := range request.InodesToMake {
+		addFile("made", inode.Name)
+	}
+	for _, hardlink := range request.HardlinksToMake {
+		addFile("made", hardlink.NewLink)
+	}
+	for _, pathname := range request.PathsToDelete {
+		addFile("deleted", pathname)
+	}
+	for _, inode := range request.InodesToChange {
+		addFile("changed", inode.Name)
+	}
+	if len(correctedFiles) < 1 {
+		return
+	}
+	t.params.Logger.Printf("Update(): corrected %d drifted files\n",
+		len(correctedFiles))
+	t.driftReport.mutex.Lock()
+	t.driftReport.correctedFiles = append(t.driftReport.correctedFiles,
+		correctedFiles...)
+	if excess := len(t.driftReport.correctedFiles) -
+		maxCorrectedFiles; excess > 0 {
+		t.driftReport.correctedFiles = append([]sub.CorrectedFile(nil),
+			t.driftReport.correctedFiles[excess:]...)
+	}
+	t.driftReport.mutex.Unlock()
+	t.writeDriftReport()
+}
+
+// resetDriftBaseline forgets the new files. The next scan becomes the new
+// baseline.
+func (t *rpcType) resetDriftBaseline() {
+	t.driftReport.mutex.Lock()
+	defer t.driftReport.mutex.Unlock()
+	t.driftReport.baseline = nil
+	t.driftReport.baselineGeneration++
+	t.driftReport.baselineScanCount = t.params.FileSystemHistory.ScanCount()
+	t.driftReport.baselineTime = time.Time{}
+	t.driftReport.fileSystem = nil
+	t.driftReport.newFiles = nil
+	t.driftReport.numNewFiles = 0
+}
+
+// setDriftImageFilter records the filter of the image which was applied. Paths
+// matching the filter are not managed by the image, so they are not new files.
+func (t *rpcType) setDriftImageFilter(request sub.UpdateRequest) {
+	if request.SparseImage {
+		return
+	}
+	imageFilter, err := filter.New(request.ImageFilterLines)
+	if err != nil {
+		t.params.Logger.Printf("Error compiling image filter: %s\n", err)
+		imageFilter = nil
+	}
+	t.driftReport.mutex.Lock()
+	t.driftReport.imageFilter = imageFilter
+	t.driftReport.mutex.Unlock()
+	t.writeDriftReport()
+}
+
+// updateNewFiles compares the latest scan with the baseline. The file-system
+// is walked without holding the lock.
+func (t *rpcType) updateNewFiles() {
+	fs := t.params.FileSystemHistory.FileSystem()
+	scanCount := t.params.FileSystemHistory.ScanCount()
+	t.driftReport.mutex.Lock()
+	if fs == nil || fs == t.driftReport.fileSystem {
+		t.driftReport.mutex.Unlock()
+		return
+	}
+	baseline := t.driftReport.baseline
+	generation := t.driftReport.baselineGeneration
+	if baseline == nil && scanCount <= t.driftReport.baselineScanCount {
+		t.driftReport.mutex.Unlock()
+		return
+	}
+	imageFilter := t.driftReport.imageFilter
+	t.driftReport.mutex.Unlock()
+	scanFilter := t.params.ScannerConfiguration.ScanFilter
+	matchFunc := func(pathname string) bool {
+		return (scanFilter != nil && scanFilter.Match(pathname)) ||
+			(imageFilter != nil && imageFilter.Match(pathname))
+	}
+	root := &fs.FileSystem.FileSystem.DirectoryInode
+	if baseline == nil {
+		baseline = make([]uint64, 0)
+		listPathnames(root, "/", matchFunc, func(pathname string) {
+			baseline = append(baseline, hashPathname(pathname))
+		})
+		sort.Slice(baseline, func(left, right int) bool {
+			return baseline[left] < baseline[right]
+		})
+		t.driftReport.mutex.Lock()
+		defer t.driftReport.mutex.Unlock()
+		if generation == t.driftReport.baselineGeneration {
+			t.driftReport.baseline = baseline
+			t.driftReport.baselineTime = time.Now()
+			t.driftReport.fileSystem = fs
+		}
+		return
+	}
+	var newFiles []string
+	var numNewFiles uint64
+	listPathnames(root, "/", matchFunc, func(pathname string) {
+		hashVal := hashPathname(pathname)
+		index := sort.Search(len(baseline), func(index int) bool {
+			return baseline[index] >= hashVal
+		})
+		if index < len(baseline) && baseline[index] == hashVal {
+			return
+		}
+		numNewFiles++
+		if len(newFiles) < maxNewFiles {
+			newFiles = append(newFiles, pathname)
+		}
+	})
+	sort.Strings(newFiles)
+	t.driftReport.mutex.Lock()
+	defer t.driftReport.mutex.Unlock()
+	if generation == t.driftReport.baselineGeneration {
+		t.driftReport.fileSystem = fs
+		t.driftReport.newFiles = newFiles
+		t.driftReport.numNewFiles = numNewFiles
+	}
+}
+
+func (t *rpcType) writeDriftReport() {
+	if t.config.DriftReportFilename == "" {
+		return
+	}
+	// The corrected files are only appended to or replaced, so the slice may
+	// be used after releasing the lock.
+	t.driftReport.mutex.Lock()
+	state := driftReportState{CorrectedFiles: t.driftReport.correctedFiles}
+	if imageFilter := t.driftReport.imageFilter; imageFilter != nil {
+		state.ImageFilterLines = imageFilter.FilterLines
+	}
+	t.driftReport.mutex.Unlock()
+	err := json.WriteToFile(t.config.DriftReportFilename,
+		fsutil.PrivateFilePerms, "    ", state)
+	if err != nil {
+		t.params.Logger.Printf("Error writing drift report: %s\n", err)
+	}
+}
+
+// hashPathname returns a hash of the pathname, which is stored in the baseline
+// instead of the pathname to save memory.
+func hashPathname(pathname string) uint64 {
+	hasher := fnv.New64a()
+	hasher.Write([]byte(pathname))
+	return hasher.Sum64()
+}
+
+// listPathnames calls pathnameFunc for each pathname under directory, skipping
+// the pathnames (and the directories below them) which matchFunc matches.
+func listPathnames(directory *filesystem.DirectoryInode, dirname string,
+	matchFunc func(pathname string) bool, pathnameFunc func(pathname string)) {
+	for _, dirent := range directory.EntryList {
+		pathname := path.Join(dirname, dirent.Name)
+		if matchFunc(pathname) {
+			continue
+		}
+		pathnameFunc(pathname)
+		if inode, ok := dirent.Inode().(*filesystem.DirectoryInode); ok {
+			listPathnames(inode, pathname, matchFunc, pathnameFunc)
+		}
+	}
+}
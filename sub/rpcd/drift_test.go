@@ -0,0 +1,122 @@
+package rpcd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Cloud-Foundations/Dominator/lib/filter"
+	"github.com/Cloud-Foundations/Dominator/lib/log/testlogger"
+	"github.com/Cloud-Foundations/Dominator/proto/sub"
+	"github.com/Cloud-Foundations/Dominator/sub/scanner"
+)
+
+func makeDriftTestRpcType(t *testing.T, rootDir string) *rpcType {
+	scanFilter, err := filter.New([]string{"/scratch"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	configuration := &scanner.Configuration{ScanFilter: scanFilter}
+	return &rpcType{
+		config: Config{
+			DriftReportFilename: filepath.Join(t.TempDir(), "drift-report"),
+			RootDirectoryName:   rootDir,
+		},
+		params: Params{
+			FileSystemHistory:    &scanner.FileSystemHistory{},
+			Logger:               testlogger.New(t),
+			ScannerConfiguration: configuration,
+		},
+	}
+}
+
+func scanForDrift(t *testing.T, rpcObj *rpcType) {
+	fs, err := scanner.ScanFileSystem(rpcObj.config.RootDirectoryName, "",
+		rpcObj.params.ScannerConfiguration)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rpcObj.params.FileSystemHistory.Update(fs)
+	rpcObj.updateNewFiles()
+}
+
+func writeDriftTestFile(t *testing.T, pathname string) {
+	if err := os.MkdirAll(filepath.Dir(pathname), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(pathname, []byte(pathname), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewFiles(t *testing.T) {
+	rootDir := t.TempDir()
+	writeDriftTestFile(t, filepath.Join(rootDir, "etc", "config"))
+	rpcObj := makeDriftTestRpcType(t, rootDir)
+	rpcObj.setDriftImageFilter(sub.UpdateRequest{
+		ImageFilterLines: []string{"/var/log"},
+	})
+	rpcObj.resetDriftBaseline()
+	scanForDrift(t, rpcObj) // Baseline.
+	if _, numNewFiles := rpcObj.getDriftCounts(); numNewFiles != 0 {
+		t.Fatalf("%d new files after baseline scan", numNewFiles)
+	}
+	writeDriftTestFile(t, filepath.Join(rootDir, "etc", "new"))
+	writeDriftTestFile(t, filepath.Join(rootDir, "var", "log", "messages"))
+	writeDriftTestFile(t, filepath.Join(rootDir, "scratch", "file"))
+	scanForDrift(t, rpcObj)
+	// The var directory is new and not excluded by the image filter.
+	expected := []string{"/etc/new", "/var"}
+	var reply sub.GetDriftReportResponse
+	if err := rpcObj.GetDriftReport(nil, sub.GetDriftReportRequest{},
+		&reply); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(reply.NewFiles, expected) {
+		t.Errorf("new files: %v, expected: %v", reply.NewFiles, expected)
+	}
+	if reply.NumNewFiles != uint64(len(expected)) {
+		t.Errorf("number of new files: %d, expected: %d",
+			reply.NumNewFiles, len(expected))
+	}
+	// An update starts a new baseline and the image filter is persistent.
+	rpcObj.resetDriftBaseline()
+	if _, numNewFiles := rpcObj.getDriftCounts(); numNewFiles != 0 {
+		t.Errorf("%d new files after reset", numNewFiles)
+	}
+	reloaded := makeDriftTestRpcType(t, rootDir)
+	reloaded.config.DriftReportFilename = rpcObj.config.DriftReportFilename
+	reloaded.loadDriftReport()
+	if imageFilter := reloaded.driftReport.imageFilter; imageFilter == nil ||
+		!reflect.DeepEqual(imageFilter.FilterLines, []string{"/var/log"}) {
+		t.Errorf("image filter not reloaded: %v", imageFilter)
+	}
+}
+
+func TestRecordCorrections(t *testing.T) {
+	rpcObj := makeDriftTestRpcType(t, t.TempDir())
+	rpcObj.recordCorrections(sub.UpdateRequest{
+		ComputedFiles:  []string{"/etc/computed"},
+		ImageName:      "image",
+		InodesToChange: []sub.Inode{{Name: "/etc/computed"}},
+		InodesToMake:   []sub.Inode{{Name: "/etc/passwd"}},
+		PathsToDelete:  []string{"/etc/extra"},
+	})
+	numCorrected, numNewFiles := rpcObj.getDriftCounts()
+	if numCorrected != 2 || numNewFiles != 0 {
+		t.Errorf("counts: corrected: %d, new: %d, expected: 2, 0",
+			numCorrected, numNewFiles)
+	}
+	reloaded := makeDriftTestRpcType(t, t.TempDir())
+	reloaded.config.DriftReportFilename = rpcObj.config.DriftReportFilename
+	reloaded.loadDriftReport()
+	var pathnames []string
+	for _, file := range reloaded.driftReport.correctedFiles {
+		pathnames = append(pathnames, file.Pathname)
+	}
+	if expected := []string{"/etc/passwd", "/etc/extra"}; !reflect.DeepEqual(
+		pathnames, expected) {
+		t.Errorf("corrected files: %v, expected: %v", pathnames, expected)
+	}
+}
@@ -1,6 +1,7 @@
 package rpcd
 
 import (
+	"crypto/sha512"
 	"errors"
 	"flag"
 	"fmt"
@@ -71,6 +72,9 @@ func (t *rpcType) fetchAndUnlock(conn *srpc.Conn, request sub.FetchRequest,
 	if err != nil && *exitOnFetchFailure {
 		os.Exit(1)
 	}
+	if err == nil && t.servableObjects != nil {
+		t.servableObjects.add(request.Hashes)
+	}
 	t.rwLock.Lock()
 	defer t.rwLock.Unlock()
 	t.fetchInProgress = false
@@ -82,6 +86,17 @@ func (t *rpcType) fetchAndUnlock(conn *srpc.Conn, request sub.FetchRequest,
 }
 
 func (t *rpcType) doFetch(request sub.FetchRequest, username string) error {
+	if request.PeerAddress != "" {
+		hashes, err := t.fetchFromPeer(request, username)
+		if err == nil {
+			t.params.WorkdirGoroutine.Run(t.params.RescanObjectCacheFunction)
+			return nil
+		}
+		t.params.Logger.Printf(
+			"Error fetching from peer: %s: %s, falling back to: %s\n",
+			request.PeerAddress, err, request.ServerAddress)
+		request.Hashes = hashes
+	}
 	objectServer := objectclient.NewObjectClient(request.ServerAddress)
 	defer objectServer.Close()
 	defer t.params.ScannerConfiguration.BoostCpuLimit(t.params.Logger)
@@ -168,6 +183,50 @@ func (t *rpcType) doFetch(request sub.FetchRequest, username string) error {
 	return nil
 }
 
+// fetchFromPeer fetches the objects from a peer sub, verifying their hashes
+// since the peer is not trusted to serve correct data. On error, the objects
+// not yet fetched are returned.
+func (t *rpcType) fetchFromPeer(request sub.FetchRequest, username string) (
+	[]hash.Hash, error) {
+	var suffix string
+	if username != "" {
+		suffix = " by " + username
+	}
+	t.params.Logger.Printf("Fetch(%s) %d objects from peer%s\n",
+		request.PeerAddress, len(request.Hashes), suffix)
+	objectServer := objectclient.NewObjectClient(request.PeerAddress)
+	defer objectServer.Close()
+	objectsReader, err := objectServer.GetObjects(request.Hashes)
+	if err != nil {
+		return request.Hashes, err
+	}
+	defer objectsReader.Close()
+	var totalLength uint64
+	timeStart := time.Now()
+	for index, hashVal := range request.Hashes {
+		length, reader, err := objectsReader.NextObject()
+		if err != nil {
+			return request.Hashes[index:], err
+		}
+		t.params.WorkdirGoroutine.Run(func() {
+			err = readOneVerified(t.config.ObjectsDirectoryName, hashVal,
+				length, reader)
+		})
+		reader.Close()
+		if err != nil {
+			return request.Hashes[index:], err
+		}
+		totalLength += length
+	}
+	duration := time.Since(timeStart)
+	speed := uint64(float64(totalLength) / duration.Seconds())
+	t.params.Logger.Printf(
+		"Fetch() from peer complete. Read: %s in %s (%s/s)\n",
+		format.FormatBytes(totalLength), format.Duration(duration),
+		format.FormatBytes(speed))
+	return nil, nil
+}
+
 func (t *rpcType) logFetch(request sub.FetchRequest, speed, speedPercent uint64,
 	username string) {
 	speedString := "unlimited speed"
@@ -207,3 +266,22 @@ func readOne(objectsDir string, hash hash.Hash, length uint64,
 	}
 	return fsutil.CopyToFile(filename, filePerms, reader, length)
 }
+
+// readOneVerified is like readOne, but removes the object and returns an error
+// if the data do not match the hash.
+func readOneVerified(objectsDir string, hashVal hash.Hash, length uint64,
+	reader io.Reader) error {
+	hasher := sha512.New()
+	err := readOne(objectsDir, hashVal, length, io.TeeReader(reader, hasher))
+	if err != nil {
+		return err
+	}
+	var computedHash hash.Hash
+	copy(computedHash[:], hasher.Sum(nil))
+	if computedHash != hashVal {
+		os.Remove(path.Join(objectsDir, objectcache.HashToFilename(hashVal)))
+		return fmt.Errorf("hash mismatch. Computed=%x, expected=%x",
+			computedHash, hashVal)
+	}
+	return nil
+}
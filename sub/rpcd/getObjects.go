@@ -0,0 +1,157 @@
+package rpcd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+
+	"github.com/Cloud-Foundations/Dominator/lib/filesystem"
+	"github.com/Cloud-Foundations/Dominator/lib/hash"
+	"github.com/Cloud-Foundations/Dominator/lib/objectcache"
+	"github.com/Cloud-Foundations/Dominator/lib/objectserver/compression"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	"github.com/Cloud-Foundations/Dominator/proto/objectserver"
+)
+
+// GetObjects serves objects to peer subs. Only objects which were fetched for
+// an image may be served, so that other files (such as computed files and host
+// keys) are not leaked. Objects are read from the object cache or from files in
+// the scanned file-system with matching hashes.
+func (t *addObjectsHandlerType) GetObjects(conn *srpc.Conn) error {
+	defer conn.Flush()
+	var request objectserver.GetObjectsRequest
+	var response objectserver.GetObjectsResponse
+	if err := conn.Decode(&request); err != nil {
+		response.ResponseString = err.Error()
+		return conn.Encode(response)
+	}
+	if !t.rpcObj.config.ServeObjects {
+		response.ResponseString = "serving objects is disabled"
+		return conn.Encode(response)
+	}
+	// Serving one peer at a time keeps within the network speed limit.
+	t.getObjectsLock.Lock()
+	defer t.getObjectsLock.Unlock()
+	files := make([]*os.File, len(request.Hashes))
+	defer func() {
+		for _, file := range files {
+			if file != nil {
+				file.Close()
+			}
+		}
+	}()
+	response.ObjectSizes = make([]uint64, len(request.Hashes))
+	for index, hashVal := range request.Hashes {
+		file, size, err := t.openObject(hashVal)
+		if err != nil {
+			response.ResponseString = fmt.Sprintf("unknown object: %x", hashVal)
+			return conn.Encode(response)
+		}
+		files[index] = file
+		response.ObjectSizes[index] = size
+	}
+	compressionType := objectserver.CompressionNone
+	if t.rpcObj.config.CompressObjects {
+		compressionType = compression.Select(request.Compressions)
+	}
+	response.ObjectHeaders = compressionType != objectserver.CompressionNone
+	if err := conn.Encode(response); err != nil {
+		return err
+	}
+	conn.Flush()
+	buffer := make([]byte, 32<<10)
+	for index, file := range files {
+		reader := io.LimitReader(
+			t.rpcObj.params.NetworkReaderContext.NewReader(file),
+			int64(response.ObjectSizes[index]))
+		if response.ObjectHeaders {
+			err := compression.WriteObject(conn, conn, reader,
+				response.ObjectSizes[index], compressionType)
+			if err != nil {
+				t.logger.Printf("Error sending: %s\n", err)
+				return err
+			}
+			continue
+		}
+		nCopied, err := io.CopyBuffer(conn, reader, buffer)
+		if err != nil {
+			t.logger.Printf("Error copying: %s\n", err)
+			return err
+		}
+		if nCopied != int64(response.ObjectSizes[index]) {
+			txt := fmt.Sprintf("Expected length: %d, got: %d for: %x",
+				response.ObjectSizes[index], nCopied, request.Hashes[index])
+			t.logger.Println(txt)
+			return errors.New(txt)
+		}
+	}
+	t.logger.Printf("GetObjects(%s) sent: %d objects\n",
+		conn.RemoteAddr(), len(request.Hashes))
+	return nil
+}
+
+// openObject opens the object from the object cache if present, else from the
+// file-system. The size of the object is returned.
+func (t *addObjectsHandlerType) openObject(hashVal hash.Hash) (
+	*os.File, uint64, error) {
+	if !t.rpcObj.servableObjects.contains(hashVal) {
+		return nil, 0, os.ErrNotExist
+	}
+	filenames := []string{
+		path.Join(t.objectsDir, objectcache.HashToFilename(hashVal)),
+	}
+	if filename, ok := t.getObjectFiles()[hashVal]; ok {
+		filenames = append(filenames, filename)
+	}
+	var file *os.File
+	var err error
+	for _, filename := range filenames {
+		t.rpcObj.params.WorkdirGoroutine.Run(func() {
+			file, err = os.Open(filename)
+		})
+		if err != nil {
+			continue
+		}
+		var fi os.FileInfo
+		if fi, err = file.Stat(); err != nil || !fi.Mode().IsRegular() {
+			file.Close()
+			continue
+		}
+		return file, uint64(fi.Size()), nil
+	}
+	if err == nil {
+		err = errors.New("not a regular file")
+	}
+	return nil, 0, err
+}
+
+// getObjectFiles returns a table of files in the latest scanned file-system,
+// indexed by hash. The getObjectsLock must be held.
+func (t *addObjectsHandlerType) getObjectFiles() map[hash.Hash]string {
+	fs := t.rpcObj.params.FileSystemHistory.FileSystem()
+	if fs == nil || fs == t.fileSystem {
+		return t.objectFiles
+	}
+	objectFiles := make(map[hash.Hash]string)
+	addObjectFiles(&fs.FileSystem.FileSystem.DirectoryInode,
+		t.rpcObj.config.RootDirectoryName, objectFiles)
+	t.fileSystem = fs
+	t.objectFiles = objectFiles
+	return objectFiles
+}
+
+func addObjectFiles(directory *filesystem.DirectoryInode, dirname string,
+	objectFiles map[hash.Hash]string) {
+	for _, dirent := range directory.EntryList {
+		switch inode := dirent.Inode().(type) {
+		case *filesystem.DirectoryInode:
+			addObjectFiles(inode, path.Join(dirname, dirent.Name), objectFiles)
+		case *filesystem.RegularInode:
+			if inode.Size > 0 {
+				objectFiles[inode.Hash] = path.Join(dirname, dirent.Name)
+			}
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package rpcd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/log"
+	"github.com/Cloud-Foundations/Dominator/lib/triggers"
+	"github.com/Cloud-Foundations/Dominator/proto/sub"
+)
+
+const healthProbeRetryInterval = time.Second
+
+// startHealthProbes runs the health probes for the services started by the
+// last update in the background, so that they do not hold the update lock.
+// Results from probes started by an earlier update are discarded.
+func (t *rpcType) startHealthProbes() {
+	probedTriggers := t.probedTriggers
+	t.probedTriggers = nil
+	t.rwLock.Lock()
+	t.healthProbeGeneration++
+	generation := t.healthProbeGeneration
+	t.healthProbesInProgress = len(probedTriggers) > 0
+	t.lastUpdateHealthProbeResults = nil
+	t.rwLock.Unlock()
+	if len(probedTriggers) < 1 {
+		return
+	}
+	go func() {
+		results := runHealthProbes(probedTriggers, t.params.Logger)
+		t.rwLock.Lock()
+		defer t.rwLock.Unlock()
+		if generation == t.healthProbeGeneration {
+			t.healthProbesInProgress = false
+			t.lastUpdateHealthProbeResults = results
+		}
+	}()
+}
+
+// runHealthProbes runs the health probes for the triggers concurrently and
+// returns the results, sorted by service.
+func runHealthProbes(triggerList []*triggers.Trigger,
+	logger log.Logger) []sub.HealthProbeResult {
+	resultsChannel := make(chan sub.HealthProbeResult, 1)
+	var numProbes int
+	for _, trigger := range triggerList {
+		for _, probe := range trigger.HealthProbes {
+			numProbes++
+			go func(service string, probe triggers.HealthProbe) {
+				resultsChannel <- runHealthProbe(service, probe)
+			}(trigger.Service, probe)
+		}
+	}
+	results := make([]sub.HealthProbeResult, 0, numProbes)
+	for ; numProbes > 0; numProbes-- {
+		result := <-resultsChannel
+		if result.Error == "" {
+			logger.Printf("Health probe for: %s (%s) succeeded\n",
+				result.Service, result.Probe)
+		} else {
+			logger.Printf("Health probe for: %s (%s) failed: %s\n",
+				result.Service, result.Probe, result.Error)
+		}
+		results = append(results, result)
+	}
+	sort.SliceStable(results, func(left, right int) bool {
+		return results[left].Service < results[right].Service
+	})
+	return results
+}
+
+// runHealthProbe retries the probe until it succeeds or times out.
+func runHealthProbe(service string,
+	probe triggers.HealthProbe) sub.HealthProbeResult {
+	result := sub.HealthProbeResult{Probe: probe.String(), Service: service}
+	stopTime := time.Now().Add(probe.Timeout())
+	for {
+		err := probeOnce(probe, time.Until(stopTime))
+		if err == nil {
+			return result
+		}
+		if time.Until(stopTime) < healthProbeRetryInterval {
+			result.Error = err.Error()
+			return result
+		}
+		time.Sleep(healthProbeRetryInterval)
+	}
+}
+
+func probeOnce(probe triggers.HealthProbe, timeout time.Duration) error {
+	switch probe.Type {
+	case triggers.HealthProbeCommand:
+		return probeCommand(probe.Command, timeout)
+	case triggers.HealthProbeHTTP:
+		return probeHTTP(probe.URL, probe.ExpectedStatus, timeout)
+	case triggers.HealthProbeTCP:
+		return probeTCP(probe.Address, timeout)
+	}
+	return fmt.Errorf("unknown health probe type: %s", probe.Type)
+}
+
+func probeCommand(command string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, "/bin/sh", "-c",
+		command).CombinedOutput()
+	if err != nil {
+		if output := strings.TrimSpace(string(output)); output != "" {
+			return fmt.Errorf("%s: %s", err, output)
+		}
+		return err
+	}
+	return nil
+}
+
+func probeHTTP(url string, expectedStatus int,
+	timeout time.Duration) error {
+	if expectedStatus == 0 {
+		expectedStatus = http.StatusOK
+	}
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode != expectedStatus {
+		return errors.New("unexpected status: " + resp.Status)
+	}
+	return nil
+}
+
+func probeTCP(address string, timeout time.Duration) error {
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
@@ -0,0 +1,147 @@
+package rpcd
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/log/testlogger"
+	"github.com/Cloud-Foundations/Dominator/lib/triggers"
+)
+
+func TestProbeOnce(t *testing.T) {
+	listener, err := net.Listen("tcp", "localhost:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddress := listener.Addr().String()
+	listener.Close()
+	listener, err = net.Listen("tcp", "localhost:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, req *http.Request) {
+			if req.URL.Path != "/healthy" {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}))
+	defer server.Close()
+	tests := []struct {
+		probe   triggers.HealthProbe
+		healthy bool
+	}{
+		{triggers.HealthProbe{Type: triggers.HealthProbeCommand,
+			Command: "exit 0"}, true},
+		{triggers.HealthProbe{Type: triggers.HealthProbeCommand,
+			Command: "echo broken; exit 1"}, false},
+		{triggers.HealthProbe{Type: triggers.HealthProbeHTTP,
+			URL: server.URL + "/healthy"}, true},
+		{triggers.HealthProbe{Type: triggers.HealthProbeHTTP,
+			URL: server.URL + "/sick"}, false},
+		{triggers.HealthProbe{Type: triggers.HealthProbeHTTP,
+			URL: server.URL + "/sick", ExpectedStatus: 503}, true},
+		{triggers.HealthProbe{Type: triggers.HealthProbeTCP,
+			Address: listener.Addr().String()}, true},
+		{triggers.HealthProbe{Type: triggers.HealthProbeTCP,
+			Address: closedAddress}, false},
+		{triggers.HealthProbe{Type: "carrier-pigeon"}, false},
+	}
+	for _, test := range tests {
+		err := probeOnce(test.probe, time.Second)
+		if test.healthy && err != nil {
+			t.Errorf("%s: %s", test.probe.String(), err)
+		} else if !test.healthy && err == nil {
+			t.Errorf("%s: did not fail", test.probe.String())
+		}
+	}
+}
+
+func TestRunHealthProbes(t *testing.T) {
+	triggerList := []*triggers.Trigger{
+		{
+			Service: "web",
+			HealthProbes: []triggers.HealthProbe{
+				{Type: triggers.HealthProbeCommand, Command: "exit 1",
+					TimeoutSeconds: 1},
+			},
+		},
+		{
+			Service: "db",
+			HealthProbes: []triggers.HealthProbe{
+				{Type: triggers.HealthProbeCommand, Command: "exit 0"},
+				{Type: triggers.HealthProbeCommand, Command: "true"},
+			},
+		},
+	}
+	results := runHealthProbes(triggerList, testlogger.New(t))
+	if len(results) != 3 {
+		t.Fatalf("%d results, expected 3", len(results))
+	}
+	for index, service := range []string{"db", "db", "web"} {
+		if results[index].Service != service {
+			t.Errorf("result %d: service: %s, expected: %s",
+				index, results[index].Service, service)
+		}
+	}
+	if results[0].Error != "" || results[1].Error != "" {
+		t.Errorf("healthy service failed: %v", results[:2])
+	}
+	if results[2].Error == "" {
+		t.Error("unhealthy service succeeded")
+	}
+}
+
+func TestStartHealthProbes(t *testing.T) {
+	rpcObj := &rpcType{params: Params{Logger: testlogger.New(t)}}
+	rpcObj.startHealthProbes()
+	if rpcObj.healthProbesInProgress {
+		t.Error("probes in progress without probed triggers")
+	}
+	firstTrigger := &triggers.Trigger{
+		Service: "first",
+		HealthProbes: []triggers.HealthProbe{
+			{Type: triggers.HealthProbeCommand, Command: "sleep 0.2"},
+		},
+	}
+	rpcObj.probedTriggers = []*triggers.Trigger{firstTrigger}
+	rpcObj.startHealthProbes()
+	// A later update discards the results of earlier probes.
+	rpcObj.probedTriggers = []*triggers.Trigger{{
+		Service: "slower",
+		HealthProbes: []triggers.HealthProbe{
+			{Type: triggers.HealthProbeCommand, Command: "sleep 0.4"},
+		},
+	}}
+	rpcObj.startHealthProbes()
+	if len(rpcObj.probedTriggers) > 0 {
+		t.Error("probed triggers not consumed")
+	}
+	time.Sleep(300 * time.Millisecond)
+	rpcObj.rwLock.RLock()
+	inProgress := rpcObj.healthProbesInProgress
+	numResults := len(rpcObj.lastUpdateHealthProbeResults)
+	rpcObj.rwLock.RUnlock()
+	if !inProgress || numResults > 0 {
+		t.Fatal("results from earlier probes were recorded")
+	}
+	for stopTime := time.Now().Add(5 * time.Second); ; {
+		rpcObj.rwLock.RLock()
+		inProgress = rpcObj.healthProbesInProgress
+		results := rpcObj.lastUpdateHealthProbeResults
+		rpcObj.rwLock.RUnlock()
+		if !inProgress {
+			if len(results) != 1 || results[0].Service != "slower" {
+				t.Errorf("bad results: %v", results)
+			}
+			break
+		}
+		if time.Now().After(stopTime) {
+			t.Fatal("timed out waiting for health probes")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
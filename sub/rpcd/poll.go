@@ -43,8 +43,11 @@ func (t *rpcType) Poll(conn *srpc.Conn) error {
 			response.LastUpdateError = t.lastUpdateError.Error()
 		}
 		response.LastUpdateHadTriggerFailures = t.lastUpdateHadTriggerFailures
+		response.LastUpdateHealthProbeResults = t.lastUpdateHealthProbeResults
+		response.HealthProbesInProgress = t.healthProbesInProgress
 	}
 	response.InitialImageName = t.initialImageName
+	response.ServesObjects = t.config.ServeObjects
 	response.LastSuccessfulImageName = t.lastSuccessfulImageName
 	response.LastNote = t.lastNote
 	response.LastWriteError = t.lastWriteError
@@ -54,6 +57,7 @@ func (t *rpcType) Poll(conn *srpc.Conn) error {
 	response.FreeSpace = t.getFreeSpace()
 	response.DisruptionState = t.disruptionState
 	t.rwLock.RUnlock()
+	response.CorrectedFileCount, response.NewFileCount = t.getDriftCounts()
 	response.StartTime = startTime
 	response.PollTime = time.Now()
 	response.ScanCount = t.params.FileSystemHistory.ScanCount()
@@ -94,6 +98,12 @@ func (t *rpcType) getFreeSpace() *uint64 {
 			return nil
 		}
 		retval := uint64(statbuf.Bfree * uint64(statbuf.Bsize))
+		// Keep space for the rollback area of the next update.
+		if retval > t.rollbackAreaSize {
+			retval -= t.rollbackAreaSize
+		} else {
+			retval = 0
+		}
 		return &retval
 	}
 }
@@ -0,0 +1,76 @@
+package rpcd
+
+import (
+	"errors"
+	"os"
+
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	"github.com/Cloud-Foundations/Dominator/proto/sub"
+	"github.com/Cloud-Foundations/Dominator/sub/lib"
+)
+
+func (t *rpcType) Rollback(conn *srpc.Conn, request sub.RollbackRequest,
+	reply *sub.RollbackResponse) error {
+	if t.config.RollbackDirectoryName == "" {
+		return errors.New("transactional updates not enabled")
+	}
+	if err := t.getUpdateLock(conn); err != nil {
+		t.params.Logger.Println(err)
+		return err
+	}
+	t.params.Logger.Printf("Rollback(%s)\n", conn.Username())
+	defer t.clearUpdateInProgress()
+	defer t.params.ScannerConfiguration.BoostCpuLimit(t.params.Logger)
+	t.params.DisableScannerFunction(true)
+	defer t.params.DisableScannerFunction(false)
+	t.probedTriggers = nil
+	t.triggerFailures = nil
+	options := lib.UpdateOptions{
+		Logger:            t.params.Logger,
+		ObjectsDir:        t.config.ObjectsDirectoryName,
+		RollbackDir:       t.config.RollbackDirectoryName,
+		RootDirectoryName: t.config.RootDirectoryName,
+		RunTriggers:       t.runTriggers,
+		SkipFilter:        t.params.ScannerConfiguration.ScanFilter,
+	}
+	var hadTriggerFailures bool
+	var imageName string
+	var err error
+	t.params.WorkdirGoroutine.Run(func() {
+		imageName, hadTriggerFailures, err = lib.Rollback(options)
+	})
+	t.lastUpdateHadTriggerFailures = hadTriggerFailures
+	t.lastUpdateError = err
+	t.startHealthProbes()
+	t.updateRollbackAreaSize()
+	if err != nil {
+		t.params.Logger.Printf("Rollback(): error: %s\n", err)
+		return err
+	}
+	t.resetDriftBaseline()
+	t.rwLock.Lock()
+	t.lastSuccessfulImageName = imageName
+	t.rwLock.Unlock()
+	t.params.Logger.Printf("Rollback(): restored: %s\n", imageName)
+	reply.ImageName = imageName
+	return nil
+}
+
+// updateRollbackAreaSize records the disk usage of the rollback area, which is
+// counted against the free space.
+func (t *rpcType) updateRollbackAreaSize() {
+	if t.config.RollbackDirectoryName == "" {
+		return
+	}
+	var size uint64
+	t.params.WorkdirGoroutine.Run(func() {
+		var err error
+		size, err = lib.GetRollbackAreaSize(t.config.RollbackDirectoryName)
+		if err != nil && !os.IsNotExist(err) {
+			t.params.Logger.Println(err)
+		}
+	})
+	t.rwLock.Lock()
+	t.rollbackAreaSize = size
+	t.rwLock.Unlock()
+}
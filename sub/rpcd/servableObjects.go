@@ -0,0 +1,109 @@
+package rpcd
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/Cloud-Foundations/Dominator/lib/fsutil"
+	"github.com/Cloud-Foundations/Dominator/lib/hash"
+	"github.com/Cloud-Foundations/Dominator/lib/log"
+)
+
+// maxServableObjects bounds the memory used to track servable objects
+// (64 bytes per object). When it is exceeded, older objects are forgotten.
+const maxServableObjects = 1 << 20
+
+// servableObjects records the objects which were fetched for images. Only
+// these objects are served to peers, since other files on the sub (such as
+// computed files and host keys) may be secret. The objects are recorded in a
+// file so that they may be served after subd restarts.
+type servableObjects struct {
+	filename string
+	logger   log.Logger
+	mutex    sync.RWMutex // Protect everything below.
+	objects  map[hash.Hash]struct{}
+}
+
+func loadServableObjects(filename string,
+	logger log.Logger) *servableObjects {
+	so := &servableObjects{
+		filename: filename,
+		logger:   logger,
+		objects:  make(map[hash.Hash]struct{}),
+	}
+	if filename == "" {
+		return so
+	}
+	file, err := os.Open(filename)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Println(err)
+		}
+		return so
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+	for len(so.objects) < maxServableObjects {
+		var hashVal hash.Hash
+		if _, err := io.ReadFull(reader, hashVal[:]); err != nil {
+			break // Ignore a partially written hash.
+		}
+		so.objects[hashVal] = struct{}{}
+	}
+	return so
+}
+
+// add records objects which may be served.
+func (so *servableObjects) add(hashes []hash.Hash) {
+	if so.filename == "" {
+		return
+	}
+	so.mutex.Lock()
+	defer so.mutex.Unlock()
+	var newHashes []hash.Hash
+	for _, hashVal := range hashes {
+		if _, ok := so.objects[hashVal]; !ok {
+			newHashes = append(newHashes, hashVal)
+		}
+	}
+	if len(newHashes) < 1 {
+		return
+	}
+	if len(so.objects)+len(newHashes) > maxServableObjects {
+		// Start again with the latest objects, which are most likely to be
+		// wanted by peers.
+		so.objects = make(map[hash.Hash]struct{}, len(newHashes))
+		if err := os.Remove(so.filename); err != nil && !os.IsNotExist(err) {
+			so.logger.Println(err)
+		}
+	}
+	for _, hashVal := range newHashes {
+		so.objects[hashVal] = struct{}{}
+	}
+	file, err := os.OpenFile(so.filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY,
+		fsutil.PrivateFilePerms)
+	if err != nil {
+		so.logger.Println(err)
+		return
+	}
+	defer file.Close()
+	writer := bufio.NewWriter(file)
+	for _, hashVal := range newHashes {
+		writer.Write(hashVal[:])
+	}
+	if err := writer.Flush(); err != nil {
+		so.logger.Println(err)
+	}
+}
+
+func (so *servableObjects) contains(hashVal hash.Hash) bool {
+	if so == nil {
+		return false
+	}
+	so.mutex.RLock()
+	defer so.mutex.RUnlock()
+	_, ok := so.objects[hashVal]
+	return ok
+}
@@ -0,0 +1,81 @@
+package rpcd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Cloud-Foundations/Dominator/lib/goroutine"
+	"github.com/Cloud-Foundations/Dominator/lib/hash"
+	"github.com/Cloud-Foundations/Dominator/lib/log/testlogger"
+	"github.com/Cloud-Foundations/Dominator/lib/objectcache"
+	"github.com/Cloud-Foundations/Dominator/sub/scanner"
+)
+
+func TestServableObjects(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "servable-objects")
+	logger := testlogger.New(t)
+	so := loadServableObjects(filename, logger)
+	hash0 := hash.Hash{0}
+	hash1 := hash.Hash{1}
+	hash2 := hash.Hash{2}
+	so.add([]hash.Hash{hash0, hash1})
+	so.add([]hash.Hash{hash1})
+	so = loadServableObjects(filename, logger)
+	if !so.contains(hash0) || !so.contains(hash1) {
+		t.Error("fetched objects not servable after reload")
+	}
+	if so.contains(hash2) {
+		t.Error("unfetched object is servable")
+	}
+	if len(so.objects) != 2 {
+		t.Errorf("expected 2 objects, got: %d", len(so.objects))
+	}
+	var nilObjects *servableObjects
+	if nilObjects.contains(hash0) {
+		t.Error("object servable when serving is disabled")
+	}
+}
+
+func TestOpenObjectOnlyServable(t *testing.T) {
+	objectsDir := t.TempDir()
+	logger := testlogger.New(t)
+	servable := hash.Hash{1}
+	secret := hash.Hash{2}
+	for _, hashVal := range []hash.Hash{servable, secret} {
+		filename := filepath.Join(objectsDir,
+			objectcache.HashToFilename(hashVal))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filename, []byte("data"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rpcObj := &rpcType{
+		params: Params{
+			FileSystemHistory: &scanner.FileSystemHistory{},
+			WorkdirGoroutine:  goroutine.New(),
+		},
+		servableObjects: loadServableObjects(
+			filepath.Join(t.TempDir(), "servable-objects"), logger),
+	}
+	rpcObj.servableObjects.add([]hash.Hash{servable})
+	handler := &addObjectsHandlerType{
+		objectsDir: objectsDir,
+		logger:     logger,
+		rpcObj:     rpcObj,
+	}
+	file, size, err := handler.openObject(servable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	if size != 4 {
+		t.Errorf("expected size: 4, got: %d", size)
+	}
+	if file, _, err := handler.openObject(secret); err == nil {
+		file.Close()
+		t.Error("object which was not fetched was served")
+	}
+}
@@ -25,6 +25,7 @@ func (t *rpcType) SetConfiguration(conn *srpc.Conn,
 		t.params.ScannerConfiguration.FsScanContext.GetContext().SetSpeedPercent(
 			request.ScanSpeedPercent)
 	}
+	t.params.ScannerConfiguration.ApplyCgroupLimits(t.params.Logger)
 	newFilter, err := filter.New(request.ScanExclusionList)
 	if err != nil {
 		return err
@@ -0,0 +1,99 @@
+package rpcd
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/Cloud-Foundations/Dominator/lib/log"
+)
+
+// runSystemdActions runs the actions using systemctl. Units with the same
+// action are passed to a single systemctl command, so that systemd orders the
+// jobs according to the dependencies between the units.
+func runSystemdActions(serviceActions []serviceAction, logPrefix string,
+	logger log.Logger) []serviceFailure {
+	var actions []string
+	unitsPerAction := make(map[string][]string)
+	for _, serviceAction := range serviceActions {
+		units, ok := unitsPerAction[serviceAction.action]
+		if !ok {
+			actions = append(actions, serviceAction.action)
+		}
+		unitsPerAction[serviceAction.action] =
+			append(units, serviceAction.service)
+	}
+	var failures []serviceFailure
+	for _, action := range actions {
+		units := unitsPerAction[action]
+		args := append([]string{action}, units...)
+		logger.Printf("%sAction: systemctl %s\n",
+			logPrefix, strings.Join(args, " "))
+		if *disableTriggers {
+			continue
+		}
+		output, err := triggerCommand("systemctl", args...).CombinedOutput()
+		if err == nil {
+			continue
+		}
+		logger.Printf("Error running: systemctl %s: %s\n",
+			strings.Join(args, " "), err)
+		if len(output) > 0 {
+			logger.Println(string(output))
+		}
+		failedUnits := findFailedUnits(action, units)
+		if len(failedUnits) < 1 {
+			failedUnits = units // Cannot tell which failed: blame them all.
+		}
+		for _, unit := range failedUnits {
+			details := fmt.Sprintf("systemctl %s %s failed:\n%s",
+				action, unit, getUnitFailureDetails(unit))
+			logger.Println(details)
+			failures = append(failures, serviceFailure{
+				details: details,
+				service: unit,
+			})
+		}
+	}
+	return failures
+}
+
+// findFailedUnits returns the units which are not in the state expected after
+// the action.
+func findFailedUnits(action string, units []string) []string {
+	var failedUnits []string
+	for _, unit := range units {
+		if action == "stop" {
+			err := exec.Command("systemctl", "is-failed", "--quiet",
+				unit).Run()
+			if err == nil {
+				failedUnits = append(failedUnits, unit)
+			}
+		} else {
+			err := exec.Command("systemctl", "is-active", "--quiet",
+				unit).Run()
+			if err != nil {
+				failedUnits = append(failedUnits, unit)
+			}
+		}
+	}
+	return failedUnits
+}
+
+// getUnitFailureDetails returns the status of the unit and the tail of its
+// journal.
+func getUnitFailureDetails(unit string) string {
+	// The exit status of "systemctl status" reflects the state of the unit.
+	status, _ := exec.Command("systemctl", "status", "--no-pager", "--lines=0",
+		unit).CombinedOutput()
+	journal, err := exec.Command("journalctl", "--no-pager", "--quiet",
+		"--lines="+strconv.FormatUint(uint64(*triggerJournalLines), 10),
+		"--unit="+unit).CombinedOutput()
+	if err != nil {
+		journal = append(journal, []byte("error reading journal: "+
+			err.Error())...)
+	}
+	return strings.TrimSpace(string(status)) + "\n" +
+		strings.TrimSpace(string(journal))
+}
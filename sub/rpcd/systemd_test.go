@@ -0,0 +1,31 @@
+package rpcd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Cloud-Foundations/Dominator/lib/log/testlogger"
+)
+
+func TestRunSystemdActions(t *testing.T) {
+	commands := fakeTriggerCommands(t, nil)
+	failures := runSystemdActions([]serviceAction{
+		{"stop", "a"},
+		{"reload", "b"},
+		{"stop", "c"},
+		{"try-restart", "d"},
+		{"reload", "e"},
+	}, "", testlogger.New(t))
+	if len(failures) > 0 {
+		t.Errorf("failures: %v", failures)
+	}
+	// The units are grouped by action, in the order first seen.
+	expected := []string{
+		"systemctl stop a c",
+		"systemctl reload b e",
+		"systemctl try-restart d",
+	}
+	if !reflect.DeepEqual(*commands, expected) {
+		t.Errorf("commands: %v, expected: %v", *commands, expected)
+	}
+}
@@ -5,8 +5,11 @@ import (
 	"encoding/json"
 	"errors"
 	"flag"
+	"fmt"
 	"os"
+	"os/exec"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -26,12 +29,38 @@ var (
 		"If true, refuse all Update requests. For debugging only")
 	disableTriggers = flag.Bool("disableTriggers", false,
 		"If true, do not run any triggers. For debugging only")
+	triggerJournalLines = flag.Uint("triggerJournalLines", 20,
+		"Number of journal lines to collect for a failed systemd unit")
+	triggerRunner = flag.String("triggerRunner", "auto",
+		"Trigger runner: auto, init or systemd")
+)
+
+// triggerCommand returns a Cmd for running a trigger action. Setup() replaces
+// it if trigger actions are run in a control group, in which case
+// releaseTriggerProcesses moves daemons started by an init script out of the
+// group, so that they are not limited for good.
+var (
+	triggerCommand          = exec.Command
+	releaseTriggerProcesses = func() error { return nil }
 )
 
 type flusher interface {
 	Flush() error
 }
 
+type serviceAction struct {
+	action  string
+	service string
+}
+
+type serviceFailure struct {
+	details string // If empty, the failure is only logged.
+	service string
+}
+
+type serviceRunner func(serviceActions []serviceAction, logPrefix string,
+	logger log.Logger) []serviceFailure
+
 func (t *rpcType) Update(conn *srpc.Conn, request sub.UpdateRequest,
 	reply *sub.UpdateResponse) error {
 	if err := t.getUpdateLock(conn); err != nil {
@@ -79,6 +108,8 @@ func (t *rpcType) updateAndUnlock(request sub.UpdateRequest,
 	t.params.DisableScannerFunction(true)
 	defer t.params.DisableScannerFunction(false)
 	startTime := time.Now()
+	t.probedTriggers = nil
+	t.triggerFailures = nil
 	oldTriggers := &triggers.MergeableTriggers{}
 	file, err := os.Open(t.config.OldTriggersFilename)
 	if err == nil {
@@ -115,6 +146,7 @@ func (t *rpcType) updateAndUnlock(request sub.UpdateRequest,
 		Logger:            t.params.Logger,
 		ObjectsDir:        t.config.ObjectsDirectoryName,
 		OldTriggers:       oldTriggers.ExportTriggers(),
+		RollbackDir:       t.config.RollbackDirectoryName,
 		RootDirectoryName: rootDirectoryName,
 		RunTriggers:       t.runTriggers,
 		SkipFilter:        t.params.ScannerConfiguration.ScanFilter,
@@ -123,16 +155,27 @@ func (t *rpcType) updateAndUnlock(request sub.UpdateRequest,
 		options.DisruptionCancel = t.disruptionCancel
 		options.DisruptionRequest = t.disruptionRequest
 	}
+	t.rwLock.RLock()
+	sameImage := !request.SparseImage && request.ImageName != "" &&
+		request.ImageName == t.lastSuccessfulImageName
+	t.rwLock.RUnlock()
 	t.params.WorkdirGoroutine.Run(func() {
 		hadTriggerFailures, fsChangeDuration, lastUpdateError =
 			lib.UpdateWithOptions(request, options)
 	})
 	t.lastUpdateHadTriggerFailures = hadTriggerFailures
 	t.lastUpdateError = lastUpdateError
+	t.startHealthProbes()
+	t.updateRollbackAreaSize()
 	timeTaken := time.Since(startTime)
 	if t.lastUpdateError != nil {
 		t.params.Logger.Printf("Update(): last error: %s\n", t.lastUpdateError)
 	} else {
+		if sameImage {
+			t.recordCorrections(request)
+		}
+		t.resetDriftBaseline()
+		t.setDriftImageFilter(request)
 		note, err := t.generateNote()
 		if err != nil {
 			t.params.Logger.Println(err)
@@ -146,6 +189,10 @@ func (t *rpcType) updateAndUnlock(request sub.UpdateRequest,
 		}
 		t.rwLock.Unlock()
 	}
+	if t.lastUpdateError == nil && len(t.triggerFailures) > 0 {
+		t.lastUpdateError = errors.New("trigger failures:\n" +
+			strings.Join(t.triggerFailures, "\n"))
+	}
 	t.params.Logger.Printf("Update() completed in %s (change window: %s)\n",
 		timeTaken, fsChangeDuration)
 	return t.lastUpdateError
@@ -162,7 +209,11 @@ func (t *rpcType) runTriggers(triggers []*triggers.Trigger, action string,
 	logger log.Logger) bool {
 	var retval bool
 	t.systemGoroutine.Run(func() {
-		retval = runTriggers(triggers, action, logger)
+		hadFailures, failures, probedTriggers := runTriggers(triggers, action,
+			logger)
+		retval = hadFailures
+		t.triggerFailures = append(t.triggerFailures, failures...)
+		t.probedTriggers = append(t.probedTriggers, probedTriggers...)
 	})
 	return retval
 }
@@ -204,10 +255,99 @@ func normalRebootAndWait(logger log.Logger) {
 	}
 }
 
-// Returns true if there were failures.
+// getServiceAction returns the action to perform for the trigger, or an empty
+// string if there is nothing to do.
+func getServiceAction(trigger *triggers.Trigger, action string) string {
+	if trigger.Action == triggers.ActionDefault {
+		return action
+	}
+	if action == "start" {
+		return trigger.Action
+	}
+	return ""
+}
+
+func getServiceRunner(logger log.Logger) serviceRunner {
+	switch *triggerRunner {
+	case "init":
+		return runInitActions
+	case "systemd":
+		return runSystemdActions
+	case "auto":
+	default:
+		logger.Printf("Unknown trigger runner: %s, using auto\n",
+			*triggerRunner)
+	}
+	// This is how sd_booted(3) detects systemd.
+	if fi, err := os.Stat("/run/systemd/system"); err == nil && fi.IsDir() {
+		return runSystemdActions
+	}
+	return runInitActions
+}
+
+func runInitActions(serviceActions []serviceAction, logPrefix string,
+	logger log.Logger) []serviceFailure {
+	var failures []serviceFailure
+	for _, serviceAction := range serviceActions {
+		logger.Printf("%sAction: service %s %s\n",
+			logPrefix, serviceAction.service, serviceAction.action)
+		if *disableTriggers {
+			continue
+		}
+		action := serviceAction.action
+		switch action {
+		case triggers.ActionReloadOrRestart:
+			if runTriggerCommand(logger, "service", serviceAction.service,
+				triggers.ActionReload) == nil {
+				continue
+			}
+			action = triggers.ActionRestart
+		case triggers.ActionTryRestart:
+			// Init scripts do not all support try-restart.
+			err := triggerCommand("service", serviceAction.service,
+				"status").Run()
+			if err != nil {
+				logger.Printf("%s not running, not restarting\n",
+					serviceAction.service)
+				continue
+			}
+			action = triggers.ActionRestart
+		}
+		err := runTriggerCommand(logger, "service", serviceAction.service,
+			action)
+		if err != nil {
+			failures = append(failures, serviceFailure{
+				details: err.Error(),
+				service: serviceAction.service,
+			})
+		}
+	}
+	return failures
+}
+
+// runTriggerCommand runs a trigger action. If there is an error, a message is
+// logged and an error containing the output of the command is returned.
+func runTriggerCommand(logger log.Logger, name string, args ...string) error {
+	output, err := triggerCommand(name, args...).CombinedOutput()
+	if err := releaseTriggerProcesses(); err != nil {
+		logger.Printf("error releasing trigger processes: %s\n", err)
+	}
+	if err != nil {
+		command := name + " " + strings.Join(args, " ")
+		logger.Printf("error running: %s: %s\n", command, err)
+		logger.Println(string(output))
+		return fmt.Errorf("%s failed: %s\n%s",
+			command, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// Returns true if there were failures, the details of the failures and the
+// triggers for the started services which have health probes.
 func runTriggers(triggerList []*triggers.Trigger, action string,
-	logger log.Logger) bool {
+	logger log.Logger) (bool, []string, []*triggers.Trigger) {
 	hadFailures := false
+	var failureDetails []string
 	needRestart := false
 	logPrefix := ""
 	var rebootingTriggers []*triggers.Trigger
@@ -225,9 +365,11 @@ func runTriggers(triggerList []*triggers.Trigger, action string,
 		} else {
 			logger.Printf("%sWill reboot on start, skipping %s actions\n",
 				logPrefix, action)
-			return hadFailures
+			return hadFailures, nil, nil
 		}
 	}
+	var serviceActions []serviceAction
+	var probedTriggers []*triggers.Trigger
 	for _, trigger := range triggerList {
 		if trigger.Service == "subd" {
 			// Never kill myself, just restart. Must do it last, so that other
@@ -237,17 +379,28 @@ func runTriggers(triggerList []*triggers.Trigger, action string,
 			}
 			continue
 		}
-		logger.Printf("%sAction: service %s %s\n",
-			logPrefix, trigger.Service, action)
-		if *disableTriggers {
-			continue
+		if serviceActionName := getServiceAction(trigger,
+			action); serviceActionName != "" {
+			serviceActions = append(serviceActions, serviceAction{
+				action:  serviceActionName,
+				service: trigger.Service,
+			})
+			if action == "start" && len(trigger.HealthProbes) > 0 &&
+				!*disableTriggers {
+				probedTriggers = append(probedTriggers, trigger)
+			}
 		}
-		if !osutil.RunCommand(logger, "service", trigger.Service, action) {
-			// Ignore failure for the "reboot" service: try later.
-			if action != "start" ||
-				!trigger.DoReboot ||
-				trigger.Service != "reboot" {
-				hadFailures = true
+	}
+	runServiceActions := getServiceRunner(logger)
+	for _, failure := range runServiceActions(serviceActions, logPrefix,
+		logger) {
+		// Ignore failure for the "reboot" service: try later.
+		if action != "start" ||
+			len(rebootingTriggers) < 1 ||
+			failure.service != "reboot" {
+			hadFailures = true
+			if failure.details != "" {
+				failureDetails = append(failureDetails, failure.details)
 			}
 		}
 	}
@@ -255,11 +408,11 @@ func runTriggers(triggerList []*triggers.Trigger, action string,
 		if hadFailures {
 			logger.Printf("%sSome triggers failed, will not reboot\n",
 				logPrefix)
-			return hadFailures
+			return hadFailures, failureDetails, nil
 		}
 		logger.Printf("%sRebooting\n", logPrefix)
 		if *disableTriggers {
-			return hadFailures
+			return hadFailures, failureDetails, nil
 		}
 		// If we get here, we are going to reboot and try harder if it fails.
 		if logger, ok := logger.(flusher); ok {
@@ -281,13 +434,19 @@ func runTriggers(triggerList []*triggers.Trigger, action string,
 			logger.Printf("%sHard reboot failed: %s\n", logPrefix, err)
 		}
 		time.Sleep(time.Second)
-		return true
+		return true, failureDetails, nil
 	}
 	if needRestart {
-		logger.Printf("%sAction: service subd restart\n", logPrefix)
-		if !osutil.RunCommand(logger, "service", "subd", "restart") {
+		failures := runServiceActions([]serviceAction{{
+			action:  triggers.ActionRestart,
+			service: "subd",
+		}}, logPrefix, logger)
+		for _, failure := range failures {
 			hadFailures = true
+			if failure.details != "" {
+				failureDetails = append(failureDetails, failure.details)
+			}
 		}
 	}
-	return hadFailures
+	return hadFailures, failureDetails, probedTriggers
 }
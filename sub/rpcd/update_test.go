@@ -0,0 +1,83 @@
+package rpcd
+
+import (
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Cloud-Foundations/Dominator/lib/log/testlogger"
+	"github.com/Cloud-Foundations/Dominator/lib/triggers"
+)
+
+// fakeTriggerCommands replaces triggerCommand with a command which runs the
+// shell command for the action (or true) and records the commands which were
+// run.
+func fakeTriggerCommands(t *testing.T,
+	shellCommands map[string]string) *[]string {
+	var commands []string
+	oldTriggerCommand := triggerCommand
+	t.Cleanup(func() { triggerCommand = oldTriggerCommand })
+	triggerCommand = func(name string, args ...string) *exec.Cmd {
+		command := name + " " + strings.Join(args, " ")
+		commands = append(commands, command)
+		if shellCommand, ok := shellCommands[command]; ok {
+			return exec.Command("/bin/sh", "-c", shellCommand)
+		}
+		return exec.Command("true")
+	}
+	return &commands
+}
+
+func TestGetServiceAction(t *testing.T) {
+	tests := []struct {
+		triggerAction string
+		action        string
+		expected      string
+	}{
+		{triggers.ActionDefault, "stop", "stop"},
+		{triggers.ActionDefault, "start", "start"},
+		{triggers.ActionReload, "stop", ""},
+		{triggers.ActionReload, "start", triggers.ActionReload},
+		{triggers.ActionTryRestart, "stop", ""},
+		{triggers.ActionTryRestart, "start", triggers.ActionTryRestart},
+	}
+	for _, test := range tests {
+		trigger := &triggers.Trigger{Service: "x", Action: test.triggerAction}
+		if action := getServiceAction(trigger, test.action); action !=
+			test.expected {
+			t.Errorf("%q on %s: %q, expected: %q",
+				test.triggerAction, test.action, action, test.expected)
+		}
+	}
+}
+
+func TestRunInitActions(t *testing.T) {
+	commands := fakeTriggerCommands(t, map[string]string{
+		"service a reload":  "exit 1",
+		"service b restart": "echo broken; exit 1",
+		"service c status":  "exit 3",
+	})
+	failures := runInitActions([]serviceAction{
+		{triggers.ActionReloadOrRestart, "a"},
+		{triggers.ActionTryRestart, "b"},
+		{triggers.ActionTryRestart, "c"},
+	}, "", testlogger.New(t))
+	expected := []string{
+		"service a reload",
+		"service a restart",
+		"service b status",
+		"service b restart",
+		"service c status",
+	}
+	if !reflect.DeepEqual(*commands, expected) {
+		t.Errorf("commands: %v, expected: %v", *commands, expected)
+	}
+	if len(failures) != 1 {
+		t.Fatalf("failures: %v, expected one", failures)
+	}
+	if failures[0].service != "b" ||
+		!strings.Contains(failures[0].details, "broken") {
+		t.Errorf("failure: %v, expected details for b", failures[0])
+	}
+}
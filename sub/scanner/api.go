@@ -3,9 +3,11 @@ package scanner
 import (
 	"fmt"
 	"io"
+	"os/exec"
 	"sync"
 	"time"
 
+	"github.com/Cloud-Foundations/Dominator/lib/cgroup"
 	"github.com/Cloud-Foundations/Dominator/lib/cpulimiter"
 	"github.com/Cloud-Foundations/Dominator/lib/filesystem/scanner"
 	"github.com/Cloud-Foundations/Dominator/lib/filter"
@@ -17,14 +19,64 @@ import (
 	"github.com/Cloud-Foundations/tricorder/go/tricorder"
 )
 
+// Cgroups holds the cgroup v2 control groups which limit the resources used
+// for scanning, fetching and running triggers.
+type Cgroups struct {
+	daemon                   *cgroup.Group // All other subd threads.
+	fetch                    *cgroup.Group // Threaded: writing objects.
+	scan                     *cgroup.Group // Threaded: scanning.
+	services                 *cgroup.Group // Daemons started by triggers.
+	triggers                 *cgroup.Group
+	rootDevice               uint64
+	rootDeviceBytesPerSecond uint64
+}
+
+// NewCgroups moves the process into a new control group and creates the
+// control groups for scanning, fetching and running triggers. The process must
+// have been delegated its control group. The I/O limits apply to rootDevice,
+// which has a maximum speed of rootDeviceBytesPerSecond. Trigger commands are
+// limited to triggerMemoryLimit bytes of memory, if not zero.
+func NewCgroups(rootDevice, rootDeviceBytesPerSecond uint64,
+	triggerMemoryLimit uint64) (*Cgroups, error) {
+	return newCgroups(rootDevice, rootDeviceBytesPerSecond, triggerMemoryLimit)
+}
+
+// MoveFetchThread moves the calling OS thread, which should be the thread
+// where objects are written, into the fetch control group.
+func (c *Cgroups) MoveFetchThread() error {
+	return c.fetch.MoveMyThread()
+}
+
+// ReleaseTriggerProcesses moves processes which were left in the triggers
+// control group, such as daemons started by init scripts, into a control group
+// without limits. It should be called after each trigger command completes.
+func (c *Cgroups) ReleaseTriggerProcesses() error {
+	return c.releaseTriggerProcesses()
+}
+
+// TriggerCommand returns a Cmd which will run the named program in the
+// triggers control group.
+func (c *Cgroups) TriggerCommand(name string, args ...string) *exec.Cmd {
+	return c.triggers.Command(name, args...)
+}
+
 type Configuration struct {
+	Cgroups              *Cgroups // If nil, CpuLimiter and FsScanContext limit.
 	CpuLimiter           *cpulimiter.CpuLimiter
 	DefaultCpuPercent    uint
 	FsScanContext        *fsrateio.ReaderContext
+	FullScanInterval     time.Duration // Used if IncrementalScanning is true.
+	IncrementalScanning  bool          // Watch for changes between full scans.
 	NetworkReaderContext *rateio.ReaderContext
 	ScanFilter           *filter.Filter
 }
 
+// ApplyCgroupLimits applies the limits recorded in CpuLimiter, FsScanContext
+// and NetworkReaderContext to the control groups, if used.
+func (configuration *Configuration) ApplyCgroupLimits(logger log.Logger) {
+	configuration.applyCgroupLimits(logger)
+}
+
 func (configuration *Configuration) BoostCpuLimit(logger log.Logger) {
 	configuration.boostCpuLimit(logger)
 }
@@ -58,6 +110,7 @@ type FileSystemHistory struct {
 	timeOfLastScan     time.Time
 	durationOfLastScan time.Duration
 	timeOfLastChange   time.Time
+	scanNotifiers      []chan<- struct{}
 }
 
 func (fsh *FileSystemHistory) DurationOfLastScan() time.Duration {
@@ -78,6 +131,14 @@ func (fsh *FileSystemHistory) GenerationCount() uint64 {
 	return fsh.generationCount
 }
 
+// RegisterScanNotifier registers a channel which is sent a value after each
+// completed scan. Notifications are dropped if the channel is full.
+func (fsh *FileSystemHistory) RegisterScanNotifier(channel chan<- struct{}) {
+	fsh.rwMutex.Lock()
+	defer fsh.rwMutex.Unlock()
+	fsh.scanNotifiers = append(fsh.scanNotifiers, channel)
+}
+
 func (fsh *FileSystemHistory) ScanCount() uint64 {
 	fsh.rwMutex.RLock()
 	defer fsh.rwMutex.RUnlock()
@@ -107,6 +168,8 @@ func (fsh *FileSystemHistory) WriteHtml(writer io.Writer) {
 type FileSystem struct {
 	configuration     *Configuration
 	rootDirectoryName string
+	incremental       bool
+	scanStartTime     time.Time
 	scanner.FileSystem
 	cacheDirectoryName string
 	objectcache.ObjectCache
@@ -115,7 +178,7 @@ type FileSystem struct {
 func ScanFileSystem(rootDirectoryName string, cacheDirectoryName string,
 	configuration *Configuration) (*FileSystem, error) {
 	return scanFileSystem(rootDirectoryName, cacheDirectoryName, configuration,
-		&FileSystem{})
+		&FileSystem{}, nil)
 }
 
 func (fs *FileSystem) ScanObjectCache() error {
@@ -126,6 +189,11 @@ func (fs *FileSystem) Configuration() *Configuration {
 	return fs.configuration
 }
 
+// Incremental returns true if only the changed paths were scanned.
+func (fs *FileSystem) Incremental() bool {
+	return fs.incremental
+}
+
 func (fs *FileSystem) RootDirectoryName() string {
 	return fs.rootDirectoryName
 }
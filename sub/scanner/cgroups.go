@@ -0,0 +1,123 @@
+package scanner
+
+import (
+	"errors"
+	"os"
+	"path"
+
+	"github.com/Cloud-Foundations/Dominator/lib/cgroup"
+)
+
+const daemonCgroupName = "subd"
+
+func newCgroups(rootDevice, rootDeviceBytesPerSecond uint64,
+	triggerMemoryLimit uint64) (*Cgroups, error) {
+	if !cgroup.Available() {
+		return nil, errors.New("cgroup v2 not available")
+	}
+	parent, err := cgroup.Self()
+	if err != nil {
+		return nil, err
+	}
+	// After a re-exec the process is already in its own group.
+	if path.Base(parent.Name()) == daemonCgroupName {
+		parent, err = cgroup.Open(path.Dir(parent.Name()))
+		if err != nil {
+			return nil, err
+		}
+	}
+	daemon, err := parent.CreateChild(daemonCgroupName, false)
+	if err != nil {
+		return nil, err
+	}
+	// Controllers may only be enabled for groups without processes.
+	if err := daemon.MoveProcess(os.Getpid()); err != nil {
+		return nil, err
+	}
+	cgroups, err := createCgroups(parent, daemon, triggerMemoryLimit)
+	if err != nil {
+		// Leave the process where it was, since the caller will fall back to
+		// other ways of limiting resources.
+		daemon.DisableControllers("cpu")
+		parent.DisableControllers("cpu", "io", "memory")
+		parent.MoveProcess(os.Getpid())
+		return nil, err
+	}
+	cgroups.rootDevice = rootDevice
+	cgroups.rootDeviceBytesPerSecond = rootDeviceBytesPerSecond
+	return cgroups, nil
+}
+
+// createCgroups creates the control groups after the process was moved into
+// the daemon group.
+func createCgroups(parent, daemon *cgroup.Group,
+	triggerMemoryLimit uint64) (*Cgroups, error) {
+	if err := parent.EnableControllers("cpu", "io", "memory"); err != nil {
+		return nil, err
+	}
+	triggers, err := parent.CreateChild("triggers", false)
+	if err != nil {
+		return nil, err
+	}
+	if triggerMemoryLimit > 0 {
+		if err := triggers.SetMemoryMax(triggerMemoryLimit); err != nil {
+			return nil, err
+		}
+	}
+	services, err := parent.CreateChild("services", false)
+	if err != nil {
+		return nil, err
+	}
+	scan, err := daemon.CreateChild("scan", true)
+	if err != nil {
+		return nil, err
+	}
+	fetch, err := daemon.CreateChild("fetch", true)
+	if err != nil {
+		return nil, err
+	}
+	if err := daemon.EnableControllers("cpu"); err != nil {
+		return nil, err
+	}
+	return &Cgroups{
+		daemon:   daemon,
+		fetch:    fetch,
+		scan:     scan,
+		services: services,
+		triggers: triggers,
+	}, nil
+}
+
+// releaseTriggerProcesses moves processes left in the triggers group (such as
+// daemons started by init scripts) into the unlimited services group.
+func (c *Cgroups) releaseTriggerProcesses() error {
+	pids, err := c.triggers.Processes()
+	if err != nil {
+		return err
+	}
+	for _, pid := range pids {
+		if err := c.services.MoveProcess(pid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setLimits sets the CPU limits for scanning, fetching and triggers and the
+// I/O limits for subd and triggers. The write limit for subd stops objects
+// from being written faster than they would be fetched with network rate
+// limiting.
+func (c *Cgroups) setLimits(cpuPercent uint, readBytesPerSecond uint64,
+	writeBytesPerSecond uint64) error {
+	for _, group := range []*cgroup.Group{c.scan, c.fetch, c.triggers} {
+		if err := group.SetCpuPercent(cpuPercent); err != nil {
+			return err
+		}
+	}
+	err := c.daemon.SetIoMax(c.rootDevice, readBytesPerSecond,
+		writeBytesPerSecond)
+	if err != nil {
+		return err
+	}
+	return c.triggers.SetIoMax(c.rootDevice, readBytesPerSecond, 0)
+}
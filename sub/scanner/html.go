@@ -12,6 +12,10 @@ import (
 
 func (fsh *FileSystemHistory) writeHtml(writer io.Writer) {
 	fmt.Fprintf(writer, "Scan count: %d<br>\n", fsh.scanCount)
+	if incrementalScanCount > 0 {
+		fmt.Fprintf(writer, "Full scans: %d, incremental scans: %d<br>\n",
+			fullScanCount, incrementalScanCount)
+	}
 	fmt.Fprintf(writer, "Generation count: %d<br>\n", fsh.generationCount)
 	if fsh.scanCount > 0 {
 		fmt.Fprintf(writer, "Last scan completed: %s<br>\n", fsh.timeOfLastScan)
@@ -46,6 +50,10 @@ func (configuration *Configuration) writeHtml(writer io.Writer) {
 			ctx.SpeedPercent(), format.FormatBytes(ctx.MaximumSpeed()))
 	}
 	fmt.Fprintf(writer, "Network Speed: %s<br>\n", speed)
+	if configuration.Cgroups != nil {
+		fmt.Fprintf(writer, "Resources limited by cgroup: %s<br>\n",
+			configuration.Cgroups.daemon.Name())
+	}
 }
 
 func (configuration *Configuration) showScanFilterHandler(
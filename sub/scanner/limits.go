@@ -4,6 +4,36 @@ import (
 	"github.com/Cloud-Foundations/Dominator/lib/log"
 )
 
+func (configuration *Configuration) applyCgroupLimits(logger log.Logger) {
+	cgroups := configuration.Cgroups
+	if cgroups == nil {
+		return
+	}
+	var cpuPercent uint
+	if configuration.CpuLimiter != nil {
+		cpuPercent = configuration.CpuLimiter.CpuPercent()
+	}
+	var readBytesPerSecond, writeBytesPerSecond uint64
+	if configuration.FsScanContext != nil {
+		sc := configuration.FsScanContext.GetContext()
+		if !sc.Disabled() {
+			readBytesPerSecond = cgroups.rootDeviceBytesPerSecond *
+				uint64(sc.SpeedPercent()) / 100
+		}
+	}
+	if nc := configuration.NetworkReaderContext; nc != nil {
+		if nc.SpeedPercent() < 100 {
+			writeBytesPerSecond = nc.MaximumSpeed() *
+				uint64(nc.SpeedPercent()) / 100
+		}
+	}
+	err := cgroups.setLimits(cpuPercent, readBytesPerSecond,
+		writeBytesPerSecond)
+	if err != nil {
+		logger.Printf("Error setting cgroup limits: %s\n", err)
+	}
+}
+
 func (configuration *Configuration) boostCpuLimit(logger log.Logger) {
 	if configuration.CpuLimiter != nil {
 		cl := configuration.CpuLimiter
@@ -12,6 +42,7 @@ func (configuration *Configuration) boostCpuLimit(logger log.Logger) {
 		}
 		cl.SetCpuPercent(100)
 	}
+	configuration.applyCgroupLimits(logger)
 }
 
 func (configuration *Configuration) boostScanLimit(logger log.Logger) {
@@ -22,6 +53,7 @@ func (configuration *Configuration) boostScanLimit(logger log.Logger) {
 		}
 		sc.DisableLimits(true)
 	}
+	configuration.applyCgroupLimits(logger)
 }
 
 func (configuration *Configuration) restoreCpuLimit(logger log.Logger) {
@@ -33,6 +65,7 @@ func (configuration *Configuration) restoreCpuLimit(logger log.Logger) {
 		}
 		cl.SetCpuPercent(configuration.DefaultCpuPercent)
 	}
+	configuration.applyCgroupLimits(logger)
 }
 
 func (configuration *Configuration) restoreScanLimit(logger log.Logger) {
@@ -44,4 +77,5 @@ func (configuration *Configuration) restoreScanLimit(logger log.Logger) {
 		}
 		sc.DisableLimits(false)
 	}
+	configuration.applyCgroupLimits(logger)
 }
@@ -7,12 +7,23 @@ import (
 
 var latencyBucketer *tricorder.Bucketer
 var scanTimeDistribution *tricorder.CumulativeDistribution
+var incrementalScanTimeDistribution *tricorder.CumulativeDistribution
+var fullScanCount uint64
+var incrementalScanCount uint64
 
 func init() {
 	latencyBucketer = tricorder.NewGeometricBucketer(1, 10e3)
 	scanTimeDistribution = latencyBucketer.NewCumulativeDistribution()
 	tricorder.RegisterMetric("/scan-time", scanTimeDistribution,
 		units.Second, "scan time")
+	incrementalScanTimeDistribution =
+		latencyBucketer.NewCumulativeDistribution()
+	tricorder.RegisterMetric("/incremental-scan-time",
+		incrementalScanTimeDistribution, units.Second, "incremental scan time")
+	tricorder.RegisterMetric("/full-scan-count", &fullScanCount, units.None,
+		"number of full scans")
+	tricorder.RegisterMetric("/incremental-scan-count", &incrementalScanCount,
+		units.None, "number of incremental scans")
 }
 
 func (configuration *Configuration) registerMetrics(
@@ -5,6 +5,7 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/Cloud-Foundations/Dominator/lib/filter"
 	"github.com/Cloud-Foundations/Dominator/lib/html"
 	"github.com/Cloud-Foundations/Dominator/lib/log"
 )
@@ -39,14 +40,59 @@ func scannerDaemon(rootDirectoryName string, cacheDirectoryName string,
 	configuration *Configuration, fsChannel chan<- *FileSystem,
 	logger log.Logger) {
 	runtime.LockOSThread()
+	if configuration.Cgroups != nil {
+		if err := configuration.Cgroups.scan.MoveMyThread(); err != nil {
+			logger.Printf("Error moving scanner to cgroup: %s\n", err)
+		}
+	}
+	var watcher *fsWatcher
+	if configuration.IncrementalScanning {
+		var err error
+		watcher, err = newFsWatcher(rootDirectoryName, configuration, logger)
+		if err != nil {
+			logger.Printf("Incremental scanning disabled: %s\n", err)
+		}
+	}
 	loweredPriority := false
 	var oldFS FileSystem
-	var sleepUntil time.Time
+	var nextFullScan, sleepUntil time.Time
+	var lastScanFilter *filter.Filter
 	for ; ; time.Sleep(time.Until(sleepUntil)) {
 		sleepUntil = time.Now().Add(time.Second)
+		var changedPaths map[string]struct{}
+		if watcher != nil && oldFS.InodeTable != nil {
+			select {
+			case <-watcher.notifyChannel:
+			case <-time.After(time.Until(nextFullScan)):
+			case <-disableScanRequest:
+				disableScanAcknowledge <- true
+				<-disableScanAcknowledge
+				continue
+			}
+			var needFullScan bool
+			changedPaths, needFullScan = watcher.takeChanges()
+			if needFullScan || time.Now().After(nextFullScan) ||
+				configuration.ScanFilter != lastScanFilter {
+				changedPaths = nil
+			} else if len(changedPaths) < 1 {
+				continue
+			}
+		}
+		if watcher != nil && changedPaths == nil {
+			nextFullScan = time.Now().Add(configuration.FullScanInterval)
+			lastScanFilter = configuration.ScanFilter
+			if err := watcher.watchTree(); err != nil {
+				logger.Printf("Incremental scanning disabled: %s\n", err)
+				watcher.close()
+				watcher = nil
+			}
+		}
 		fs, err := scanFileSystem(rootDirectoryName, cacheDirectoryName,
-			configuration, &oldFS)
+			configuration, &oldFS, changedPaths)
 		if err != nil {
+			if watcher != nil {
+				watcher.restoreChanges(changedPaths)
+			}
 			if err.Error() == "DisableScan" {
 				disableScanAcknowledge <- true
 				<-disableScanAcknowledge
@@ -56,6 +102,14 @@ func scannerDaemon(rootDirectoryName string, cacheDirectoryName string,
 		} else {
 			oldFS.InodeTable = fs.InodeTable
 			oldFS.DirectoryInode = fs.DirectoryInode
+			if watcher != nil {
+				if err := watcher.watchFileSystem(
+					&fs.FileSystem.FileSystem); err != nil {
+					logger.Printf("Incremental scanning disabled: %s\n", err)
+					watcher.close()
+					watcher = nil
+				}
+			}
 			fsChannel <- fs
 			runtime.GC()
 			if !loweredPriority {
@@ -18,8 +18,18 @@ func (fsh *FileSystemHistory) update(newFS *FileSystem) {
 	}
 	fsh.rwMutex.Lock()
 	defer fsh.rwMutex.Unlock()
-	fsh.durationOfLastScan = now.Sub(fsh.timeOfLastScan)
-	scanTimeDistribution.Add(fsh.durationOfLastScan)
+	if newFS.incremental {
+		incrementalScanCount++
+		incrementalScanTimeDistribution.Add(now.Sub(newFS.scanStartTime))
+	} else {
+		fullScanCount++
+		if incrementalScanCount > 0 { // Time between scans includes waiting.
+			fsh.durationOfLastScan = now.Sub(newFS.scanStartTime)
+		} else {
+			fsh.durationOfLastScan = now.Sub(fsh.timeOfLastScan)
+		}
+		scanTimeDistribution.Add(fsh.durationOfLastScan)
+	}
 	fsh.scanCount++
 	fsh.timeOfLastScan = now
 	if fsh.fileSystem == nil {
@@ -33,6 +43,12 @@ func (fsh *FileSystemHistory) update(newFS *FileSystem) {
 			fsh.timeOfLastChange = fsh.timeOfLastScan
 		}
 	}
+	for _, channel := range fsh.scanNotifiers {
+		select {
+		case channel <- struct{}{}:
+		default:
+		}
+	}
 }
 
 func (fsh *FileSystemHistory) updateObjectCacheOnly() error {
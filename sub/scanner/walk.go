@@ -1,23 +1,37 @@
 package scanner
 
 import (
+	"time"
+
 	"github.com/Cloud-Foundations/Dominator/lib/filesystem/scanner"
 	"github.com/Cloud-Foundations/Dominator/lib/objectcache"
 )
 
 func scanFileSystem(rootDirectoryName string, cacheDirectoryName string,
-	configuration *Configuration, oldFS *FileSystem) (*FileSystem, error) {
+	configuration *Configuration, oldFS *FileSystem,
+	changedPaths map[string]struct{}) (*FileSystem, error) {
 	var fileSystem FileSystem
 	fileSystem.configuration = configuration
 	fileSystem.rootDirectoryName = rootDirectoryName
+	fileSystem.incremental = changedPaths != nil
+	fileSystem.scanStartTime = time.Now()
 	fileSystem.cacheDirectoryName = cacheDirectoryName
 	hasher := scanner.GetSimpleHasher(true)
-	if configuration.CpuLimiter != nil {
+	fsScanContext := configuration.FsScanContext
+	if configuration.Cgroups != nil {
+		fsScanContext = nil // The control groups limit scanning.
+	} else if configuration.CpuLimiter != nil {
 		hasher = scanner.NewCpuLimitedHasher(configuration.CpuLimiter, hasher)
 	}
-	fs, err := scanner.ScanFileSystem(rootDirectoryName,
-		configuration.FsScanContext, configuration.ScanFilter,
-		checkScanDisableRequest, hasher, &oldFS.FileSystem)
+	fs, err := scanner.ScanFileSystemWithParams(scanner.Params{
+		ChangedPaths:            changedPaths,
+		CheckScanDisableRequest: checkScanDisableRequest,
+		FsScanContext:           fsScanContext,
+		Hasher:                  hasher,
+		OldFS:                   &oldFS.FileSystem,
+		RootDirectoryName:       rootDirectoryName,
+		ScanFilter:              configuration.ScanFilter,
+	})
 	if err != nil {
 		return nil, err
 	}
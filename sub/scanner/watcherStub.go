@@ -0,0 +1,35 @@
+// +build !linux
+
+package scanner
+
+import (
+	"errors"
+
+	"github.com/Cloud-Foundations/Dominator/lib/filesystem"
+	"github.com/Cloud-Foundations/Dominator/lib/log"
+)
+
+type fsWatcher struct {
+	notifyChannel chan struct{}
+}
+
+func newFsWatcher(rootDirectoryName string, configuration *Configuration,
+	logger log.Logger) (*fsWatcher, error) {
+	return nil, errors.New("watching for changes not supported")
+}
+
+func (w *fsWatcher) close() {}
+
+func (w *fsWatcher) requestFullScan() {}
+
+func (w *fsWatcher) restoreChanges(changedPaths map[string]struct{}) {}
+
+func (w *fsWatcher) takeChanges() (map[string]struct{}, bool) {
+	return nil, true
+}
+
+func (w *fsWatcher) watchTree() error { return nil }
+
+func (w *fsWatcher) watchFileSystem(fs *filesystem.FileSystem) error {
+	return nil
+}
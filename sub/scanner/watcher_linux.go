@@ -0,0 +1,258 @@
+package scanner
+
+import (
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/Cloud-Foundations/Dominator/lib/filesystem"
+	"github.com/Cloud-Foundations/Dominator/lib/fsutil"
+	"github.com/Cloud-Foundations/Dominator/lib/log"
+	"github.com/Cloud-Foundations/Dominator/lib/wsyscall"
+	"github.com/fsnotify/fsnotify"
+)
+
+type fsWatcher struct {
+	configuration      *Configuration
+	dev                uint64
+	logger             log.Logger
+	notifyChannel      chan struct{}
+	rootDirectoryName  string
+	watcher            *fsnotify.Watcher
+	mutex              sync.Mutex          // Protect everything below.
+	changedPaths       map[string]struct{} // Relative to the root.
+	needFullScan       bool
+	watchedDirectories map[string]struct{} // Relative to the root.
+}
+
+func newFsWatcher(rootDirectoryName string, configuration *Configuration,
+	logger log.Logger) (*fsWatcher, error) {
+	var stat wsyscall.Stat_t
+	if err := wsyscall.Lstat(rootDirectoryName, &stat); err != nil {
+		return nil, err
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	w := &fsWatcher{
+		configuration:      configuration,
+		dev:                stat.Dev,
+		logger:             logger,
+		notifyChannel:      make(chan struct{}, 1),
+		rootDirectoryName:  path.Clean(rootDirectoryName),
+		watcher:            watcher,
+		changedPaths:       make(map[string]struct{}),
+		watchedDirectories: make(map[string]struct{}),
+	}
+	go w.loop()
+	return w, nil
+}
+
+func (w *fsWatcher) close() {
+	w.watcher.Close()
+}
+
+func (w *fsWatcher) loop() {
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			w.handleEvent(event)
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			w.logger.Printf("Error watching for changes, will rescan: %s\n",
+				err)
+			w.requestFullScan()
+		}
+	}
+}
+
+func (w *fsWatcher) handleEvent(event fsnotify.Event) {
+	pathname, ok := w.relativePath(event.Name)
+	if !ok || w.ignore(pathname) {
+		return
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if event.Op&fsnotify.Rename != 0 {
+		// The watches below a renamed directory keep their old names.
+		if _, ok := w.watchedDirectories[pathname]; ok {
+			w.needFullScan = true
+		}
+	}
+	w.changedPaths[pathname] = struct{}{}
+	w.notify()
+}
+
+// ignore returns true if the path is not scanned.
+func (w *fsWatcher) ignore(pathname string) bool {
+	if pathname == "/.subd" || strings.HasPrefix(pathname, "/.subd/") {
+		return true
+	}
+	if scanFilter := w.configuration.ScanFilter; scanFilter != nil {
+		return scanFilter.Match(pathname)
+	}
+	return false
+}
+
+// notify must be called with the lock held.
+func (w *fsWatcher) notify() {
+	select {
+	case w.notifyChannel <- struct{}{}:
+	default:
+	}
+}
+
+func (w *fsWatcher) relativePath(pathname string) (string, bool) {
+	pathname = path.Clean(pathname)
+	if w.rootDirectoryName == "/" {
+		return pathname, true
+	}
+	if pathname == w.rootDirectoryName {
+		return "/", true
+	}
+	if !strings.HasPrefix(pathname, w.rootDirectoryName+"/") {
+		return "", false
+	}
+	return pathname[len(w.rootDirectoryName):], true
+}
+
+func (w *fsWatcher) requestFullScan() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.needFullScan = true
+	w.notify()
+}
+
+// restoreChanges puts back changes which were taken but not scanned. If
+// changedPaths is nil a full scan is requested.
+func (w *fsWatcher) restoreChanges(changedPaths map[string]struct{}) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if changedPaths == nil {
+		w.needFullScan = true
+	}
+	for pathname := range changedPaths {
+		w.changedPaths[pathname] = struct{}{}
+	}
+	w.notify()
+}
+
+// takeChanges returns the paths which changed since the last call and whether
+// a full scan is needed.
+func (w *fsWatcher) takeChanges() (map[string]struct{}, bool) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	changedPaths := w.changedPaths
+	needFullScan := w.needFullScan
+	w.changedPaths = make(map[string]struct{})
+	w.needFullScan = false
+	return changedPaths, needFullScan
+}
+
+// watchTree replaces all the watches with watches for every directory which
+// will be scanned. It is called prior to a full scan, so that changes made
+// during the scan will not be missed.
+func (w *fsWatcher) watchTree() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	for pathname := range w.watchedDirectories {
+		w.watcher.Remove(path.Join(w.rootDirectoryName, pathname))
+	}
+	w.watchedDirectories = make(map[string]struct{})
+	w.changedPaths = make(map[string]struct{})
+	w.needFullScan = false
+	return w.watchDirectory("/")
+}
+
+// watchDirectory must be called with the lock held.
+func (w *fsWatcher) watchDirectory(pathname string) error {
+	dirname := path.Join(w.rootDirectoryName, pathname)
+	if err := w.addWatch(pathname); err != nil {
+		return err
+	}
+	names, err := fsutil.ReadDirnames(dirname, true)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		childPathname := path.Join(pathname, name)
+		if w.ignore(childPathname) {
+			continue
+		}
+		var stat wsyscall.Stat_t
+		err := wsyscall.Lstat(path.Join(dirname, name), &stat)
+		if err != nil {
+			if err == syscall.ENOENT {
+				continue
+			}
+			return err
+		}
+		if stat.Dev != w.dev || stat.Mode&syscall.S_IFMT != syscall.S_IFDIR {
+			continue
+		}
+		if err := w.watchDirectory(childPathname); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// watchFileSystem adds watches for directories in the scanned file-system
+// which are not yet watched and forgets directories which are gone. Newly
+// watched directories are rescanned, since changes made before the watch was
+// added would have been missed.
+func (w *fsWatcher) watchFileSystem(fs *filesystem.FileSystem) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	directories := make(map[string]struct{}, fs.DirectoryCount)
+	listDirectories(&fs.DirectoryInode, "/", directories)
+	for pathname := range w.watchedDirectories {
+		if _, ok := directories[pathname]; !ok {
+			w.watcher.Remove(path.Join(w.rootDirectoryName, pathname))
+			delete(w.watchedDirectories, pathname)
+		}
+	}
+	for pathname := range directories {
+		if _, ok := w.watchedDirectories[pathname]; ok {
+			continue
+		}
+		if err := w.addWatch(pathname); err != nil {
+			return err
+		}
+		w.changedPaths[pathname] = struct{}{}
+		w.notify()
+	}
+	return nil
+}
+
+// addWatch must be called with the lock held.
+func (w *fsWatcher) addWatch(pathname string) error {
+	err := w.watcher.Add(path.Join(w.rootDirectoryName, pathname))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil // Removed: the parent directory will have changed.
+		}
+		return err
+	}
+	w.watchedDirectories[pathname] = struct{}{}
+	return nil
+}
+
+func listDirectories(directory *filesystem.DirectoryInode, myPathName string,
+	directories map[string]struct{}) {
+	directories[myPathName] = struct{}{}
+	for _, dirent := range directory.EntryList {
+		if inode, ok := dirent.Inode().(*filesystem.DirectoryInode); ok {
+			listDirectories(inode, path.Join(myPathName, dirent.Name),
+				directories)
+		}
+	}
+}
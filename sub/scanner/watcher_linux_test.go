@@ -0,0 +1,180 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/filesystem/scanner"
+	"github.com/Cloud-Foundations/Dominator/lib/filter"
+	"github.com/Cloud-Foundations/Dominator/lib/log/testlogger"
+)
+
+func makeTestWatcher(t *testing.T, rootDir string) *fsWatcher {
+	scanFilter, err := filter.New([]string{"/scratch"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	watcher, err := newFsWatcher(rootDir,
+		&Configuration{ScanFilter: scanFilter}, testlogger.New(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(watcher.close)
+	if err := watcher.watchTree(); err != nil {
+		t.Fatal(err)
+	}
+	return watcher
+}
+
+func mkdirTest(t *testing.T, pathname string) {
+	if err := os.MkdirAll(pathname, 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// waitForChanges collects the changes until the expected paths (or a request
+// for a full scan) have been seen.
+func waitForChanges(t *testing.T, watcher *fsWatcher,
+	expected ...string) (map[string]struct{}, bool) {
+	allChanges := make(map[string]struct{})
+	timer := time.NewTimer(5 * time.Second)
+	defer timer.Stop()
+	for {
+		changedPaths, needFullScan := watcher.takeChanges()
+		for pathname := range changedPaths {
+			allChanges[pathname] = struct{}{}
+		}
+		if needFullScan {
+			return allChanges, true
+		}
+		missing := false
+		for _, pathname := range expected {
+			if _, ok := allChanges[pathname]; !ok {
+				missing = true
+			}
+		}
+		if !missing {
+			return allChanges, false
+		}
+		select {
+		case <-watcher.notifyChannel:
+		case <-timer.C:
+			t.Fatalf("timed out waiting for: %v, got: %v",
+				expected, allChanges)
+		}
+	}
+}
+
+func TestWatcherChanges(t *testing.T) {
+	rootDir := t.TempDir()
+	mkdirTest(t, filepath.Join(rootDir, "dir"))
+	mkdirTest(t, filepath.Join(rootDir, "scratch"))
+	mkdirTest(t, filepath.Join(rootDir, ".subd"))
+	if err := os.WriteFile(filepath.Join(rootDir, "dir", "old"), nil,
+		0644); err != nil {
+		t.Fatal(err)
+	}
+	watcher := makeTestWatcher(t, rootDir)
+	if _, ok := watcher.watchedDirectories["/scratch"]; ok {
+		t.Error("filtered directory is watched")
+	}
+	if _, ok := watcher.watchedDirectories["/.subd"]; ok {
+		t.Error("subd directory is watched")
+	}
+	if err := os.WriteFile(filepath.Join(rootDir, "dir", "new"), nil,
+		0644); err != nil {
+		t.Fatal(err)
+	}
+	err := os.Rename(filepath.Join(rootDir, "dir", "old"),
+		filepath.Join(rootDir, "dir", "renamed"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(rootDir, "dir", "new")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(rootDir, "scratch", "file"), nil,
+		0644); err != nil {
+		t.Fatal(err)
+	}
+	changedPaths, needFullScan := waitForChanges(t, watcher,
+		"/dir/new", "/dir/old", "/dir/renamed")
+	if needFullScan {
+		t.Error("full scan requested for file changes")
+	}
+	if _, ok := changedPaths["/scratch/file"]; ok {
+		t.Error("change to filtered path reported")
+	}
+	// Changes which were not scanned are put back.
+	watcher.restoreChanges(changedPaths)
+	if restored, _ := watcher.takeChanges(); len(restored) !=
+		len(changedPaths) {
+		t.Errorf("restored: %v, expected: %v", restored, changedPaths)
+	}
+	watcher.restoreChanges(nil)
+	if _, needFullScan := watcher.takeChanges(); !needFullScan {
+		t.Error("full scan not requested")
+	}
+}
+
+func TestWatcherRenamedDirectory(t *testing.T) {
+	rootDir := t.TempDir()
+	mkdirTest(t, filepath.Join(rootDir, "dir", "subdir"))
+	watcher := makeTestWatcher(t, rootDir)
+	err := os.Rename(filepath.Join(rootDir, "dir", "subdir"),
+		filepath.Join(rootDir, "dir", "moved"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The watches below the renamed directory have the wrong names.
+	if _, needFullScan := waitForChanges(t, watcher,
+		"/dir/subdir", "/dir/moved"); !needFullScan {
+		t.Error("full scan not requested for renamed directory")
+	}
+}
+
+func TestWatcherNewDirectory(t *testing.T) {
+	rootDir := t.TempDir()
+	watcher := makeTestWatcher(t, rootDir)
+	mkdirTest(t, filepath.Join(rootDir, "newdir"))
+	waitForChanges(t, watcher, "/newdir")
+	fs, err := scanner.ScanFileSystem(rootDir, nil, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := watcher.watchFileSystem(&fs.FileSystem); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := watcher.watchedDirectories["/newdir"]; !ok {
+		t.Fatal("new directory not watched")
+	}
+	// The new directory must be rescanned in case it changed before the
+	// watch was added.
+	if changedPaths, _ := watcher.takeChanges(); len(changedPaths) != 1 {
+		t.Errorf("changed paths: %v, expected: /newdir", changedPaths)
+	}
+	if err := os.WriteFile(filepath.Join(rootDir, "newdir", "file"), nil,
+		0644); err != nil {
+		t.Fatal(err)
+	}
+	waitForChanges(t, watcher, "/newdir/file")
+	if err := os.Remove(filepath.Join(rootDir, "newdir", "file")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(rootDir, "newdir")); err != nil {
+		t.Fatal(err)
+	}
+	waitForChanges(t, watcher, "/newdir")
+	fs, err = scanner.ScanFileSystem(rootDir, nil, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := watcher.watchFileSystem(&fs.FileSystem); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := watcher.watchedDirectories["/newdir"]; ok {
+		t.Error("removed directory still watched")
+	}
+}